@@ -0,0 +1,254 @@
+// Capture and replay of the frames exchanged between a Client and the gateway.
+//
+// A capture is a UTF-8 text file. The first line is the header:
+//
+//	# klf200-capture 1
+//
+// Each following line is one frame:
+//
+//	<time> <connection id> <direction> <command name> <command code> <data>
+//
+// with:
+//   - time: RFC 3339 timestamp with nanoseconds (UTC)
+//   - connection id: decimal, 1 for the first connection of the client, incremented on each reconnection
+//   - direction: '>' for a frame sent to the gateway, '<' for a frame received from the gateway
+//   - command name: informative only, the code is authoritative
+//   - command code: 4 hexadecimal digits
+//   - data: frame data in hexadecimal, or '-' if empty
+//
+// Empty lines and lines starting with '#' are ignored.
+// Passwords (GW_PASSWORD_ENTER_REQ, GW_PASSWORD_CHANGE_REQ, GW_PASSWORD_CHANGE_NTF) are replaced by zeros when written.
+package capture
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Version of the file format
+const FormatVersion = 1
+
+const header = "# klf200-capture"
+
+type Record struct {
+	Time      time.Time
+	ConnID    int
+	Direction klf200.FrameDirection
+	Frame     *transport.Frame
+}
+
+// Writes a capture. Can be given to klf200.WithFrameRecorder
+type Writer struct {
+	lock   sync.Mutex
+	writer *bufio.Writer
+	err    error
+}
+
+var _ klf200.FrameRecorder = (*Writer)(nil)
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := &Writer{writer: bufio.NewWriter(w)}
+
+	if _, err := fmt.Fprintf(writer.writer, "%s %d\n", header, FormatVersion); err != nil {
+		return nil, err
+	}
+
+	if err := writer.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// Write a record. Each record is flushed so that the capture is usable if the program is killed
+func (writer *Writer) Write(record *Record) error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	direction := ">"
+	if record.Direction == klf200.FrameReceived {
+		direction = "<"
+	}
+
+	data := "-"
+	if len(record.Frame.Data) > 0 {
+		data = hex.EncodeToString(redact(record.Frame))
+	}
+
+	_, err := fmt.Fprintf(writer.writer, "%s %d %s %s %04x %s\n",
+		record.Time.UTC().Format(time.RFC3339Nano), record.ConnID, direction, record.Frame.Cmd, uint16(record.Frame.Cmd), data)
+
+	if err == nil {
+		err = writer.writer.Flush()
+	}
+
+	if err != nil && writer.err == nil {
+		writer.err = err
+	}
+
+	return err
+}
+
+// Record a frame now. Errors are kept and returned by Err
+func (writer *Writer) RecordFrame(connID int, direction klf200.FrameDirection, frame *transport.Frame) {
+	writer.Write(&Record{
+		Time:      time.Now(),
+		ConnID:    connID,
+		Direction: direction,
+		Frame:     frame,
+	})
+}
+
+// First write error, if any
+func (writer *Writer) Err() error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	return writer.err
+}
+
+// Copy of the frame data without password
+func redact(frame *transport.Frame) []byte {
+	data := append([]byte(nil), frame.Data...)
+
+	switch frame.Cmd {
+	case transport.GW_PASSWORD_ENTER_REQ, transport.GW_PASSWORD_CHANGE_REQ, transport.GW_PASSWORD_CHANGE_NTF:
+		clear(data)
+	}
+
+	return data
+}
+
+// Reads a capture
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{scanner: bufio.NewScanner(r)}
+
+	if !reader.scanner.Scan() {
+		if err := reader.scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, errors.New("empty capture")
+	}
+
+	reader.line++
+
+	var version int
+	if _, err := fmt.Sscanf(reader.scanner.Text(), header+" %d", &version); err != nil {
+		return nil, errors.New("bad capture header")
+	}
+
+	if version != FormatVersion {
+		return nil, fmt.Errorf("unsupported capture version %d", version)
+	}
+
+	return reader, nil
+}
+
+// Read the next record. Returns io.EOF at the end of the capture
+func (reader *Reader) Read() (*Record, error) {
+	for reader.scanner.Scan() {
+		reader.line++
+		line := strings.TrimSpace(reader.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		record, err := parseRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", reader.line, err)
+		}
+
+		return record, nil
+	}
+
+	if err := reader.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+// Read all the records of a capture
+func ReadAll(r io.Reader) ([]*Record, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*Record, 0)
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+}
+
+func parseRecord(line string) (*Record, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 6 {
+		return nil, errors.New("bad field count")
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("bad time: %w", err)
+	}
+
+	connID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("bad connection id: %w", err)
+	}
+
+	var direction klf200.FrameDirection
+	switch fields[2] {
+	case ">":
+		direction = klf200.FrameSent
+	case "<":
+		direction = klf200.FrameReceived
+	default:
+		return nil, errors.New("bad direction")
+	}
+
+	code, err := strconv.ParseUint(fields[4], 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("bad command code: %w", err)
+	}
+
+	data := []byte{}
+	if fields[5] != "-" {
+		if data, err = hex.DecodeString(fields[5]); err != nil {
+			return nil, fmt.Errorf("bad data: %w", err)
+		}
+	}
+
+	return &Record{
+		Time:      timestamp,
+		ConnID:    connID,
+		Direction: direction,
+		Frame:     &transport.Frame{Cmd: transport.Command(code), Data: data},
+	}, nil
+}
@@ -0,0 +1,239 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/simulator"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+const testTimeout = time.Second * 5
+
+const testPassword = "secret-password"
+
+// Start a client and wait for its connection
+func startClient(t *testing.T, address string, recorder klf200.FrameRecorder) *klf200.Client {
+	t.Helper()
+
+	var options []klf200.ClientOption
+	if recorder != nil {
+		options = append(options, klf200.WithFrameRecorder(recorder))
+	}
+
+	client := klf200.NewClient(address, testPassword, options...)
+
+	opened := make(chan struct{}, 1)
+	client.RegisterStatusChange(func(status klf200.ConnectionStatus) {
+		if status == klf200.ConnectionOpen {
+			select {
+			case opened <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	client.Start()
+
+	select {
+	case <-opened:
+	case <-time.After(testTimeout):
+		client.Close()
+		t.Fatalf("connection not opened: %s", client.LastStatusEvent())
+	}
+
+	return client
+}
+
+// Scenario played both on the simulator and on the replayer
+func runScenario(t *testing.T, client *klf200.Client) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if _, err := client.Device().GetVersionContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := client.Commands().ChangePosition(ctx, 0, commands.NewMPValueAbsolute(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last *klf200.RunStatus
+	for event := range sess.Events() {
+		switch event := event.(type) {
+		case *klf200.RunStatus:
+			last = event
+		case *klf200.RunError:
+			t.Fatalf("session error: %s", event.Err)
+		}
+	}
+
+	if last == nil || last.RunStatus != commands.CommandRunStatusCompleted {
+		t.Fatalf("got last run status %+v", last)
+	}
+
+	if err := client.Device().ChangePasswordContext(ctx, testPassword); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func record(t *testing.T) []byte {
+	t.Helper()
+
+	gw, err := simulator.Start(simulator.Config{
+		Password:       testPassword,
+		Latency:        time.Millisecond * 10,
+		ReportInterval: time.Millisecond * 50,
+		Nodes: []simulator.NodeConfig{
+			{Index: 0, Name: "Kitchen", NodeType: commands.NodeTypeWindowOpener, TravelTime: time.Millisecond * 200},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer gw.Close()
+
+	buff := &bytes.Buffer{}
+	writer, err := NewWriter(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := startClient(t, gw.Address(), writer)
+	runScenario(t, client)
+	client.Close()
+
+	if err := writer.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buff.Bytes()
+}
+
+func TestRecordRedactsPasswords(t *testing.T) {
+	data := record(t)
+
+	if bytes.Contains(data, []byte(hex.EncodeToString([]byte(testPassword)))) {
+		t.Errorf("the password appears in the capture:\n%s", data)
+	}
+
+	records, err := ReadAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := 0
+	for _, record := range records {
+		switch record.Frame.Cmd {
+		case transport.GW_PASSWORD_ENTER_REQ, transport.GW_PASSWORD_CHANGE_REQ:
+			if len(record.Frame.Data) == 0 || !bytes.Equal(record.Frame.Data, make([]byte, len(record.Frame.Data))) {
+				t.Errorf("%s not redacted: %x", record.Frame.Cmd, record.Frame.Data)
+			}
+
+			redacted++
+		}
+	}
+
+	if redacted != 2 {
+		t.Errorf("got %d password frames, expected 2", redacted)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	records, err := ReadAll(bytes.NewReader(record(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shift the session ids of the capture, as if it was taken from a client which had run other sessions before
+	shifted := 0
+	for _, record := range records {
+		if id, ok := sessionID(record.Frame); ok {
+			record.Frame = withSessionID(record.Frame, id+100)
+			shifted++
+		}
+	}
+
+	if shifted == 0 {
+		t.Fatal("no session in the capture")
+	}
+
+	rp, err := Replay(records, ReplayConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rp.Close()
+
+	client := startClient(t, rp.Address(), nil)
+	runScenario(t, client)
+	client.Close()
+
+	select {
+	case <-rp.Done():
+	case <-time.After(testTimeout):
+		t.Fatal("replay not finished")
+	}
+
+	for _, mismatch := range rp.Mismatches() {
+		t.Errorf("mismatch on connection %d: expected %v, got %v", mismatch.ConnID, mismatch.Expected, mismatch.Got)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	records, err := ReadAll(bytes.NewReader(record(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Capture of a command on another node
+	for _, record := range records {
+		if record.Frame.Cmd == transport.GW_COMMAND_SEND_REQ {
+			record.Frame.Data[42] = 1 // first entry of the index array
+		}
+	}
+
+	rp, err := Replay(records, ReplayConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rp.Close()
+
+	client := startClient(t, rp.Address(), nil)
+	runScenario(t, client)
+	client.Close()
+
+	<-rp.Done()
+
+	mismatches := rp.Mismatches()
+	if len(mismatches) != 1 || mismatches[0].Expected == nil || mismatches[0].Got == nil || mismatches[0].Got.Cmd != transport.GW_COMMAND_SEND_REQ {
+		t.Errorf("got mismatches %+v", mismatches)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, capture := range []string{
+		"",
+		"not a capture\n",
+		"# klf200-capture 2\n",
+		"# klf200-capture 1\n2024-01-01T00:00:00Z 1 > GW_GET_STATE_REQ 000c\n",
+		"# klf200-capture 1\n2024-01-01T00:00:00Z 1 ? GW_GET_STATE_REQ 000c -\n",
+		"# klf200-capture 1\n2024-01-01T00:00:00Z 1 > GW_GET_STATE_REQ 000c 0g\n",
+	} {
+		if _, err := ReadAll(strings.NewReader(capture)); err == nil {
+			t.Errorf("%q: expected an error", capture)
+		}
+	}
+}
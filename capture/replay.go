@@ -0,0 +1,347 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/internal/server"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type ReplayConfig struct {
+	// TCP address to listen on. Defaults to "127.0.0.1:0" (random port)
+	Address string
+
+	// Keep the original delays between received frames. By default frames are sent as soon as possible
+	Realtime bool
+
+	// Optional
+	Log Logger
+}
+
+// Difference between the frames sent by the client and the capture
+type Mismatch struct {
+	ConnID int
+
+	// Frame of the capture. Nil if the client sent an unexpected frame
+	Expected *transport.Frame
+
+	// Frame sent by the client. Nil if the client did not send the expected frame before the connection ended
+	Got *transport.Frame
+}
+
+// Fake gateway which plays the received side of a capture back to a client.
+//
+// Each connection of the capture is replayed on a new client connection, in order.
+// Frames received by the client in the capture are sent after the client has sent the frames which preceded them,
+// so that the replay does not depend on timings. When all records of a connection are replayed, the connection is closed.
+//
+// Session ids chosen by the client differ between runs: the session id of each frame sent by the client is mapped to the
+// one of the capture before comparing them, and the session ids of the received frames are mapped back to the client ones.
+type Replayer struct {
+	listener *server.Listener
+	log      Logger
+	realtime bool
+	conns    [][]*Record
+
+	lock       sync.Mutex
+	mismatches []Mismatch
+	current    *server.Conn
+
+	done       chan struct{}
+	workerSync sync.WaitGroup
+}
+
+// Start a replayer. It is listening when the function returns
+func Replay(records []*Record, config ReplayConfig) (*Replayer, error) {
+	address := config.Address
+	if address == "" {
+		address = "127.0.0.1:0"
+	}
+
+	listener, err := server.Listen(address)
+	if err != nil {
+		return nil, err
+	}
+
+	rp := &Replayer{
+		listener: listener,
+		log:      config.Log,
+		realtime: config.Realtime,
+		conns:    splitConnections(records),
+		done:     make(chan struct{}),
+	}
+
+	rp.workerSync.Add(1)
+	go rp.acceptWorker()
+
+	return rp, nil
+}
+
+// Address the replayer is listening on, to be given to the client
+func (rp *Replayer) Address() string {
+	return rp.listener.Addr()
+}
+
+// Closed when all connections of the capture have been replayed
+func (rp *Replayer) Done() <-chan struct{} {
+	return rp.done
+}
+
+// Differences found so far between the client and the capture
+func (rp *Replayer) Mismatches() []Mismatch {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	return slices.Clone(rp.mismatches)
+}
+
+// Stop listening and wait for the replay to end
+func (rp *Replayer) Close() {
+	rp.listener.Close()
+
+	rp.lock.Lock()
+	if rp.current != nil {
+		rp.current.Close()
+	}
+	rp.lock.Unlock()
+
+	rp.workerSync.Wait()
+}
+
+// Group records by connection id, in order of appearance
+func splitConnections(records []*Record) [][]*Record {
+	conns := make([][]*Record, 0)
+	indexes := make(map[int]int)
+
+	for _, record := range records {
+		index, ok := indexes[record.ConnID]
+		if !ok {
+			index = len(conns)
+			indexes[record.ConnID] = index
+			conns = append(conns, nil)
+		}
+
+		conns[index] = append(conns[index], record)
+	}
+
+	return conns
+}
+
+func (rp *Replayer) acceptWorker() {
+	defer rp.workerSync.Done()
+
+	for index := 0; ; index++ {
+		if index == len(rp.conns) {
+			close(rp.done)
+		}
+
+		conn, err := rp.listener.Accept()
+		if err != nil {
+			if !server.IsClosed(err) {
+				rp.errorf("Accept error: %s", err)
+			}
+
+			if index < len(rp.conns) {
+				close(rp.done)
+			}
+
+			return
+		}
+
+		if index >= len(rp.conns) {
+			rp.debugf("Capture is over, closing connection from %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		rp.lock.Lock()
+		rp.current = conn
+		rp.lock.Unlock()
+
+		rp.replayConnection(conn, rp.conns[index])
+
+		rp.lock.Lock()
+		rp.current = nil
+		rp.lock.Unlock()
+	}
+}
+
+func (rp *Replayer) replayConnection(conn *server.Conn, records []*Record) {
+	defer conn.Close()
+
+	connID := records[0].ConnID
+	rp.debugf("Replaying connection %d (%d records)", connID, len(records))
+
+	frames := make(chan *transport.Frame, 100)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		defer close(frames)
+
+		for {
+			frame, err := conn.ReadFrame()
+			if errors.Is(err, server.ErrBadFrame) {
+				rp.debugf("Bad frame received: %s", err)
+				continue
+			}
+
+			if err != nil {
+				return
+			}
+
+			select {
+			case frames <- frame:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var last time.Time
+
+	// capture session id => client session id
+	sessions := make(map[int]int)
+
+	for _, record := range records {
+		switch record.Direction {
+		case klf200.FrameSent:
+			if !rp.expect(connID, record.Frame, frames, sessions) {
+				return
+			}
+
+		case klf200.FrameReceived:
+			if rp.realtime && !last.IsZero() {
+				time.Sleep(record.Time.Sub(last))
+			}
+
+			frame := record.Frame
+			if capturedID, ok := sessionID(frame); ok {
+				if clientID, ok := sessions[capturedID]; ok {
+					frame = withSessionID(frame, clientID)
+				}
+			}
+
+			if err := conn.WriteFrame(frame); err != nil {
+				rp.debugf("Write error: %s", err)
+				return
+			}
+		}
+
+		last = record.Time
+	}
+
+	rp.debugf("Connection %d replayed", connID)
+}
+
+// Wait for the client to send the expected frame. Returns false if the connection ended
+func (rp *Replayer) expect(connID int, expected *transport.Frame, frames <-chan *transport.Frame, sessions map[int]int) bool {
+	for frame := range frames {
+		if frame.Cmd != expected.Cmd {
+			rp.mismatch(Mismatch{ConnID: connID, Got: frame})
+			continue
+		}
+
+		compared := frame
+		if capturedID, ok := sessionID(expected); ok {
+			if clientID, ok := sessionID(frame); ok {
+				sessions[capturedID] = clientID
+				compared = withSessionID(frame, capturedID)
+			}
+		}
+
+		if !bytes.Equal(redact(compared), expected.Data) {
+			rp.mismatch(Mismatch{ConnID: connID, Expected: expected, Got: frame})
+		}
+
+		return true
+	}
+
+	rp.mismatch(Mismatch{ConnID: connID, Expected: expected})
+	return false
+}
+
+// Request, confirm or notification of a session, which can be decoded and encoded again
+type sessionFrame interface {
+	commands.SessionCommand
+	Read(data []byte) error
+	Write() ([]byte, error)
+}
+
+// Decode the frame if it carries a session id
+func decodeSession(frame *transport.Frame) (sessionFrame, bool) {
+	var cmd commands.Command
+	if req := commands.GetRequest(frame.Cmd); req != nil {
+		cmd = req
+	} else if cfm := commands.GetConfirm(frame.Cmd); cfm != nil {
+		cmd = cfm
+	} else if ntf := commands.GetNotify(frame.Cmd); ntf != nil {
+		cmd = ntf
+	}
+
+	session, ok := cmd.(sessionFrame)
+	if !ok || session.Read(frame.Data) != nil {
+		return nil, false
+	}
+
+	return session, true
+}
+
+// Session id of the frame, if it carries one
+func sessionID(frame *transport.Frame) (int, bool) {
+	session, ok := decodeSession(frame)
+	if !ok {
+		return 0, false
+	}
+
+	return session.GetSessionID(), true
+}
+
+// Copy of the frame with another session id
+func withSessionID(frame *transport.Frame, id int) *transport.Frame {
+	session, ok := decodeSession(frame)
+	if !ok {
+		return frame
+	}
+
+	session.SetSessionID(id)
+
+	data, err := session.Write()
+	if err != nil {
+		return frame
+	}
+
+	return &transport.Frame{Cmd: frame.Cmd, Data: data}
+}
+
+func (rp *Replayer) mismatch(mismatch Mismatch) {
+	rp.debugf("Mismatch on connection %d: expected %v, got %v", mismatch.ConnID, mismatch.Expected, mismatch.Got)
+
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	rp.mismatches = append(rp.mismatches, mismatch)
+}
+
+func (rp *Replayer) debugf(format string, args ...interface{}) {
+	if rp.log != nil {
+		rp.log.Debugf(format, args...)
+	}
+}
+
+func (rp *Replayer) errorf(format string, args ...interface{}) {
+	if rp.log != nil {
+		rp.log.Errorf(format, args...)
+	}
+}
@@ -2,7 +2,6 @@ package klf200
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"reflect"
 	"sync"
@@ -12,10 +11,6 @@ import (
 	"github.com/mylife-home/klf200-go/transport"
 )
 
-const executeTimeout = time.Second * 5
-const reconnectTimeout = time.Second * 5
-const heartbeatInterval = time.Minute
-
 type ConnectionStatus uint8
 
 const (
@@ -46,51 +41,74 @@ type Client struct {
 	servAddr string
 	password string
 	log      Logger
-
-	status                    ConnectionStatus
-	connectionStatusCallbacks []func(ConnectionStatus)
-	notifiers                 map[*notifier]struct{}
-	notifiersLock             sync.Mutex
-
-	ctx         context.Context
-	close       context.CancelFunc
-	workerSync  sync.WaitGroup
-	trans       sync.Mutex
-	pendingConf *pendingConfirm
-
-	conn *connection
+	options  clientOptions
+
+	// lock protects lastStatus, the status callbacks and updates, statusEnd, dispatcher and password
+	lock                 sync.Mutex
+	lastStatus           StatusEvent
+	statusCallbacks      []func(ConnectionStatus)
+	statusEventCallbacks []func(StatusEvent)
+	statusUpdates        []statusUpdate
+	statusSignal         chan struct{}
+	statusEnd            bool
+	dispatcher           *dispatcher
+	notifiers            map[notifyReceiver]struct{}
+	notifiersLock        sync.Mutex
+
+	ctx        context.Context
+	close      context.CancelFunc
+	workerSync sync.WaitGroup
+	connCount  int
 
 	device   *Device
 	config   *Config
 	info     *Info
 	commands *Commands
+	scenes   *Scenes
 }
 
+// Create a client with the default options, see NewClient
 func MakeClient(servAddr string, password string, log Logger) *Client {
+	return NewClient(servAddr, password, WithLogger(log))
+}
+
+// Create a client of the gateway at servAddr (host:port, the gateway listens on port 51200).
+// By default the certificate of the gateway is not verified (InsecureSkipVerify), as it is self-signed:
+// use WithPinnedCertificate, WithTrustOnFirstUse, WithRootCAs or WithTLSConfig to verify it
+func NewClient(servAddr string, password string, options ...ClientOption) *Client {
+	opts := defaultClientOptions()
+	for _, option := range options {
+		option(&opts)
+	}
+
 	ctx, close := context.WithCancel(context.Background())
 
 	client := &Client{
-		servAddr:                  servAddr,
-		password:                  password,
-		log:                       log,
-		ctx:                       ctx,
-		close:                     close,
-		status:                    ConnectionClosed,
-		connectionStatusCallbacks: make([]func(ConnectionStatus), 0),
-		notifiers:                 make(map[*notifier]struct{}),
+		servAddr:        servAddr,
+		password:        password,
+		log:             opts.log,
+		options:         opts,
+		ctx:             ctx,
+		close:           close,
+		lastStatus:      StatusEvent{Status: ConnectionClosed},
+		statusCallbacks: make([]func(ConnectionStatus), 0),
+		statusSignal:    make(chan struct{}, 1),
+		notifiers:       make(map[notifyReceiver]struct{}),
 	}
 
 	client.device = &Device{client}
 	client.config = newConfig(client)
 	client.info = newInfo(client)
 	client.commands = newCommands(client)
+	client.scenes = newScenes(client)
 
 	return client
 }
 
 func (client *Client) Start() {
-	client.workerSync.Add(1)
+	client.workerSync.Add(2)
 	go client.worker()
+	go client.statusWorker()
 }
 
 func (client *Client) Close() {
@@ -98,35 +116,138 @@ func (client *Client) Close() {
 	client.workerSync.Wait()
 }
 
-func (client *Client) changeStatus(newStatus ConnectionStatus) {
-	if client.status == newStatus {
-		return
+type statusUpdate struct {
+	event   StatusEvent
+	changed bool
+}
+
+func (client *Client) changeStatus(newStatus ConnectionStatus, attempt int) {
+	client.publishStatus(StatusEvent{Status: newStatus, Attempt: attempt})
+}
+
+func (client *Client) publishStatus(event StatusEvent) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	changed := client.lastStatus.Status != event.Status
+	client.lastStatus = event
+	client.statusUpdates = append(client.statusUpdates, statusUpdate{event: event, changed: changed})
+	client.signalStatus()
+}
+
+// Must be called with lock held
+func (client *Client) signalStatus() {
+	select {
+	case client.statusSignal <- struct{}{}:
+	default:
 	}
+}
 
-	client.status = newStatus
+// Calls the status callbacks in order, outside of the connection loop so that they can execute requests
+func (client *Client) statusWorker() {
+	defer client.workerSync.Done()
 
-	for _, callback := range client.connectionStatusCallbacks {
-		go callback(newStatus)
+	for range client.statusSignal {
+		client.lock.Lock()
+		updates := client.statusUpdates
+		client.statusUpdates = nil
+		callbacks := client.statusCallbacks
+		eventCallbacks := client.statusEventCallbacks
+		end := client.statusEnd
+		client.lock.Unlock()
+
+		for _, update := range updates {
+			if update.changed {
+				for _, callback := range callbacks {
+					callback(update.event.Status)
+				}
+			}
+
+			for _, callback := range eventCallbacks {
+				callback(update.event)
+			}
+		}
+
+		if end {
+			return
+		}
 	}
 }
 
 func (client *Client) Status() ConnectionStatus {
-	return client.status
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	return client.lastStatus.Status
+}
+
+// Current status, with the reconnection diagnostics
+func (client *Client) LastStatusEvent() StatusEvent {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	return client.lastStatus
 }
 
+// The callback is called on each status change. The callbacks are called in order, one at a time. They should not block for long
 func (client *Client) RegisterStatusChange(callback func(ConnectionStatus)) {
-	client.connectionStatusCallbacks = append(client.connectionStatusCallbacks, callback)
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	client.statusCallbacks = append(client.statusCallbacks, callback)
 }
 
-func (client *Client) RegisterNotifications(types []reflect.Type) Notifier {
-	n := &notifier{client: client, stream: make(chan commands.Notify, 1000)}
+// Same as RegisterStatusChange, but the callback is also called on each failed connection attempt, with the error and the next retry time
+func (client *Client) RegisterStatusEvents(callback func(StatusEvent)) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
 
-	if types != nil {
-		n.types = make(map[reflect.Type]struct{})
+	client.statusEventCallbacks = append(client.statusEventCallbacks, callback)
+}
 
+// Register a notifier receiving the notifications of the given types, or all of them if types is nil.
+// The notifier is lossless by default (OverflowBlock): a full queue delays the other notifiers until it is read.
+// Prefer Subscribe which delivers typed notifications
+func (client *Client) RegisterNotifications(types []reflect.Type, options ...SubscribeOption) Notifier {
+	var filter func(commands.Notify) bool
+
+	if types != nil {
+		typeSet := make(map[reflect.Type]struct{})
 		for _, typ := range types {
-			n.types[typ] = struct{}{}
+			typeSet[typ] = struct{}{}
 		}
+
+		filter = func(notif commands.Notify) bool {
+			_, found := typeSet[reflect.TypeOf(notif)]
+			return found
+		}
+	}
+
+	options = append([]SubscribeOption{WithOverflowPolicy(OverflowBlock)}, options...)
+
+	return subscribe(client, filter, options)
+}
+
+// Subscribe to the notifications of type T (eg: *commands.NodeStatePositionChangedNtf) matching the filter (all of them if filter is nil).
+// Use commands.Notify as T to receive all types. The channel is closed by cancel, or on overflow with OverflowDisconnect
+func Subscribe[T commands.Notify](client *Client, filter func(T) bool, options ...SubscribeOption) (<-chan T, func()) {
+	n := subscribe(client, filter, options)
+	return n.stream, n.Close
+}
+
+func subscribe[T commands.Notify](client *Client, filter func(T) bool, options []SubscribeOption) *notifier[T] {
+	opts := subscribeOptions{policy: client.options.overflowPolicy, queueSize: defaultQueueSize}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	n := &notifier[T]{
+		client:  client,
+		filter:  filter,
+		policy:  opts.policy,
+		dropped: opts.dropped,
+		stream:  make(chan T, opts.queueSize),
+		closing: make(chan struct{}),
 	}
 
 	client.notifiersLock.Lock()
@@ -137,141 +258,255 @@ func (client *Client) RegisterNotifications(types []reflect.Type) Notifier {
 	return n
 }
 
-type notifier struct {
-	client *Client
-	types  map[reflect.Type]struct{}
-	stream chan commands.Notify
+// Notifier registered in the client, whatever its notifications type
+type notifyReceiver interface {
+	// Returns false if the notifier must be disconnected
+	process(notif commands.Notify) bool
+	queueLength() int
+	disconnect()
 }
 
-func (n *notifier) process(notif commands.Notify) {
-	if n.filter(notif) {
-		n.stream <- notif
-	}
+type notifier[T commands.Notify] struct {
+	client  *Client
+	filter  func(T) bool
+	policy  OverflowPolicy
+	dropped func()
+	stream  chan T
+
+	// Closed by Close before it waits for notifiersLock, so that a blocked send (OverflowBlock) gives up
+	closing   chan struct{}
+	closeOnce sync.Once
 }
 
-func (n *notifier) filter(notif commands.Notify) bool {
-	if n.types == nil {
+var _ Notifier = (*notifier[commands.Notify])(nil)
+
+func (n *notifier[T]) process(notif commands.Notify) bool {
+	typed, ok := notif.(T)
+	if !ok {
 		return true
 	}
 
-	_, found := n.types[reflect.TypeOf(notif)]
-	return found
+	if n.filter != nil && !n.filter(typed) {
+		return true
+	}
+
+	if n.policy == OverflowBlock {
+		select {
+		case n.stream <- typed:
+		case <-n.closing:
+		case <-n.client.ctx.Done():
+		}
+
+		return true
+	}
+
+	return offer(n.stream, typed, n.policy, func(dropped T) {
+		if observer := n.client.options.observer; observer != nil {
+			observer.NotificationDropped(dropped, n.policy)
+		}
+
+		if n.dropped != nil {
+			n.dropped()
+		}
+	})
 }
 
-func (n *notifier) Stream() <-chan commands.Notify {
+// Must be called with notifiersLock held
+func (n *notifier[T]) disconnect() {
+	delete(n.client.notifiers, n)
+	close(n.stream)
+}
+
+func (n *notifier[T]) queueLength() int {
+	return len(n.stream)
+}
+
+// Number of notifications waiting in the queue of each registered notifier
+func (client *Client) NotifierQueueLengths() []int {
+	client.notifiersLock.Lock()
+	defer client.notifiersLock.Unlock()
+
+	lengths := make([]int, 0, len(client.notifiers))
+	for n := range client.notifiers {
+		lengths = append(lengths, n.queueLength())
+	}
+
+	return lengths
+}
+
+func (n *notifier[T]) Stream() <-chan T {
 	return n.stream
 }
 
-func (n *notifier) Close() {
+func (n *notifier[T]) Close() {
+	n.closeOnce.Do(func() { close(n.closing) })
+
 	n.client.notifiersLock.Lock()
 	defer n.client.notifiersLock.Unlock()
 
-	delete(n.client.notifiers, n)
-	close(n.stream)
+	if _, ok := n.client.notifiers[n]; ok {
+		n.disconnect()
+	}
 }
 
 func (client *Client) worker() {
 	defer client.workerSync.Done()
 
+	// Let the status worker deliver the last changes then exit
+	defer func() {
+		client.lock.Lock()
+		defer client.lock.Unlock()
+
+		client.statusEnd = true
+		client.signalStatus()
+	}()
+
+	policy := client.options.reconnectPolicy
+	delays := newBackoff(policy.InitialDelay, policy.MaxDelay, policy.Jitter)
+	failures := 0
+
 	for {
-		client.connection()
+		opened, err := client.connection(failures)
+		if opened {
+			failures = 0
+			delays.Reset()
+		}
+
+		if client.ctx.Err() != nil {
+			client.publishStatus(StatusEvent{Status: ConnectionClosed})
+			return
+		}
+
+		failures++
+		event := StatusEvent{Status: ConnectionClosed, Err: err, Attempt: failures}
+
+		delay, retry := policy.nextDelay(delays, err)
+		if !retry {
+			client.log.WithError(err).Errorf("Authentication failed, stop reconnecting")
+			event.Stopped = true
+			client.publishStatus(event)
+			return
+		}
+
+		event.NextRetry = time.Now().Add(delay)
+		client.log.Infof("Reconnect in %s (attempt %d)", delay, failures)
+		client.publishStatus(event)
 
 		select {
 		case <-client.ctx.Done():
+			client.publishStatus(StatusEvent{Status: ConnectionClosed})
 			return
-		case <-time.After(reconnectTimeout):
+		case <-time.After(delay):
 			// reconnect
 		}
 	}
 }
 
-func (client *Client) connection() {
+// Returns true if the connection has been opened, and the error which ended it
+func (client *Client) connection(failures int) (bool, error) {
 	client.log.Infof("Dial to '%s'", client.servAddr)
 
-	conn, err := makeConnection(client.ctx, client.servAddr, client.log)
+	client.connCount++
+
+	if client.options.observer != nil {
+		client.options.observer.Dialing()
+	}
+
+	var record func(FrameDirection, *transport.Frame)
+	if recorder := client.options.recorder; recorder != nil {
+		connID := client.connCount
+		record = func(direction FrameDirection, frame *transport.Frame) {
+			recorder.RecordFrame(connID, direction, frame)
+		}
+	}
+
+	// The dial timeout bounds the whole establishment, up to the end of the handshake
+	ctx, cancel := client.options.establishContext(client.ctx)
+	defer cancel()
+
+	conn, err := makeConnection(ctx, client.servAddr, record, &client.options)
 	if err != nil {
 		client.log.WithError(err).Errorf("Could not connect to '%s'", client.servAddr)
-		return
+
+		if client.options.observer != nil {
+			client.options.observer.DialFailed(err)
+		}
+
+		return false, err
 	}
 
-	client.conn = conn
 	defer func() {
-		client.conn.Close()
-		client.conn = nil
-		client.changeStatus(ConnectionClosed)
+		conn.Close()
 		client.log.Infof("Connection closed")
 	}()
 
-	client.changeStatus(ConnectionHandshaking)
+	client.changeStatus(ConnectionHandshaking, failures)
 	client.log.Debugf("Start handshake")
 
-	if err := handshake(client.ctx, client.conn, client.password); err != nil {
+	if err := handshake(ctx, conn, client.currentPassword()); err != nil {
 		client.log.WithError(err).Error("Handshake failed")
-		return
+
+		if client.options.observer != nil {
+			client.options.observer.HandshakeFailed(err)
+		}
+
+		return false, err
 	}
 
-	client.changeStatus(ConnectionOpen)
+	d := newDispatcher(client, conn)
+	client.setDispatcher(d)
+	defer client.setDispatcher(nil)
+
+	client.changeStatus(ConnectionOpen, 0)
 	client.log.Debugf("Handshake done")
 
-	defer func() {
-		pendingConf := client.pendingConf
-		if pendingConf != nil {
-			pendingConf.Cancel()
-		}
-	}()
+	return true, d.run()
+}
 
-	for {
-		select {
-		case <-client.ctx.Done():
-			return
+func (client *Client) setDispatcher(d *dispatcher) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
 
-		case <-time.After(heartbeatInterval):
-			go client.heartbeat()
+	client.dispatcher = d
+}
 
-		case err := <-client.conn.Errors():
-			client.log.WithError(err).Error("Error on connection")
-			return
+func (client *Client) currentPassword() string {
+	client.lock.Lock()
+	defer client.lock.Unlock()
 
-		case frame := <-client.conn.Read():
-			client.processFrame(frame)
-		}
-	}
+	return client.password
 }
 
-func (client *Client) processFrame(frame *transport.Frame) {
-	// try to read it as notify
-	notify := commands.GetNotify(frame.Cmd)
-	if notify != nil {
-		if err := notify.Read(frame.Data); err != nil {
-			client.log.WithError(err).Errorf("Cannot read frame %s", frame.Cmd)
-			return
-		}
+func (client *Client) setPassword(password string) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
 
-		client.notifiersLock.Lock()
-		defer client.notifiersLock.Unlock()
+	client.password = password
+}
 
-		for n := range client.notifiers {
-			n.process(notify)
-		}
+func (client *Client) currentDispatcher() *dispatcher {
+	client.lock.Lock()
+	defer client.lock.Unlock()
 
-		return
-	}
+	return client.dispatcher
+}
 
-	pendingConf := client.pendingConf
-	if pendingConf != nil {
-		pendingConf.Confirm(frame)
+func (client *Client) dispatchNotify(notify commands.Notify) {
+	client.notifiersLock.Lock()
+	defer client.notifiersLock.Unlock()
 
-		return
+	for n := range client.notifiers {
+		if !n.process(notify) {
+			client.log.Warnf("Notifier overflow on %s, disconnected", notify.Code())
+			n.disconnect()
+		}
 	}
-
-	client.log.Warnf("Got unmatched frame %s", frame.Cmd)
 }
 
-func (client *Client) send(conn *connection, req commands.Request) error {
-
+func encodeRequest(req commands.Request) (*transport.Frame, error) {
 	data, err := req.Write()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	frame := &transport.Frame{
@@ -279,83 +514,79 @@ func (client *Client) send(conn *connection, req commands.Request) error {
 		Data: data,
 	}
 
-	conn.Write(frame)
-
-	return nil
+	return frame, nil
 }
 
 func (client *Client) execute(req commands.Request) (commands.Confirm, error) {
-	conn := client.conn
-	if conn == nil {
-		return nil, errors.New("not connected")
-	}
+	return client.executeContext(context.Background(), req)
+}
 
-	client.trans.Lock()
-	defer client.trans.Unlock()
+// Each attempt times out after the request timeout, ctx bounds the whole call including the retries
+func (client *Client) executeContext(ctx context.Context, req commands.Request) (commands.Confirm, error) {
+	cfm, _, err := client.executeConn(ctx, req, nil)
+	return cfm, err
+}
 
-	pendingConf := newPendingConfirm()
-	client.pendingConf = pendingConf
-	defer func() {
-		client.pendingConf = nil
-	}()
+// Also returns a channel closed when the connection on which the request has been confirmed is torn down.
+// If not nil, retrying is called before each retry of the request
+func (client *Client) executeConn(ctx context.Context, req commands.Request, retrying func()) (commands.Confirm, <-chan struct{}, error) {
+	policy := client.options.retryPolicy
+	class := policy.classify(req)
+	delays := newBackoff(policy.InitialBackoff, policy.MaxBackoff, policy.Jitter)
+
+	for attempt := 1; ; attempt++ {
+		cfm, done, err := client.executeOnce(ctx, req)
+		if err == nil || attempt >= policy.MaxAttempts || !class.shouldRetry(err) {
+			return cfm, done, err
+		}
 
-	if err := client.send(conn, req); err != nil {
-		return nil, err
-	}
+		delay := delays.Next()
+		client.log.WithError(err).Debugf("Request %s failed (attempt %d/%d), retry in %s", req.Code(), attempt, policy.MaxAttempts, delay)
 
-	frame, err := pendingConf.Wait()
-	if err != nil {
-		return nil, err
-	}
-
-	cfm := req.NewConfirm()
-	if frame.Cmd != cfm.Code() {
-		return nil, fmt.Errorf("unexpected confirm: got %d, expected %d", frame.Cmd, cfm.Code())
-	}
+		select {
+		case <-client.ctx.Done():
+			return nil, nil, err
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(delay):
+			// retry
+		}
 
-	if err := cfm.Read(frame.Data); err != nil {
-		return nil, err
+		if retrying != nil {
+			retrying()
+		}
 	}
-
-	return cfm, nil
-}
-
-type pendingConfirm struct {
-	ctx    context.Context
-	cancel context.CancelCauseFunc
-	cfm    chan *transport.Frame
 }
 
-func newPendingConfirm() *pendingConfirm {
-	ctx := context.Background()
-	ctx, _ = context.WithTimeout(ctx, executeTimeout)
-	ctx, cancel := context.WithCancelCause(ctx)
-	cfm := make(chan *transport.Frame)
-
-	return &pendingConfirm{ctx, cancel, cfm}
-}
+func (client *Client) executeOnce(ctx context.Context, req commands.Request) (commands.Confirm, <-chan struct{}, error) {
+	if observer := client.options.observer; observer != nil {
+		start := time.Now()
+		cfm, done, err := client.executeTransaction(ctx, req)
+		observer.RequestCompleted(req.Code(), time.Since(start), err)
+		return cfm, done, err
+	}
 
-func (pc *pendingConfirm) Cancel() {
-	pc.cancel(errors.New("connection closed"))
+	return client.executeTransaction(ctx, req)
 }
 
-func (pc *pendingConfirm) Confirm(frame *transport.Frame) {
-	pc.cfm <- frame
-}
+func (client *Client) executeTransaction(ctx context.Context, req commands.Request) (commands.Confirm, <-chan struct{}, error) {
+	d := client.currentDispatcher()
+	if d == nil {
+		return nil, nil, ErrNotConnected
+	}
 
-func (pc *pendingConfirm) Wait() (*transport.Frame, error) {
-	select {
-	case <-pc.ctx.Done():
-		return nil, pc.ctx.Err()
-	case cfm := <-pc.cfm:
-		pc.cancel(nil)
-		return cfm, nil
+	cfm, err := d.execute(ctx, req)
+	if err != nil {
+		return nil, nil, err
 	}
+
+	return cfm, d.Done(), nil
 }
 
 func (client *Client) heartbeat() {
-	if _, err := client.device.GetState(); err != nil {
+	if _, err := client.device.GetStateContext(client.ctx); err != nil {
 		client.log.WithError(err).Errorf("Heartbeat error")
+		return
 	}
 
 	client.log.Debugf("Heartbeat OK")
@@ -379,6 +610,8 @@ func (client *Client) Commands() *Commands {
 	return client.commands
 }
 
-// TODO: Scenes
+func (client *Client) Scenes() *Scenes {
+	return client.scenes
+}
 
 // TODO: ContactInput
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+const frameEnd byte = 192
+const frameEsc byte = 219
+const frameEscEnd byte = 220
+const frameEscEsc byte = 221
+
+// Frame as found in the input, possibly invalid
+type rawFrame struct {
+	raw        []byte
+	hasCmd     bool
+	cmd        transport.Command
+	data       []byte
+	checksumOk bool
+	checksum   byte
+	expectedCs byte
+	err        error
+}
+
+// Split a SLIP stream into frames. Unlike transport.SlipDecoder, errors are reported on the frame and decoding goes on
+func splitSlip(input []byte) []*rawFrame {
+	frames := make([]*rawFrame, 0)
+
+	var current []byte
+	var escaping, inFrame, badEscape bool
+
+	for _, b := range input {
+		if !inFrame {
+			if b == frameEnd {
+				inFrame = true
+				current = nil
+				escaping = false
+				badEscape = false
+			}
+
+			continue
+		}
+
+		if escaping {
+			switch b {
+			case frameEscEnd:
+				current = append(current, frameEnd)
+			case frameEscEsc:
+				current = append(current, frameEsc)
+			default:
+				badEscape = true
+				current = append(current, b)
+			}
+
+			escaping = false
+			continue
+		}
+
+		switch b {
+		case frameEnd:
+			if len(current) == 0 {
+				// Consecutive END bytes: start of the next frame
+				continue
+			}
+
+			frame := parseFrame(current)
+			if badEscape {
+				frame.err = fmt.Errorf("bad SLIP escape sequence")
+			}
+
+			frames = append(frames, frame)
+			inFrame = false
+
+		case frameEsc:
+			escaping = true
+
+		default:
+			current = append(current, b)
+		}
+	}
+
+	if inFrame && len(current) > 0 {
+		frame := parseFrame(current)
+		frame.err = fmt.Errorf("truncated SLIP frame")
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// Parse the frame header and footer: protocol id, length, command, data, checksum
+func parseFrame(raw []byte) *rawFrame {
+	frame := &rawFrame{raw: raw}
+
+	if len(raw) < 5 {
+		frame.err = fmt.Errorf("frame too short (%d bytes)", len(raw))
+		return frame
+	}
+
+	if raw[0] != 0 {
+		frame.err = fmt.Errorf("unexpected protocol ID %d", raw[0])
+		return frame
+	}
+
+	length := int(raw[1])
+	frame.cmd = transport.Command(uint16(raw[2])<<8 | uint16(raw[3]))
+	frame.hasCmd = true
+
+	if length+2 != len(raw) {
+		frame.err = fmt.Errorf("length field is %d, but frame has %d bytes", length, len(raw))
+		return frame
+	}
+
+	frame.data = raw[4 : len(raw)-1]
+	frame.checksum = raw[len(raw)-1]
+
+	for _, b := range raw[:len(raw)-1] {
+		frame.expectedCs ^= b
+	}
+
+	frame.checksumOk = frame.checksum == frame.expectedCs
+
+	return frame
+}
+
+func decodeHex(digits string) ([]byte, error) {
+	data, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty frame")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,185 @@
+// Decode KLF 200 frames from SLIP dumps, hex dumps or captures.
+//
+// Usage:
+//
+//	klf200-dissect [-format auto|slip|hex|capture] [file]
+//
+// Reads standard input if no file is given.
+//
+//   - slip: raw bytes of the SLIP stream (as seen inside TLS)
+//   - hex: one frame per line, in hexadecimal (separators allowed), either SLIP-encoded (starting with c0) or not
+//   - capture: capture file written by the capture package
+//   - auto: detect the format from the content (default)
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mylife-home/klf200-go/capture"
+)
+
+func main() {
+	format, path, err := parseArgs(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		os.Exit(2)
+	}
+
+	if err := run(format, path); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// Parse the command line, errors and usage are printed on output
+func parseArgs(args []string, output io.Writer) (format string, path string, err error) {
+	flags := flag.NewFlagSet("klf200-dissect", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&format, "format", "auto", "input format: auto, slip, hex or capture")
+	flags.Usage = func() {
+		fmt.Fprintf(output, "Usage: klf200-dissect [-format auto|slip|hex|capture] [file]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case flags.NArg() > 1:
+		err = errors.New("too many arguments")
+	case format != "auto" && format != "slip" && format != "hex" && format != "capture":
+		err = fmt.Errorf("unknown format '%s'", format)
+	}
+
+	if err != nil {
+		fmt.Fprintf(output, "%s\n", err)
+		flags.Usage()
+		return "", "", err
+	}
+
+	return format, flags.Arg(0), nil
+}
+
+func run(format string, path string) error {
+	input, err := readInput(path)
+	if err != nil {
+		return err
+	}
+
+	if format == "auto" {
+		format = detectFormat(input)
+	}
+
+	printer := &printer{out: os.Stdout}
+
+	switch format {
+	case "slip":
+		return dissectSlip(printer, input)
+	case "hex":
+		return dissectHex(printer, input)
+	case "capture":
+		return dissectCapture(printer, input)
+	default:
+		return fmt.Errorf("unknown format '%s'", format)
+	}
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(path)
+}
+
+func detectFormat(input []byte) string {
+	if bytes.HasPrefix(input, []byte("# klf200-capture")) {
+		return "capture"
+	}
+
+	for _, b := range input {
+		if !isHexDigit(b) && !isSeparator(b) {
+			return "slip"
+		}
+	}
+
+	return "hex"
+}
+
+func isHexDigit(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+func isSeparator(b byte) bool {
+	return strings.IndexByte(" \t\r\n:-,", b) >= 0
+}
+
+func dissectSlip(printer *printer, input []byte) error {
+	for _, frame := range splitSlip(input) {
+		printer.print(nil, frame)
+	}
+
+	return nil
+}
+
+func dissectHex(printer *printer, input []byte) error {
+	for index, line := range strings.Split(string(input), "\n") {
+		digits := strings.Map(func(r rune) rune {
+			if r < 128 && isSeparator(byte(r)) {
+				return -1
+			}
+			return r
+		}, line)
+
+		if digits == "" {
+			continue
+		}
+
+		data, err := decodeHex(digits)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", index+1, err)
+		}
+
+		if data[0] == frameEnd {
+			for _, frame := range splitSlip(data) {
+				printer.print(nil, frame)
+			}
+
+			continue
+		}
+
+		printer.print(nil, parseFrame(data))
+	}
+
+	return nil
+}
+
+func dissectCapture(printer *printer, input []byte) error {
+	reader, err := capture.NewReader(bytes.NewReader(input))
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		printer.print(record, &rawFrame{hasCmd: true, cmd: record.Frame.Cmd, data: record.Frame.Data, checksumOk: true})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		format string
+		path   string
+		ok     bool
+	}{
+		{nil, "auto", "", true},
+		{[]string{"dump.bin"}, "auto", "dump.bin", true},
+		{[]string{"-format", "hex", "-"}, "hex", "-", true},
+		{[]string{"-format=capture"}, "capture", "", true},
+		{[]string{"-format", "pcap"}, "", "", false},
+		{[]string{"a", "b"}, "", "", false},
+		{[]string{"-unknown"}, "", "", false},
+	}
+
+	for _, test := range tests {
+		format, path, err := parseArgs(test.args, io.Discard)
+		if (err == nil) != test.ok || format != test.format || path != test.path {
+			t.Errorf("%q: got format %q, path %q, error %v", test.args, format, path, err)
+		}
+	}
+
+	if _, _, err := parseArgs([]string{"-h"}, io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("-h: got %v", err)
+	}
+}
+
+// SLIP encoded frame of the request
+func slipFrame(t *testing.T, req commands.Request) []byte {
+	t.Helper()
+
+	data, err := req.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := &transport.Frame{Cmd: req.Code(), Data: data}
+	return transport.SlipEncode(frame.Write()).Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"# klf200-capture v1\n":        "capture",
+		"c0 00 03 00 08 0b c0\n":       "hex",
+		"00:03:00:08:0b":               "hex",
+		"\xc0\x00\x03\x00\x08\x0b\xc0": "slip",
+	}
+
+	for input, format := range tests {
+		if got := detectFormat([]byte(input)); got != format {
+			t.Errorf("%q: got %s, expected %s", input, got, format)
+		}
+	}
+}
+
+func TestSplitSlip(t *testing.T) {
+	version := slipFrame(t, &commands.GetVersionReq{})
+	state := slipFrame(t, &commands.GetStateReq{})
+
+	input := bytes.Join([][]byte{version, state, {frameEnd, 0x00, frameEsc, 0x01, frameEnd}, state[:len(state)-2]}, nil)
+	frames := splitSlip(input)
+
+	if len(frames) != 4 {
+		t.Fatalf("got %d frames", len(frames))
+	}
+
+	if frames[0].cmd != transport.GW_GET_VERSION_REQ || !frames[0].checksumOk || frames[0].err != nil {
+		t.Errorf("first frame: got %+v", frames[0])
+	}
+
+	if frames[1].cmd != transport.GW_GET_STATE_REQ || !frames[1].checksumOk || frames[1].err != nil {
+		t.Errorf("second frame: got %+v", frames[1])
+	}
+
+	if frames[2].err == nil {
+		t.Error("bad escape sequence not reported")
+	}
+
+	if frames[3].err == nil || !strings.Contains(frames[3].err.Error(), "truncated") {
+		t.Errorf("truncated frame: got %v", frames[3].err)
+	}
+}
+
+func TestDissectHex(t *testing.T) {
+	version := slipFrame(t, &commands.GetVersionReq{})
+
+	// SLIP-encoded line, plain frame line with a bad checksum, and a line which is not hexadecimal
+	plain := bytes.Trim(version, string([]byte{frameEnd}))
+	corrupted := bytes.Clone(plain)
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	input := hex.EncodeToString(version) + "\n\n" + hex.EncodeToString(corrupted) + "\n"
+
+	out := &bytes.Buffer{}
+	if err := dissectHex(&printer{out: out}, []byte(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	text := out.String()
+	if strings.Count(text, "GW_GET_VERSION_REQ") != 2 || strings.Count(text, "CHECKSUM ERROR") != 1 {
+		t.Errorf("got output:\n%s", text)
+	}
+
+	if err := dissectHex(&printer{out: io.Discard}, []byte("c0 zz\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("got %v, expected the bad line", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/capture"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+type printer struct {
+	out   io.Writer
+	count int
+}
+
+func (p *printer) print(record *capture.Record, frame *rawFrame) {
+	p.count++
+
+	fmt.Fprintf(p.out, "#%d", p.count)
+
+	if record != nil {
+		fmt.Fprintf(p.out, " %s conn=%d", record.Time.Format(time.RFC3339Nano), record.ConnID)
+	}
+
+	if !frame.hasCmd {
+		fmt.Fprintf(p.out, " ? INVALID\n")
+		fmt.Fprintf(p.out, "    ERROR: %s\n", frame.err)
+		fmt.Fprintf(p.out, "    raw: %s\n", hex.EncodeToString(frame.raw))
+		return
+	}
+
+	message, direction := decode(record, frame)
+
+	fmt.Fprintf(p.out, " %s %s (0x%04x) %d bytes\n", direction, frame.cmd, uint16(frame.cmd), len(frame.data))
+
+	if frame.err != nil {
+		fmt.Fprintf(p.out, "    ERROR: %s\n", frame.err)
+		fmt.Fprintf(p.out, "    raw: %s\n", hex.EncodeToString(frame.raw))
+		return
+	}
+
+	if !frame.checksumOk {
+		fmt.Fprintf(p.out, "    CHECKSUM ERROR: got 0x%02x, expected 0x%02x\n", frame.checksum, frame.expectedCs)
+	}
+
+	if len(frame.data) > 0 {
+		fmt.Fprintf(p.out, "    data: %s\n", hex.EncodeToString(frame.data))
+	}
+
+	switch message := message.(type) {
+	case nil:
+		fmt.Fprintf(p.out, "    UNKNOWN COMMAND\n")
+	case error:
+		fmt.Fprintf(p.out, "    DECODE ERROR: %s\n", message)
+	default:
+		fmt.Fprintf(p.out, "    %T %+v\n", message, message)
+	}
+}
+
+// Decode the frame data with the matching commands type.
+// Returns the decoded struct (nil if the code is unknown, or an error) and the direction
+func decode(record *capture.Record, frame *rawFrame) (interface{}, string) {
+	type reader interface {
+		Read(data []byte) error
+	}
+
+	var message reader
+	direction := "?"
+
+	if req := commands.GetRequest(frame.cmd); req != nil {
+		message = req
+		direction = ">"
+	} else if cfm := commands.GetConfirm(frame.cmd); cfm != nil {
+		message = cfm
+		direction = "<"
+	} else if ntf := commands.GetNotify(frame.cmd); ntf != nil {
+		message = ntf
+		direction = "<"
+	}
+
+	if record != nil {
+		direction = ">"
+		if record.Direction == klf200.FrameReceived {
+			direction = "<"
+		}
+	}
+
+	if message == nil {
+		return nil, direction
+	}
+
+	if err := message.Read(frame.data); err != nil {
+		return err, direction
+	}
+
+	return message, direction
+}
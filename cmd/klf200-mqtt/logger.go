@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	klf200 "github.com/mylife-home/klf200-go"
+)
+
+// Logs on standard error. Debug messages are only printed in verbose mode
+type logger struct {
+	out     *log.Logger
+	verbose bool
+	err     error
+}
+
+func newLogger(verbose bool) *logger {
+	return &logger{out: log.New(os.Stderr, "", log.LstdFlags), verbose: verbose}
+}
+
+func (l *logger) Debugf(format string, args ...interface{}) {
+	l.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Infof(format string, args ...interface{}) {
+	l.Info(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Warnf(format string, args ...interface{}) {
+	l.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Errorf(format string, args ...interface{}) {
+	l.Error(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Debug(msg string) {
+	if l.verbose {
+		l.print("DEBUG", msg)
+	}
+}
+
+func (l *logger) Info(msg string) {
+	l.print("INFO ", msg)
+}
+
+func (l *logger) Warn(msg string) {
+	l.print("WARN ", msg)
+}
+
+func (l *logger) Error(msg string) {
+	l.print("ERROR", msg)
+}
+
+func (l *logger) print(level string, msg string) {
+	if l.err != nil {
+		l.out.Printf("%s %s: %s", level, msg, l.err)
+	} else {
+		l.out.Printf("%s %s", level, msg)
+	}
+}
+
+func (l *logger) WithError(err error) klf200.Logger {
+	return &logger{out: l.out, verbose: l.verbose, err: err}
+}
@@ -0,0 +1,169 @@
+// Daemon which bridges a KLF 200 gateway to an MQTT broker (see the mqttbridge package for the topics).
+//
+// Usage:
+//
+//	klf200-mqtt -address <gateway> -broker <url> [flags]
+//
+// The passwords can also be given with the KLF200_PASSWORD and MQTT_PASSWORD environment variables.
+// The gateway certificate is not verified unless it is pinned with -fingerprint or -pin-file.
+// With -metrics, the Prometheus metrics of the gateway client are served on http://<address>/metrics.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/metrics"
+	"github.com/mylife-home/klf200-go/mqttbridge"
+)
+
+// Default TCP port of the gateway API
+const defaultPort = "51200"
+
+// Command line settings
+type settings struct {
+	gateway         string
+	password        string
+	fingerprint     string
+	pinFile         string
+	broker          string
+	clientID        string
+	username        string
+	mqttPassword    string
+	prefix          string
+	homeAssistant   bool
+	discoveryPrefix string
+	metricsAddress  string
+	verbose         bool
+}
+
+// Parse the command line, the environment provides the defaults. Errors and usage are printed on output
+func parseArgs(args []string, getenv func(string) string, output io.Writer) (*settings, error) {
+	s := &settings{}
+
+	flags := flag.NewFlagSet("klf200-mqtt", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&s.gateway, "address", getenv("KLF200_ADDRESS"), "gateway address (host[:port])")
+	flags.StringVar(&s.password, "password", getenv("KLF200_PASSWORD"), "gateway password")
+	flags.StringVar(&s.fingerprint, "fingerprint", getenv("KLF200_FINGERPRINT"), "SHA-256 fingerprint of the gateway certificate, other certificates are refused")
+	flags.StringVar(&s.pinFile, "pin-file", "", "pin the gateway certificate on first connection in this file, other certificates are refused afterwards")
+	flags.StringVar(&s.broker, "broker", "tcp://localhost:1883", "MQTT broker URL")
+	flags.StringVar(&s.clientID, "client-id", mqttbridge.DefaultClientID, "MQTT client ID")
+	flags.StringVar(&s.username, "mqtt-user", "", "MQTT user name")
+	flags.StringVar(&s.mqttPassword, "mqtt-password", getenv("MQTT_PASSWORD"), "MQTT password")
+	flags.StringVar(&s.prefix, "prefix", mqttbridge.DefaultPrefix, "prefix of the MQTT topics")
+	flags.BoolVar(&s.homeAssistant, "homeassistant", false, "publish Home Assistant discovery messages")
+	flags.StringVar(&s.discoveryPrefix, "discovery-prefix", mqttbridge.DefaultDiscoveryPrefix, "prefix of the Home Assistant discovery topics")
+	flags.StringVar(&s.metricsAddress, "metrics", "", "serve the Prometheus metrics on this address (eg: ':9200')")
+	flags.BoolVar(&s.verbose, "v", false, "debug logs")
+	flags.Usage = func() {
+		fmt.Fprintf(output, "Usage: klf200-mqtt -address <gateway> -broker <url> [flags]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch {
+	case flags.NArg() > 0:
+		err = errors.New("unexpected arguments")
+	case s.gateway == "":
+		err = errors.New("no gateway address")
+	case s.password == "":
+		err = errors.New("no gateway password")
+	}
+
+	if err != nil {
+		fmt.Fprintf(output, "%s\n", err)
+		flags.Usage()
+		return nil, err
+	}
+
+	if _, _, err := net.SplitHostPort(s.gateway); err != nil {
+		s.gateway = net.JoinHostPort(s.gateway, defaultPort)
+	}
+
+	return s, nil
+}
+
+func main() {
+	s, err := parseArgs(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		os.Exit(2)
+	}
+
+	log := newLogger(s.verbose)
+
+	options := []klf200.ClientOption{klf200.WithLogger(log)}
+	switch {
+	case s.fingerprint != "":
+		options = append(options, klf200.WithPinnedCertificate(s.fingerprint))
+	case s.pinFile != "":
+		options = append(options, klf200.WithTrustOnFirstUse(klf200.NewFilePinStore(s.pinFile)))
+	}
+
+	var collector *metrics.Collector
+	if s.metricsAddress != "" {
+		collector = metrics.New()
+		options = append(options, klf200.WithObserver(collector))
+	}
+
+	client := klf200.NewClient(s.gateway, s.password, options...)
+
+	if collector != nil {
+		collector.Attach(client)
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", collector)
+
+		listener, err := net.Listen("tcp", s.metricsAddress)
+		if err != nil {
+			log.Errorf("Could not listen on '%s': %s", s.metricsAddress, err)
+			os.Exit(1)
+		}
+
+		go http.Serve(listener, mux)
+		log.Infof("Serving metrics on '%s'", listener.Addr())
+	}
+
+	bridge := mqttbridge.New(client, mqttbridge.Config{
+		Broker:   s.broker,
+		ClientID: s.clientID,
+		Username: s.username,
+		Password: s.mqttPassword,
+		Prefix:   s.prefix,
+		Log:      log,
+
+		HomeAssistant:   s.homeAssistant,
+		DiscoveryPrefix: s.discoveryPrefix,
+	})
+
+	client.Start()
+	bridge.Start()
+
+	log.Infof("Bridging gateway '%s' to broker '%s'", s.gateway, s.broker)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	<-ctx.Done()
+
+	log.Infof("Stopping")
+	bridge.Close()
+	client.Close()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/mylife-home/klf200-go/mqttbridge"
+)
+
+func environment(values map[string]string) func(string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	s, err := parseArgs([]string{"-address", "192.168.0.10", "-password", "velux123", "-broker", "tcp://broker:1883", "-homeassistant"}, environment(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.gateway != "192.168.0.10:51200" {
+		t.Errorf("got gateway %q, expected the default port", s.gateway)
+	}
+
+	if s.password != "velux123" || s.broker != "tcp://broker:1883" || !s.homeAssistant {
+		t.Errorf("got %+v", s)
+	}
+
+	if s.clientID != mqttbridge.DefaultClientID || s.prefix != mqttbridge.DefaultPrefix || s.discoveryPrefix != mqttbridge.DefaultDiscoveryPrefix {
+		t.Errorf("got %+v, expected the bridge defaults", s)
+	}
+}
+
+func TestParseArgsEnvironment(t *testing.T) {
+	env := environment(map[string]string{
+		"KLF200_ADDRESS":     "gateway:1234",
+		"KLF200_PASSWORD":    "velux123",
+		"KLF200_FINGERPRINT": "ab:cd",
+		"MQTT_PASSWORD":      "secret",
+	})
+
+	s, err := parseArgs(nil, env, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.gateway != "gateway:1234" || s.password != "velux123" || s.fingerprint != "ab:cd" || s.mqttPassword != "secret" {
+		t.Errorf("got %+v", s)
+	}
+
+	// Flags take precedence
+	s, err = parseArgs([]string{"-password", "other"}, env, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.password != "other" {
+		t.Errorf("got password %q", s.password)
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	tests := [][]string{
+		{"-password", "velux123"},
+		{"-address", "gateway"},
+		{"-address", "gateway", "-password", "velux123", "extra"},
+		{"-unknown"},
+	}
+
+	for _, args := range tests {
+		if _, err := parseArgs(args, environment(nil), io.Discard); err == nil {
+			t.Errorf("%q: accepted", args)
+		}
+	}
+
+	if _, err := parseArgs([]string{"-h"}, environment(nil), io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("-h: got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+func runMove(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 2, 2, "<node> <percent>"); err != nil {
+		return err
+	}
+
+	index, err := resolveNode(ctx, env, args[0])
+	if err != nil {
+		return err
+	}
+
+	percent, err := strconv.Atoi(args[1])
+	if err != nil || percent < 0 || percent > 100 {
+		return fmt.Errorf("%w: bad position '%s' (expected 0 to 100)", errUsage, args[1])
+	}
+
+	session, err := env.client.Commands().ChangePosition(ctx, index, commands.NewMPValueAbsolute(percent))
+	if err != nil {
+		return err
+	}
+
+	return followSession(env, session)
+}
+
+func runStop(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 1, 1, "<node>"); err != nil {
+		return err
+	}
+
+	index, err := resolveNode(ctx, env, args[0])
+	if err != nil {
+		return err
+	}
+
+	// Targeting the current position stops the movement
+	session, err := env.client.Commands().ChangePosition(ctx, index, commands.NewMPValueCurrent())
+	if err != nil {
+		return err
+	}
+
+	return followSession(env, session)
+}
+
+func runScene(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 1, 2, "list | activate <scene> | stop <scene>"); err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		scenes, err := env.client.Scenes().GetSceneList(ctx)
+		if err != nil {
+			return err
+		}
+
+		type sceneView struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		}
+
+		views := make([]sceneView, 0, len(scenes))
+		for _, scene := range scenes {
+			views = append(views, sceneView{ID: scene.SceneID, Name: scene.Name})
+		}
+
+		return env.out.print(views, func(table io.Writer) {
+			fmt.Fprintf(table, "ID\tNAME\n")
+			for _, view := range views {
+				fmt.Fprintf(table, "%d\t%s\n", view.ID, view.Name)
+			}
+		})
+
+	case args[0] == "activate" && len(args) == 2:
+		id, err := resolveScene(ctx, env, args[1])
+		if err != nil {
+			return err
+		}
+
+		session, err := env.client.Scenes().Activate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return followSession(env, session)
+
+	case args[0] == "stop" && len(args) == 2:
+		id, err := resolveScene(ctx, env, args[1])
+		if err != nil {
+			return err
+		}
+
+		return env.client.Scenes().StopContext(ctx, id)
+
+	default:
+		return fmt.Errorf("%w: expected list | activate <scene> | stop <scene>", errUsage)
+	}
+}
+
+type eventView struct {
+	Event         string        `json:"event"`
+	RunStatus     string        `json:"runStatus,omitempty"`
+	StatusReply   string        `json:"statusReply,omitempty"`
+	Position      *int          `json:"position,omitempty"`
+	RemainingTime time.Duration `json:"remainingTime,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// Print the events of the session until it is finished
+func followSession(env *env, session *klf200.Session) error {
+	var sessionErr error
+
+	for event := range session.Events() {
+		var view eventView
+
+		switch event := event.(type) {
+		case *klf200.RunStatus:
+			view = eventView{Event: "status", RunStatus: event.RunStatus.String(), StatusReply: event.StatusReply.String(), Position: percent(event.ParameterValue)}
+		case *klf200.RunRemainingTime:
+			view = eventView{Event: "remainingTime", RemainingTime: event.Duration}
+		case *klf200.RunError:
+			sessionErr = event.Err
+			view = eventView{Event: "error", Error: event.Err.Error()}
+		default:
+			continue
+		}
+
+		err := env.out.print(view, func(table io.Writer) {
+			switch view.Event {
+			case "status":
+				fmt.Fprintf(table, "%s\t%s\t%s\n", view.RunStatus, view.StatusReply, formatPercent(view.Position))
+			case "remainingTime":
+				fmt.Fprintf(table, "remaining time\t%s\n", view.RemainingTime)
+			case "error":
+				fmt.Fprintf(table, "error\t%s\n", view.Error)
+			}
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return sessionErr
+}
+
+func runWatch(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	notifications, unsubscribe := klf200.Subscribe[commands.Notify](env.client, nil, klf200.WithOverflowPolicy(klf200.OverflowDropOldest))
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case notif, ok := <-notifications:
+			if !ok {
+				return errors.New("notification stream closed")
+			}
+
+			if err := printNotification(env.out, notif); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func printNotification(out *output, notif commands.Notify) error {
+	view := struct {
+		Time         time.Time       `json:"time"`
+		Command      string          `json:"command"`
+		Notification commands.Notify `json:"notification"`
+	}{time.Now(), notif.Code().String(), notif}
+
+	return out.print(view, func(table io.Writer) {
+		fmt.Fprintf(table, "%s %s %+v\n", view.Time.Format("15:04:05.000"), view.Command, notif)
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mylife-home/klf200-go"
+)
+
+// Context of a command execution
+type env struct {
+	client  *klf200.Client
+	out     *output
+	timeout time.Duration
+
+	// Only set for the offline commands
+	settings *settings
+
+	// Asks the user a yes/no question, nil if there is no terminal to ask on
+	confirm func(question string) bool
+}
+
+type command struct {
+	name string
+	args string
+	help string
+
+	// Does not need a connection to the gateway
+	offline bool
+
+	// Runs until interrupted, the timeout does not apply
+	stream bool
+
+	// Takes over the terminal, cannot be run from the shell
+	interactive bool
+
+	run func(ctx context.Context, env *env, args []string) error
+}
+
+var commandList []*command
+
+func init() {
+	commandList = []*command{
+		{name: "help", help: "Show this help", offline: true, run: runHelp},
+		{name: "version", help: "Show the gateway firmware and hardware versions", run: runVersion},
+		{name: "state", help: "Show the gateway state", run: runState},
+		{name: "nodes", help: "List the nodes with their position", run: runNodes},
+		{name: "groups", help: "List the groups", run: runGroups},
+		{name: "systable", help: "Show the system table (actuators known by the gateway)", run: runSystable},
+		{name: "status", args: "[node...]", help: "Request the status of the nodes (all if none given)", run: runStatus},
+		{name: "move", args: "<node> <percent>", help: "Move the node to an absolute position and follow the run", run: runMove},
+		{name: "stop", args: "<node>", help: "Stop the node at its current position", run: runStop},
+		{name: "scene", args: "list | activate <scene> | stop <scene>", help: "List, activate or stop scenes", run: runScene},
+		{name: "time", args: "[sync [timezone]]", help: "Show the gateway time, or set it to the local clock", run: runTime},
+		{name: "network", help: "Show the gateway network setup", run: runNetwork},
+		{name: "reboot", args: "[--yes]", help: "Reboot the gateway, after confirmation unless --yes is given", run: runReboot},
+		{name: "fingerprint", help: "Show the fingerprint of the gateway certificate, to pin it with -fingerprint", offline: true, run: runFingerprint},
+		{name: "watch", help: "Print all notifications sent by the gateway until interrupted", stream: true, run: runWatch},
+		{name: "dashboard", help: "Live view of the nodes, with keys to move them", stream: true, interactive: true, run: runDashboard},
+		{name: "shell", help: "Interactive shell, with completion and notifications printed as they arrive", stream: true, interactive: true, run: runShell},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commandList {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+func printCommands(out io.Writer, list []*command) {
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	for _, cmd := range list {
+		fmt.Fprintf(table, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.help)
+	}
+
+	table.Flush()
+}
+
+func runHelp(ctx context.Context, env *env, args []string) error {
+	usage()
+	return nil
+}
+
+// Parse the optional --yes (or -y) argument of a destructive command
+func parseYes(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	if args[0] != "--yes" && args[0] != "-y" {
+		return false, fmt.Errorf("%w: unknown argument '%s', expected --yes", errUsage, args[0])
+	}
+
+	return true, nil
+}
+
+func checkArgs(args []string, min int, max int, usage string) error {
+	if len(args) < min || len(args) > max {
+		return fmt.Errorf("%w: expected %s", errUsage, usage)
+	}
+
+	return nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+const dashboardHelp = "↑/↓ select  o open  c close  s stop  0-9 move to 0-90%  r reload  q quit"
+
+type dashboardNode struct {
+	id       int
+	name     string
+	state    commands.NodeState
+	position *int
+	target   *int
+
+	// Last run status of a command sent from the dashboard
+	run string
+
+	// Remaining time as last reported, at stamp
+	remaining      time.Duration
+	remainingStamp time.Time
+}
+
+func (node *dashboardNode) setRemaining(remaining time.Duration) {
+	node.remaining = remaining
+	node.remainingStamp = time.Now()
+}
+
+func (node *dashboardNode) currentRemaining() time.Duration {
+	return max(node.remaining-time.Since(node.remainingStamp), 0).Round(time.Second)
+}
+
+// Event of a session started from the dashboard
+type dashboardEvent struct {
+	nodeID int
+	event  klf200.Event
+}
+
+type dashboard struct {
+	client  *klf200.Client
+	timeout time.Duration
+
+	nodes    []*dashboardNode
+	selected int
+	message  string
+
+	events   chan dashboardEvent
+	reloaded chan []*commands.GetAllNodesInformationNtf
+}
+
+func runDashboard(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(fd) {
+		return errors.New("the dashboard needs a terminal")
+	}
+
+	db := &dashboard{
+		client:   env.client,
+		timeout:  env.timeout,
+		events:   make(chan dashboardEvent, 100),
+		reloaded: make(chan []*commands.GetAllNodesInformationNtf, 1),
+	}
+
+	notifications, unsubscribe := klf200.Subscribe[commands.Notify](env.client, nil, klf200.WithOverflowPolicy(klf200.OverflowDropOldest))
+	defer unsubscribe()
+
+	if err := db.load(ctx); err != nil {
+		return err
+	}
+
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return err
+	}
+
+	defer restore()
+
+	// Alternate screen, cursor hidden
+	fmt.Fprintf(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprintf(os.Stdout, "\x1b[?25h\x1b[?1049l")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := make(chan key)
+	go func() {
+		input := newKeyReader(os.Stdin)
+		for {
+			k, err := input.readKey(ctx)
+			if err != nil {
+				close(keys)
+				return
+			}
+
+			select {
+			case keys <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		db.render()
+
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case k, ok := <-keys:
+			if !ok || !db.handleKey(ctx, k) {
+				return nil
+			}
+
+		case notif := <-notifications:
+			db.handleNotification(notif)
+
+		case event := <-db.events:
+			db.handleEvent(event)
+
+		case nodes := <-db.reloaded:
+			db.setNodes(nodes)
+
+		case <-ticker.C:
+		}
+	}
+}
+
+func (db *dashboard) load(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	nodes, err := db.client.Info().GetAllNodesInformation(ctx)
+	if err != nil {
+		return err
+	}
+
+	db.setNodes(nodes)
+	return nil
+}
+
+func (db *dashboard) setNodes(nodes []*commands.GetAllNodesInformationNtf) {
+	db.nodes = make([]*dashboardNode, 0, len(nodes))
+
+	for _, info := range nodes {
+		node := &dashboardNode{
+			id:       info.NodeID,
+			name:     info.Name,
+			state:    info.State,
+			position: nodePercent(info.CurrentPosition),
+			target:   nodePercent(info.Target),
+		}
+
+		node.setRemaining(info.RemainingTime)
+		db.nodes = append(db.nodes, node)
+	}
+
+	db.selected = min(db.selected, max(len(db.nodes)-1, 0))
+}
+
+func (db *dashboard) findNode(id int) *dashboardNode {
+	for _, node := range db.nodes {
+		if node.id == id {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// Returns false to quit
+func (db *dashboard) handleKey(ctx context.Context, k key) bool {
+	switch {
+	case k.r == 'q' || k.r == keyCtrlC:
+		return false
+
+	case k.r == keyEscape && k.sequence == "A", k.r == 'k':
+		db.selected = max(db.selected-1, 0)
+
+	case k.r == keyEscape && k.sequence == "B", k.r == 'j':
+		db.selected = min(db.selected+1, max(len(db.nodes)-1, 0))
+
+	case k.r == 'o':
+		db.move(ctx, commands.NewMPValueAbsolute(0))
+
+	case k.r == 'c':
+		db.move(ctx, commands.NewMPValueAbsolute(100))
+
+	case k.r == 's':
+		db.move(ctx, commands.NewMPValueCurrent())
+
+	case k.r >= '0' && k.r <= '9':
+		db.move(ctx, commands.NewMPValueAbsolute(int(k.r-'0')*10))
+
+	case k.r == 'r':
+		db.message = "reloading nodes"
+		go db.reload(ctx)
+	}
+
+	return true
+}
+
+// Send the command to the selected node. The session events are handled by the main loop
+func (db *dashboard) move(ctx context.Context, position commands.MPValue) {
+	if len(db.nodes) == 0 {
+		return
+	}
+
+	node := db.nodes[db.selected]
+	node.run = "Sending"
+	db.message = fmt.Sprintf("%s: %s", node.name, position)
+
+	go func() {
+		session, err := db.client.Commands().ChangePosition(ctx, node.id, position)
+		if err != nil {
+			db.post(ctx, node.id, &klf200.RunError{Err: err})
+			return
+		}
+
+		for event := range session.Events() {
+			db.post(ctx, node.id, event)
+		}
+	}()
+}
+
+func (db *dashboard) reload(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	nodes, err := db.client.Info().GetAllNodesInformation(ctx)
+	if err != nil {
+		db.post(ctx, -1, &klf200.RunError{Err: err})
+		return
+	}
+
+	select {
+	case db.reloaded <- nodes:
+	case <-ctx.Done():
+	}
+}
+
+func (db *dashboard) post(ctx context.Context, nodeID int, event klf200.Event) {
+	select {
+	case db.events <- dashboardEvent{nodeID: nodeID, event: event}:
+	case <-ctx.Done():
+	}
+}
+
+func (db *dashboard) handleNotification(notif commands.Notify) {
+	ntf, ok := notif.(*commands.NodeStatePositionChangedNtf)
+	if !ok {
+		return
+	}
+
+	node := db.findNode(ntf.NodeID)
+	if node == nil {
+		return
+	}
+
+	node.state = ntf.State
+	node.position = nodePercent(ntf.CurrentPosition)
+	node.target = nodePercent(ntf.Target)
+	node.setRemaining(ntf.RemainingTime)
+}
+
+func (db *dashboard) handleEvent(event dashboardEvent) {
+	node := db.findNode(event.nodeID)
+
+	switch event := event.event.(type) {
+	case *klf200.RunStatus:
+		if node == nil {
+			return
+		}
+
+		node.run = event.RunStatus.String()
+		if event.StatusReply != commands.CommandRunStatusReplyUnknownStatusReply && event.StatusReply != commands.CommandRunStatusReplyCommandCompletedOk {
+			node.run += " (" + event.StatusReply.String() + ")"
+		}
+
+		if position := percent(event.ParameterValue); position != nil {
+			node.position = position
+		}
+
+	case *klf200.RunRemainingTime:
+		if node != nil {
+			node.setRemaining(event.Duration)
+		}
+
+	case *klf200.RunError:
+		if node != nil {
+			node.run = "Error"
+			db.message = fmt.Sprintf("%s: %s", node.name, event.Err)
+		} else {
+			db.message = event.Err.Error()
+		}
+	}
+}
+
+func (db *dashboard) render() {
+	width, height, err := terminalSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	const barWidth = 20
+
+	var screen strings.Builder
+	screen.WriteString("\x1b[H")
+
+	line := func(format string, args ...interface{}) {
+		text := []rune(fmt.Sprintf(format, args...))
+		if len(text) > width {
+			text = text[:width]
+		}
+
+		screen.WriteString(string(text))
+		screen.WriteString("\x1b[K\n")
+	}
+
+	line("KLF 200 dashboard  %s", time.Now().Format("15:04:05"))
+	line("")
+	line("  %-4s %-20s %-*s %6s  %-14s %-24s %s", "ID", "NAME", barWidth+9, "POSITION", "TARGET", "STATE", "RUN", "REMAINING")
+
+	// Header (3 lines) and footer (2 lines) are always shown
+	visible := max(height-6, 1)
+	first := max(db.selected-visible+1, 0)
+
+	for index := first; index < len(db.nodes) && index < first+visible; index++ {
+		node := db.nodes[index]
+
+		cursor := " "
+		if index == db.selected {
+			cursor = ">"
+		}
+
+		remaining := ""
+		if node.state == commands.NodeStateExecuting || node.currentRemaining() > 0 {
+			remaining = node.currentRemaining().String()
+		}
+
+		line("%s %-4d %-20s [%s] %6s %6s  %-14s %-24s %s",
+			cursor, node.id, node.name, positionBar(node.position, barWidth), formatPercent(node.position), formatPercent(node.target),
+			strings.TrimPrefix(node.state.String(), "NodeState"), node.run, remaining)
+	}
+
+	if len(db.nodes) == 0 {
+		line("  no node")
+	}
+
+	screen.WriteString("\x1b[J")
+	fmt.Fprintf(&screen, "\x1b[%d;1H", height-1)
+	line("%s", db.message)
+	screen.WriteString(dashboardHelp)
+	screen.WriteString("\x1b[K")
+
+	os.Stdout.WriteString(screen.String())
+}
+
+// Bar filled according to the closure percentage
+func positionBar(position *int, width int) string {
+	if position == nil {
+		return strings.Repeat("?", width)
+	}
+
+	filled := min(*position*width/100, width)
+	return strings.Repeat("#", filled) + strings.Repeat(".", width-filled)
+}
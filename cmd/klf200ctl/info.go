@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mylife-home/klf200-go"
+)
+
+func runVersion(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	version, err := env.client.Device().GetVersionContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	protocol, err := env.client.Device().GetProtocolVersionContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	sw := version.SoftwareVersion
+	value := struct {
+		Software        string `json:"software"`
+		Hardware        int    `json:"hardware"`
+		ProductGroup    int    `json:"productGroup"`
+		ProductType     int    `json:"productType"`
+		ProtocolVersion string `json:"protocolVersion"`
+	}{
+		Software:        fmt.Sprintf("%d.%d.%d.%d.%d.%d", sw.CommandVersionNumber, sw.VersionWholeNumber, sw.VersionSubNumber, sw.BranchID, sw.BuildNumber, sw.MicroBuild),
+		Hardware:        version.HardwareVersion,
+		ProductGroup:    int(version.ProductGroup),
+		ProductType:     version.ProductType,
+		ProtocolVersion: fmt.Sprintf("%d.%d", protocol.MajorVersion, protocol.MinorVersion),
+	}
+
+	return env.out.print(value, func(table io.Writer) {
+		fmt.Fprintf(table, "Software version:\t%s\n", value.Software)
+		fmt.Fprintf(table, "Hardware version:\t%d\n", value.Hardware)
+		fmt.Fprintf(table, "Product:\tgroup %d, type %d\n", value.ProductGroup, value.ProductType)
+		fmt.Fprintf(table, "Protocol version:\t%s\n", value.ProtocolVersion)
+	})
+}
+
+func runState(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	state, err := env.client.Device().GetStateContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	value := struct {
+		State    string `json:"state"`
+		SubState string `json:"subState"`
+	}{state.GatewayState.String(), state.SubState.String()}
+
+	return env.out.print(value, func(table io.Writer) {
+		fmt.Fprintf(table, "State:\t%s\n", value.State)
+		fmt.Fprintf(table, "Sub-state:\t%s\n", value.SubState)
+	})
+}
+
+type nodeView struct {
+	ID            int           `json:"id"`
+	Name          string        `json:"name"`
+	Type          string        `json:"type"`
+	State         string        `json:"state"`
+	Position      *int          `json:"position"`
+	Target        *int          `json:"target"`
+	RemainingTime time.Duration `json:"remainingTime"`
+	SerialNumber  uint64        `json:"serialNumber"`
+}
+
+func runNodes(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	nodes, err := env.client.Info().GetAllNodesInformation(ctx)
+	if err != nil {
+		return err
+	}
+
+	views := make([]nodeView, 0, len(nodes))
+	for _, node := range nodes {
+		views = append(views, nodeView{
+			ID:            node.NodeID,
+			Name:          node.Name,
+			Type:          node.NodeTypeSubType.String(),
+			State:         node.State.String(),
+			Position:      nodePercent(node.CurrentPosition),
+			Target:        nodePercent(node.Target),
+			RemainingTime: node.RemainingTime,
+			SerialNumber:  node.SerialNumber,
+		})
+	}
+
+	return env.out.print(views, func(table io.Writer) {
+		fmt.Fprintf(table, "ID\tNAME\tTYPE\tSTATE\tPOSITION\tTARGET\n")
+		for _, view := range views {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\t%s\n", view.ID, view.Name, view.Type, view.State, formatPercent(view.Position), formatPercent(view.Target))
+		}
+	})
+}
+
+func runGroups(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	groups, err := env.client.Info().GetAllGroupsInformation(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	type groupView struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		Nodes []int  `json:"nodes"`
+	}
+
+	views := make([]groupView, 0, len(groups))
+	for _, group := range groups {
+		views = append(views, groupView{ID: group.GroupID, Name: group.Name, Type: group.GroupType.String(), Nodes: group.NodeIndexes})
+	}
+
+	return env.out.print(views, func(table io.Writer) {
+		fmt.Fprintf(table, "ID\tNAME\tTYPE\tNODES\n")
+		for _, view := range views {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%v\n", view.ID, view.Name, view.Type, view.Nodes)
+		}
+	})
+}
+
+func runSystable(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	objects, err := env.client.Config().GetSystemTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	type objectView struct {
+		Index          int           `json:"index"`
+		Address        string        `json:"address"`
+		Type           string        `json:"type"`
+		Manufacturer   string        `json:"manufacturer"`
+		PowerSaveMode  bool          `json:"powerSaveMode"`
+		RfSupport      bool          `json:"rfSupport"`
+		TurnaroundTime time.Duration `json:"turnaroundTime"`
+	}
+
+	views := make([]objectView, 0, len(objects))
+	for _, object := range objects {
+		views = append(views, objectView{
+			Index:          object.SystemTableIndex,
+			Address:        fmt.Sprintf("%06x", object.ActuatorAddress),
+			Type:           object.NodeTypeSubType().String(),
+			Manufacturer:   object.IoManufacturer.String(),
+			PowerSaveMode:  object.PowerSaveMode,
+			RfSupport:      object.RfSupport,
+			TurnaroundTime: object.ActuatorTurnaroundTime,
+		})
+	}
+
+	return env.out.print(views, func(table io.Writer) {
+		fmt.Fprintf(table, "INDEX\tADDRESS\tTYPE\tMANUFACTURER\tPOWER SAVE\tTURNAROUND\n")
+		for _, view := range views {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%t\t%s\n", view.Index, view.Address, view.Type, view.Manufacturer, view.PowerSaveMode, view.TurnaroundTime)
+		}
+	})
+}
+
+func runStatus(ctx context.Context, env *env, args []string) error {
+	indexes := make([]int, 0, len(args))
+
+	for _, arg := range args {
+		index, err := resolveNode(ctx, env, arg)
+		if err != nil {
+			return err
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	if len(indexes) == 0 {
+		nodes, err := env.client.Info().GetAllNodesInformation(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			indexes = append(indexes, node.NodeID)
+		}
+	}
+
+	statuses, err := env.client.Commands().Status(ctx, indexes)
+	if err != nil {
+		return err
+	}
+
+	type statusView struct {
+		ID            int           `json:"id"`
+		RunStatus     string        `json:"runStatus"`
+		StatusReply   string        `json:"statusReply"`
+		Owner         string        `json:"owner"`
+		Position      *int          `json:"position"`
+		Target        *int          `json:"target"`
+		RemainingTime time.Duration `json:"remainingTime"`
+	}
+
+	views := make([]statusView, 0, len(statuses))
+	for _, status := range statuses {
+		views = append(views, statusView{
+			ID:            status.NodeIndex,
+			RunStatus:     status.RunStatus.String(),
+			StatusReply:   status.StatusReply.String(),
+			Owner:         status.StatusID.String(),
+			Position:      percent(status.CurrentPosition),
+			Target:        percent(status.TargetPosition),
+			RemainingTime: status.RemainingTime,
+		})
+	}
+
+	return env.out.print(views, func(table io.Writer) {
+		fmt.Fprintf(table, "ID\tRUN STATUS\tREPLY\tPOSITION\tTARGET\tREMAINING\n")
+		for _, view := range views {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\t%s\n", view.ID, view.RunStatus, view.StatusReply, formatPercent(view.Position), formatPercent(view.Target), view.RemainingTime)
+		}
+	})
+}
+
+func runTime(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 2, "[sync [timezone]]"); err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		if args[0] != "sync" {
+			return fmt.Errorf("%w: unknown time command '%s'", errUsage, args[0])
+		}
+
+		if err := env.client.Device().SetUtcContext(ctx, time.Now()); err != nil {
+			return err
+		}
+
+		if len(args) > 1 {
+			if err := env.client.Device().SetTimeZoneContext(ctx, args[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	cfm, err := env.client.Device().GetLocalTimeContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	local := cfm.LocalTime
+	value := struct {
+		Utc   time.Time `json:"utc"`
+		Local string    `json:"local"`
+	}{
+		Utc:   cfm.UtcTime,
+		Local: fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", local.Year, local.Month+1, local.DayOfMonth, local.Hour, local.Minute, local.Second),
+	}
+
+	return env.out.print(value, func(table io.Writer) {
+		fmt.Fprintf(table, "UTC time:\t%s\n", value.Utc.Format(time.RFC3339))
+		fmt.Fprintf(table, "Local time:\t%s\n", value.Local)
+	})
+}
+
+func runNetwork(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	network, err := env.client.Device().GetNetworkSetupContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	value := struct {
+		DHCP    bool   `json:"dhcp"`
+		Address string `json:"address"`
+		Mask    string `json:"mask"`
+		Gateway string `json:"gateway"`
+	}{network.DHCP, net.IP(network.IpAddress).String(), net.IP(network.Mask).String(), net.IP(network.DefGW).String()}
+
+	return env.out.print(value, func(table io.Writer) {
+		fmt.Fprintf(table, "DHCP:\t%t\n", value.DHCP)
+		fmt.Fprintf(table, "Address:\t%s\n", value.Address)
+		fmt.Fprintf(table, "Mask:\t%s\n", value.Mask)
+		fmt.Fprintf(table, "Gateway:\t%s\n", value.Gateway)
+	})
+}
+
+func runReboot(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 1, "[--yes]"); err != nil {
+		return err
+	}
+
+	confirmed, err := parseYes(args)
+	if err != nil {
+		return err
+	}
+
+	if !confirmed && (env.confirm == nil || !env.confirm("Reboot the gateway?")) {
+		return fmt.Errorf("%w: reboot not confirmed (use --yes to reboot without confirmation)", errUsage)
+	}
+
+	return env.client.Device().RebootContext(ctx)
+}
+
+func runFingerprint(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	if err := env.settings.resolveAddress(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, env.timeout)
+	defer cancel()
+
+	cert, err := klf200.FetchCertificate(ctx, env.settings.Address)
+	if err != nil {
+		return err
+	}
+
+	value := struct {
+		Fingerprint string    `json:"fingerprint"`
+		Subject     string    `json:"subject"`
+		Issuer      string    `json:"issuer"`
+		NotAfter    time.Time `json:"notAfter"`
+	}{klf200.CertificateFingerprint(cert), cert.Subject.String(), cert.Issuer.String(), cert.NotAfter}
+
+	return env.out.print(value, func(table io.Writer) {
+		fmt.Fprintf(table, "Fingerprint:\t%s\n", value.Fingerprint)
+		fmt.Fprintf(table, "Subject:\t%s\n", value.Subject)
+		fmt.Fprintf(table, "Issuer:\t%s\n", value.Issuer)
+		fmt.Fprintf(table, "Expires:\t%s\n", value.NotAfter.Format(time.RFC3339))
+	})
+}
+
+// Find a node by index or by name (case insensitive)
+func resolveNode(ctx context.Context, env *env, arg string) (int, error) {
+	if index, err := strconv.Atoi(arg); err == nil {
+		return index, nil
+	}
+
+	nodes, err := env.client.Info().GetAllNodesInformation(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, node := range nodes {
+		if strings.EqualFold(node.Name, arg) {
+			return node.NodeID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown node '%s'", arg)
+}
+
+// Find a scene by id or by name (case insensitive)
+func resolveScene(ctx context.Context, env *env, arg string) (int, error) {
+	if id, err := strconv.Atoi(arg); err == nil {
+		return id, nil
+	}
+
+	scenes, err := env.client.Scenes().GetSceneList(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, scene := range scenes {
+		if strings.EqualFold(scene.Name, arg) {
+			return scene.SceneID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown scene '%s'", arg)
+}
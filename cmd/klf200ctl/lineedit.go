@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Minimal readline-style line editor: cursor moves, history and completion.
+//
+// If the input is a terminal, it is kept in raw mode until close, so that Ctrl-C is received as a key (see interrupt).
+// Text written to the editor while a line is being edited is printed above the prompt, which is then redrawn.
+// If the input is not a terminal (e.g. a script), lines are read as is.
+type lineEditor struct {
+	out io.Writer
+
+	// Returns the word before the cursor which is completed, and the candidates which can replace it
+	complete func(line string) (string, []string)
+
+	restore func()
+	input   *keyReader
+	lines   chan string
+	readErr error
+
+	lock    sync.Mutex
+	active  bool
+	prompt  string
+	buffer  []rune
+	cursor  int
+	history []string
+
+	// Text written while the line is edited, not terminated by a new line yet
+	partial []byte
+
+	// Position in history while browsing it, and the line which was being edited before
+	historyIndex int
+	pending      []rune
+}
+
+func newLineEditor(in *os.File, out io.Writer, complete func(line string) (string, []string)) *lineEditor {
+	e := &lineEditor{out: out, complete: complete}
+
+	if isTerminal(int(in.Fd())) {
+		if restore, err := makeRaw(int(in.Fd())); err == nil {
+			e.restore = restore
+		}
+	}
+
+	if e.restore != nil {
+		e.input = newKeyReader(in)
+	} else {
+		e.lines = make(chan string)
+		go e.readLines(in)
+	}
+
+	return e
+}
+
+func (e *lineEditor) close() {
+	if e.restore != nil {
+		e.restore()
+	}
+}
+
+func (e *lineEditor) readLines(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		e.lines <- scanner.Text()
+	}
+
+	e.readErr = scanner.Err()
+	close(e.lines)
+}
+
+// Wait until Ctrl-C is typed (returns true) or done is closed (returns false).
+// Other keys are discarded. Always returns false if the input is not a terminal.
+func (e *lineEditor) interrupt(done <-chan struct{}) bool {
+	var keys <-chan byte
+	if e.input != nil {
+		keys = e.input.keys
+	}
+
+	for {
+		select {
+		case <-done:
+			return false
+
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+			} else if key == keyCtrlC {
+				return true
+			}
+		}
+	}
+}
+
+// Write implements io.Writer. If a line is being edited, complete lines of text are printed above it.
+func (e *lineEditor) Write(data []byte) (int, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.active {
+		return e.out.Write(data)
+	}
+
+	e.partial = append(e.partial, data...)
+
+	end := bytes.LastIndexByte(e.partial, '\n')
+	if end < 0 {
+		return len(data), nil
+	}
+
+	fmt.Fprintf(e.out, "\r\x1b[K%s", e.partial[:end+1])
+	e.partial = slices.Clone(e.partial[end+1:])
+	e.refresh()
+
+	return len(data), nil
+}
+
+// Read a line. Returns io.EOF when the input is closed or on Ctrl-D on an empty line
+func (e *lineEditor) readLine(ctx context.Context, prompt string) (string, error) {
+	if e.input == nil {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case line, ok := <-e.lines:
+			if !ok {
+				if e.readErr != nil {
+					return "", e.readErr
+				}
+
+				return "", io.EOF
+			}
+
+			return line, nil
+		}
+	}
+
+	e.lock.Lock()
+	e.active = true
+	e.prompt = prompt
+	e.buffer = nil
+	e.cursor = 0
+	e.historyIndex = len(e.history)
+	e.refresh()
+	e.lock.Unlock()
+
+	for {
+		key, err := e.input.readKey(ctx)
+		if err != nil {
+			e.deactivate()
+			return "", err
+		}
+
+		line, done, err := e.handleKey(key)
+		if done || err != nil {
+			e.deactivate()
+			return line, err
+		}
+	}
+}
+
+func (e *lineEditor) deactivate() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.active = false
+	fmt.Fprintf(e.out, "\n%s", e.partial)
+	e.partial = nil
+}
+
+func (e *lineEditor) handleKey(k key) (string, bool, error) {
+	if k.r == keyTab {
+		e.completeWord()
+		return "", false, nil
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	switch k.r {
+	case keyEnter, keyLineFeed:
+		line := string(e.buffer)
+		e.addHistory(line)
+		return line, true, nil
+
+	case keyCtrlC:
+		// Abandon the current line
+		fmt.Fprintf(e.out, "^C\n")
+		e.buffer = nil
+		e.cursor = 0
+		e.historyIndex = len(e.history)
+
+	case keyCtrlD:
+		if len(e.buffer) == 0 {
+			return "", true, io.EOF
+		}
+
+		e.deleteRunes(e.cursor, e.cursor+1)
+
+	case keyBackspace, keyCtrlH:
+		e.deleteRunes(e.cursor-1, e.cursor)
+
+	case keyCtrlA:
+		e.cursor = 0
+	case keyCtrlE:
+		e.cursor = len(e.buffer)
+	case keyCtrlB:
+		e.cursor = max(e.cursor-1, 0)
+	case keyCtrlF:
+		e.cursor = min(e.cursor+1, len(e.buffer))
+	case keyCtrlK:
+		e.buffer = e.buffer[:e.cursor]
+	case keyCtrlU:
+		e.deleteRunes(0, e.cursor)
+	case keyCtrlW:
+		e.deleteRunes(e.previousWord(), e.cursor)
+	case keyCtrlP:
+		e.browseHistory(-1)
+	case keyCtrlN:
+		e.browseHistory(1)
+
+	case keyCtrlL:
+		fmt.Fprintf(e.out, "\x1b[H\x1b[2J")
+
+	case keyEscape:
+		e.handleSequence(k.sequence)
+
+	default:
+		if !unicode.IsPrint(k.r) {
+			return "", false, nil
+		}
+
+		e.insert(string(k.r))
+	}
+
+	e.refresh()
+	return "", false, nil
+}
+
+func (e *lineEditor) handleSequence(sequence string) {
+	switch sequence {
+	case "A":
+		e.browseHistory(-1)
+	case "B":
+		e.browseHistory(1)
+	case "C":
+		e.cursor = min(e.cursor+1, len(e.buffer))
+	case "D":
+		e.cursor = max(e.cursor-1, 0)
+	case "H", "1~", "7~":
+		e.cursor = 0
+	case "F", "4~", "8~":
+		e.cursor = len(e.buffer)
+	case "3~":
+		e.deleteRunes(e.cursor, e.cursor+1)
+	}
+}
+
+func (e *lineEditor) insert(text string) {
+	runes := []rune(text)
+	buffer := make([]rune, 0, len(e.buffer)+len(runes))
+	buffer = append(buffer, e.buffer[:e.cursor]...)
+	buffer = append(buffer, runes...)
+	buffer = append(buffer, e.buffer[e.cursor:]...)
+
+	e.buffer = buffer
+	e.cursor += len(runes)
+}
+
+// Delete the runes in [begin, end), clamped to the buffer
+func (e *lineEditor) deleteRunes(begin int, end int) {
+	begin = max(begin, 0)
+	end = min(end, len(e.buffer))
+	if begin >= end {
+		return
+	}
+
+	e.buffer = append(e.buffer[:begin], e.buffer[end:]...)
+	e.cursor = begin
+}
+
+func (e *lineEditor) previousWord() int {
+	pos := e.cursor
+	for pos > 0 && unicode.IsSpace(e.buffer[pos-1]) {
+		pos--
+	}
+
+	for pos > 0 && !unicode.IsSpace(e.buffer[pos-1]) {
+		pos--
+	}
+
+	return pos
+}
+
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+
+	e.history = append(e.history, line)
+}
+
+func (e *lineEditor) browseHistory(delta int) {
+	index := e.historyIndex + delta
+	if index < 0 || index > len(e.history) {
+		return
+	}
+
+	if e.historyIndex == len(e.history) {
+		e.pending = e.buffer
+	}
+
+	e.historyIndex = index
+
+	if index == len(e.history) {
+		e.buffer = e.pending
+	} else {
+		e.buffer = []rune(e.history[index])
+	}
+
+	e.cursor = len(e.buffer)
+}
+
+// Complete the word before the cursor: insert the candidate if it is unique, or their common prefix.
+// If there is nothing to insert, list the candidates.
+func (e *lineEditor) completeWord() {
+	e.lock.Lock()
+	line := string(e.buffer[:e.cursor])
+	e.lock.Unlock()
+
+	// May query the gateway, so do not hold the lock
+	word, candidates := e.complete(line)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	wordLen := utf8.RuneCountInString(word)
+
+	switch len(candidates) {
+	case 0:
+		fmt.Fprintf(e.out, "\a")
+		return
+
+	case 1:
+		e.deleteRunes(e.cursor-wordLen, e.cursor)
+		e.insert(candidates[0] + " ")
+
+	default:
+		prefix := commonPrefix(candidates)
+		if utf8.RuneCountInString(prefix) > wordLen {
+			e.deleteRunes(e.cursor-wordLen, e.cursor)
+			e.insert(prefix)
+		} else {
+			fmt.Fprintf(e.out, "\r\x1b[K%s\n", strings.Join(candidates, "  "))
+		}
+	}
+
+	e.refresh()
+}
+
+// Longest common prefix, case insensitive. The case of the first string is kept
+func commonPrefix(values []string) string {
+	prefix := []rune(values[0])
+
+	for _, value := range values[1:] {
+		runes := []rune(value)
+		length := 0
+		for length < len(prefix) && length < len(runes) && unicode.ToLower(prefix[length]) == unicode.ToLower(runes[length]) {
+			length++
+		}
+
+		prefix = prefix[:length]
+	}
+
+	return string(prefix)
+}
+
+// Redraw the prompt and the line, and place the cursor
+func (e *lineEditor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.buffer))
+
+	if back := len(e.buffer) - e.cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mylife-home/klf200-go"
+)
+
+// Logs on standard error. Debug and info messages are only printed in verbose mode
+type logger struct {
+	verbose bool
+	err     error
+}
+
+func newLogger(verbose bool) *logger {
+	return &logger{verbose: verbose}
+}
+
+func (l *logger) Debugf(format string, args ...interface{}) {
+	l.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Infof(format string, args ...interface{}) {
+	l.Info(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Warnf(format string, args ...interface{}) {
+	l.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Errorf(format string, args ...interface{}) {
+	l.Error(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Debug(msg string) {
+	if l.verbose {
+		l.print("DEBUG", msg)
+	}
+}
+
+func (l *logger) Info(msg string) {
+	if l.verbose {
+		l.print("INFO ", msg)
+	}
+}
+
+func (l *logger) Warn(msg string) {
+	if l.verbose {
+		l.print("WARN ", msg)
+	}
+}
+
+func (l *logger) Error(msg string) {
+	if l.verbose {
+		l.print("ERROR", msg)
+	}
+}
+
+func (l *logger) print(level string, msg string) {
+	if l.err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", level, msg, l.err)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s %s\n", level, msg)
+	}
+}
+
+func (l *logger) WithError(err error) klf200.Logger {
+	return &logger{verbose: l.verbose, err: err}
+}
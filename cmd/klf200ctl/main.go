@@ -0,0 +1,128 @@
+// Command-line tool for the KLF 200 gateway.
+//
+// Usage:
+//
+//	klf200ctl [flags] <command> [arguments]
+//
+// Run 'klf200ctl help' for the list of commands.
+//
+// The gateway address and password are taken, by order of precedence, from the -address and -password flags,
+// from the KLF200_ADDRESS and KLF200_PASSWORD environment variables, or from the configuration file
+// (-config, defaults to klf200ctl.json in the user configuration directory):
+//
+//	{ "address": "192.168.0.10:51200", "password": "velux123" }
+//
+// The gateway certificate is not verified unless its fingerprint is given with -fingerprint, KLF200_FINGERPRINT
+// or the "fingerprint" key of the configuration file. Run 'klf200ctl fingerprint' to display it.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// Error which only requires to print the usage
+var errUsage = errors.New("usage")
+
+func main() {
+	var settings settings
+
+	flag.StringVar(&settings.Address, "address", "", "gateway address (host[:port])")
+	flag.StringVar(&settings.Password, "password", "", "gateway password")
+	flag.StringVar(&settings.Fingerprint, "fingerprint", "", "SHA-256 fingerprint of the gateway certificate, to refuse other certificates (see the fingerprint command)")
+	flag.StringVar(&settings.configFile, "config", "", "configuration file (default: klf200ctl.json in the user configuration directory)")
+	flag.BoolVar(&settings.json, "json", false, "JSON output")
+	flag.BoolVar(&settings.verbose, "v", false, "verbose logs on standard error")
+	flag.DurationVar(&settings.timeout, "timeout", time.Second*30, "timeout of the command (not applied to watch and shell)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, &settings, flag.Args())
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: klf200ctl [flags] <command> [arguments]\n\nCommands:\n")
+	printCommands(out, commandList)
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func run(ctx context.Context, settings *settings, args []string) error {
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return fmt.Errorf("%w: unknown command '%s'", errUsage, args[0])
+	}
+
+	out := newOutput(os.Stdout, settings.json)
+
+	if cmd.offline {
+		return cmd.run(ctx, &env{out: out, settings: settings, timeout: settings.timeout}, args[1:])
+	}
+
+	if err := settings.resolve(); err != nil {
+		return err
+	}
+
+	conn, err := connect(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if !cmd.stream {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.timeout)
+		defer cancel()
+	}
+
+	cmdEnv := &env{client: conn, out: out, timeout: settings.timeout}
+	if isTerminal(int(os.Stdin.Fd())) {
+		cmdEnv.confirm = askTerminal
+	}
+
+	return cmd.run(ctx, cmdEnv, args[1:])
+}
+
+// Ask the question on the terminal, only "y" or "yes" confirms
+func askTerminal(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/simulator"
+)
+
+const testTimeout = time.Second * 5
+
+// Environment connected to a simulator with named nodes and scenes
+func startEnv(t *testing.T) *env {
+	t.Helper()
+
+	gw, err := simulator.Start(simulator.Config{
+		Nodes: []simulator.NodeConfig{
+			{Index: 0, Name: "Kitchen", NodeType: commands.NodeTypeWindowOpener},
+			{Index: 4, Name: "Living Room", NodeType: commands.NodeTypeRollerShutter},
+		},
+		Scenes: []simulator.SceneConfig{
+			{ID: 2, Name: "Night"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(gw.Close)
+
+	client, err := connect(context.Background(), &settings{Address: gw.Address(), Password: gw.Password(), timeout: testTimeout})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(client.Close)
+
+	return &env{client: client, out: newOutput(io.Discard, false), timeout: testTimeout}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestResolveNode(t *testing.T) {
+	env := startEnv(t)
+	ctx := testContext(t)
+
+	tests := map[string]int{
+		"0":           0,
+		"4":           4,
+		"7":           7, // indexes are not checked
+		"kitchen":     0,
+		"LIVING ROOM": 4,
+	}
+
+	for arg, expected := range tests {
+		index, err := resolveNode(ctx, env, arg)
+		if err != nil || index != expected {
+			t.Errorf("%q: got %d, %v", arg, index, err)
+		}
+	}
+
+	if _, err := resolveNode(ctx, env, "Garage"); err == nil {
+		t.Error("unknown node resolved")
+	}
+}
+
+func TestResolveScene(t *testing.T) {
+	env := startEnv(t)
+	ctx := testContext(t)
+
+	for _, arg := range []string{"2", "night", "Night"} {
+		id, err := resolveScene(ctx, env, arg)
+		if err != nil || id != 2 {
+			t.Errorf("%q: got %d, %v", arg, id, err)
+		}
+	}
+
+	if _, err := resolveScene(ctx, env, "Morning"); err == nil {
+		t.Error("unknown scene resolved")
+	}
+}
+
+func TestRunState(t *testing.T) {
+	env := startEnv(t)
+
+	text := &bytes.Buffer{}
+	env.out = newOutput(text, false)
+
+	if err := runState(testContext(t), env, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(text.String(), "GatewayStateGatewayModeWithActuator") || !strings.Contains(text.String(), "GatewaySubStateIdle") {
+		t.Errorf("got output:\n%s", text)
+	}
+}
+
+func TestRebootConfirmation(t *testing.T) {
+	env := startEnv(t)
+	ctx := testContext(t)
+
+	// No terminal to ask on
+	if err := runReboot(ctx, env, nil); !errors.Is(err, errUsage) {
+		t.Errorf("without confirmation: got %v", err)
+	}
+
+	var questions int
+	env.confirm = func(question string) bool {
+		questions++
+		return false
+	}
+
+	if err := runReboot(ctx, env, nil); !errors.Is(err, errUsage) || questions != 1 {
+		t.Errorf("refused: got %v after %d questions", err, questions)
+	}
+
+	if err := runReboot(ctx, env, []string{"--force"}); !errors.Is(err, errUsage) {
+		t.Errorf("bad argument: got %v", err)
+	}
+
+	// The gateway was not rebooted
+	if _, err := env.client.Device().GetStateContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runReboot(ctx, env, []string{"--yes"}); err != nil {
+		t.Errorf("--yes: got %v", err)
+	}
+
+	if questions != 1 {
+		t.Errorf("asked %d times", questions)
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	if err := checkArgs([]string{"a"}, 1, 2, "<x> [y]"); err != nil {
+		t.Error(err)
+	}
+
+	for _, args := range [][]string{nil, {"a", "b", "c"}} {
+		if err := checkArgs(args, 1, 2, "<x> [y]"); !errors.Is(err, errUsage) {
+			t.Errorf("%q: got %v", args, err)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := map[string][]string{
+		"":                        {},
+		"  nodes  ":               {"nodes"},
+		"move kitchen 50":         {"move", "kitchen", "50"},
+		`move "Living Room" 50`:   {"move", "Living Room", "50"},
+		`scene activate "" extra`: {"scene", "activate", "", "extra"},
+		`move Living" "Room 50`:   {"move", "Living Room", "50"},
+		"notifications\ton\t":     {"notifications", "on"},
+	}
+
+	for line, expected := range tests {
+		args, err := splitArgs(line)
+		if err != nil || !reflect.DeepEqual(args, expected) {
+			t.Errorf("%q: got %q, %v", line, args, err)
+		}
+	}
+
+	if _, err := splitArgs(`move "Living Room`); !errors.Is(err, errUsage) {
+		t.Errorf("unterminated quote: got %v", err)
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	for _, cmd := range commandList {
+		if findCommand(cmd.name) != cmd {
+			t.Errorf("%s not found", cmd.name)
+		}
+	}
+
+	if findCommand("unknown") != nil {
+		t.Error("unknown command found")
+	}
+}
+
+func TestSettingsResolve(t *testing.T) {
+	t.Setenv("KLF200_ADDRESS", "")
+	t.Setenv("KLF200_PASSWORD", "")
+	t.Setenv("KLF200_FINGERPRINT", "")
+
+	path := filepath.Join(t.TempDir(), configFileName)
+	if err := os.WriteFile(path, []byte(`{ "address": "192.168.0.10", "password": "velux123", "fingerprint": "ab:cd" }`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// From the configuration file, with the default port
+	s := &settings{configFile: path}
+	if err := s.resolve(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Address != "192.168.0.10:51200" || s.Password != "velux123" || s.Fingerprint != "ab:cd" {
+		t.Errorf("got %+v", s)
+	}
+
+	// Flags, then environment, take precedence
+	t.Setenv("KLF200_PASSWORD", "fromenv")
+
+	s = &settings{configFile: path, Address: "gateway:1234"}
+	if err := s.resolve(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Address != "gateway:1234" || s.Password != "fromenv" {
+		t.Errorf("got %+v", s)
+	}
+
+	// Missing password
+	os.WriteFile(path, []byte(`{ "address": "192.168.0.10" }`), 0o600)
+	t.Setenv("KLF200_PASSWORD", "")
+
+	if err := (&settings{configFile: path}).resolve(); !errors.Is(err, errUsage) {
+		t.Errorf("without password: got %v", err)
+	}
+
+	// An explicit configuration file must exist
+	if err := (&settings{configFile: path + ".missing"}).resolve(); err == nil || errors.Is(err, errUsage) {
+		t.Errorf("missing configuration file: got %v", err)
+	}
+
+	if err := (&settings{configFile: path}).resolveAddress(); err != nil {
+		t.Errorf("resolveAddress does not require the password: got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+// Prints results either as text or as JSON (one document per line, so that streams can be parsed)
+type output struct {
+	writer io.Writer
+	json   bool
+}
+
+func newOutput(writer io.Writer, json bool) *output {
+	return &output{writer: writer, json: json}
+}
+
+// Print the value as JSON, or call text to print it in a table
+func (out *output) print(value interface{}, text func(table io.Writer)) error {
+	if out.json {
+		return json.NewEncoder(out.writer).Encode(value)
+	}
+
+	table := tabwriter.NewWriter(out.writer, 0, 4, 2, ' ', 0)
+	text(table)
+	return table.Flush()
+}
+
+// Position in percent, nil if not an absolute position
+func percent(value commands.MPValue) *int {
+	if ok, percent := value.Absolute(); ok {
+		return &percent
+	}
+
+	return nil
+}
+
+func nodePercent(position commands.NodePosition) *int {
+	if position == commands.NodePositionUnknown {
+		return nil
+	}
+
+	return percent(commands.MPValue(position))
+}
+
+func formatPercent(percent *int) string {
+	if percent == nil {
+		return "-"
+	}
+
+	return fmt.Sprintf("%d%%", *percent)
+}
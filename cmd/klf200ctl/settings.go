@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mylife-home/klf200-go"
+)
+
+// Default TCP port of the gateway API
+const defaultPort = "51200"
+
+const configFileName = "klf200ctl.json"
+
+type settings struct {
+	Address     string `json:"address"`
+	Password    string `json:"password"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	configFile string
+	json       bool
+	verbose    bool
+	timeout    time.Duration
+}
+
+// Fill the credentials from the environment and the configuration file, if not given as flags
+func (s *settings) resolve() error {
+	if err := s.resolveAddress(); err != nil {
+		return err
+	}
+
+	if s.Password == "" {
+		return fmt.Errorf("%w: no gateway password (use -password, KLF200_PASSWORD or the configuration file)", errUsage)
+	}
+
+	return nil
+}
+
+// Same as resolve, but the password is not required
+func (s *settings) resolveAddress() error {
+	if s.Address == "" {
+		s.Address = os.Getenv("KLF200_ADDRESS")
+	}
+
+	if s.Password == "" {
+		s.Password = os.Getenv("KLF200_PASSWORD")
+	}
+
+	if s.Fingerprint == "" {
+		s.Fingerprint = os.Getenv("KLF200_FINGERPRINT")
+	}
+
+	if s.Address == "" || s.Password == "" || s.Fingerprint == "" {
+		file, err := s.readConfigFile()
+		if err != nil {
+			return err
+		}
+
+		if s.Address == "" {
+			s.Address = file.Address
+		}
+
+		if s.Password == "" {
+			s.Password = file.Password
+		}
+
+		if s.Fingerprint == "" {
+			s.Fingerprint = file.Fingerprint
+		}
+	}
+
+	if s.Address == "" {
+		return fmt.Errorf("%w: no gateway address (use -address, KLF200_ADDRESS or the configuration file)", errUsage)
+	}
+
+	if _, _, err := net.SplitHostPort(s.Address); err != nil {
+		s.Address = net.JoinHostPort(s.Address, defaultPort)
+	}
+
+	return nil
+}
+
+func (s *settings) readConfigFile() (*settings, error) {
+	path := s.configFile
+	explicit := path != ""
+
+	if !explicit {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return &settings{}, nil
+		}
+
+		path = filepath.Join(dir, configFileName)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return &settings{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	file := &settings{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("bad configuration file '%s': %w", path, err)
+	}
+
+	return file, nil
+}
+
+// Start the client and wait for the connection to be open
+func connect(ctx context.Context, s *settings) (*klf200.Client, error) {
+	options := []klf200.ClientOption{klf200.WithLogger(newLogger(s.verbose))}
+	if s.Fingerprint != "" {
+		options = append(options, klf200.WithPinnedCertificate(s.Fingerprint))
+	}
+
+	client := klf200.NewClient(s.Address, s.Password, options...)
+
+	// nil once open, or the authentication error: retrying would not help
+	result := make(chan error, 1)
+	client.RegisterStatusEvents(func(event klf200.StatusEvent) {
+		var err error
+		switch {
+		case event.Status == klf200.ConnectionOpen:
+		case klf200.IsAuthFailure(event.Err):
+			err = event.Err
+		default:
+			return
+		}
+
+		select {
+		case result <- err:
+		default:
+		}
+	})
+
+	client.Start()
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("could not connect to '%s': %w", s.Address, err)
+		}
+
+		return client, nil
+
+	case <-ctx.Done():
+		// Closing resets the status error
+		last := client.LastStatusEvent()
+		client.Close()
+
+		if err := last.Err; err != nil {
+			return nil, fmt.Errorf("could not connect to '%s': %w", s.Address, err)
+		}
+
+		return nil, fmt.Errorf("could not connect to '%s'", s.Address)
+	}
+}
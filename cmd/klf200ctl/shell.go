@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+const shellPrompt = "klf200> "
+
+// Node and scene names used for completion are reloaded after this delay
+const namesLifetime = time.Minute
+
+// Commands only available in the shell
+var shellCommands = []*command{
+	{name: "notifications", args: "[on | off | <notification>...]", help: "Show all, none or only some notifications"},
+	{name: "exit", help: "Leave the shell (or Ctrl-D)"},
+}
+
+type shell struct {
+	client  *klf200.Client
+	editor  *lineEditor
+	out     *output
+	timeout time.Duration
+
+	lock          sync.Mutex
+	notifications bool
+	filter        map[transport.Command]struct{} // nil shows all
+
+	names      []string
+	scenes     []string
+	namesStamp time.Time
+}
+
+func runShell(ctx context.Context, env *env, args []string) error {
+	if err := checkArgs(args, 0, 0, "no argument"); err != nil {
+		return err
+	}
+
+	sh := &shell{
+		client:        env.client,
+		timeout:       env.timeout,
+		notifications: true,
+	}
+
+	sh.editor = newLineEditor(os.Stdin, os.Stdout, sh.complete)
+	defer sh.editor.close()
+
+	sh.out = newOutput(sh.editor, env.out.json)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go sh.printNotifications(ctx)
+
+	for {
+		line, err := sh.editor.readLine(ctx, shellPrompt)
+		if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		args, err := splitArgs(line)
+		if err != nil {
+			sh.printError(err)
+			continue
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		if args[0] == "exit" || args[0] == "quit" {
+			return nil
+		}
+
+		sh.execute(ctx, args)
+	}
+}
+
+func (sh *shell) execute(ctx context.Context, args []string) {
+	switch args[0] {
+	case "help":
+		fmt.Fprintf(sh.editor, "Commands:\n")
+		printCommands(sh.editor, slices.Concat(sh.commands(), shellCommands))
+
+	case "notifications":
+		if err := sh.setNotifications(args[1:]); err != nil {
+			sh.printError(err)
+		}
+
+	default:
+		cmd := findCommand(args[0])
+		if cmd == nil || cmd.offline || cmd.interactive {
+			sh.printError(fmt.Errorf("%w: unknown command '%s' (try 'help')", errUsage, args[0]))
+			return
+		}
+
+		if err := sh.run(ctx, cmd, args[1:]); err != nil {
+			sh.printError(err)
+		}
+	}
+}
+
+// Run the command until it ends, it times out or Ctrl-C is typed
+func (sh *shell) run(ctx context.Context, cmd *command, args []string) error {
+	var cancel context.CancelFunc
+	if cmd.stream {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, sh.timeout)
+	}
+
+	defer cancel()
+
+	done := make(chan struct{})
+	var err error
+
+	go func() {
+		defer close(done)
+		err = cmd.run(ctx, &env{client: sh.client, out: sh.out, timeout: sh.timeout}, args)
+	}()
+
+	if sh.editor.interrupt(done) {
+		cancel()
+		<-done
+	}
+
+	return err
+}
+
+// Commands of the command line which make sense in the shell
+func (sh *shell) commands() []*command {
+	var list []*command
+
+	for _, cmd := range commandList {
+		if !cmd.offline && !cmd.interactive {
+			list = append(list, cmd)
+		}
+	}
+
+	return list
+}
+
+func (sh *shell) printError(err error) {
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(sh.editor, "%s\n", err)
+	} else {
+		fmt.Fprintf(sh.editor, "error: %s\n", err)
+	}
+}
+
+func (sh *shell) setNotifications(args []string) error {
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+
+	switch {
+	case len(args) == 0:
+		switch {
+		case !sh.notifications:
+			fmt.Fprintf(sh.editor, "notifications: off\n")
+		case sh.filter == nil:
+			fmt.Fprintf(sh.editor, "notifications: on\n")
+		default:
+			var names []string
+			for code := range sh.filter {
+				names = append(names, code.String())
+			}
+
+			slices.Sort(names)
+			fmt.Fprintf(sh.editor, "notifications: %s\n", strings.Join(names, " "))
+		}
+
+	case len(args) == 1 && args[0] == "on":
+		sh.notifications = true
+		sh.filter = nil
+
+	case len(args) == 1 && args[0] == "off":
+		sh.notifications = false
+
+	default:
+		filter := make(map[transport.Command]struct{})
+
+		for _, arg := range args {
+			code, ok := findNotifyCode(arg)
+			if !ok {
+				return fmt.Errorf("%w: unknown notification '%s'", errUsage, arg)
+			}
+
+			filter[code] = struct{}{}
+		}
+
+		sh.notifications = true
+		sh.filter = filter
+	}
+
+	return nil
+}
+
+func (sh *shell) showNotification(code transport.Command) bool {
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+
+	if !sh.notifications {
+		return false
+	}
+
+	if sh.filter == nil {
+		return true
+	}
+
+	_, found := sh.filter[code]
+	return found
+}
+
+func (sh *shell) printNotifications(ctx context.Context) {
+	notifications, unsubscribe := klf200.Subscribe[commands.Notify](sh.client, nil, klf200.WithOverflowPolicy(klf200.OverflowDropOldest))
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case notif := <-notifications:
+			if sh.showNotification(notif.Code()) {
+				printNotification(sh.out, notif)
+			}
+		}
+	}
+}
+
+func findNotifyCode(name string) (transport.Command, bool) {
+	for _, code := range commands.NotifyCodes() {
+		if strings.EqualFold(code.String(), name) {
+			return code, true
+		}
+	}
+
+	return 0, false
+}
+
+// Return the word before the cursor and its possible completions
+func (sh *shell) complete(line string) (string, []string) {
+	tokens, open := tokenize(line)
+
+	var word string
+	if len(tokens) > 0 && (open || !unicode.IsSpace(rune(line[len(line)-1]))) {
+		last := tokens[len(tokens)-1]
+		word = line[last.start:]
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	args := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		args = append(args, token.value)
+	}
+
+	prefix := strings.ToLower(strings.TrimPrefix(word, "\""))
+
+	var matches []string
+	for _, candidate := range sh.candidates(args) {
+		if strings.HasPrefix(strings.ToLower(candidate), prefix) {
+			matches = append(matches, quoteArg(candidate))
+		}
+	}
+
+	return word, matches
+}
+
+// Possible values of the argument following args
+func (sh *shell) candidates(args []string) []string {
+	if len(args) == 0 {
+		names := []string{"help", "quit"}
+		for _, cmd := range slices.Concat(sh.commands(), shellCommands) {
+			names = append(names, cmd.name)
+		}
+
+		slices.Sort(names)
+		return names
+	}
+
+	switch args[0] {
+	case "help":
+		if len(args) == 1 {
+			return sh.candidates(nil)
+		}
+
+	case "move", "stop":
+		if len(args) == 1 {
+			return sh.nodeNames()
+		}
+
+	case "status":
+		return sh.nodeNames()
+
+	case "scene":
+		switch {
+		case len(args) == 1:
+			return []string{"activate", "list", "stop"}
+		case len(args) == 2 && (args[1] == "activate" || args[1] == "stop"):
+			return sh.sceneNames()
+		}
+
+	case "time":
+		if len(args) == 1 {
+			return []string{"sync"}
+		}
+
+	case "notifications":
+		var names []string
+		if len(args) == 1 {
+			names = append(names, "off", "on")
+		}
+
+		for _, code := range commands.NotifyCodes() {
+			names = append(names, code.String())
+		}
+
+		return names
+	}
+
+	return nil
+}
+
+func (sh *shell) nodeNames() []string {
+	sh.loadNames()
+
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+
+	return sh.names
+}
+
+func (sh *shell) sceneNames() []string {
+	sh.loadNames()
+
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+
+	return sh.scenes
+}
+
+// Fetch the node and scene names from the gateway, if not done recently
+func (sh *shell) loadNames() {
+	sh.lock.Lock()
+	fresh := time.Since(sh.namesStamp) < namesLifetime
+	sh.lock.Unlock()
+
+	if fresh {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	var names, scenes []string
+
+	nodes, err := sh.client.Info().GetAllNodesInformation(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+
+	sceneList, err := sh.client.Scenes().GetSceneList(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, scene := range sceneList {
+		scenes = append(scenes, scene.Name)
+	}
+
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+
+	sh.names = names
+	sh.scenes = scenes
+	sh.namesStamp = time.Now()
+}
+
+type lineToken struct {
+	value string
+	start int
+}
+
+// Split a line into words separated by spaces. Double quotes group words containing spaces.
+// open is true if the last quote is not closed.
+func tokenize(line string) (tokens []lineToken, open bool) {
+	var current *lineToken
+	var value strings.Builder
+
+	for index, r := range line {
+		switch {
+		case r == '"':
+			if current == nil {
+				current = &lineToken{start: index}
+			}
+
+			open = !open
+
+		case unicode.IsSpace(r) && !open:
+			if current != nil {
+				current.value = value.String()
+				tokens = append(tokens, *current)
+				current = nil
+				value.Reset()
+			}
+
+		default:
+			if current == nil {
+				current = &lineToken{start: index}
+			}
+
+			value.WriteRune(r)
+		}
+	}
+
+	if current != nil {
+		current.value = value.String()
+		tokens = append(tokens, *current)
+	}
+
+	return tokens, open
+}
+
+func splitArgs(line string) ([]string, error) {
+	tokens, open := tokenize(line)
+	if open {
+		return nil, fmt.Errorf("%w: unterminated quote", errUsage)
+	}
+
+	args := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		args = append(args, token.value)
+	}
+
+	return args, nil
+}
+
+func quoteArg(arg string) string {
+	if strings.IndexFunc(arg, unicode.IsSpace) < 0 {
+		return arg
+	}
+
+	return "\"" + arg + "\""
+}
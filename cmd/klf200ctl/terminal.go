@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	keyCtrlA     = 0x01
+	keyCtrlB     = 0x02
+	keyCtrlC     = 0x03
+	keyCtrlD     = 0x04
+	keyCtrlE     = 0x05
+	keyCtrlF     = 0x06
+	keyCtrlH     = 0x08
+	keyTab       = 0x09
+	keyLineFeed  = 0x0a
+	keyCtrlK     = 0x0b
+	keyCtrlL     = 0x0c
+	keyEnter     = 0x0d
+	keyCtrlN     = 0x0e
+	keyCtrlP     = 0x10
+	keyCtrlU     = 0x15
+	keyCtrlW     = 0x17
+	keyEscape    = 0x1b
+	keyBackspace = 0x7f
+)
+
+// Reads keys from a terminal in raw mode
+type keyReader struct {
+	keys chan byte
+}
+
+func newKeyReader(in io.Reader) *keyReader {
+	r := &keyReader{keys: make(chan byte, 256)}
+	go r.worker(in)
+	return r
+}
+
+func (r *keyReader) worker(in io.Reader) {
+	buffer := make([]byte, 256)
+
+	for {
+		n, err := in.Read(buffer)
+		for _, key := range buffer[:n] {
+			r.keys <- key
+		}
+
+		if err != nil {
+			close(r.keys)
+			return
+		}
+	}
+}
+
+func (r *keyReader) readByte(ctx context.Context) (byte, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case key, ok := <-r.keys:
+		if !ok {
+			return 0, io.EOF
+		}
+
+		return key, nil
+	}
+}
+
+// A key is either a rune, or an escape sequence starting with keyEscape
+type key struct {
+	r        rune
+	sequence string
+}
+
+func (r *keyReader) readKey(ctx context.Context) (key, error) {
+	first, err := r.readByte(ctx)
+	if err != nil {
+		return key{}, err
+	}
+
+	if first == keyEscape {
+		return r.readEscapeSequence(ctx)
+	}
+
+	data := []byte{first}
+	for !utf8.FullRune(data) {
+		next, err := r.readByte(ctx)
+		if err != nil {
+			return key{}, err
+		}
+
+		data = append(data, next)
+	}
+
+	value, _ := utf8.DecodeRune(data)
+	return key{r: value}, nil
+}
+
+// Read a CSI ("ESC [") or SS3 ("ESC O") sequence, without the escape
+func (r *keyReader) readEscapeSequence(ctx context.Context) (key, error) {
+	introducer, err := r.readByte(ctx)
+	if err != nil {
+		return key{}, err
+	}
+
+	if introducer != '[' && introducer != 'O' {
+		return key{r: keyEscape, sequence: string(introducer)}, nil
+	}
+
+	var sequence strings.Builder
+	for {
+		next, err := r.readByte(ctx)
+		if err != nil {
+			return key{}, err
+		}
+
+		sequence.WriteByte(next)
+		if next >= 0x40 && next <= 0x7e {
+			return key{r: keyEscape, sequence: sequence.String()}, nil
+		}
+	}
+}
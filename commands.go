@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"reflect"
 	"sync/atomic"
 	"time"
 
@@ -29,31 +28,36 @@ func (cmds *Commands) newSessionId() int {
 }
 
 type Session struct {
-	id       int
-	notifier Notifier
-	ctx      context.Context
-	events   chan Event
+	trans   *sessionTransaction
+	ctx     context.Context
+	events  chan Event
+	started bool
 }
 
-func newSession(client *Client, id int, ctx context.Context) *Session {
-	notifier := client.RegisterNotifications([]reflect.Type{
-		reflect.TypeOf(&commands.CommandRemainingTimeNtf{}),
-		reflect.TypeOf(&commands.CommandRunStatusNtf{}),
-		reflect.TypeOf(&commands.SessionFinishedNtf{}),
-	})
-
-	sess := &Session{
-		id:       id,
-		notifier: notifier,
-		ctx:      ctx,
-		events:   make(chan Event, 100),
+// The session listens to its notifications before the request is executed, then it must be started or aborted.
+// setSessionId is called with the session id of each attempt of the request
+func newSession(client *Client, ctx context.Context, setSessionId func(sessionId int)) *Session {
+	return &Session{
+		trans:  newSessionTransaction(client, setSessionId),
+		ctx:    ctx,
+		events: make(chan Event, 100),
 	}
+}
 
+func (sess *Session) start() *Session {
+	sess.started = true
 	go sess.worker()
 
 	return sess
 }
 
+// Release the session if it has not been started
+func (sess *Session) abort() {
+	if !sess.started {
+		sess.trans.close()
+	}
+}
+
 func (sess *Session) Events() <-chan Event {
 	return sess.events
 }
@@ -62,7 +66,7 @@ func (sess *Session) worker() {
 
 	for {
 
-		notif, err := sess.selectNotif()
+		notif, err := sess.trans.next(sess.ctx)
 		if err != nil {
 			sess.events <- &RunError{err}
 			break
@@ -72,26 +76,20 @@ func (sess *Session) worker() {
 
 		switch notif := notif.(type) {
 		case *commands.CommandRunStatusNtf:
-			if sess.id == notif.SessionID {
-				sess.events <- &RunStatus{
-					StatusID:       notif.StatusID,
-					ParameterValue: commands.MPValue(notif.ParameterValue),
-					RunStatus:      notif.RunStatus,
-					StatusReply:    notif.StatusReply,
-				}
+			sess.events <- &RunStatus{
+				StatusID:       notif.StatusID,
+				ParameterValue: commands.MPValue(notif.ParameterValue),
+				RunStatus:      notif.RunStatus,
+				StatusReply:    notif.StatusReply,
 			}
 
 		case *commands.CommandRemainingTimeNtf:
-			if sess.id == notif.SessionID {
-				sess.events <- &RunRemainingTime{
-					Duration: notif.Duration,
-				}
+			sess.events <- &RunRemainingTime{
+				Duration: notif.Duration,
 			}
 
 		case *commands.SessionFinishedNtf:
-			if sess.id == notif.SessionID {
-				finished = true
-			}
+			finished = true
 		}
 
 		if finished {
@@ -100,7 +98,7 @@ func (sess *Session) worker() {
 	}
 
 	close(sess.events)
-	sess.notifier.Close()
+	sess.trans.close()
 }
 
 type Event interface {
@@ -121,23 +119,9 @@ type RunError struct {
 	Err error
 }
 
-func (sess *Session) selectNotif() (commands.Notify, error) {
-	select {
-	// TODO: handle disconnection
-	case <-sess.ctx.Done():
-		return nil, sess.ctx.Err()
-
-	case notif := <-sess.notifier.Stream():
-		return notif, nil
-	}
-}
-
 func (cmds *Commands) ChangePosition(ctx context.Context, nodeIndex int, position commands.MPValue) (*Session, error) {
-	sessionId := cmds.newSessionId()
-
 	// TODO: customize parameters
 	req := &commands.CommandSendReq{
-		SessionID:                 sessionId,
 		CommandOriginator:         commands.CommandOriginatorUser,
 		PriorityLevel:             commands.PriorityUserLevel2,
 		ParameterActive:           commands.FunctionalParameterMP,
@@ -148,7 +132,10 @@ func (cmds *Commands) ChangePosition(ctx context.Context, nodeIndex int, positio
 		LockTime:                  commands.NewLockTimeUnlimited(),
 	}
 
-	cfm, err := cmds.client.execute(req)
+	sess := newSession(cmds.client, ctx, func(sessionId int) { req.SessionID = sessionId })
+	defer sess.abort()
+
+	cfm, err := sess.trans.execute(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -159,19 +146,16 @@ func (cmds *Commands) ChangePosition(ctx context.Context, nodeIndex int, positio
 		return nil, errors.New("the request failed")
 	}
 
-	if tcfm.SessionID != sessionId {
+	if tcfm.SessionID != sess.trans.sessionId() {
 		return nil, errors.New("session id mismatch")
 	}
 
-	return newSession(cmds.client, sessionId, ctx), nil
+	return sess.start(), nil
 }
 
 func (cmds *Commands) Mode(ctx context.Context, nodeIndex int) (*Session, error) {
-	sessionId := cmds.newSessionId()
-
 	// TODO: customize parameters
 	req := &commands.ModeSendReq{
-		SessionID:         sessionId,
 		CommandOriginator: commands.CommandOriginatorUser,
 		PriorityLevel:     commands.PriorityUserLevel2,
 		ModeNumber:        0,
@@ -182,7 +166,10 @@ func (cmds *Commands) Mode(ctx context.Context, nodeIndex int) (*Session, error)
 		LockTime:          commands.NewLockTimeUnlimited(),
 	}
 
-	cfm, err := cmds.client.execute(req)
+	sess := newSession(cmds.client, ctx, func(sessionId int) { req.SessionID = sessionId })
+	defer sess.abort()
+
+	cfm, err := sess.trans.execute(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -193,32 +180,26 @@ func (cmds *Commands) Mode(ctx context.Context, nodeIndex int) (*Session, error)
 		return nil, fmt.Errorf("error : '%s'", tcfm.Status)
 	}
 
-	if tcfm.SessionID != sessionId {
+	if tcfm.SessionID != sess.trans.sessionId() {
 		return nil, errors.New("session id mismatch")
 	}
 
-	return newSession(cmds.client, sessionId, ctx), nil
+	return sess.start(), nil
 }
 
 func (cmds *Commands) Status(ctx context.Context, nodeIndexes []int) ([]*StatusData, error) {
-	sessionId := cmds.newSessionId()
-
 	// TODO: customize parameters
 	req := &commands.StatusRequestReq{
-		SessionID:            sessionId,
 		NodeIndexes:          nodeIndexes,
 		StatusType:           commands.StatusRequestMainInfo,
 		FunctionalParameters: make(map[commands.FunctionalParameter]bool),
 	}
 
-	n := cmds.client.RegisterNotifications([]reflect.Type{
-		reflect.TypeOf(&commands.StatusRequestNtf{}),
-		reflect.TypeOf(&commands.SessionFinishedNtf{}),
-	})
+	trans := newSessionTransaction(cmds.client, func(sessionId int) { req.SessionID = sessionId })
 
-	defer n.Close()
+	defer trans.close()
 
-	cfm, err := cmds.client.execute(req)
+	cfm, err := trans.execute(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -229,14 +210,14 @@ func (cmds *Commands) Status(ctx context.Context, nodeIndexes []int) ([]*StatusD
 		return nil, errors.New("the request failed")
 	}
 
-	if tcfm.SessionID != sessionId {
+	if tcfm.SessionID != trans.sessionId() {
 		return nil, errors.New("session id mismatch")
 	}
 
 	data := make([]*StatusData, 0, len(nodeIndexes))
 
 	for {
-		notif, err := cmds.selectStatusNotif(ctx, n)
+		notif, err := trans.next(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -245,7 +226,7 @@ func (cmds *Commands) Status(ctx context.Context, nodeIndexes []int) ([]*StatusD
 
 		switch notif := notif.(type) {
 		case *commands.StatusRequestNtf:
-			if sessionId == notif.SessionID {
+			if trans.sessionId() == notif.SessionID {
 
 				statusData := &StatusData{
 					NodeIndex:   notif.NodeIndex,
@@ -269,7 +250,7 @@ func (cmds *Commands) Status(ctx context.Context, nodeIndexes []int) ([]*StatusD
 			}
 
 		case *commands.SessionFinishedNtf:
-			if sessionId == notif.SessionID {
+			if trans.sessionId() == notif.SessionID {
 				finished = true
 			}
 		}
@@ -294,15 +275,4 @@ type StatusData struct {
 	LastCommandOriginator      commands.CommandOriginator
 }
 
-func (cmds *Commands) selectStatusNotif(ctx context.Context, n Notifier) (commands.Notify, error) {
-	select {
-	// TODO: handle disconnection
-	case <-ctx.Done():
-		return nil, ctx.Err()
-
-	case notif := <-n.Stream():
-		return notif, nil
-	}
-}
-
 // TODO: missing API
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/binary"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type ActivateSceneReq struct {
+	SessionID         int
+	CommandOriginator CommandOriginator
+	PriorityLevel     PriorityLevel
+	SceneID           int
+	Velocity          Velocity
+}
+
+var _ Request = (*ActivateSceneReq)(nil)
+var _ SessionCommand = (*ActivateSceneReq)(nil)
+
+func init() {
+	registerRequest(func() Request { return &ActivateSceneReq{} })
+}
+
+func (req *ActivateSceneReq) Code() transport.Command {
+	return transport.GW_ACTIVATE_SCENE_REQ
+}
+
+func (req *ActivateSceneReq) GetSessionID() int {
+	return req.SessionID
+}
+
+func (req *ActivateSceneReq) SetSessionID(id int) {
+	req.SessionID = id
+}
+
+func (req *ActivateSceneReq) NewConfirm() Confirm {
+	return &ActivateSceneCfm{}
+}
+
+func (req *ActivateSceneReq) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(req.SessionID))
+	writer.WriteU8(uint8(req.CommandOriginator))
+	writer.WriteU8(uint8(req.PriorityLevel))
+	writer.WriteU8(uint8(req.SceneID))
+	writer.WriteU8(uint8(req.Velocity))
+
+	return buff.Bytes(), nil
+}
+
+func (req *ActivateSceneReq) Read(data []byte) error {
+	if len(data) != 6 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+	var u16 uint16
+
+	u16, _ = reader.ReadU16()
+	req.SessionID = int(u16)
+
+	u8, _ = reader.ReadU8()
+	req.CommandOriginator = CommandOriginator(u8)
+
+	u8, _ = reader.ReadU8()
+	req.PriorityLevel = PriorityLevel(u8)
+
+	u8, _ = reader.ReadU8()
+	req.SceneID = int(u8)
+
+	u8, _ = reader.ReadU8()
+	req.Velocity = Velocity(u8)
+
+	return nil
+}
+
+type SceneStatus int
+
+// OK - Request accepted
+const SceneStatusSuccess SceneStatus = 0
+
+// Error – Invalid parameter
+const SceneStatusInvalidParameter SceneStatus = 1
+
+// Error – Request rejected
+const SceneStatusRejected SceneStatus = 2
+
+func (s SceneStatus) String() string {
+	switch s {
+	case SceneStatusSuccess:
+		return "SceneStatusSuccess"
+	case SceneStatusInvalidParameter:
+		return "SceneStatusInvalidParameter"
+	case SceneStatusRejected:
+		return "SceneStatusRejected"
+	default:
+		return fmt.Sprintf("<%d>", s)
+	}
+}
+
+type ActivateSceneCfm struct {
+	Status    SceneStatus
+	SessionID int
+}
+
+var _ Confirm = (*ActivateSceneCfm)(nil)
+var _ SessionCommand = (*ActivateSceneCfm)(nil)
+
+func (cfm *ActivateSceneCfm) Code() transport.Command {
+	return transport.GW_ACTIVATE_SCENE_CFM
+}
+
+func (cfm *ActivateSceneCfm) GetSessionID() int {
+	return cfm.SessionID
+}
+
+func (cfm *ActivateSceneCfm) SetSessionID(id int) {
+	cfm.SessionID = id
+}
+
+func (cfm *ActivateSceneCfm) Read(data []byte) error {
+	return readSceneCfm(data, &cfm.Status, &cfm.SessionID)
+}
+
+func (cfm *ActivateSceneCfm) Write() ([]byte, error) {
+	return writeSceneCfm(cfm.Status, cfm.SessionID)
+}
+
+type StopSceneReq struct {
+	SessionID         int
+	CommandOriginator CommandOriginator
+	PriorityLevel     PriorityLevel
+	SceneID           int
+}
+
+var _ Request = (*StopSceneReq)(nil)
+var _ SessionCommand = (*StopSceneReq)(nil)
+
+func init() {
+	registerRequest(func() Request { return &StopSceneReq{} })
+}
+
+func (req *StopSceneReq) Code() transport.Command {
+	return transport.GW_STOP_SCENE_REQ
+}
+
+func (req *StopSceneReq) GetSessionID() int {
+	return req.SessionID
+}
+
+func (req *StopSceneReq) SetSessionID(id int) {
+	req.SessionID = id
+}
+
+func (req *StopSceneReq) NewConfirm() Confirm {
+	return &StopSceneCfm{}
+}
+
+func (req *StopSceneReq) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(req.SessionID))
+	writer.WriteU8(uint8(req.CommandOriginator))
+	writer.WriteU8(uint8(req.PriorityLevel))
+	writer.WriteU8(uint8(req.SceneID))
+
+	return buff.Bytes(), nil
+}
+
+func (req *StopSceneReq) Read(data []byte) error {
+	if len(data) != 5 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+	var u16 uint16
+
+	u16, _ = reader.ReadU16()
+	req.SessionID = int(u16)
+
+	u8, _ = reader.ReadU8()
+	req.CommandOriginator = CommandOriginator(u8)
+
+	u8, _ = reader.ReadU8()
+	req.PriorityLevel = PriorityLevel(u8)
+
+	u8, _ = reader.ReadU8()
+	req.SceneID = int(u8)
+
+	return nil
+}
+
+type StopSceneCfm struct {
+	Status    SceneStatus
+	SessionID int
+}
+
+var _ Confirm = (*StopSceneCfm)(nil)
+var _ SessionCommand = (*StopSceneCfm)(nil)
+
+func (cfm *StopSceneCfm) Code() transport.Command {
+	return transport.GW_STOP_SCENE_CFM
+}
+
+func (cfm *StopSceneCfm) GetSessionID() int {
+	return cfm.SessionID
+}
+
+func (cfm *StopSceneCfm) SetSessionID(id int) {
+	cfm.SessionID = id
+}
+
+func (cfm *StopSceneCfm) Read(data []byte) error {
+	return readSceneCfm(data, &cfm.Status, &cfm.SessionID)
+}
+
+func (cfm *StopSceneCfm) Write() ([]byte, error) {
+	return writeSceneCfm(cfm.Status, cfm.SessionID)
+}
+
+func readSceneCfm(data []byte, status *SceneStatus, sessionID *int) error {
+	if len(data) != 3 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+
+	u8, _ := reader.ReadU8()
+	*status = SceneStatus(u8)
+
+	u16, _ := reader.ReadU16()
+	*sessionID = int(u16)
+
+	return nil
+}
+
+func writeSceneCfm(status SceneStatus, sessionID int) ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU8(uint8(status))
+	writer.WriteU16(uint16(sessionID))
+
+	return buff.Bytes(), nil
+}
@@ -1,25 +1,46 @@
 package commands
 
-import "github.com/mylife-home/klf200-go/transport"
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/mylife-home/klf200-go/binary"
+	"github.com/mylife-home/klf200-go/transport"
+)
 
 type Command interface {
 	Code() transport.Command
 }
 
+// Read on requests and Write on confirms/notifications are the gateway side of the protocol.
+// They are used by the simulator and the dissector.
+
 type Request interface {
 	Command
 	NewConfirm() Confirm
 	Write() ([]byte, error)
+	Read(data []byte) error
 }
 
 type Confirm interface {
 	Command
 	Read(data []byte) error
+	Write() ([]byte, error)
 }
 
 type Notify interface {
 	Command
 	Read(data []byte) error
+	Write() ([]byte, error)
+}
+
+// Request, confirm or notification which belongs to a session (GW_COMMAND_SEND_REQ, GW_SESSION_FINISHED_NTF, ...).
+// The methods are named after the SessionID field of the implementations
+type SessionCommand interface {
+	Command
+	GetSessionID() int
+	SetSessionID(id int)
 }
 
 var notifyRegistry = make(map[transport.Command]func() Notify)
@@ -38,4 +59,71 @@ func GetNotify(code transport.Command) Notify {
 	return builder()
 }
 
+// Codes of all the known notifications, sorted
+func NotifyCodes() []transport.Command {
+	codes := make([]transport.Command, 0, len(notifyRegistry))
+	for code := range notifyRegistry {
+		codes = append(codes, code)
+	}
+
+	slices.Sort(codes)
+	return codes
+}
+
+var requestRegistry = make(map[transport.Command]func() Request)
+var confirmRegistry = make(map[transport.Command]func() Confirm)
+
+func registerRequest(builder func() Request) {
+	req := builder()
+	requestRegistry[req.Code()] = builder
+	confirmRegistry[req.NewConfirm().Code()] = func() Confirm { return builder().NewConfirm() }
+}
+
+// Codes of all the known requests, sorted
+func RequestCodes() []transport.Command {
+	codes := make([]transport.Command, 0, len(requestRegistry))
+	for code := range requestRegistry {
+		codes = append(codes, code)
+	}
+
+	slices.Sort(codes)
+	return codes
+}
+
+func GetRequest(code transport.Command) Request {
+	builder, ok := requestRegistry[code]
+	if !ok {
+		return nil
+	}
+
+	return builder()
+}
+
+func GetConfirm(code transport.Command) Confirm {
+	builder, ok := confirmRegistry[code]
+	if !ok {
+		return nil
+	}
+
+	return builder()
+}
+
 var emptyData = make([]byte, 0)
+
+// Read a zero-padded UTF-8 string field
+func readString(reader binary.BinaryReader, size int) string {
+	data := make([]byte, size)
+	reader.Read(data)
+	return string(bytes.TrimRight(data, "\x00"))
+}
+
+// Write a zero-padded UTF-8 string field
+func writeString(writer binary.BinaryWriter, value string, size int) error {
+	data := []byte(value)
+	if len(data) > size {
+		return fmt.Errorf("string too long (got %d bytes, max %d)", len(data), size)
+	}
+
+	data = append(data, make([]byte, size-len(data))...)
+	return writer.Write(data)
+}
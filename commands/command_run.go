@@ -201,6 +201,7 @@ const CommandRunStatusReplyLimitationByAutomaticCycle CommandRunStatusReply = 0x
 const CommandRunStatusReplyLimitationByEmergency CommandRunStatusReply = 0xEE
 
 var _ Notify = (*CommandRunStatusNtf)(nil)
+var _ SessionCommand = (*CommandRunStatusNtf)(nil)
 
 func init() {
 	registerNotify(func() Notify { return &CommandRunStatusNtf{} })
@@ -210,6 +211,14 @@ func (ntf *CommandRunStatusNtf) Code() transport.Command {
 	return transport.GW_COMMAND_RUN_STATUS_NTF
 }
 
+func (ntf *CommandRunStatusNtf) GetSessionID() int {
+	return ntf.SessionID
+}
+
+func (ntf *CommandRunStatusNtf) SetSessionID(id int) {
+	ntf.SessionID = id
+}
+
 func (ntf *CommandRunStatusNtf) Read(data []byte) error {
 	if len(data) != 13 {
 		return fmt.Errorf("bad length")
@@ -247,6 +256,22 @@ func (ntf *CommandRunStatusNtf) Read(data []byte) error {
 	return nil
 }
 
+func (ntf *CommandRunStatusNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(ntf.SessionID))
+	writer.WriteU8(uint8(ntf.StatusID))
+	writer.WriteU8(uint8(ntf.NodeIndex))
+	writer.WriteU8(uint8(ntf.NodeParameter))
+	writer.WriteU16(uint16(ntf.ParameterValue))
+	writer.WriteU8(uint8(ntf.RunStatus))
+	writer.WriteU8(uint8(ntf.StatusReply))
+	writer.WriteU32(ntf.InformationCode)
+
+	return buff.Bytes(), nil
+}
+
 type CommandRemainingTimeNtf struct {
 	SessionID     int
 	NodeIndex     int
@@ -255,6 +280,7 @@ type CommandRemainingTimeNtf struct {
 }
 
 var _ Notify = (*CommandRemainingTimeNtf)(nil)
+var _ SessionCommand = (*CommandRemainingTimeNtf)(nil)
 
 func init() {
 	registerNotify(func() Notify { return &CommandRemainingTimeNtf{} })
@@ -264,6 +290,14 @@ func (ntf *CommandRemainingTimeNtf) Code() transport.Command {
 	return transport.GW_COMMAND_REMAINING_TIME_NTF
 }
 
+func (ntf *CommandRemainingTimeNtf) GetSessionID() int {
+	return ntf.SessionID
+}
+
+func (ntf *CommandRemainingTimeNtf) SetSessionID(id int) {
+	ntf.SessionID = id
+}
+
 func (ntf *CommandRemainingTimeNtf) Read(data []byte) error {
 	if len(data) != 6 {
 		return fmt.Errorf("bad length")
@@ -288,11 +322,24 @@ func (ntf *CommandRemainingTimeNtf) Read(data []byte) error {
 	return nil
 }
 
+func (ntf *CommandRemainingTimeNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(ntf.SessionID))
+	writer.WriteU8(uint8(ntf.NodeIndex))
+	writer.WriteU8(uint8(ntf.NodeParameter))
+	writer.WriteU16(uint16(ntf.Duration / time.Second))
+
+	return buff.Bytes(), nil
+}
+
 type SessionFinishedNtf struct {
 	SessionID int
 }
 
 var _ Notify = (*SessionFinishedNtf)(nil)
+var _ SessionCommand = (*SessionFinishedNtf)(nil)
 
 func init() {
 	registerNotify(func() Notify { return &SessionFinishedNtf{} })
@@ -302,6 +349,14 @@ func (ntf *SessionFinishedNtf) Code() transport.Command {
 	return transport.GW_SESSION_FINISHED_NTF
 }
 
+func (ntf *SessionFinishedNtf) GetSessionID() int {
+	return ntf.SessionID
+}
+
+func (ntf *SessionFinishedNtf) SetSessionID(id int) {
+	ntf.SessionID = id
+}
+
 func (ntf *SessionFinishedNtf) Read(data []byte) error {
 	if len(data) != 2 {
 		return fmt.Errorf("bad length")
@@ -316,6 +371,15 @@ func (ntf *SessionFinishedNtf) Read(data []byte) error {
 	return nil
 }
 
+func (ntf *SessionFinishedNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(ntf.SessionID))
+
+	return buff.Bytes(), nil
+}
+
 func (value CommandRunOwner) String() string {
 	switch value {
 	case CommandRunOwnerUser:
@@ -22,11 +22,24 @@ type CommandSendReq struct {
 }
 
 var _ Request = (*CommandSendReq)(nil)
+var _ SessionCommand = (*CommandSendReq)(nil)
+
+func init() {
+	registerRequest(func() Request { return &CommandSendReq{} })
+}
 
 func (req *CommandSendReq) Code() transport.Command {
 	return transport.GW_COMMAND_SEND_REQ
 }
 
+func (req *CommandSendReq) GetSessionID() int {
+	return req.SessionID
+}
+
+func (req *CommandSendReq) SetSessionID(id int) {
+	req.SessionID = id
+}
+
 func (req *CommandSendReq) NewConfirm() Confirm {
 	return &CommandSendCfm{}
 }
@@ -96,17 +109,85 @@ func (req *CommandSendReq) Write() ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
+func (req *CommandSendReq) Read(data []byte) error {
+	if len(data) != 66 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+	var u16 uint16
+
+	u16, _ = reader.ReadU16()
+	req.SessionID = int(u16)
+
+	u8, _ = reader.ReadU8()
+	req.CommandOriginator = CommandOriginator(u8)
+
+	u8, _ = reader.ReadU8()
+	req.PriorityLevel = PriorityLevel(u8)
+
+	u8, _ = reader.ReadU8()
+	req.ParameterActive = FunctionalParameter(u8)
+
+	bitmap, _ := reader.ReadU16()
+
+	req.FunctionalParameterValues = make(map[FunctionalParameter]int)
+
+	for index := 0; index < 17; index++ {
+		param := FunctionalParameter(index)
+		u16, _ = reader.ReadU16()
+
+		if param == FunctionalParameterMP || bitmap&(1<<(16-param)) != 0 {
+			req.FunctionalParameterValues[param] = int(u16)
+		}
+	}
+
+	u8, _ = reader.ReadU8()
+	if u8 < 1 || u8 > 20 {
+		return fmt.Errorf("bad node indexes len (got %d, expected > 0 && <= 20)", u8)
+	}
+
+	req.NodeIndexes = make([]int, u8)
+	for index := 0; index < 20; index++ {
+		value, _ := reader.ReadU8()
+		if index < len(req.NodeIndexes) {
+			req.NodeIndexes[index] = int(value)
+		}
+	}
+
+	u8, _ = reader.ReadU8()
+	req.PriorityLevelLock = PriorityLevelLock(u8)
+
+	u16, _ = reader.ReadU16()
+	req.PriorityLevelInfo = PriorityLevelInfo(u16)
+
+	u8, _ = reader.ReadU8()
+	req.LockTime = LockTime(u8)
+
+	return nil
+}
+
 type CommandSendCfm struct {
 	SessionID int
 	Success   bool
 }
 
 var _ Confirm = (*CommandSendCfm)(nil)
+var _ SessionCommand = (*CommandSendCfm)(nil)
 
 func (cfm *CommandSendCfm) Code() transport.Command {
 	return transport.GW_COMMAND_SEND_CFM
 }
 
+func (cfm *CommandSendCfm) GetSessionID() int {
+	return cfm.SessionID
+}
+
+func (cfm *CommandSendCfm) SetSessionID(id int) {
+	cfm.SessionID = id
+}
+
 func (cfm *CommandSendCfm) Read(data []byte) error {
 	if len(data) != 3 {
 		return fmt.Errorf("bad length")
@@ -131,3 +212,19 @@ func (cfm *CommandSendCfm) Read(data []byte) error {
 
 	return nil
 }
+
+func (cfm *CommandSendCfm) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(cfm.SessionID))
+
+	var status uint8 = 0
+	if cfm.Success {
+		status = 1
+	}
+
+	writer.WriteU8(status)
+
+	return buff.Bytes(), nil
+}
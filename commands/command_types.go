@@ -235,7 +235,6 @@ const PriorityLevelLockNewLock PriorityLevelLock = 1
 
 type PriorityLevelInfo uint16
 
-// TODO: test this accesses
 /*
 Bit 7-6 = PLI 0
 Bit 5-4 = PLI 1
@@ -252,36 +251,49 @@ func NewPriorityLevelInfo() PriorityLevelInfo {
 	return PriorityLevelInfo(0)
 }
 
+// Lock information of the given priority level (0-7)
+func (info PriorityLevelInfo) PLI(level PriorityLevel) PriorityLevelLevel {
+	shift := 14 - 2*uint(level)
+	return PriorityLevelLevel(info >> shift & 0x03)
+}
+
+// Returns a copy of info with the lock information of the given priority level (0-7) replaced
+func (info PriorityLevelInfo) WithPLI(level PriorityLevel, value PriorityLevelLevel) PriorityLevelInfo {
+	shift := 14 - 2*uint(level)
+	info &= ^(PriorityLevelInfo(0x03) << shift)
+	return info | PriorityLevelInfo(value&0x03)<<shift
+}
+
 func (info PriorityLevelInfo) PLI0() PriorityLevelLevel {
-	return PriorityLevelLevel(info << 14 & 0x03)
+	return info.PLI(0)
 }
 
 func (info PriorityLevelInfo) PLI1() PriorityLevelLevel {
-	return PriorityLevelLevel(info << 12 & 0x03)
+	return info.PLI(1)
 }
 
 func (info PriorityLevelInfo) PLI2() PriorityLevelLevel {
-	return PriorityLevelLevel(info << 10 & 0x03)
+	return info.PLI(2)
 }
 
 func (info PriorityLevelInfo) PLI3() PriorityLevelLevel {
-	return PriorityLevelLevel(info << 8 & 0x03)
+	return info.PLI(3)
 }
 
 func (info PriorityLevelInfo) PLI4() PriorityLevelLevel {
-	return PriorityLevelLevel(info << 6 & 0x03)
+	return info.PLI(4)
 }
 
 func (info PriorityLevelInfo) PLI5() PriorityLevelLevel {
-	return PriorityLevelLevel(info << 4 & 0x03)
+	return info.PLI(5)
 }
 
 func (info PriorityLevelInfo) PLI6() PriorityLevelLevel {
-	return PriorityLevelLevel(info << 2 & 0x03)
+	return info.PLI(6)
 }
 
 func (info PriorityLevelInfo) PLI7() PriorityLevelLevel {
-	return PriorityLevelLevel(info << 0 & 0x03)
+	return info.PLI(7)
 }
 
 type PriorityLevelLevel int
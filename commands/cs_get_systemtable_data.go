@@ -14,6 +14,10 @@ type CsGetSystemtableDataReq struct {
 
 var _ Request = (*CsGetSystemtableDataReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &CsGetSystemtableDataReq{} })
+}
+
 func (req *CsGetSystemtableDataReq) Code() transport.Command {
 	return transport.GW_CS_GET_SYSTEMTABLE_DATA_REQ
 }
@@ -26,6 +30,14 @@ func (req *CsGetSystemtableDataReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *CsGetSystemtableDataReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type CsGetSystemtableDataCfm struct {
 }
 
@@ -43,6 +55,10 @@ func (cfm *CsGetSystemtableDataCfm) Read(data []byte) error {
 	return nil
 }
 
+func (cfm *CsGetSystemtableDataCfm) Write() ([]byte, error) {
+	return emptyData, nil
+}
+
 type CsGetSystemtableDataNtf struct {
 	NumberOfEntry          int
 	Objects                []SystemtableObject
@@ -61,6 +77,10 @@ type SystemtableObject struct {
 	BackboneReferenceNumber uint
 }
 
+func (obj *SystemtableObject) NodeTypeSubType() NodeTypeSubType {
+	return NewNodeTypeSubType(obj.ActuatorType, obj.ActuatorSubType)
+}
+
 type ActuatorType int
 
 const VenetianBlind ActuatorType = 1
@@ -87,10 +107,9 @@ const HeatPump ActuatorType = 22
 const IntrusionAlarm ActuatorType = 23
 const SwingingShutter ActuatorType = 24
 
+// See NodeTypeSubType for the decoding of sub types
 type ActuatorSubType int
 
-// TODO: sub type decoding
-
 type IoManufacturer int
 
 const Velux IoManufacturer = 1
@@ -164,8 +183,8 @@ func (ntf *CsGetSystemtableDataNtf) readObject(reader binary.BinaryReader, obj *
 	obj.ActuatorSubType = ActuatorSubType(u16 & 0x3F)
 
 	u8, _ = reader.ReadU8()
-	obj.PowerSaveMode = u8&1 == 1
-	obj.RfSupport = u8&3 == 1
+	obj.PowerSaveMode = u8&3 == 1
+	obj.RfSupport = u8&(1<<3) != 0
 
 	switch u8 >> 6 {
 	case 0:
@@ -190,6 +209,63 @@ func (ntf *CsGetSystemtableDataNtf) readObject(reader binary.BinaryReader, obj *
 	obj.BackboneReferenceNumber |= uint(u8)
 }
 
+func (ntf *CsGetSystemtableDataNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	// object size = 11
+	if 2+11*len(ntf.Objects) > 250 {
+		return nil, fmt.Errorf("too many objects (got %d)", len(ntf.Objects))
+	}
+
+	writer.WriteU8(uint8(len(ntf.Objects)))
+
+	for index := range ntf.Objects {
+		ntf.writeObject(writer, &ntf.Objects[index])
+	}
+
+	writer.WriteU8(uint8(ntf.RemainingNumberOfEntry))
+
+	return buff.Bytes(), nil
+}
+
+func (ntf *CsGetSystemtableDataNtf) writeObject(writer binary.BinaryWriter, obj *SystemtableObject) {
+	writer.WriteU8(uint8(obj.SystemTableIndex))
+
+	writer.WriteU8(uint8(obj.ActuatorAddress >> 16))
+	writer.WriteU8(uint8(obj.ActuatorAddress >> 8))
+	writer.WriteU8(uint8(obj.ActuatorAddress))
+
+	writer.WriteU16(uint16(obj.ActuatorType)<<6 | uint16(obj.ActuatorSubType&0x3F))
+
+	// io-Membership is always 1
+	var flags uint8 = 1 << 2
+	if obj.PowerSaveMode {
+		flags |= 1
+	}
+	if obj.RfSupport {
+		flags |= 1 << 3
+	}
+
+	switch {
+	case obj.ActuatorTurnaroundTime <= time.Millisecond*5:
+		flags |= 0 << 6
+	case obj.ActuatorTurnaroundTime <= time.Millisecond*10:
+		flags |= 1 << 6
+	case obj.ActuatorTurnaroundTime <= time.Millisecond*20:
+		flags |= 2 << 6
+	default:
+		flags |= 3 << 6
+	}
+
+	writer.WriteU8(flags)
+	writer.WriteU8(uint8(obj.IoManufacturer))
+
+	writer.WriteU8(uint8(obj.BackboneReferenceNumber >> 16))
+	writer.WriteU8(uint8(obj.BackboneReferenceNumber >> 8))
+	writer.WriteU8(uint8(obj.BackboneReferenceNumber))
+}
+
 func (t ActuatorType) String() string {
 	switch t {
 	case VenetianBlind:
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordChangeReqLayout(t *testing.T) {
+	req := &PasswordChangeReq{CurrentPassword: "velux123", NewPassword: "secret"}
+
+	data, err := req.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 64 {
+		t.Fatalf("got %d bytes", len(data))
+	}
+
+	if current := bytes.TrimRight(data[:32], "\x00"); string(current) != "velux123" {
+		t.Errorf("got current password %q", current)
+	}
+
+	if next := bytes.TrimRight(data[32:], "\x00"); string(next) != "secret" {
+		t.Errorf("got new password %q", next)
+	}
+
+	var parsed PasswordChangeReq
+	if err := parsed.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed != *req {
+		t.Errorf("got %+v", parsed)
+	}
+
+	if err := parsed.Read(data[:32]); err == nil {
+		t.Error("single password accepted")
+	}
+
+	if _, err := (&PasswordChangeReq{CurrentPassword: "velux123", NewPassword: strings.Repeat("x", 33)}).Write(); err == nil {
+		t.Error("new password of 33 bytes accepted")
+	}
+}
+
+func TestGetAllNodesInformationNtfSerialNumber(t *testing.T) {
+	data := make([]byte, 124)
+	data[75] = 42 // build number
+	copy(data[76:84], []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08})
+	data[84] = byte(NodeStateDone)
+
+	var ntf GetAllNodesInformationNtf
+	if err := ntf.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if ntf.BuildNumber != 42 {
+		t.Errorf("got build number %d", ntf.BuildNumber)
+	}
+
+	if ntf.SerialNumber != 0x0102030405060708 {
+		t.Errorf("got serial number 0x%016X", ntf.SerialNumber)
+	}
+
+	if ntf.State != NodeStateDone {
+		t.Errorf("got state %d, the fields after the serial number are shifted", ntf.State)
+	}
+
+	written, err := ntf.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(written, data) {
+		t.Errorf("got %X, expected %X", written, data)
+	}
+}
+
+func TestStatusDataParametersLayout(t *testing.T) {
+	// Header: session 1, node 3, current position
+	data := []byte{0x00, 0x01, 0x01, 0x03, 0x00, 0x01, byte(StatusRequestCurrentPosition)}
+
+	// 2 significant entries out of the fixed array of 17, the following ones are garbage
+	data = append(data, 2)
+	data = append(data, byte(FunctionalParameterMP), 0x64, 0x00)
+	data = append(data, byte(FunctionalParameterFP2), 0x32, 0x00)
+	for range 15 {
+		data = append(data, byte(FunctionalParameterFP1), 0xFF, 0xFF)
+	}
+
+	var ntf StatusRequestNtf
+	if err := ntf.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	params, ok := ntf.StatusData.(*StatusDataParameters)
+	if !ok {
+		t.Fatalf("got status data %T", ntf.StatusData)
+	}
+
+	expected := map[FunctionalParameter]int{FunctionalParameterMP: 0x6400, FunctionalParameterFP2: 0x3200}
+	if !reflect.DeepEqual(params.ParameterValues, expected) {
+		t.Errorf("got %v", params.ParameterValues)
+	}
+
+	if err := ntf.Read(data[:len(data)-1]); err == nil {
+		t.Error("truncated array accepted")
+	}
+}
+
+func TestSystemtableObjectFlags(t *testing.T) {
+	tests := []struct {
+		flags         byte
+		powerSaveMode bool
+		rfSupport     bool
+	}{
+		{0b0000_0100, false, false},
+		{0b0000_0101, true, false},
+		{0b0000_0110, false, false}, // power save mode 2 (light)
+		{0b0000_1100, false, true},
+		{0b0000_1101, true, true},
+		{0b1100_1001, true, true},
+	}
+
+	for _, test := range tests {
+		data := []byte{1, 0, 0x00, 0x00, 0x01, 0x00, 0x40, test.flags, byte(Velux), 0x00, 0x00, 0x00, 0}
+
+		var ntf CsGetSystemtableDataNtf
+		if err := ntf.Read(data); err != nil {
+			t.Fatal(err)
+		}
+
+		obj := ntf.Objects[0]
+		if obj.PowerSaveMode != test.powerSaveMode || obj.RfSupport != test.rfSupport {
+			t.Errorf("flags %08b: got power save mode %t, RF support %t", test.flags, obj.PowerSaveMode, obj.RfSupport)
+		}
+	}
+}
@@ -37,6 +37,10 @@ func (ntf *ErrorNtf) Read(data []byte) error {
 	return nil
 }
 
+func (ntf *ErrorNtf) Write() ([]byte, error) {
+	return []byte{byte(ntf.ErrorNumber)}, nil
+}
+
 // Not further defined error.
 const ErrorUnknown ErrorNumber = 0
 
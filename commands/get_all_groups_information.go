@@ -0,0 +1,271 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/binary"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type GetAllGroupsInformationReq struct {
+	UseFilter bool
+	GroupType GroupType
+}
+
+var _ Request = (*GetAllGroupsInformationReq)(nil)
+
+func init() {
+	registerRequest(func() Request { return &GetAllGroupsInformationReq{} })
+}
+
+func (req *GetAllGroupsInformationReq) Code() transport.Command {
+	return transport.GW_GET_ALL_GROUPS_INFORMATION_REQ
+}
+
+func (req *GetAllGroupsInformationReq) NewConfirm() Confirm {
+	return &GetAllGroupsInformationCfm{}
+}
+
+func (req *GetAllGroupsInformationReq) Write() ([]byte, error) {
+	var useFilter byte = 0
+	if req.UseFilter {
+		useFilter = 1
+	}
+
+	return []byte{useFilter, byte(req.GroupType)}, nil
+}
+
+func (req *GetAllGroupsInformationReq) Read(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("bad length")
+	}
+
+	switch data[0] {
+	case 0:
+		req.UseFilter = false
+	case 1:
+		req.UseFilter = true
+	default:
+		return fmt.Errorf("bad filter flag %d", data[0])
+	}
+
+	req.GroupType = GroupType(data[1])
+
+	return nil
+}
+
+type GetAllGroupsInformationStatus int
+
+// OK - Request accepted
+const GetAllGroupsInformationStatusSuccess GetAllGroupsInformationStatus = 0
+
+// Failed - Request rejected
+const GetAllGroupsInformationStatusFailed GetAllGroupsInformationStatus = 1
+
+// No groups available
+const GetAllGroupsInformationStatusNoGroups GetAllGroupsInformationStatus = 2
+
+func (s GetAllGroupsInformationStatus) String() string {
+	switch s {
+	case GetAllGroupsInformationStatusSuccess:
+		return "GetAllGroupsInformationStatusSuccess"
+	case GetAllGroupsInformationStatusFailed:
+		return "GetAllGroupsInformationStatusFailed"
+	case GetAllGroupsInformationStatusNoGroups:
+		return "GetAllGroupsInformationStatusNoGroups"
+	default:
+		return fmt.Sprintf("<%d>", s)
+	}
+}
+
+type GetAllGroupsInformationCfm struct {
+	Status              GetAllGroupsInformationStatus
+	TotalNumberOfGroups int
+}
+
+var _ Confirm = (*GetAllGroupsInformationCfm)(nil)
+
+func (cfm *GetAllGroupsInformationCfm) Code() transport.Command {
+	return transport.GW_GET_ALL_GROUPS_INFORMATION_CFM
+}
+
+func (cfm *GetAllGroupsInformationCfm) Read(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("bad length")
+	}
+
+	cfm.Status = GetAllGroupsInformationStatus(data[0])
+	cfm.TotalNumberOfGroups = int(data[1])
+
+	return nil
+}
+
+func (cfm *GetAllGroupsInformationCfm) Write() ([]byte, error) {
+	return []byte{byte(cfm.Status), byte(cfm.TotalNumberOfGroups)}, nil
+}
+
+type GroupType int
+
+// User group
+const GroupTypeUser GroupType = 0
+
+// Room
+const GroupTypeRoom GroupType = 1
+
+// House
+const GroupTypeHouse GroupType = 2
+
+// All-group
+const GroupTypeAll GroupType = 3
+
+func (t GroupType) String() string {
+	switch t {
+	case GroupTypeUser:
+		return "GroupTypeUser"
+	case GroupTypeRoom:
+		return "GroupTypeRoom"
+	case GroupTypeHouse:
+		return "GroupTypeHouse"
+	case GroupTypeAll:
+		return "GroupTypeAll"
+	default:
+		return fmt.Sprintf("<%d>", t)
+	}
+}
+
+type GetAllGroupsInformationNtf struct {
+	GroupID       int
+	Order         int
+	Placement     int
+	Name          string
+	Velocity      Velocity
+	NodeVariation NodeVariation
+	GroupType     GroupType
+	NodeIndexes   []int
+	Revision      int
+}
+
+var _ Notify = (*GetAllGroupsInformationNtf)(nil)
+
+func init() {
+	registerNotify(func() Notify { return &GetAllGroupsInformationNtf{} })
+}
+
+func (ntf *GetAllGroupsInformationNtf) Code() transport.Command {
+	return transport.GW_GET_ALL_GROUPS_INFORMATION_NTF
+}
+
+func (ntf *GetAllGroupsInformationNtf) Read(data []byte) error {
+	if len(data) != 99 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+	var u16 uint16
+
+	u8, _ = reader.ReadU8()
+	ntf.GroupID = int(u8)
+
+	u16, _ = reader.ReadU16()
+	ntf.Order = int(u16)
+
+	u8, _ = reader.ReadU8()
+	ntf.Placement = int(u8)
+
+	ntf.Name = readString(reader, 64)
+
+	u8, _ = reader.ReadU8()
+	ntf.Velocity = Velocity(u8)
+
+	u8, _ = reader.ReadU8()
+	ntf.NodeVariation = NodeVariation(u8)
+
+	u8, _ = reader.ReadU8()
+	ntf.GroupType = GroupType(u8)
+
+	nbrOfObjects, _ := reader.ReadU8()
+
+	// Actuator bit array: LSB of the first byte is node 0
+	bitArray := make([]byte, 25)
+	reader.Read(bitArray)
+
+	ntf.NodeIndexes = make([]int, 0, nbrOfObjects)
+	for index := 0; index < 200; index++ {
+		if bitArray[index/8]&(1<<(index%8)) != 0 {
+			ntf.NodeIndexes = append(ntf.NodeIndexes, index)
+		}
+	}
+
+	if len(ntf.NodeIndexes) != int(nbrOfObjects) {
+		return fmt.Errorf("bad number of objects (got %d, actuator array has %d)", nbrOfObjects, len(ntf.NodeIndexes))
+	}
+
+	u16, _ = reader.ReadU16()
+	ntf.Revision = int(u16)
+
+	return nil
+}
+
+func (ntf *GetAllGroupsInformationNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU8(uint8(ntf.GroupID))
+	writer.WriteU16(uint16(ntf.Order))
+	writer.WriteU8(uint8(ntf.Placement))
+
+	if err := writeString(writer, ntf.Name, 64); err != nil {
+		return nil, fmt.Errorf("name: %w", err)
+	}
+
+	writer.WriteU8(uint8(ntf.Velocity))
+	writer.WriteU8(uint8(ntf.NodeVariation))
+	writer.WriteU8(uint8(ntf.GroupType))
+
+	bitArray := make([]byte, 25)
+	count := 0
+	for _, index := range ntf.NodeIndexes {
+		if index < 0 || index >= 200 {
+			return nil, fmt.Errorf("bad node index %d", index)
+		}
+
+		mask := byte(1 << (index % 8))
+		if bitArray[index/8]&mask == 0 {
+			bitArray[index/8] |= mask
+			count++
+		}
+	}
+
+	writer.WriteU8(uint8(count))
+	writer.Write(bitArray)
+	writer.WriteU16(uint16(ntf.Revision))
+
+	return buff.Bytes(), nil
+}
+
+type GetAllGroupsInformationFinishedNtf struct {
+}
+
+var _ Notify = (*GetAllGroupsInformationFinishedNtf)(nil)
+
+func init() {
+	registerNotify(func() Notify { return &GetAllGroupsInformationFinishedNtf{} })
+}
+
+func (ntf *GetAllGroupsInformationFinishedNtf) Code() transport.Command {
+	return transport.GW_GET_ALL_GROUPS_INFORMATION_FINISHED_NTF
+}
+
+func (ntf *GetAllGroupsInformationFinishedNtf) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
+func (ntf *GetAllGroupsInformationFinishedNtf) Write() ([]byte, error) {
+	return emptyData, nil
+}
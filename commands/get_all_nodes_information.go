@@ -3,6 +3,7 @@ package commands
 import (
 	"bytes"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/mylife-home/klf200-go/binary"
@@ -14,6 +15,10 @@ type GetAllNodesInformationReq struct {
 
 var _ Request = (*GetAllNodesInformationReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &GetAllNodesInformationReq{} })
+}
+
 func (req *GetAllNodesInformationReq) Code() transport.Command {
 	return transport.GW_GET_ALL_NODES_INFORMATION_REQ
 }
@@ -26,6 +31,14 @@ func (req *GetAllNodesInformationReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *GetAllNodesInformationReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type GetAllNodesInformationCfm struct {
 	Success            bool
 	TotalNumberOfNodes int
@@ -56,6 +69,15 @@ func (cfm *GetAllNodesInformationCfm) Read(data []byte) error {
 	return nil
 }
 
+func (cfm *GetAllNodesInformationCfm) Write() ([]byte, error) {
+	var status byte = 1
+	if cfm.Success {
+		status = 0
+	}
+
+	return []byte{status, byte(cfm.TotalNumberOfNodes)}, nil
+}
+
 type Velocity int
 
 // The node operates by its default velocity
@@ -70,14 +92,6 @@ const VelocityFast Velocity = 2
 // Not supported by node
 const VelocityNotAvailable Velocity = 255
 
-type NodeTypeSubType int
-
-// TODO
-
-type ProductType int
-
-// TODO
-
 type NodeVariation int
 
 // Not set
@@ -159,12 +173,12 @@ type GetAllNodesInformationNtf struct {
 	Name               string
 	Velocity           Velocity
 	NodeTypeSubType    NodeTypeSubType
-	ProductGroup       int
+	ProductGroup       ProductGroup
 	ProductType        ProductType
 	NodeVariation      NodeVariation
 	PowerMode          PowerMode
 	BuildNumber        int
-	SerialNumber       int
+	SerialNumber       uint64
 	State              NodeState
 	CurrentPosition    NodePosition
 	Target             NodePosition
@@ -217,7 +231,7 @@ func (ntf *GetAllNodesInformationNtf) Read(data []byte) error {
 	ntf.NodeTypeSubType = NodeTypeSubType(u16)
 
 	u8, _ = reader.ReadU8()
-	ntf.ProductGroup = int(u8)
+	ntf.ProductGroup = ProductGroup(u8)
 
 	u8, _ = reader.ReadU8()
 	ntf.ProductType = ProductType(u8)
@@ -231,8 +245,7 @@ func (ntf *GetAllNodesInformationNtf) Read(data []byte) error {
 	u8, _ = reader.ReadU8()
 	ntf.BuildNumber = int(u8)
 
-	u16, _ = reader.ReadU16()
-	ntf.BuildNumber = int(u16)
+	ntf.SerialNumber, _ = reader.ReadU64()
 
 	u8, _ = reader.ReadU8()
 	ntf.State = NodeState(u8)
@@ -274,6 +287,62 @@ func (ntf *GetAllNodesInformationNtf) Read(data []byte) error {
 	return nil
 }
 
+func (ntf *GetAllNodesInformationNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU8(uint8(ntf.NodeID))
+	writer.WriteU16(uint16(ntf.Order))
+	writer.WriteU8(uint8(ntf.Placement))
+
+	if err := writeString(writer, ntf.Name, 64); err != nil {
+		return nil, fmt.Errorf("name: %w", err)
+	}
+
+	writer.WriteU8(uint8(ntf.Velocity))
+	writer.WriteU16(uint16(ntf.NodeTypeSubType))
+	writer.WriteU8(uint8(ntf.ProductGroup))
+	writer.WriteU8(uint8(ntf.ProductType))
+	writer.WriteU8(uint8(ntf.NodeVariation))
+	writer.WriteU8(uint8(ntf.PowerMode))
+	writer.WriteU8(uint8(ntf.BuildNumber))
+	writer.WriteU64(ntf.SerialNumber)
+	writer.WriteU8(uint8(ntf.State))
+	writer.WriteU16(uint16(ntf.CurrentPosition))
+	writer.WriteU16(uint16(ntf.Target))
+	writer.WriteU16(uint16(ntf.FP1CurrentPosition))
+	writer.WriteU16(uint16(ntf.FP2CurrentPosition))
+	writer.WriteU16(uint16(ntf.FP3CurrentPosition))
+	writer.WriteU16(uint16(ntf.FP4CurrentPosition))
+	writer.WriteU16(uint16(ntf.RemainingTime / time.Second))
+	writer.WriteU32(uint32(ntf.TimeStamp.Unix()))
+
+	if len(ntf.Aliases) > 5 {
+		return nil, fmt.Errorf("too many aliases (got %d, max 5)", len(ntf.Aliases))
+	}
+
+	writer.WriteU8(uint8(len(ntf.Aliases)))
+
+	aliases := make([]NodeAliasId, 0, len(ntf.Aliases))
+	for typ := range ntf.Aliases {
+		aliases = append(aliases, typ)
+	}
+
+	slices.Sort(aliases)
+
+	for index := 0; index < 5; index++ {
+		if index < len(aliases) {
+			writer.WriteU16(uint16(aliases[index]))
+			writer.WriteU16(uint16(ntf.Aliases[aliases[index]]))
+		} else {
+			writer.WriteU16(0)
+			writer.WriteU16(0)
+		}
+	}
+
+	return buff.Bytes(), nil
+}
+
 type GetAllNodesInformationFinishedNtf struct {
 }
 
@@ -295,6 +364,10 @@ func (ntf *GetAllNodesInformationFinishedNtf) Read(data []byte) error {
 	return nil
 }
 
+func (ntf *GetAllNodesInformationFinishedNtf) Write() ([]byte, error) {
+	return emptyData, nil
+}
+
 func (v Velocity) String() string {
 	switch v {
 	case VelocityDefault:
@@ -14,6 +14,10 @@ type GetLocalTimeReq struct {
 
 var _ Request = (*GetLocalTimeReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &GetLocalTimeReq{} })
+}
+
 func (req *GetLocalTimeReq) Code() transport.Command {
 	return transport.GW_GET_LOCAL_TIME_REQ
 }
@@ -26,6 +30,14 @@ func (req *GetLocalTimeReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *GetLocalTimeReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type DaylightSavingFlag int8
 
 const DaylightSavingUnknown DaylightSavingFlag = -1
@@ -93,6 +105,24 @@ func (cfm *GetLocalTimeCfm) Read(data []byte) error {
 	return nil
 }
 
+func (cfm *GetLocalTimeCfm) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU32(uint32(cfm.UtcTime.Unix()))
+	writer.WriteU8(uint8(cfm.LocalTime.Second))
+	writer.WriteU8(uint8(cfm.LocalTime.Minute))
+	writer.WriteU8(uint8(cfm.LocalTime.Hour))
+	writer.WriteU8(uint8(cfm.LocalTime.DayOfMonth))
+	writer.WriteU8(uint8(cfm.LocalTime.Month))
+	writer.WriteU16(uint16(cfm.LocalTime.Year - 1900))
+	writer.WriteU8(uint8(cfm.LocalTime.WeekDay))
+	writer.WriteU16(uint16(cfm.LocalTime.DayOfYear))
+	writer.WriteI8(int8(cfm.LocalTime.DaylightSavingFlag))
+
+	return buff.Bytes(), nil
+}
+
 func (cfm *GetLocalTimeCfm) readU8AsInt(reader binary.BinaryReader) int {
 	value, _ := reader.ReadU8()
 	return int(value)
@@ -14,6 +14,10 @@ type GetNetworkSetupReq struct {
 
 var _ Request = (*GetNetworkSetupReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &GetNetworkSetupReq{} })
+}
+
 func (req *GetNetworkSetupReq) Code() transport.Command {
 	return transport.GW_GET_NETWORK_SETUP_REQ
 }
@@ -26,6 +30,14 @@ func (req *GetNetworkSetupReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *GetNetworkSetupReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type GetNetworkSetupCfm struct {
 	IpAddress net.IP
 	Mask      net.IPMask
@@ -65,6 +77,24 @@ func (cfm *GetNetworkSetupCfm) Read(data []byte) error {
 	return nil
 }
 
+func (cfm *GetNetworkSetupCfm) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.Write(cfm.IpAddress.To4())
+	writer.Write(cfm.Mask)
+	writer.Write(cfm.DefGW.To4())
+
+	var dhcp uint8 = 0
+	if cfm.DHCP {
+		dhcp = 1
+	}
+
+	writer.WriteU8(dhcp)
+
+	return buff.Bytes(), nil
+}
+
 func (cfm *GetNetworkSetupCfm) readIp(reader binary.BinaryReader) net.IP {
 	data := make([]byte, 4)
 	reader.Read(data)
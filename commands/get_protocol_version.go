@@ -13,6 +13,10 @@ type GetProtocolVersionReq struct {
 
 var _ Request = (*GetProtocolVersionReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &GetProtocolVersionReq{} })
+}
+
 func (req *GetProtocolVersionReq) Code() transport.Command {
 	return transport.GW_GET_PROTOCOL_VERSION_REQ
 }
@@ -25,6 +29,14 @@ func (req *GetProtocolVersionReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *GetProtocolVersionReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type GetProtocolVersionCfm struct {
 	MajorVersion int
 	MinorVersion int
@@ -52,3 +64,13 @@ func (cfm *GetProtocolVersionCfm) Read(data []byte) error {
 
 	return nil
 }
+
+func (cfm *GetProtocolVersionCfm) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(cfm.MajorVersion))
+	writer.WriteU16(uint16(cfm.MinorVersion))
+
+	return buff.Bytes(), nil
+}
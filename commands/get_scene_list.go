@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/binary"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type GetSceneListReq struct {
+}
+
+var _ Request = (*GetSceneListReq)(nil)
+
+func init() {
+	registerRequest(func() Request { return &GetSceneListReq{} })
+}
+
+func (req *GetSceneListReq) Code() transport.Command {
+	return transport.GW_GET_SCENE_LIST_REQ
+}
+
+func (req *GetSceneListReq) NewConfirm() Confirm {
+	return &GetSceneListCfm{}
+}
+
+func (req *GetSceneListReq) Write() ([]byte, error) {
+	return emptyData, nil
+}
+
+func (req *GetSceneListReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
+type GetSceneListCfm struct {
+	TotalNumberOfObjects int
+}
+
+var _ Confirm = (*GetSceneListCfm)(nil)
+
+func (cfm *GetSceneListCfm) Code() transport.Command {
+	return transport.GW_GET_SCENE_LIST_CFM
+}
+
+func (cfm *GetSceneListCfm) Read(data []byte) error {
+	if len(data) != 1 {
+		return fmt.Errorf("bad length")
+	}
+
+	cfm.TotalNumberOfObjects = int(data[0])
+
+	return nil
+}
+
+func (cfm *GetSceneListCfm) Write() ([]byte, error) {
+	return []byte{byte(cfm.TotalNumberOfObjects)}, nil
+}
+
+// Maximum number of scenes in one GW_GET_SCENE_LIST_NTF frame
+const SceneListMaxObjects = 3
+
+type GetSceneListNtf struct {
+	Objects                 []SceneListObject
+	RemainingNumberOfObject int
+}
+
+type SceneListObject struct {
+	SceneID int
+	Name    string
+}
+
+var _ Notify = (*GetSceneListNtf)(nil)
+
+func init() {
+	registerNotify(func() Notify { return &GetSceneListNtf{} })
+}
+
+func (ntf *GetSceneListNtf) Code() transport.Command {
+	return transport.GW_GET_SCENE_LIST_NTF
+}
+
+func (ntf *GetSceneListNtf) Read(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+
+	numberOfObject, _ := reader.ReadU8()
+
+	// object size = 65
+	if len(data) != 2+65*int(numberOfObject) {
+		return fmt.Errorf("bad length")
+	}
+
+	ntf.Objects = make([]SceneListObject, numberOfObject)
+
+	for index := range ntf.Objects {
+		obj := &ntf.Objects[index]
+
+		u8, _ = reader.ReadU8()
+		obj.SceneID = int(u8)
+		obj.Name = readString(reader, 64)
+	}
+
+	u8, _ = reader.ReadU8()
+	ntf.RemainingNumberOfObject = int(u8)
+
+	return nil
+}
+
+func (ntf *GetSceneListNtf) Write() ([]byte, error) {
+	if len(ntf.Objects) > SceneListMaxObjects {
+		return nil, fmt.Errorf("too many objects (got %d, max %d)", len(ntf.Objects), SceneListMaxObjects)
+	}
+
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU8(uint8(len(ntf.Objects)))
+
+	for _, obj := range ntf.Objects {
+		writer.WriteU8(uint8(obj.SceneID))
+
+		if err := writeString(writer, obj.Name, 64); err != nil {
+			return nil, fmt.Errorf("name: %w", err)
+		}
+	}
+
+	writer.WriteU8(uint8(ntf.RemainingNumberOfObject))
+
+	return buff.Bytes(), nil
+}
@@ -13,6 +13,10 @@ type GetStateReq struct {
 
 var _ Request = (*GetStateReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &GetStateReq{} })
+}
+
 func (req *GetStateReq) Code() transport.Command {
 	return transport.GW_GET_STATE_REQ
 }
@@ -25,6 +29,14 @@ func (req *GetStateReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *GetStateReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type GatewayState int
 
 // Test mode.
@@ -42,6 +54,23 @@ const GatewayStateBeaconMode GatewayState = 3
 // Beacon mode, has been configured by a remote controller.
 const GatewayStateBeaconModeConfigured GatewayState = 4
 
+func (s GatewayState) String() string {
+	switch s {
+	case GatewayStateTest:
+		return "GatewayStateTest"
+	case GatewayStateGatewayMode:
+		return "GatewayStateGatewayMode"
+	case GatewayStateGatewayModeWithActuator:
+		return "GatewayStateGatewayModeWithActuator"
+	case GatewayStateBeaconMode:
+		return "GatewayStateBeaconMode"
+	case GatewayStateBeaconModeConfigured:
+		return "GatewayStateBeaconModeConfigured"
+	default:
+		return fmt.Sprintf("<%d>", s)
+	}
+}
+
 type GatewaySubState int
 
 // Idle state.
@@ -68,6 +97,29 @@ const GatewaySubStateActivateGroupHandler GatewaySubState = 0x81
 // Performing task in Activate Scene Handler
 const GatewaySubStateActivateSceneHandler GatewaySubState = 0x82
 
+func (s GatewaySubState) String() string {
+	switch s {
+	case GatewaySubStateIdle:
+		return "GatewaySubStateIdle"
+	case GatewaySubStateConfigurationServiceHandler:
+		return "GatewaySubStateConfigurationServiceHandler"
+	case GatewaySubStateSceneConfiguration:
+		return "GatewaySubStateSceneConfiguration"
+	case GatewaySubStateInformationServiceConfiguration:
+		return "GatewaySubStateInformationServiceConfiguration"
+	case GatewaySubStateContactInputConfiguration:
+		return "GatewaySubStateContactInputConfiguration"
+	case GatewaySubStateCommandHandler:
+		return "GatewaySubStateCommandHandler"
+	case GatewaySubStateActivateGroupHandler:
+		return "GatewaySubStateActivateGroupHandler"
+	case GatewaySubStateActivateSceneHandler:
+		return "GatewaySubStateActivateSceneHandler"
+	default:
+		return fmt.Sprintf("<0x%02x>", int(s))
+	}
+}
+
 type GetStateCfm struct {
 	GatewayState GatewayState
 	SubState     GatewaySubState
@@ -100,3 +152,14 @@ func (cfm *GetStateCfm) Read(data []byte) error {
 
 	return nil
 }
+
+func (cfm *GetStateCfm) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU8(uint8(cfm.GatewayState))
+	writer.WriteU8(uint8(cfm.SubState))
+	writer.WriteU32(uint32(cfm.StateData))
+
+	return buff.Bytes(), nil
+}
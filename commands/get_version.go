@@ -13,6 +13,10 @@ type GetVersionReq struct {
 
 var _ Request = (*GetVersionReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &GetVersionReq{} })
+}
+
 func (req *GetVersionReq) Code() transport.Command {
 	return transport.GW_GET_VERSION_REQ
 }
@@ -25,6 +29,14 @@ func (req *GetVersionReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *GetVersionReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type GetVersionCfm struct {
 	SoftwareVersion SoftWareVersionData
 	HardwareVersion int
@@ -67,6 +79,20 @@ func (cfm *GetVersionCfm) Read(data []byte) error {
 	return nil
 }
 
+func (cfm *GetVersionCfm) Write() ([]byte, error) {
+	return []byte{
+		byte(cfm.SoftwareVersion.CommandVersionNumber),
+		byte(cfm.SoftwareVersion.VersionWholeNumber),
+		byte(cfm.SoftwareVersion.VersionSubNumber),
+		byte(cfm.SoftwareVersion.BranchID),
+		byte(cfm.SoftwareVersion.BuildNumber),
+		byte(cfm.SoftwareVersion.MicroBuild),
+		byte(cfm.HardwareVersion),
+		byte(cfm.ProductGroup),
+		byte(cfm.ProductType),
+	}, nil
+}
+
 func (cfm *GetVersionCfm) readAsInt(reader binary.BinaryReader) int {
 	value, _ := reader.ReadU8()
 	return int(value)
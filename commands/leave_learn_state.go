@@ -11,6 +11,10 @@ type LeaveLearnStateReq struct {
 
 var _ Request = (*LeaveLearnStateReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &LeaveLearnStateReq{} })
+}
+
 func (req *LeaveLearnStateReq) Code() transport.Command {
 	return transport.GW_LEAVE_LEARN_STATE_REQ
 }
@@ -23,6 +27,14 @@ func (req *LeaveLearnStateReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *LeaveLearnStateReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type LeaveLearnStateCfm struct {
 	Success bool
 }
@@ -48,3 +60,11 @@ func (cfm *LeaveLearnStateCfm) Read(data []byte) error {
 	}
 	return nil
 }
+
+func (cfm *LeaveLearnStateCfm) Write() ([]byte, error) {
+	if cfm.Success {
+		return []byte{1}, nil
+	}
+
+	return []byte{0}, nil
+}
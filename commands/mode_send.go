@@ -23,11 +23,24 @@ type ModeSendReq struct {
 }
 
 var _ Request = (*ModeSendReq)(nil)
+var _ SessionCommand = (*ModeSendReq)(nil)
+
+func init() {
+	registerRequest(func() Request { return &ModeSendReq{} })
+}
 
 func (req *ModeSendReq) Code() transport.Command {
 	return transport.GW_MODE_SEND_REQ
 }
 
+func (req *ModeSendReq) GetSessionID() int {
+	return req.SessionID
+}
+
+func (req *ModeSendReq) SetSessionID(id int) {
+	req.SessionID = id
+}
+
 func (req *ModeSendReq) NewConfirm() Confirm {
 	return &ModeSendCfm{}
 }
@@ -65,6 +78,55 @@ func (req *ModeSendReq) Write() ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
+func (req *ModeSendReq) Read(data []byte) error {
+	if len(data) != 31 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+	var u16 uint16
+
+	u16, _ = reader.ReadU16()
+	req.SessionID = int(u16)
+
+	u8, _ = reader.ReadU8()
+	req.CommandOriginator = CommandOriginator(u8)
+
+	u8, _ = reader.ReadU8()
+	req.PriorityLevel = PriorityLevel(u8)
+
+	u8, _ = reader.ReadU8()
+	req.ModeNumber = int(u8)
+
+	u8, _ = reader.ReadU8()
+	req.ModeParameter = int(u8)
+
+	u8, _ = reader.ReadU8()
+	if u8 < 1 || u8 > 20 {
+		return fmt.Errorf("bad node indexes len (got %d, expected > 0 && <= 20)", u8)
+	}
+
+	req.NodeIndexes = make([]int, u8)
+	for index := 0; index < 20; index++ {
+		value, _ := reader.ReadU8()
+		if index < len(req.NodeIndexes) {
+			req.NodeIndexes[index] = int(value)
+		}
+	}
+
+	u8, _ = reader.ReadU8()
+	req.PriorityLevelLock = PriorityLevelLock(u8)
+
+	u16, _ = reader.ReadU16()
+	req.PriorityLevelInfo = PriorityLevelInfo(u16)
+
+	u8, _ = reader.ReadU8()
+	req.LockTime = LockTime(u8)
+
+	return nil
+}
+
 type ModeSendCfm struct {
 	SessionID int
 	Status    ModeSendStatus
@@ -115,11 +177,20 @@ func (s ModeSendStatus) String() string {
 }
 
 var _ Confirm = (*ModeSendCfm)(nil)
+var _ SessionCommand = (*ModeSendCfm)(nil)
 
 func (cfm *ModeSendCfm) Code() transport.Command {
 	return transport.GW_MODE_SEND_CFM
 }
 
+func (cfm *ModeSendCfm) GetSessionID() int {
+	return cfm.SessionID
+}
+
+func (cfm *ModeSendCfm) SetSessionID(id int) {
+	cfm.SessionID = id
+}
+
 func (cfm *ModeSendCfm) Read(data []byte) error {
 	if len(data) != 3 {
 		return fmt.Errorf("bad length")
@@ -137,3 +208,13 @@ func (cfm *ModeSendCfm) Read(data []byte) error {
 
 	return nil
 }
+
+func (cfm *ModeSendCfm) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(cfm.SessionID))
+	writer.WriteU8(uint8(cfm.Status))
+
+	return buff.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/binary"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Sent by the gateway when the name, order, placement or variation of a node changes
+type NodeInformationChangedNtf struct {
+	NodeID        int
+	Name          string
+	Order         int
+	Placement     int
+	NodeVariation NodeVariation
+}
+
+var _ Notify = (*NodeInformationChangedNtf)(nil)
+
+func init() {
+	registerNotify(func() Notify { return &NodeInformationChangedNtf{} })
+}
+
+func (ntf *NodeInformationChangedNtf) Code() transport.Command {
+	return transport.GW_NODE_INFORMATION_CHANGED_NTF
+}
+
+func (ntf *NodeInformationChangedNtf) Read(data []byte) error {
+	if len(data) != 69 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+	var u16 uint16
+
+	u8, _ = reader.ReadU8()
+	ntf.NodeID = int(u8)
+
+	name := make([]byte, 64)
+	reader.Read(name)
+	ntf.Name = string(bytes.TrimRight(name, "\x00"))
+
+	u16, _ = reader.ReadU16()
+	ntf.Order = int(u16)
+
+	u8, _ = reader.ReadU8()
+	ntf.Placement = int(u8)
+
+	u8, _ = reader.ReadU8()
+	ntf.NodeVariation = NodeVariation(u8)
+
+	return nil
+}
+
+func (ntf *NodeInformationChangedNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU8(uint8(ntf.NodeID))
+
+	if err := writeString(writer, ntf.Name, 64); err != nil {
+		return nil, fmt.Errorf("name: %w", err)
+	}
+
+	writer.WriteU16(uint16(ntf.Order))
+	writer.WriteU8(uint8(ntf.Placement))
+	writer.WriteU8(uint8(ntf.NodeVariation))
+
+	return buff.Bytes(), nil
+}
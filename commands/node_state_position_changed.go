@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/mylife-home/klf200-go/binary"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Sent by the gateway each time the state or the position of a node changes
+type NodeStatePositionChangedNtf struct {
+	NodeID             int
+	State              NodeState
+	CurrentPosition    NodePosition
+	Target             NodePosition
+	FP1CurrentPosition NodePosition
+	FP2CurrentPosition NodePosition
+	FP3CurrentPosition NodePosition
+	FP4CurrentPosition NodePosition
+	RemainingTime      time.Duration
+	TimeStamp          time.Time
+}
+
+var _ Notify = (*NodeStatePositionChangedNtf)(nil)
+
+func init() {
+	registerNotify(func() Notify { return &NodeStatePositionChangedNtf{} })
+}
+
+func (ntf *NodeStatePositionChangedNtf) Code() transport.Command {
+	return transport.GW_NODE_STATE_POSITION_CHANGED_NTF
+}
+
+func (ntf *NodeStatePositionChangedNtf) Read(data []byte) error {
+	if len(data) != 20 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+	var u16 uint16
+	var u32 uint32
+
+	u8, _ = reader.ReadU8()
+	ntf.NodeID = int(u8)
+
+	u8, _ = reader.ReadU8()
+	ntf.State = NodeState(u8)
+
+	u16, _ = reader.ReadU16()
+	ntf.CurrentPosition = NodePosition(u16)
+
+	u16, _ = reader.ReadU16()
+	ntf.Target = NodePosition(u16)
+
+	u16, _ = reader.ReadU16()
+	ntf.FP1CurrentPosition = NodePosition(u16)
+
+	u16, _ = reader.ReadU16()
+	ntf.FP2CurrentPosition = NodePosition(u16)
+
+	u16, _ = reader.ReadU16()
+	ntf.FP3CurrentPosition = NodePosition(u16)
+
+	u16, _ = reader.ReadU16()
+	ntf.FP4CurrentPosition = NodePosition(u16)
+
+	u16, _ = reader.ReadU16()
+	ntf.RemainingTime = time.Second * time.Duration(u16)
+
+	u32, _ = reader.ReadU32()
+	ntf.TimeStamp = time.Unix(int64(u32), 0)
+
+	return nil
+}
+
+func (ntf *NodeStatePositionChangedNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU8(uint8(ntf.NodeID))
+	writer.WriteU8(uint8(ntf.State))
+	writer.WriteU16(uint16(ntf.CurrentPosition))
+	writer.WriteU16(uint16(ntf.Target))
+	writer.WriteU16(uint16(ntf.FP1CurrentPosition))
+	writer.WriteU16(uint16(ntf.FP2CurrentPosition))
+	writer.WriteU16(uint16(ntf.FP3CurrentPosition))
+	writer.WriteU16(uint16(ntf.FP4CurrentPosition))
+	writer.WriteU16(uint16(ntf.RemainingTime / time.Second))
+	writer.WriteU32(uint32(ntf.TimeStamp.Unix()))
+
+	return buff.Bytes(), nil
+}
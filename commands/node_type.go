@@ -0,0 +1,565 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Actuator type (10 bits, MSB) and actuator sub type (6 bits, LSB)
+//
+// See "Appendix 2: List of actuator types and their use of Main Parameter and Functional Parameters" in the specification
+type NodeTypeSubType int
+
+func NewNodeTypeSubType(actuatorType ActuatorType, subType ActuatorSubType) NodeTypeSubType {
+	return NodeTypeSubType((int(actuatorType) << 6) | (int(subType) & 0x3F))
+}
+
+func (t NodeTypeSubType) ActuatorType() ActuatorType {
+	return ActuatorType(int(t) >> 6)
+}
+
+func (t NodeTypeSubType) SubType() ActuatorSubType {
+	return ActuatorSubType(int(t) & 0x3F)
+}
+
+// Interior Venetian Blind
+const NodeTypeInteriorVenetianBlind NodeTypeSubType = 0x0040
+
+// Roller Shutter
+const NodeTypeRollerShutter NodeTypeSubType = 0x0080
+
+// Adjustable slats rolling shutter
+const NodeTypeAdjustableSlatsRollerShutter NodeTypeSubType = 0x0081
+
+// Roller Shutter With projection
+const NodeTypeRollerShutterWithProjection NodeTypeSubType = 0x0082
+
+// Vertical Exterior Awning
+const NodeTypeVerticalExteriorAwning NodeTypeSubType = 0x00C0
+
+// Window opener
+const NodeTypeWindowOpener NodeTypeSubType = 0x0100
+
+// Window opener with integrated rain sensor
+const NodeTypeWindowOpenerWithRainSensor NodeTypeSubType = 0x0101
+
+// Garage door opener
+const NodeTypeGarageDoorOpener NodeTypeSubType = 0x0140
+
+// Garage door opener, position only (no speed parameter)
+const NodeTypeGarageDoorOpenerWithoutSpeed NodeTypeSubType = 0x017A
+
+// Light
+const NodeTypeLight NodeTypeSubType = 0x0180
+
+// Light only supporting on/off
+const NodeTypeLightOnOff NodeTypeSubType = 0x01BA
+
+// Gate opener
+const NodeTypeGateOpener NodeTypeSubType = 0x01C0
+
+// Gate opener, position only (no speed parameter)
+const NodeTypeGateOpenerWithoutSpeed NodeTypeSubType = 0x01FA
+
+// Door lock
+const NodeTypeDoorLock NodeTypeSubType = 0x0240
+
+// Window lock
+const NodeTypeWindowLock NodeTypeSubType = 0x0241
+
+// Vertical Interior Blinds
+const NodeTypeVerticalInteriorBlind NodeTypeSubType = 0x0280
+
+// Dual Roller Shutter
+const NodeTypeDualRollerShutter NodeTypeSubType = 0x0340
+
+// On/Off switch
+const NodeTypeOnOffSwitch NodeTypeSubType = 0x03C0
+
+// Horizontal awning
+const NodeTypeHorizontalAwning NodeTypeSubType = 0x0400
+
+// Exterior Venetian blind
+const NodeTypeExteriorVenetianBlind NodeTypeSubType = 0x0440
+
+// Louver blind
+const NodeTypeLouverBlind NodeTypeSubType = 0x0480
+
+// Curtain track
+const NodeTypeCurtainTrack NodeTypeSubType = 0x04C0
+
+// Ventilation point
+const NodeTypeVentilationPoint NodeTypeSubType = 0x0500
+
+// Ventilation point, air inlet
+const NodeTypeVentilationPointAirInlet NodeTypeSubType = 0x0501
+
+// Ventilation point, air transfer
+const NodeTypeVentilationPointAirTransfer NodeTypeSubType = 0x0502
+
+// Ventilation point, air outlet
+const NodeTypeVentilationPointAirOutlet NodeTypeSubType = 0x0503
+
+// Exterior heating
+const NodeTypeExteriorHeating NodeTypeSubType = 0x0540
+
+// Exterior heating, energy demand only (no gradient parameter)
+const NodeTypeExteriorHeatingWithoutGradient NodeTypeSubType = 0x057A
+
+// Swinging Shutters
+const NodeTypeSwingingShutter NodeTypeSubType = 0x0600
+
+// Swinging Shutter with independent handling of the leaves
+const NodeTypeSwingingShutterIndependentLeaves NodeTypeSubType = 0x0601
+
+// What a parameter (main or functional) controls on a node
+type ParameterFunction int
+
+// The parameter is not used
+const ParameterFunctionNone ParameterFunction = 0
+
+// Linear or angular position (0% = open, 100% = closed)
+const ParameterFunctionPosition ParameterFunction = 1
+
+// Speed of the main parameter
+const ParameterFunctionSpeed ParameterFunction = 2
+
+// Orientation of the slats or hangers (tilting)
+const ParameterFunctionOrientation ParameterFunction = 3
+
+// Speed of the slats or hangers during orientation
+const ParameterFunctionOrientationSpeed ParameterFunction = 4
+
+// Light intensity
+const ParameterFunctionLightIntensity ParameterFunction = 5
+
+// Light intensity gradient
+const ParameterFunctionLightIntensityGradient ParameterFunction = 6
+
+// Lock state (0% = unlocked, 100% = locked)
+const ParameterFunctionLockState ParameterFunction = 7
+
+// Switch position (0% = on, 100% = off)
+const ParameterFunctionSwitchPosition ParameterFunction = 8
+
+// Position of the upper curtain of a dual shutter
+const ParameterFunctionUpperCurtainPosition ParameterFunction = 9
+
+// Position of the lower curtain of a dual shutter
+const ParameterFunctionLowerCurtainPosition ParameterFunction = 10
+
+// Air demand (0% = maximum allowable ventilation, 100% = minimum)
+const ParameterFunctionAirDemand ParameterFunction = 11
+
+// Energy demand (0% = 100% heat, 100% = 0% heat)
+const ParameterFunctionEnergyDemand ParameterFunction = 12
+
+// Energy demand gradient
+const ParameterFunctionEnergyGradient ParameterFunction = 13
+
+// How a node is expected to be driven by a user interface
+type ControlMode int
+
+// Continuous position between 0% and 100%
+const ControlModePosition ControlMode = 0
+
+// Only fully on (0%) or fully off (100%)
+const ControlModeOnOff ControlMode = 1
+
+// Only unlocked (0%) or locked (100%)
+const ControlModeLock ControlMode = 2
+
+// Continuous demand level between 0% and 100%
+const ControlModeLevel ControlMode = 3
+
+type NodeCapabilities struct {
+	ControlMode ControlMode
+
+	// Function of the main parameter
+	MainParameter ParameterFunction
+
+	// Function of each functional parameter supported by the node
+	FunctionalParameters map[FunctionalParameter]ParameterFunction
+}
+
+// Indicates if the functional parameter is used by this node type
+func (caps *NodeCapabilities) Supports(param FunctionalParameter) bool {
+	if param == FunctionalParameterMP {
+		return caps.MainParameter != ParameterFunctionNone
+	}
+
+	_, ok := caps.FunctionalParameters[param]
+	return ok
+}
+
+// Find the functional parameter which has the given function, if supported
+func (caps *NodeCapabilities) Find(function ParameterFunction) (FunctionalParameter, bool) {
+	if caps.MainParameter == function {
+		return FunctionalParameterMP, true
+	}
+
+	for param, paramFunction := range caps.FunctionalParameters {
+		if paramFunction == function {
+			return param, true
+		}
+	}
+
+	return FunctionalParameterMP, false
+}
+
+type nodeTypeInfo struct {
+	name         string
+	description  string
+	capabilities NodeCapabilities
+}
+
+func positionWithSpeed() NodeCapabilities {
+	return NodeCapabilities{
+		ControlMode:   ControlModePosition,
+		MainParameter: ParameterFunctionPosition,
+		FunctionalParameters: map[FunctionalParameter]ParameterFunction{
+			FunctionalParameterFP1: ParameterFunctionSpeed,
+		},
+	}
+}
+
+func positionWithSlats() NodeCapabilities {
+	return NodeCapabilities{
+		ControlMode:   ControlModePosition,
+		MainParameter: ParameterFunctionPosition,
+		FunctionalParameters: map[FunctionalParameter]ParameterFunction{
+			FunctionalParameterFP1: ParameterFunctionSpeed,
+			FunctionalParameterFP2: ParameterFunctionOrientationSpeed,
+			FunctionalParameterFP3: ParameterFunctionOrientation,
+		},
+	}
+}
+
+func mainParameterOnly(mode ControlMode, function ParameterFunction) NodeCapabilities {
+	return NodeCapabilities{
+		ControlMode:          mode,
+		MainParameter:        function,
+		FunctionalParameters: map[FunctionalParameter]ParameterFunction{},
+	}
+}
+
+var nodeTypeCatalogue = map[NodeTypeSubType]nodeTypeInfo{
+	NodeTypeInteriorVenetianBlind: {"InteriorVenetianBlind", "Interior venetian blind", NodeCapabilities{
+		ControlMode:   ControlModePosition,
+		MainParameter: ParameterFunctionPosition,
+		// Differs from the generic slats functions
+		FunctionalParameters: map[FunctionalParameter]ParameterFunction{
+			FunctionalParameterFP1: ParameterFunctionOrientation,
+			FunctionalParameterFP2: ParameterFunctionOrientationSpeed,
+			FunctionalParameterFP3: ParameterFunctionSpeed,
+		},
+	}},
+	NodeTypeRollerShutter:                    {"RollerShutter", "Roller shutter", positionWithSpeed()},
+	NodeTypeAdjustableSlatsRollerShutter:     {"AdjustableSlatsRollerShutter", "Adjustable slats rolling shutter", positionWithSlats()},
+	NodeTypeRollerShutterWithProjection:      {"RollerShutterWithProjection", "Roller shutter with projection", positionWithSpeed()},
+	NodeTypeVerticalExteriorAwning:           {"VerticalExteriorAwning", "Vertical exterior awning", positionWithSpeed()},
+	NodeTypeWindowOpener:                     {"WindowOpener", "Window opener", positionWithSpeed()},
+	NodeTypeWindowOpenerWithRainSensor:       {"WindowOpenerWithRainSensor", "Window opener with integrated rain sensor", positionWithSpeed()},
+	NodeTypeGarageDoorOpener:                 {"GarageDoorOpener", "Garage door opener", positionWithSpeed()},
+	NodeTypeGarageDoorOpenerWithoutSpeed:     {"GarageDoorOpenerWithoutSpeed", "Garage door opener (position only)", mainParameterOnly(ControlModePosition, ParameterFunctionPosition)},
+	NodeTypeLight:                            {"Light", "Light", NodeCapabilities{ControlMode: ControlModePosition, MainParameter: ParameterFunctionLightIntensity, FunctionalParameters: map[FunctionalParameter]ParameterFunction{FunctionalParameterFP1: ParameterFunctionLightIntensityGradient}}},
+	NodeTypeLightOnOff:                       {"LightOnOff", "Light only supporting on/off", mainParameterOnly(ControlModeOnOff, ParameterFunctionLightIntensity)},
+	NodeTypeGateOpener:                       {"GateOpener", "Gate opener", positionWithSpeed()},
+	NodeTypeGateOpenerWithoutSpeed:           {"GateOpenerWithoutSpeed", "Gate opener (position only)", mainParameterOnly(ControlModePosition, ParameterFunctionPosition)},
+	NodeTypeDoorLock:                         {"DoorLock", "Door lock", mainParameterOnly(ControlModeLock, ParameterFunctionLockState)},
+	NodeTypeWindowLock:                       {"WindowLock", "Window lock", mainParameterOnly(ControlModeLock, ParameterFunctionLockState)},
+	NodeTypeVerticalInteriorBlind:            {"VerticalInteriorBlind", "Vertical interior blinds", positionWithSpeed()},
+	NodeTypeDualRollerShutter:                {"DualRollerShutter", "Dual roller shutter", NodeCapabilities{ControlMode: ControlModePosition, MainParameter: ParameterFunctionPosition, FunctionalParameters: map[FunctionalParameter]ParameterFunction{FunctionalParameterFP1: ParameterFunctionUpperCurtainPosition, FunctionalParameterFP2: ParameterFunctionLowerCurtainPosition, FunctionalParameterFP3: ParameterFunctionSpeed}}},
+	NodeTypeOnOffSwitch:                      {"OnOffSwitch", "On/Off switch", mainParameterOnly(ControlModeOnOff, ParameterFunctionSwitchPosition)},
+	NodeTypeHorizontalAwning:                 {"HorizontalAwning", "Horizontal awning", positionWithSpeed()},
+	NodeTypeExteriorVenetianBlind:            {"ExteriorVenetianBlind", "Exterior venetian blind", positionWithSlats()},
+	NodeTypeLouverBlind:                      {"LouverBlind", "Louver blind", positionWithSlats()},
+	NodeTypeCurtainTrack:                     {"CurtainTrack", "Curtain track", positionWithSpeed()},
+	NodeTypeVentilationPoint:                 {"VentilationPoint", "Ventilation point", mainParameterOnly(ControlModeLevel, ParameterFunctionAirDemand)},
+	NodeTypeVentilationPointAirInlet:         {"VentilationPointAirInlet", "Ventilation point (air inlet)", mainParameterOnly(ControlModeLevel, ParameterFunctionAirDemand)},
+	NodeTypeVentilationPointAirTransfer:      {"VentilationPointAirTransfer", "Ventilation point (air transfer)", mainParameterOnly(ControlModeLevel, ParameterFunctionAirDemand)},
+	NodeTypeVentilationPointAirOutlet:        {"VentilationPointAirOutlet", "Ventilation point (air outlet)", mainParameterOnly(ControlModeLevel, ParameterFunctionAirDemand)},
+	NodeTypeExteriorHeating:                  {"ExteriorHeating", "Exterior heating", NodeCapabilities{ControlMode: ControlModeLevel, MainParameter: ParameterFunctionEnergyDemand, FunctionalParameters: map[FunctionalParameter]ParameterFunction{FunctionalParameterFP1: ParameterFunctionEnergyGradient}}},
+	NodeTypeExteriorHeatingWithoutGradient:   {"ExteriorHeatingWithoutGradient", "Exterior heating (energy demand only)", mainParameterOnly(ControlModeLevel, ParameterFunctionEnergyDemand)},
+	NodeTypeSwingingShutter:                  {"SwingingShutter", "Swinging shutters", positionWithSpeed()},
+	NodeTypeSwingingShutterIndependentLeaves: {"SwingingShutterIndependentLeaves", "Swinging shutter with independent handling of the leaves", positionWithSpeed()},
+}
+
+func (t NodeTypeSubType) lookup() (nodeTypeInfo, bool) {
+	if info, ok := nodeTypeCatalogue[t]; ok {
+		return info, true
+	}
+
+	// Unknown sub type: fallback on the generic sub type of the actuator type
+	info, ok := nodeTypeCatalogue[NewNodeTypeSubType(t.ActuatorType(), 0)]
+	return info, ok
+}
+
+// Indicates if the node type and sub type are listed in the specification
+func (t NodeTypeSubType) Known() bool {
+	_, ok := nodeTypeCatalogue[t]
+	return ok
+}
+
+// Human readable description of the node type
+func (t NodeTypeSubType) Description() string {
+	if info, ok := nodeTypeCatalogue[t]; ok {
+		return info.description
+	}
+
+	if info, ok := t.lookup(); ok {
+		return fmt.Sprintf("%s (sub type %d)", info.description, t.SubType())
+	}
+
+	return fmt.Sprintf("Unknown actuator type %d (sub type %d)", t.ActuatorType(), t.SubType())
+}
+
+// Parameters supported by the node type.
+// For unknown sub types, the capabilities of the generic sub type of the actuator type are returned.
+// For unknown actuator types, only the main parameter is reported, as a position.
+func (t NodeTypeSubType) Capabilities() NodeCapabilities {
+	if info, ok := t.lookup(); ok {
+		// do not expose the catalogue map
+		caps := info.capabilities
+		caps.FunctionalParameters = make(map[FunctionalParameter]ParameterFunction, len(info.capabilities.FunctionalParameters))
+		for param, function := range info.capabilities.FunctionalParameters {
+			caps.FunctionalParameters[param] = function
+		}
+
+		return caps
+	}
+
+	return mainParameterOnly(ControlModePosition, ParameterFunctionPosition)
+}
+
+func (t NodeTypeSubType) String() string {
+	if info, ok := nodeTypeCatalogue[t]; ok {
+		return info.name
+	}
+
+	return fmt.Sprintf("<%d.%d>", t.ActuatorType(), t.SubType())
+}
+
+func (t NodeTypeSubType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Accepts a catalogue name (eg: "RollerShutter"), "<type.subtype>" as produced by String, or a raw number
+func (t *NodeTypeSubType) UnmarshalText(text []byte) error {
+	value := string(text)
+
+	for nodeType, info := range nodeTypeCatalogue {
+		if info.name == value {
+			*t = nodeType
+			return nil
+		}
+	}
+
+	if strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">") {
+		parts := strings.Split(value[1:len(value)-1], ".")
+		if len(parts) == 2 {
+			actuatorType, err1 := strconv.Atoi(parts[0])
+			subType, err2 := strconv.Atoi(parts[1])
+			if err1 == nil && err2 == nil {
+				*t = NewNodeTypeSubType(ActuatorType(actuatorType), ActuatorSubType(subType))
+				return nil
+			}
+		}
+	}
+
+	number, err := strconv.ParseInt(value, 0, 32)
+	if err != nil {
+		return fmt.Errorf("unknown node type '%s'", value)
+	}
+
+	*t = NodeTypeSubType(number)
+	return nil
+}
+
+func (t ActuatorType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t ActuatorSubType) String() string {
+	return strconv.Itoa(int(t))
+}
+
+func (t IoManufacturer) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Product group of a node or of the gateway.
+//
+// The specification only defines the product group and type of the gateway itself (see GW_GET_VERSION_CFM).
+// The product type of a node is a manufacturer model number within its NodeTypeSubType (ex. KMG, KMX), which is not published.
+type ProductGroup int
+
+// Remote controls. The KLF 200 is a member of this product group
+const ProductGroupRemoteControl ProductGroup = 14
+
+// Product group of the KLF 200 (see GW_GET_VERSION_CFM)
+const ProductGroupGateway = ProductGroupRemoteControl
+
+func (g ProductGroup) String() string {
+	switch g {
+	case ProductGroupRemoteControl:
+		return "RemoteControl"
+	default:
+		return fmt.Sprintf("<%d>", g)
+	}
+}
+
+func (g ProductGroup) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+// Accepts a name as produced by String, "<n>", or a raw number
+func (g *ProductGroup) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, g, "product group", ProductGroupRemoteControl)
+}
+
+type ProductType int
+
+// Product type of the KLF 200 (see GW_GET_VERSION_CFM)
+const ProductTypeKlf200 ProductType = 3
+
+func (t ProductType) String() string {
+	switch t {
+	case ProductTypeKlf200:
+		return "KLF200"
+	default:
+		return fmt.Sprintf("<%d>", t)
+	}
+}
+
+func (t ProductType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Accepts a name as produced by String, "<n>", or a raw number
+func (t *ProductType) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, t, "product type", ProductTypeKlf200)
+}
+
+func (function ParameterFunction) String() string {
+	switch function {
+	case ParameterFunctionNone:
+		return "None"
+	case ParameterFunctionPosition:
+		return "Position"
+	case ParameterFunctionSpeed:
+		return "Speed"
+	case ParameterFunctionOrientation:
+		return "Orientation"
+	case ParameterFunctionOrientationSpeed:
+		return "OrientationSpeed"
+	case ParameterFunctionLightIntensity:
+		return "LightIntensity"
+	case ParameterFunctionLightIntensityGradient:
+		return "LightIntensityGradient"
+	case ParameterFunctionLockState:
+		return "LockState"
+	case ParameterFunctionSwitchPosition:
+		return "SwitchPosition"
+	case ParameterFunctionUpperCurtainPosition:
+		return "UpperCurtainPosition"
+	case ParameterFunctionLowerCurtainPosition:
+		return "LowerCurtainPosition"
+	case ParameterFunctionAirDemand:
+		return "AirDemand"
+	case ParameterFunctionEnergyDemand:
+		return "EnergyDemand"
+	case ParameterFunctionEnergyGradient:
+		return "EnergyGradient"
+	default:
+		return fmt.Sprintf("<%d>", function)
+	}
+}
+
+func (function ParameterFunction) MarshalText() ([]byte, error) {
+	return []byte(function.String()), nil
+}
+
+func (function *ParameterFunction) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, function, "parameter function",
+		ParameterFunctionNone, ParameterFunctionPosition, ParameterFunctionSpeed, ParameterFunctionOrientation, ParameterFunctionOrientationSpeed,
+		ParameterFunctionLightIntensity, ParameterFunctionLightIntensityGradient, ParameterFunctionLockState, ParameterFunctionSwitchPosition,
+		ParameterFunctionUpperCurtainPosition, ParameterFunctionLowerCurtainPosition, ParameterFunctionAirDemand, ParameterFunctionEnergyDemand,
+		ParameterFunctionEnergyGradient)
+}
+
+func (mode ControlMode) String() string {
+	switch mode {
+	case ControlModePosition:
+		return "Position"
+	case ControlModeOnOff:
+		return "OnOff"
+	case ControlModeLock:
+		return "Lock"
+	case ControlModeLevel:
+		return "Level"
+	default:
+		return fmt.Sprintf("<%d>", mode)
+	}
+}
+
+func (mode ControlMode) MarshalText() ([]byte, error) {
+	return []byte(mode.String()), nil
+}
+
+func (mode *ControlMode) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, mode, "control mode", ControlModePosition, ControlModeOnOff, ControlModeLock, ControlModeLevel)
+}
+
+func (param FunctionalParameter) String() string {
+	if param == FunctionalParameterMP {
+		return "MP"
+	}
+
+	return fmt.Sprintf("FP%d", int(param))
+}
+
+func (param FunctionalParameter) MarshalText() ([]byte, error) {
+	return []byte(param.String()), nil
+}
+
+// Accepts "MP" or "FP1" to "FP16"
+func (param *FunctionalParameter) UnmarshalText(text []byte) error {
+	value := string(text)
+
+	if value == "MP" {
+		*param = FunctionalParameterMP
+		return nil
+	}
+
+	if number, err := strconv.Atoi(strings.TrimPrefix(value, "FP")); err == nil && strings.HasPrefix(value, "FP") && number >= 1 && number <= 16 {
+		*param = FunctionalParameter(number)
+		return nil
+	}
+
+	return fmt.Errorf("unknown functional parameter '%s'", value)
+}
+
+// Parse text as the name of one of values (as produced by String), "<n>" or a raw number
+func unmarshalEnum[T interface {
+	~int
+	fmt.Stringer
+}](text []byte, target *T, kind string, values ...T) error {
+	value := string(text)
+
+	for _, candidate := range values {
+		if candidate.String() == value {
+			*target = candidate
+			return nil
+		}
+	}
+
+	number, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">"), 0, 32)
+	if err != nil {
+		return fmt.Errorf("unknown %s '%s'", kind, value)
+	}
+
+	*target = T(number)
+	return nil
+}
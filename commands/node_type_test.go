@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNodeTypeSubTypeTextRoundTrip(t *testing.T) {
+	types := []NodeTypeSubType{
+		// unknown sub type of a known actuator type, and unknown actuator type
+		NewNodeTypeSubType(RollerShutter, 5),
+		NewNodeTypeSubType(ActuatorType(0x3FF), 0x3F),
+	}
+
+	for nodeType := range nodeTypeCatalogue {
+		types = append(types, nodeType)
+	}
+
+	for _, nodeType := range types {
+		text, err := nodeType.MarshalText()
+		if err != nil {
+			t.Fatalf("%d: marshal: %s", nodeType, err)
+		}
+
+		var parsed NodeTypeSubType
+		if err := parsed.UnmarshalText(text); err != nil {
+			t.Fatalf("%s: unmarshal: %s", text, err)
+		}
+
+		if parsed != nodeType {
+			t.Errorf("%s: got %d, expected %d", text, parsed, nodeType)
+		}
+	}
+}
+
+func TestNodeTypeSubTypeUnmarshalNumber(t *testing.T) {
+	var nodeType NodeTypeSubType
+	if err := nodeType.UnmarshalText([]byte("0x0101")); err != nil {
+		t.Fatal(err)
+	}
+
+	if nodeType != NodeTypeWindowOpenerWithRainSensor {
+		t.Errorf("got %s", nodeType)
+	}
+
+	if err := nodeType.UnmarshalText([]byte("NotAType")); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestNodeTypeSubTypeFallback(t *testing.T) {
+	nodeType := NewNodeTypeSubType(RollerShutter, 5)
+
+	if nodeType.Known() {
+		t.Error("sub type 5 of roller shutter should not be known")
+	}
+
+	if got, expected := nodeType.Capabilities(), NodeTypeRollerShutter.Capabilities(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v, expected the generic roller shutter capabilities %+v", got, expected)
+	}
+
+	if got, expected := nodeType.Description(), "Roller shutter (sub type 5)"; got != expected {
+		t.Errorf("got '%s', expected '%s'", got, expected)
+	}
+}
+
+func TestNodeCapabilitiesJSONRoundTrip(t *testing.T) {
+	for nodeType := range nodeTypeCatalogue {
+		caps := nodeType.Capabilities()
+
+		data, err := json.Marshal(caps)
+		if err != nil {
+			t.Fatalf("%s: marshal: %s", nodeType, err)
+		}
+
+		var parsed NodeCapabilities
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			t.Fatalf("%s: unmarshal %s: %s", nodeType, data, err)
+		}
+
+		if !reflect.DeepEqual(parsed, caps) {
+			t.Errorf("%s: got %+v, expected %+v", nodeType, parsed, caps)
+		}
+	}
+}
+
+func TestNodeCapabilitiesJSON(t *testing.T) {
+	data, err := json.Marshal(NodeTypeDualRollerShutter.Capabilities())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"ControlMode":"Position","MainParameter":"Position","FunctionalParameters":{"FP1":"UpperCurtainPosition","FP2":"LowerCurtainPosition","FP3":"Speed"}}`
+	if string(data) != expected {
+		t.Errorf("got %s, expected %s", data, expected)
+	}
+}
+
+func TestControlModeTextRoundTrip(t *testing.T) {
+	for _, mode := range []ControlMode{ControlModePosition, ControlModeOnOff, ControlModeLock, ControlModeLevel, ControlMode(42)} {
+		text, _ := mode.MarshalText()
+
+		var parsed ControlMode
+		if err := parsed.UnmarshalText(text); err != nil {
+			t.Fatalf("%s: %s", text, err)
+		}
+
+		if parsed != mode {
+			t.Errorf("%s: got %d, expected %d", text, parsed, mode)
+		}
+	}
+
+	var mode ControlMode
+	if err := mode.UnmarshalText([]byte("Dimmer")); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestFunctionalParameterUnmarshalText(t *testing.T) {
+	for _, text := range []string{"FP0", "FP17", "XP1", "FP", ""} {
+		var param FunctionalParameter
+		if err := param.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("'%s': expected an error, got %s", text, param)
+		}
+	}
+}
+
+func TestProductTextRoundTrip(t *testing.T) {
+	for _, group := range []ProductGroup{ProductGroupGateway, ProductGroup(2)} {
+		text, _ := group.MarshalText()
+
+		var parsed ProductGroup
+		if err := parsed.UnmarshalText(text); err != nil || parsed != group {
+			t.Errorf("%s: got %d (%v), expected %d", text, parsed, err, group)
+		}
+	}
+
+	for _, typ := range []ProductType{ProductTypeKlf200, ProductType(13)} {
+		text, _ := typ.MarshalText()
+
+		var parsed ProductType
+		if err := parsed.UnmarshalText(text); err != nil || parsed != typ {
+			t.Errorf("%s: got %d (%v), expected %d", text, parsed, err, typ)
+		}
+	}
+}
@@ -4,15 +4,21 @@ import (
 	"bytes"
 	"fmt"
 
+	"github.com/mylife-home/klf200-go/binary"
 	"github.com/mylife-home/klf200-go/transport"
 )
 
 type PasswordChangeReq struct {
-	Password string
+	CurrentPassword string
+	NewPassword     string
 }
 
 var _ Request = (*PasswordChangeReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &PasswordChangeReq{} })
+}
+
 func (req *PasswordChangeReq) Code() transport.Command {
 	return transport.GW_PASSWORD_CHANGE_REQ
 }
@@ -22,19 +28,31 @@ func (req *PasswordChangeReq) NewConfirm() Confirm {
 }
 
 func (req *PasswordChangeReq) Write() ([]byte, error) {
-	array := []byte(req.Password)
-	if len(array) > 32 {
-		return nil, fmt.Errorf("password too long")
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	if err := writeString(writer, req.CurrentPassword, 32); err != nil {
+		return nil, fmt.Errorf("current password: %w", err)
+	}
+
+	if err := writeString(writer, req.NewPassword, 32); err != nil {
+		return nil, fmt.Errorf("new password: %w", err)
 	}
 
-	remain := 32 - len(array)
+	return buff.Bytes(), nil
+}
 
-	if remain > 0 {
-		pad := make([]byte, remain)
-		array = append(array, pad...)
+func (req *PasswordChangeReq) Read(data []byte) error {
+	if len(data) != 64 {
+		return fmt.Errorf("bad length")
 	}
 
-	return array, nil
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+
+	req.CurrentPassword = readString(reader, 32)
+	req.NewPassword = readString(reader, 32)
+
+	return nil
 }
 
 type PasswordChangeCfm struct {
@@ -63,6 +81,14 @@ func (cfm *PasswordChangeCfm) Read(data []byte) error {
 	return nil
 }
 
+func (cfm *PasswordChangeCfm) Write() ([]byte, error) {
+	if cfm.Success {
+		return []byte{0}, nil
+	}
+
+	return []byte{1}, nil
+}
+
 type PasswordChangeNtf struct {
 	NewPassword string
 }
@@ -88,3 +114,14 @@ func (ntf *PasswordChangeNtf) Read(data []byte) error {
 
 	return nil
 }
+
+func (ntf *PasswordChangeNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	if err := writeString(writer, ntf.NewPassword, 32); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
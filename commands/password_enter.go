@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/mylife-home/klf200-go/transport"
@@ -12,6 +13,10 @@ type PasswordEnterReq struct {
 
 var _ Request = (*PasswordEnterReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &PasswordEnterReq{} })
+}
+
 func (req *PasswordEnterReq) Code() transport.Command {
 	return transport.GW_PASSWORD_ENTER_REQ
 }
@@ -36,6 +41,16 @@ func (req *PasswordEnterReq) Write() ([]byte, error) {
 	return array, nil
 }
 
+func (req *PasswordEnterReq) Read(data []byte) error {
+	if len(data) != 32 {
+		return fmt.Errorf("bad length")
+	}
+
+	req.Password = string(bytes.TrimRight(data, "\x00"))
+
+	return nil
+}
+
 type PasswordEnterCfm struct {
 	Success bool
 }
@@ -61,3 +76,11 @@ func (cfm *PasswordEnterCfm) Read(data []byte) error {
 	}
 	return nil
 }
+
+func (cfm *PasswordEnterCfm) Write() ([]byte, error) {
+	if cfm.Success {
+		return []byte{0}, nil
+	}
+
+	return []byte{1}, nil
+}
@@ -11,6 +11,10 @@ type RebootReq struct {
 
 var _ Request = (*RebootReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &RebootReq{} })
+}
+
 func (req *RebootReq) Code() transport.Command {
 	return transport.GW_REBOOT_REQ
 }
@@ -23,6 +27,14 @@ func (req *RebootReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *RebootReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type RebootCfm struct {
 }
 
@@ -39,3 +51,7 @@ func (cfm *RebootCfm) Read(data []byte) error {
 
 	return nil
 }
+
+func (cfm *RebootCfm) Write() ([]byte, error) {
+	return emptyData, nil
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/mylife-home/klf200-go/transport"
@@ -12,6 +13,10 @@ type RtcSetTimeZoneReq struct {
 
 var _ Request = (*RtcSetTimeZoneReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &RtcSetTimeZoneReq{} })
+}
+
 func (req *RtcSetTimeZoneReq) Code() transport.Command {
 	return transport.GW_RTC_SET_TIME_ZONE_REQ
 }
@@ -36,6 +41,16 @@ func (req *RtcSetTimeZoneReq) Write() ([]byte, error) {
 	return array, nil
 }
 
+func (req *RtcSetTimeZoneReq) Read(data []byte) error {
+	if len(data) != 64 {
+		return fmt.Errorf("bad length")
+	}
+
+	req.TimeZoneString = string(bytes.TrimRight(data, "\x00"))
+
+	return nil
+}
+
 type RtcSetTimeZoneCfm struct {
 	Success bool
 }
@@ -60,5 +75,12 @@ func (cfm *RtcSetTimeZoneCfm) Read(data []byte) error {
 		return fmt.Errorf("bad status")
 	}
 	return nil
+}
+
+func (cfm *RtcSetTimeZoneCfm) Write() ([]byte, error) {
+	if cfm.Success {
+		return []byte{1}, nil
+	}
 
+	return []byte{0}, nil
 }
@@ -11,6 +11,10 @@ type SetFactoryDefaultReq struct {
 
 var _ Request = (*SetFactoryDefaultReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &SetFactoryDefaultReq{} })
+}
+
 func (req *SetFactoryDefaultReq) Code() transport.Command {
 	return transport.GW_SET_FACTORY_DEFAULT_REQ
 }
@@ -23,6 +27,14 @@ func (req *SetFactoryDefaultReq) Write() ([]byte, error) {
 	return emptyData, nil
 }
 
+func (req *SetFactoryDefaultReq) Read(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("bad length")
+	}
+
+	return nil
+}
+
 type SetFactoryDefaultCfm struct {
 }
 
@@ -39,3 +51,7 @@ func (cfm *SetFactoryDefaultCfm) Read(data []byte) error {
 
 	return nil
 }
+
+func (cfm *SetFactoryDefaultCfm) Write() ([]byte, error) {
+	return emptyData, nil
+}
@@ -18,6 +18,10 @@ type SetNetworkSetupReq struct {
 
 var _ Request = (*SetNetworkSetupReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &SetNetworkSetupReq{} })
+}
+
 func (req *SetNetworkSetupReq) Code() transport.Command {
 	return transport.GW_SET_NETWORK_SETUP_REQ
 }
@@ -45,6 +49,27 @@ func (req *SetNetworkSetupReq) Write() ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
+func (req *SetNetworkSetupReq) Read(data []byte) error {
+	if len(data) != 13 {
+		return fmt.Errorf("bad length")
+	}
+
+	req.IpAddress = net.IPv4(data[0], data[1], data[2], data[3])
+	req.Mask = net.IPv4Mask(data[4], data[5], data[6], data[7])
+	req.DefGW = net.IPv4(data[8], data[9], data[10], data[11])
+
+	switch data[12] {
+	case 0:
+		req.DHCP = false
+	case 1:
+		req.DHCP = true
+	default:
+		return fmt.Errorf("bad DHCP flag %d", data[12])
+	}
+
+	return nil
+}
+
 type SetNetworkSetupCfm struct {
 }
 
@@ -61,3 +86,7 @@ func (cfm *SetNetworkSetupCfm) Read(data []byte) error {
 
 	return nil
 }
+
+func (cfm *SetNetworkSetupCfm) Write() ([]byte, error) {
+	return emptyData, nil
+}
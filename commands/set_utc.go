@@ -15,6 +15,10 @@ type SetUtcReq struct {
 
 var _ Request = (*SetUtcReq)(nil)
 
+func init() {
+	registerRequest(func() Request { return &SetUtcReq{} })
+}
+
 func (req *SetUtcReq) Code() transport.Command {
 	return transport.GW_SET_UTC_REQ
 }
@@ -31,6 +35,19 @@ func (req *SetUtcReq) Write() ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
+func (req *SetUtcReq) Read(data []byte) error {
+	if len(data) != 4 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+
+	timestamp, _ := reader.ReadU32()
+	req.Timestamp = time.Unix(int64(timestamp), 0)
+
+	return nil
+}
+
 type SetUtcCfm struct {
 }
 
@@ -47,3 +64,7 @@ func (cfm *SetUtcCfm) Read(data []byte) error {
 
 	return nil
 }
+
+func (cfm *SetUtcCfm) Write() ([]byte, error) {
+	return emptyData, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/mylife-home/klf200-go/binary"
@@ -47,11 +48,24 @@ func (t StatusRequestStatusType) String() string {
 }
 
 var _ Request = (*StatusRequestReq)(nil)
+var _ SessionCommand = (*StatusRequestReq)(nil)
+
+func init() {
+	registerRequest(func() Request { return &StatusRequestReq{} })
+}
 
 func (req *StatusRequestReq) Code() transport.Command {
 	return transport.GW_STATUS_REQUEST_REQ
 }
 
+func (req *StatusRequestReq) GetSessionID() int {
+	return req.SessionID
+}
+
+func (req *StatusRequestReq) SetSessionID(id int) {
+	req.SessionID = id
+}
+
 func (req *StatusRequestReq) NewConfirm() Confirm {
 	return &StatusRequestCfm{}
 }
@@ -109,17 +123,68 @@ func (req *StatusRequestReq) Write() ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
+func (req *StatusRequestReq) Read(data []byte) error {
+	if len(data) != 26 {
+		return fmt.Errorf("bad length")
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+	var u8 uint8
+	var u16 uint16
+
+	u16, _ = reader.ReadU16()
+	req.SessionID = int(u16)
+
+	u8, _ = reader.ReadU8()
+	if u8 < 1 || u8 > 20 {
+		return fmt.Errorf("bad node indexes len (got %d, expected > 0 && <= 20)", u8)
+	}
+
+	req.NodeIndexes = make([]int, u8)
+	for index := 0; index < 20; index++ {
+		value, _ := reader.ReadU8()
+		if index < len(req.NodeIndexes) {
+			req.NodeIndexes[index] = int(value)
+		}
+	}
+
+	u8, _ = reader.ReadU8()
+	req.StatusType = StatusRequestStatusType(u8)
+
+	bitmap, _ := reader.ReadU16()
+
+	req.FunctionalParameters = make(map[FunctionalParameter]bool)
+
+	for index := 1; index < 17; index++ {
+		param := FunctionalParameter(index)
+		if bitmap&(1<<(16-param)) != 0 {
+			req.FunctionalParameters[param] = true
+		}
+	}
+
+	return nil
+}
+
 type StatusRequestCfm struct {
 	SessionID int
 	Success   bool
 }
 
 var _ Confirm = (*StatusRequestCfm)(nil)
+var _ SessionCommand = (*StatusRequestCfm)(nil)
 
 func (cfm *StatusRequestCfm) Code() transport.Command {
 	return transport.GW_STATUS_REQUEST_CFM
 }
 
+func (cfm *StatusRequestCfm) GetSessionID() int {
+	return cfm.SessionID
+}
+
+func (cfm *StatusRequestCfm) SetSessionID(id int) {
+	cfm.SessionID = id
+}
+
 func (cfm *StatusRequestCfm) Read(data []byte) error {
 	if len(data) != 3 {
 		return fmt.Errorf("bad length")
@@ -145,6 +210,22 @@ func (cfm *StatusRequestCfm) Read(data []byte) error {
 	return nil
 }
 
+func (cfm *StatusRequestCfm) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(cfm.SessionID))
+
+	var status uint8 = 0
+	if cfm.Success {
+		status = 1
+	}
+
+	writer.WriteU8(status)
+
+	return buff.Bytes(), nil
+}
+
 type StatusRequestNtf struct {
 	SessionID   int
 	StatusID    CommandRunOwner
@@ -156,6 +237,7 @@ type StatusRequestNtf struct {
 }
 
 var _ Notify = (*StatusRequestNtf)(nil)
+var _ SessionCommand = (*StatusRequestNtf)(nil)
 
 func init() {
 	registerNotify(func() Notify { return &StatusRequestNtf{} })
@@ -165,6 +247,14 @@ func (ntf *StatusRequestNtf) Code() transport.Command {
 	return transport.GW_STATUS_REQUEST_NTF
 }
 
+func (ntf *StatusRequestNtf) GetSessionID() int {
+	return ntf.SessionID
+}
+
+func (ntf *StatusRequestNtf) SetSessionID(id int) {
+	ntf.SessionID = id
+}
+
 func (ntf *StatusRequestNtf) Read(data []byte) error {
 	if len(data) < 6 {
 		return fmt.Errorf("bad length")
@@ -218,9 +308,32 @@ func (ntf *StatusRequestNtf) Read(data []byte) error {
 	return nil
 }
 
+func (ntf *StatusRequestNtf) Write() ([]byte, error) {
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.WriteU16(uint16(ntf.SessionID))
+	writer.WriteU8(uint8(ntf.StatusID))
+	writer.WriteU8(uint8(ntf.NodeIndex))
+	writer.WriteU8(uint8(ntf.RunStatus))
+	writer.WriteU8(uint8(ntf.StatusReply))
+	writer.WriteU8(uint8(ntf.StatusType))
+
+	if ntf.StatusData == nil {
+		return nil, errors.New("missing status data")
+	}
+
+	if err := ntf.StatusData.write(writer); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
 // StatusDataParameters or StatusDataMainInfo
 type StatusData interface {
 	read(reader binary.BinaryReader)
+	write(writer binary.BinaryWriter) error
 }
 
 // Filled when StatusType = "Target Position" or StatusType = "Current Position" or StatusType = "Remaining Time"
@@ -230,19 +343,50 @@ type StatusDataParameters struct {
 
 func (data *StatusDataParameters) read(reader binary.BinaryReader) {
 	var count uint8
-	var typ uint16
+	var typ uint8
 	var value uint16
 
 	count, _ = reader.ReadU8()
 
 	data.ParameterValues = make(map[FunctionalParameter]int)
 
-	for index := 0; index < int(count); index++ {
-		typ, _ = reader.ReadU16()
+	// Fixed array of 17 entries, only the first count ones are significant
+	for index := 0; index < 17; index++ {
+		typ, _ = reader.ReadU8()
 		value, _ = reader.ReadU16()
 
-		data.ParameterValues[FunctionalParameter(typ)] = int(value)
+		if index < int(count) {
+			data.ParameterValues[FunctionalParameter(typ)] = int(value)
+		}
+	}
+}
+
+func (data *StatusDataParameters) write(writer binary.BinaryWriter) error {
+	params := make([]FunctionalParameter, 0, len(data.ParameterValues))
+	for param := range data.ParameterValues {
+		if param < FunctionalParameterMP || param > FunctionalParameterFP16 {
+			return fmt.Errorf("bad functional parameter %d", param)
+		}
+
+		params = append(params, param)
+	}
+
+	slices.Sort(params)
+
+	writer.WriteU8(uint8(len(params)))
+
+	for index := 0; index < 17; index++ {
+		if index < len(params) {
+			param := params[index]
+			writer.WriteU8(uint8(param))
+			writer.WriteU16(uint16(data.ParameterValues[param]))
+		} else {
+			writer.WriteU8(0)
+			writer.WriteU16(0)
+		}
 	}
+
+	return nil
 }
 
 // Filled when StatusType = "Main info"
@@ -274,3 +418,13 @@ func (data *StatusDataMainInfo) read(reader binary.BinaryReader) {
 	u8, _ = reader.ReadU8()
 	data.LastCommandOriginator = CommandOriginator(u8)
 }
+
+func (data *StatusDataMainInfo) write(writer binary.BinaryWriter) error {
+	writer.WriteU16(uint16(data.TargetPosition))
+	writer.WriteU16(uint16(data.CurrentPosition))
+	writer.WriteU16(uint16(data.RemainingTime / time.Second))
+	writer.WriteU32(data.LastMasterExecutionAddress)
+	writer.WriteU8(uint8(data.LastCommandOriginator))
+
+	return nil
+}
@@ -2,7 +2,6 @@ package klf200
 
 import (
 	"context"
-	"reflect"
 
 	"github.com/mylife-home/klf200-go/commands"
 	"github.com/mylife-home/klf200-go/utils"
@@ -28,11 +27,11 @@ func (config *Config) GetSystemTable(ctx context.Context) ([]commands.Systemtabl
 
 	defer config.sysTableTrans.Unlock()
 
-	n := config.client.RegisterNotifications([]reflect.Type{reflect.TypeOf(&commands.CsGetSystemtableDataNtf{})})
+	trans := newTransaction[*commands.CsGetSystemtableDataNtf](config.client, nil)
 
-	defer n.Close()
+	defer trans.close()
 
-	_, err := config.client.execute(&commands.CsGetSystemtableDataReq{})
+	_, err := trans.execute(ctx, &commands.CsGetSystemtableDataReq{})
 	if err != nil {
 		return nil, err
 	}
@@ -40,13 +39,11 @@ func (config *Config) GetSystemTable(ctx context.Context) ([]commands.Systemtabl
 	objects := make([]commands.SystemtableObject, 0)
 
 	for {
-		notif, err := config.selectNotif(ctx, n)
+		packet, err := trans.next(ctx)
 		if err != nil {
 			return nil, err
 		}
 
-		packet := notif.(*commands.CsGetSystemtableDataNtf)
-
 		for index := 0; index < packet.NumberOfEntry; index++ {
 			object := packet.Objects[index]
 			objects = append(objects, object)
@@ -60,17 +57,6 @@ func (config *Config) GetSystemTable(ctx context.Context) ([]commands.Systemtabl
 	return objects, nil
 }
 
-func (config *Config) selectNotif(ctx context.Context, n Notifier) (commands.Notify, error) {
-	select {
-	// TODO: handle disconnection
-	case <-ctx.Done():
-		return nil, ctx.Err()
-
-	case notif := <-n.Stream():
-		return notif, nil
-	}
-}
-
 // TODO: keep systemtable data cached and listen to GW_CS_SYSTEM_TABLE_UPDATE_NTF to refresh
 
 // TODO: missing API
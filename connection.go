@@ -15,25 +15,28 @@ type connection struct {
 	errors     chan error
 	exit       chan struct{}
 	decoder    transport.SlipDecoder
+	pending    [][]byte
 	workerSync sync.WaitGroup
+	record     func(direction FrameDirection, frame *transport.Frame)
 	log        Logger
 }
 
 var errConnectionRemotelyClosed = errors.New("connection closed by remote side")
 
-func makeConnection(ctx context.Context, address string, log Logger) (*connection, error) {
-	sock, err := makeSocket(ctx, address)
+func makeConnection(ctx context.Context, address string, record func(FrameDirection, *transport.Frame), options *clientOptions) (*connection, error) {
+	sock, err := makeSocket(ctx, address, options)
 	if err != nil {
 		return nil, err
 	}
 
 	conn := &connection{
 		sock:   sock,
-		write:  make(chan *transport.Frame, 10),
-		read:   make(chan *transport.Frame, 10),
-		errors: make(chan error, 10),
+		write:  make(chan *transport.Frame, options.writeChannelSize),
+		read:   make(chan *transport.Frame, options.readChannelSize),
+		errors: make(chan error, errorsChannelSize),
 		exit:   make(chan struct{}, 1),
-		log:    log,
+		record: record,
+		log:    options.log,
 	}
 
 	conn.workerSync.Add(1)
@@ -46,6 +49,14 @@ func (conn *connection) worker() {
 	defer conn.workerSync.Done()
 
 	for {
+		// The reads go on while the socket does not accept the writes
+		var write chan<- []byte
+		var next []byte
+		if len(conn.pending) > 0 {
+			write = conn.sock.write
+			next = conn.pending[0]
+		}
+
 		select {
 		case <-conn.exit:
 			return
@@ -58,6 +69,9 @@ func (conn *connection) worker() {
 
 		case frame := <-conn.write:
 			conn.processWrite(frame)
+
+		case write <- next:
+			conn.pending = conn.pending[1:]
 		}
 	}
 }
@@ -86,21 +100,33 @@ func (conn *connection) processRead(data []byte) {
 		}
 
 		// conn.log.Debugf("Recv frame %v", frame)
+		if conn.record != nil {
+			conn.record(FrameReceived, frame)
+		}
+
 		conn.read <- frame
 	}
 }
 
 func (conn *connection) processWrite(frame *transport.Frame) {
 	// conn.log.Debugf("Send frame %v", frame)
+	if conn.record != nil {
+		conn.record(FrameSent, frame)
+	}
 
 	buffer := transport.SlipEncode(frame.Write())
-	conn.sock.Write(buffer.Bytes())
+	conn.pending = append(conn.pending, buffer.Bytes())
 }
 
 func (conn *connection) Write(frame *transport.Frame) {
 	conn.write <- frame
 }
 
+// Same as Write, for the callers which must not block on it
+func (conn *connection) Writes() chan<- *transport.Frame {
+	return conn.write
+}
+
 func (conn *connection) Read() <-chan *transport.Frame {
 	return conn.read
 }
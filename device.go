@@ -1,19 +1,25 @@
 package klf200
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/mylife-home/klf200-go/commands"
 )
 
+// Gateway management requests. The methods without context use context.Background(), each request still times out after the request timeout (see WithRequestTimeout)
 type Device struct {
 	client *Client
 }
 
 func (dev *Device) ChangePassword(newPassword string) error {
-	req := &commands.PasswordChangeReq{Password: newPassword}
-	cfm, err := dev.client.execute(req)
+	return dev.ChangePasswordContext(context.Background(), newPassword)
+}
+
+func (dev *Device) ChangePasswordContext(ctx context.Context, newPassword string) error {
+	req := &commands.PasswordChangeReq{CurrentPassword: dev.client.currentPassword(), NewPassword: newPassword}
+	cfm, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -22,12 +28,19 @@ func (dev *Device) ChangePassword(newPassword string) error {
 		return errors.New("the request failed")
 	}
 
+	// Use the new password on next connections
+	dev.client.setPassword(newPassword)
+
 	return nil
 }
 
 func (dev *Device) GetVersion() (*commands.GetVersionCfm, error) {
+	return dev.GetVersionContext(context.Background())
+}
+
+func (dev *Device) GetVersionContext(ctx context.Context) (*commands.GetVersionCfm, error) {
 	req := &commands.GetVersionReq{}
-	cfm, err := dev.client.execute(req)
+	cfm, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -36,8 +49,12 @@ func (dev *Device) GetVersion() (*commands.GetVersionCfm, error) {
 }
 
 func (dev *Device) GetProtocolVersion() (*commands.GetProtocolVersionCfm, error) {
+	return dev.GetProtocolVersionContext(context.Background())
+}
+
+func (dev *Device) GetProtocolVersionContext(ctx context.Context) (*commands.GetProtocolVersionCfm, error) {
 	req := &commands.GetProtocolVersionReq{}
-	cfm, err := dev.client.execute(req)
+	cfm, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -46,8 +63,12 @@ func (dev *Device) GetProtocolVersion() (*commands.GetProtocolVersionCfm, error)
 }
 
 func (dev *Device) GetState() (*commands.GetStateCfm, error) {
+	return dev.GetStateContext(context.Background())
+}
+
+func (dev *Device) GetStateContext(ctx context.Context) (*commands.GetStateCfm, error) {
 	req := &commands.GetStateReq{}
-	cfm, err := dev.client.execute(req)
+	cfm, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -56,8 +77,12 @@ func (dev *Device) GetState() (*commands.GetStateCfm, error) {
 }
 
 func (dev *Device) LeaveLearnState() error {
+	return dev.LeaveLearnStateContext(context.Background())
+}
+
+func (dev *Device) LeaveLearnStateContext(ctx context.Context) error {
 	req := &commands.LeaveLearnStateReq{}
-	cfm, err := dev.client.execute(req)
+	cfm, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -70,8 +95,12 @@ func (dev *Device) LeaveLearnState() error {
 }
 
 func (dev *Device) SetUtc(timestamp time.Time) error {
+	return dev.SetUtcContext(context.Background(), timestamp)
+}
+
+func (dev *Device) SetUtcContext(ctx context.Context, timestamp time.Time) error {
 	req := &commands.SetUtcReq{Timestamp: timestamp}
-	_, err := dev.client.execute(req)
+	_, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -80,9 +109,13 @@ func (dev *Device) SetUtc(timestamp time.Time) error {
 }
 
 func (dev *Device) SetTimeZone(tzstr string) error {
+	return dev.SetTimeZoneContext(context.Background(), tzstr)
+}
+
+func (dev *Device) SetTimeZoneContext(ctx context.Context, tzstr string) error {
 	// TODO: help create tzstr
 	req := &commands.RtcSetTimeZoneReq{TimeZoneString: tzstr}
-	cfm, err := dev.client.execute(req)
+	cfm, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -95,8 +128,12 @@ func (dev *Device) SetTimeZone(tzstr string) error {
 }
 
 func (dev *Device) GetLocalTime() (*commands.GetLocalTimeCfm, error) {
+	return dev.GetLocalTimeContext(context.Background())
+}
+
+func (dev *Device) GetLocalTimeContext(ctx context.Context) (*commands.GetLocalTimeCfm, error) {
 	req := &commands.GetLocalTimeReq{}
-	cfm, err := dev.client.execute(req)
+	cfm, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -105,8 +142,12 @@ func (dev *Device) GetLocalTime() (*commands.GetLocalTimeCfm, error) {
 }
 
 func (dev *Device) Reboot() error {
+	return dev.RebootContext(context.Background())
+}
+
+func (dev *Device) RebootContext(ctx context.Context) error {
 	req := &commands.RebootReq{}
-	_, err := dev.client.execute(req)
+	_, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -115,8 +156,12 @@ func (dev *Device) Reboot() error {
 }
 
 func (dev *Device) SetFactoryDefault() error {
+	return dev.SetFactoryDefaultContext(context.Background())
+}
+
+func (dev *Device) SetFactoryDefaultContext(ctx context.Context) error {
 	req := &commands.SetFactoryDefaultReq{}
-	_, err := dev.client.execute(req)
+	_, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -125,8 +170,12 @@ func (dev *Device) SetFactoryDefault() error {
 }
 
 func (dev *Device) GetNetworkSetup() (*commands.GetNetworkSetupCfm, error) {
+	return dev.GetNetworkSetupContext(context.Background())
+}
+
+func (dev *Device) GetNetworkSetupContext(ctx context.Context) (*commands.GetNetworkSetupCfm, error) {
 	req := &commands.GetNetworkSetupReq{}
-	cfm, err := dev.client.execute(req)
+	cfm, err := dev.client.executeContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,102 @@
+package klf200
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/faultinject"
+	"github.com/mylife-home/klf200-go/simulator"
+)
+
+// Connected client whose requests never reach the gateway. Without the context, they would only end after testTimeout
+func startSilentClient(t *testing.T) *Client {
+	t.Helper()
+
+	gw := startSimulator(t, simulator.Config{Nodes: testNodes()})
+	inj := faultinject.NewInjector(1)
+	client := startClient(t, gw, WithConnWrapper(inj.Wrap), WithRequestTimeout(testTimeout), WithRetryPolicy(NoRetryPolicy()))
+
+	inj.AddRule(faultinject.Rule{Direction: faultinject.DirectionToGateway, Fault: faultinject.FaultDrop, Probability: faultinject.Always})
+
+	return client
+}
+
+func silentGatewayCalls(client *Client) map[string]func(ctx context.Context) error {
+	dev := client.Device()
+
+	return map[string]func(ctx context.Context) error{
+		"ChangePassword": func(ctx context.Context) error { return dev.ChangePasswordContext(ctx, "new-password") },
+		"GetVersion": func(ctx context.Context) error {
+			_, err := dev.GetVersionContext(ctx)
+			return err
+		},
+		"GetProtocolVersion": func(ctx context.Context) error {
+			_, err := dev.GetProtocolVersionContext(ctx)
+			return err
+		},
+		"GetState": func(ctx context.Context) error {
+			_, err := dev.GetStateContext(ctx)
+			return err
+		},
+		"LeaveLearnState": dev.LeaveLearnStateContext,
+		"SetUtc":          func(ctx context.Context) error { return dev.SetUtcContext(ctx, time.Now()) },
+		"SetTimeZone": func(ctx context.Context) error {
+			return dev.SetTimeZoneContext(ctx, ":GMT+1:GMT+2:0060:(1994)040102-0:110102-0")
+		},
+		"GetLocalTime": func(ctx context.Context) error {
+			_, err := dev.GetLocalTimeContext(ctx)
+			return err
+		},
+		"Reboot":            dev.RebootContext,
+		"SetFactoryDefault": dev.SetFactoryDefaultContext,
+		"GetNetworkSetup": func(ctx context.Context) error {
+			_, err := dev.GetNetworkSetupContext(ctx)
+			return err
+		},
+		"StopScene": func(ctx context.Context) error { return client.Scenes().StopContext(ctx, 0) },
+	}
+}
+
+func TestSilentGatewayDeadline(t *testing.T) {
+	client := startSilentClient(t)
+
+	for name, call := range silentGatewayCalls(client) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+
+		start := time.Now()
+		err := call(ctx)
+		cancel()
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: got %v, expected the deadline to be exceeded", name, err)
+		}
+
+		if elapsed := time.Since(start); elapsed > testTimeout/2 {
+			t.Errorf("%s: returned after %s", name, elapsed)
+		}
+	}
+}
+
+func TestSilentGatewayCancel(t *testing.T) {
+	client := startSilentClient(t)
+
+	for name, call := range silentGatewayCalls(client) {
+		ctx, cancel := context.WithCancel(context.Background())
+		timer := time.AfterFunc(time.Millisecond*50, cancel)
+
+		start := time.Now()
+		err := call(ctx)
+		timer.Stop()
+		cancel()
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: got %v, expected the cancellation", name, err)
+		}
+
+		if elapsed := time.Since(start); elapsed > testTimeout/2 {
+			t.Errorf("%s: returned after %s", name, elapsed)
+		}
+	}
+}
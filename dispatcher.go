@@ -0,0 +1,372 @@
+package klf200
+
+import (
+	"context"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type request struct {
+	ctx context.Context
+	req commands.Request
+	// Buffered so that the dispatcher never blocks on it
+	result chan *requestResult
+}
+
+type requestResult struct {
+	cfm commands.Confirm
+	err error
+}
+
+// Confirm of an abandoned request (timed out or canceled), which may still come
+type staleConfirm struct {
+	expires   time.Time
+	sessionID int
+	session   bool
+}
+
+// Owns an open connection: sends the requests one at a time, correlates the confirms and dispatches the notifications.
+// All its state is only accessed from its goroutine, the other ones communicate with it through channels.
+type dispatcher struct {
+	client   *Client
+	conn     *connection
+	requests chan *request
+	cancels  chan *request
+	done     chan struct{}
+
+	queue    []*request
+	inflight *request
+	expected transport.Command
+	sentAt   time.Time
+	timer    *time.Timer
+	timeout  <-chan time.Time
+	// Error of the in-flight request when the timer fires: ErrTimeout, or the error of its context if its deadline comes first
+	timeoutErr error
+
+	// Frames not yet accepted by the connection: a stalled write must not hold the confirms and the notifications
+	outbox []*transport.Frame
+
+	// Until their late confirm comes or the quarantine ends, no request expecting the same confirm is sent,
+	// so that it cannot be taken for the confirm of the next one
+	stale           map[transport.Command]*staleConfirm
+	staleTimer      *time.Timer
+	staleExpiration <-chan time.Time
+}
+
+func newDispatcher(client *Client, conn *connection) *dispatcher {
+	return &dispatcher{
+		client:   client,
+		conn:     conn,
+		requests: make(chan *request),
+		cancels:  make(chan *request),
+		done:     make(chan struct{}),
+		stale:    make(map[transport.Command]*staleConfirm),
+	}
+}
+
+// Submit a request and wait for its confirm. Every accepted request is answered, if needed with ErrConnectionClosed
+func (d *dispatcher) execute(ctx context.Context, req commands.Request) (commands.Confirm, error) {
+	r := &request{ctx: ctx, req: req, result: make(chan *requestResult, 1)}
+
+	select {
+	case d.requests <- r:
+	case <-d.done:
+		return nil, ErrNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case result := <-r.result:
+		return result.cfm, result.err
+
+	case <-ctx.Done():
+		// Release the request so that it does not hold the next ones
+		select {
+		case d.cancels <- r:
+		case <-d.done:
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+// Closed when the dispatcher stops, i.e. when the connection is torn down
+func (d *dispatcher) Done() <-chan struct{} {
+	return d.done
+}
+
+// Runs until the connection fails or the client is closed. Returns the connection error, nil if the client is closed
+func (d *dispatcher) run() error {
+	defer d.stop()
+
+	var heartbeat <-chan time.Time
+	if interval := d.client.options.heartbeatInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		var write chan<- *transport.Frame
+		var next *transport.Frame
+		if len(d.outbox) > 0 {
+			write = d.conn.Writes()
+			next = d.outbox[0]
+		}
+
+		select {
+		case <-d.client.ctx.Done():
+			return nil
+
+		case write <- next:
+			d.outbox = d.outbox[1:]
+
+		case <-heartbeat:
+			go d.client.heartbeat()
+
+		case err := <-d.conn.Errors():
+			d.client.log.WithError(err).Error("Error on connection")
+			return err
+
+		case frame := <-d.conn.Read():
+			d.processFrame(frame)
+
+		case r := <-d.requests:
+			d.queue = append(d.queue, r)
+			d.sendNext()
+
+		case r := <-d.cancels:
+			d.cancel(r)
+
+		case <-d.timeout:
+			d.abandon(d.timeoutErr)
+
+		case <-d.staleExpiration:
+			d.expireStale()
+		}
+	}
+}
+
+func (d *dispatcher) stop() {
+	close(d.done)
+
+	if d.staleTimer != nil {
+		d.staleTimer.Stop()
+	}
+
+	if d.inflight != nil {
+		d.complete(nil, ErrConnectionClosed)
+	}
+
+	for _, r := range d.queue {
+		r.result <- &requestResult{err: ErrNotConnected}
+	}
+
+	d.queue = nil
+}
+
+func (d *dispatcher) sendNext() {
+	for d.inflight == nil && len(d.queue) > 0 && !d.closed() {
+		r := d.queue[0]
+
+		// Keep the order: the next requests wait too
+		if _, ok := d.stale[r.req.NewConfirm().Code()]; ok {
+			return
+		}
+
+		d.queue = d.queue[1:]
+
+		frame, err := encodeRequest(r.req)
+		if err != nil {
+			r.result <- &requestResult{err: err}
+			continue
+		}
+
+		d.outbox = append(d.outbox, frame)
+
+		d.inflight = r
+		d.expected = r.req.NewConfirm().Code()
+		d.sentAt = time.Now()
+
+		timeout := d.client.options.requestTimeout
+		d.timeoutErr = ErrTimeout
+		if deadline, ok := r.ctx.Deadline(); ok && time.Until(deadline) < timeout {
+			timeout = time.Until(deadline)
+			d.timeoutErr = context.DeadlineExceeded
+		}
+
+		d.timer = time.NewTimer(timeout)
+		d.timeout = d.timer.C
+	}
+}
+
+// The request may already be answered, then there is nothing to do
+func (d *dispatcher) cancel(r *request) {
+	if d.inflight == r {
+		d.abandon(r.ctx.Err())
+		return
+	}
+
+	for index, queued := range d.queue {
+		if queued == r {
+			d.queue = append(d.queue[:index], d.queue[index+1:]...)
+			r.result <- &requestResult{err: r.ctx.Err()}
+			return
+		}
+	}
+}
+
+func (d *dispatcher) closed() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Complete the in-flight request without its confirm, which is quarantined in case it comes later:
+// until a request timeout after the request would have timed out
+func (d *dispatcher) abandon(err error) {
+	stale := &staleConfirm{expires: d.sentAt.Add(d.client.options.requestTimeout * 2)}
+	stale.sessionID, stale.session = sessionID(d.inflight.req)
+	d.stale[d.expected] = stale
+	d.scheduleStaleExpiration()
+
+	d.complete(nil, err)
+}
+
+func (d *dispatcher) expireStale() {
+	now := time.Now()
+
+	for code, stale := range d.stale {
+		if !stale.expires.After(now) {
+			d.client.log.Debugf("Confirm %s did not come, end of quarantine", code)
+			delete(d.stale, code)
+		}
+	}
+
+	d.scheduleStaleExpiration()
+	d.sendNext()
+}
+
+// Arm the timer on the first quarantine to end
+func (d *dispatcher) scheduleStaleExpiration() {
+	if d.staleTimer != nil {
+		d.staleTimer.Stop()
+		d.staleTimer = nil
+		d.staleExpiration = nil
+	}
+
+	var next time.Time
+	for _, stale := range d.stale {
+		if next.IsZero() || stale.expires.Before(next) {
+			next = stale.expires
+		}
+	}
+
+	if next.IsZero() {
+		return
+	}
+
+	d.staleTimer = time.NewTimer(time.Until(next))
+	d.staleExpiration = d.staleTimer.C
+}
+
+func (d *dispatcher) complete(cfm commands.Confirm, err error) {
+	r := d.inflight
+	d.inflight = nil
+	d.timer.Stop()
+	d.timer = nil
+	d.timeout = nil
+
+	r.result <- &requestResult{cfm: cfm, err: err}
+
+	d.sendNext()
+}
+
+func (d *dispatcher) processFrame(frame *transport.Frame) {
+	// try to read it as notify
+	notify := commands.GetNotify(frame.Cmd)
+	if notify != nil {
+		if err := notify.Read(frame.Data); err != nil {
+			d.client.log.WithError(err).Errorf("Cannot read frame %s", frame.Cmd)
+			return
+		}
+
+		if observer := d.client.options.observer; observer != nil {
+			observer.NotificationReceived(notify)
+		}
+
+		// The gateway answers GW_ERROR_NTF instead of the confirm when it cannot process a request.
+		// It does not tell which one: while abandoned requests may still be answered, the in-flight one waits for its confirm or its timeout
+		if errNtf, ok := notify.(*commands.ErrorNtf); ok && d.inflight != nil {
+			if len(d.stale) == 0 {
+				d.complete(nil, &GatewayError{ErrorNumber: errNtf.ErrorNumber})
+			} else {
+				d.client.log.Debugf("Got error %s while confirms are quarantined, not taken for the answer of %s", errNtf.ErrorNumber, d.inflight.req.Code())
+			}
+		}
+
+		d.client.dispatchNotify(notify)
+		return
+	}
+
+	// The late confirm of an abandoned request: the requests expecting it can be sent again
+	if stale, ok := d.stale[frame.Cmd]; ok && (!stale.session || frameSessionID(frame) == stale.sessionID) {
+		d.client.log.Debugf("Got late confirm %s, discarded", frame.Cmd)
+		delete(d.stale, frame.Cmd)
+		d.scheduleStaleExpiration()
+		d.sendNext()
+		return
+	}
+
+	if d.inflight == nil || frame.Cmd != d.expected {
+		d.client.log.Warnf("Got unmatched frame %s, discarded", frame.Cmd)
+		return
+	}
+
+	cfm := d.inflight.req.NewConfirm()
+	if err := cfm.Read(frame.Data); err != nil {
+		d.complete(nil, err)
+		return
+	}
+
+	// A confirm of another session answers a request abandoned before its quarantine ended
+	if id, ok := sessionID(d.inflight.req); ok {
+		if cfmID, _ := sessionID(cfm); cfmID != id {
+			d.client.log.Warnf("Got frame %s of session %d while waiting for session %d, discarded", frame.Cmd, cfmID, id)
+			return
+		}
+	}
+
+	d.complete(cfm, nil)
+}
+
+// Session of a session command request or confirm (GW_COMMAND_SEND_REQ, GW_ACTIVATE_SCENE_REQ, ...)
+func sessionID(cmd commands.Command) (int, bool) {
+	session, ok := cmd.(commands.SessionCommand)
+	if !ok {
+		return 0, false
+	}
+
+	return session.GetSessionID(), true
+}
+
+// Session of a confirm frame, -1 if it cannot be read
+func frameSessionID(frame *transport.Frame) int {
+	cfm := commands.GetConfirm(frame.Cmd)
+	if cfm == nil || cfm.Read(frame.Data) != nil {
+		return -1
+	}
+
+	id, ok := sessionID(cfm)
+	if !ok {
+		return -1
+	}
+
+	return id
+}
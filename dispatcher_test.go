@@ -0,0 +1,408 @@
+package klf200
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Gateway side of a dispatcher running on an in-memory connection
+type fakeGateway struct {
+	conn *connection
+	d    *dispatcher
+}
+
+func startDispatcher(t *testing.T, requestTimeout time.Duration) *fakeGateway {
+	t.Helper()
+
+	client := NewClient("127.0.0.1:1", "", WithRequestTimeout(requestTimeout), WithHeartbeatInterval(0))
+	conn := &connection{
+		write:  make(chan *transport.Frame, 100),
+		read:   make(chan *transport.Frame, 100),
+		errors: make(chan error),
+	}
+
+	gw := &fakeGateway{conn: conn, d: newDispatcher(client, conn)}
+	go gw.d.run()
+
+	t.Cleanup(func() {
+		client.Close()
+		<-gw.d.Done()
+	})
+
+	return gw
+}
+
+// Next request received by the gateway
+func (gw *fakeGateway) next(t *testing.T) commands.Request {
+	t.Helper()
+
+	select {
+	case frame := <-gw.conn.write:
+		req := commands.GetRequest(frame.Cmd)
+		if err := req.Read(frame.Data); err != nil {
+			t.Fatalf("cannot read %s: %s", frame.Cmd, err)
+		}
+
+		return req
+
+	case <-time.After(testTimeout):
+		t.Fatal("no request received")
+		return nil
+	}
+}
+
+func (gw *fakeGateway) noRequest(t *testing.T, wait time.Duration) {
+	t.Helper()
+
+	select {
+	case frame := <-gw.conn.write:
+		t.Fatalf("got request %s", frame.Cmd)
+	case <-time.After(wait):
+	}
+}
+
+func (gw *fakeGateway) answer(cfm commands.Confirm) {
+	data, _ := cfm.Write()
+	gw.conn.read <- &transport.Frame{Cmd: cfm.Code(), Data: data}
+}
+
+func commandSend(sessionID int) *commands.CommandSendReq {
+	return &commands.CommandSendReq{
+		SessionID:                 sessionID,
+		FunctionalParameterValues: map[commands.FunctionalParameter]int{commands.FunctionalParameterMP: 0},
+		NodeIndexes:               []int{0},
+	}
+}
+
+type asyncResult struct {
+	cfm commands.Confirm
+	err error
+}
+
+func (gw *fakeGateway) executeAsync(ctx context.Context, req commands.Request) <-chan asyncResult {
+	result := make(chan asyncResult, 1)
+
+	go func() {
+		cfm, err := gw.d.execute(ctx, req)
+		result <- asyncResult{cfm, err}
+	}()
+
+	return result
+}
+
+func waitResult(t *testing.T, result <-chan asyncResult) asyncResult {
+	t.Helper()
+
+	select {
+	case res := <-result:
+		return res
+	case <-time.After(testTimeout):
+		t.Fatal("request not answered")
+		return asyncResult{}
+	}
+}
+
+func TestDispatcherLateConfirm(t *testing.T) {
+	gw := startDispatcher(t, testTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := gw.executeAsync(ctx, &commands.GetVersionReq{})
+	gw.next(t)
+
+	cancel()
+	if res := waitResult(t, first); !errors.Is(res.err, context.Canceled) {
+		t.Fatalf("got %v, expected cancellation", res.err)
+	}
+
+	// Held until the confirm of the canceled request comes
+	second := gw.executeAsync(context.Background(), &commands.GetVersionReq{})
+	gw.noRequest(t, time.Millisecond*100)
+
+	gw.answer(&commands.GetVersionCfm{HardwareVersion: 1})
+	gw.next(t)
+	gw.answer(&commands.GetVersionCfm{HardwareVersion: 2})
+
+	res := waitResult(t, second)
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+
+	if version := res.cfm.(*commands.GetVersionCfm).HardwareVersion; version != 2 {
+		t.Errorf("got the confirm of hardware version %d", version)
+	}
+}
+
+func TestDispatcherQuarantineExpires(t *testing.T) {
+	gw := startDispatcher(t, time.Millisecond*200)
+
+	first := gw.executeAsync(context.Background(), &commands.GetVersionReq{})
+	gw.next(t)
+
+	if res := waitResult(t, first); !errors.Is(res.err, ErrTimeout) {
+		t.Fatalf("got %v, expected a timeout", res.err)
+	}
+
+	// Another confirm is not held
+	state := gw.executeAsync(context.Background(), &commands.GetStateReq{})
+	gw.next(t)
+	gw.answer(&commands.GetStateCfm{})
+
+	if res := waitResult(t, state); res.err != nil {
+		t.Fatal(res.err)
+	}
+
+	start := time.Now()
+	second := gw.executeAsync(context.Background(), &commands.GetVersionReq{})
+	gw.next(t)
+
+	if elapsed := time.Since(start); elapsed < time.Millisecond*100 {
+		t.Errorf("request sent after %s, during the quarantine", elapsed)
+	}
+
+	gw.answer(&commands.GetVersionCfm{})
+
+	if res := waitResult(t, second); res.err != nil {
+		t.Fatal(res.err)
+	}
+}
+
+func TestDispatcherContextDeadline(t *testing.T) {
+	gw := startDispatcher(t, testTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	// The dispatcher does not wait for the request timeout
+	start := time.Now()
+	cfm, err := gw.d.execute(ctx, &commands.GetVersionReq{})
+	if !errors.Is(err, context.DeadlineExceeded) || cfm != nil {
+		t.Fatalf("got %v, expected the deadline to be exceeded", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > testTimeout/2 {
+		t.Errorf("request completed after %s", elapsed)
+	}
+
+	gw.next(t)
+
+	state := gw.executeAsync(context.Background(), &commands.GetStateReq{})
+	gw.next(t)
+	gw.answer(&commands.GetStateCfm{})
+
+	if res := waitResult(t, state); res.err != nil {
+		t.Fatal(res.err)
+	}
+}
+
+func TestDispatcherSessionMatch(t *testing.T) {
+	gw := startDispatcher(t, time.Millisecond*50)
+
+	first := gw.executeAsync(context.Background(), commandSend(1))
+	gw.next(t)
+
+	if res := waitResult(t, first); !errors.Is(res.err, ErrTimeout) {
+		t.Fatalf("got %v, expected a timeout", res.err)
+	}
+
+	// Sent once the quarantine is over
+	second := gw.executeAsync(context.Background(), commandSend(2))
+	if req := gw.next(t).(*commands.CommandSendReq); req.SessionID != 2 {
+		t.Fatalf("got session %d", req.SessionID)
+	}
+
+	// Too late even for the quarantine
+	gw.answer(&commands.CommandSendCfm{SessionID: 1, Success: true})
+	gw.answer(&commands.CommandSendCfm{SessionID: 2, Success: true})
+
+	res := waitResult(t, second)
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+
+	if id := res.cfm.(*commands.CommandSendCfm).SessionID; id != 2 {
+		t.Errorf("got the confirm of session %d", id)
+	}
+}
+
+func (gw *fakeGateway) notify(ntf commands.Notify) {
+	data, _ := ntf.Write()
+	gw.conn.read <- &transport.Frame{Cmd: ntf.Code(), Data: data}
+}
+
+func TestDispatcherErrorNtf(t *testing.T) {
+	gw := startDispatcher(t, testTimeout)
+
+	failed := gw.executeAsync(context.Background(), &commands.GetVersionReq{})
+	gw.next(t)
+	gw.notify(&commands.ErrorNtf{ErrorNumber: commands.ErrorBusy})
+
+	if res := waitResult(t, failed); !IsGatewayError(res.err, commands.ErrorBusy) {
+		t.Fatalf("got %v, expected the gateway error", res.err)
+	}
+
+	// The error may answer the abandoned request
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := gw.executeAsync(ctx, &commands.GetVersionReq{})
+	gw.next(t)
+	cancel()
+	waitResult(t, abandoned)
+
+	state := gw.executeAsync(context.Background(), &commands.GetStateReq{})
+	gw.next(t)
+	gw.notify(&commands.ErrorNtf{ErrorNumber: commands.ErrorBusy})
+	gw.answer(&commands.GetStateCfm{})
+
+	if res := waitResult(t, state); res.err != nil {
+		t.Fatalf("got %v, expected the confirm", res.err)
+	}
+}
+
+func TestDispatcherStalledWrite(t *testing.T) {
+	gw := startDispatcher(t, testTimeout)
+
+	notifications, unsubscribe := Subscribe[*commands.SessionFinishedNtf](gw.d.client, nil)
+	defer unsubscribe()
+
+	// The connection does not accept any more frame
+	for len(gw.conn.write) < cap(gw.conn.write) {
+		gw.conn.write <- &transport.Frame{Cmd: transport.GW_GET_STATE_REQ}
+	}
+
+	result := gw.executeAsync(context.Background(), &commands.GetVersionReq{})
+
+	// The notifications are still dispatched
+	gw.notify(&commands.SessionFinishedNtf{SessionID: 1})
+
+	if ids := readSessions(t, notifications, 1); ids[0] != 1 {
+		t.Errorf("got session %d", ids[0])
+	}
+
+	for range cap(gw.conn.write) {
+		if frame := <-gw.conn.write; frame.Cmd != transport.GW_GET_STATE_REQ {
+			t.Fatalf("got %s before the stalled frames", frame.Cmd)
+		}
+	}
+
+	gw.next(t)
+	gw.answer(&commands.GetVersionCfm{})
+
+	if res := waitResult(t, result); res.err != nil {
+		t.Fatal(res.err)
+	}
+}
+
+func TestSessionID(t *testing.T) {
+	if id, ok := sessionID(&commands.ActivateSceneCfm{SessionID: 42}); !ok || id != 42 {
+		t.Errorf("got %d, %t", id, ok)
+	}
+
+	if _, ok := sessionID(&commands.GetVersionCfm{}); ok {
+		t.Error("session id of a confirm without session")
+	}
+}
+
+func TestDispatcherConcurrentLoad(t *testing.T) {
+	const requestTimeout = time.Millisecond * 100
+	gw := startDispatcher(t, requestTimeout)
+
+	// Answers most requests, some after their timeout (but before the end of the quarantine), and drops the others.
+	// Late version confirms are marked with hardware version 1
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+
+			case frame := <-gw.conn.write:
+				req := commands.GetRequest(frame.Cmd)
+				req.Read(frame.Data)
+
+				late := false
+				switch draw := rand.IntN(10); {
+				case draw == 0:
+					continue
+				case draw == 1:
+					late = true
+				}
+
+				var cfm commands.Confirm
+				switch req := req.(type) {
+				case *commands.GetVersionReq:
+					version := 0
+					if late {
+						version = 1
+					}
+					cfm = &commands.GetVersionCfm{HardwareVersion: version}
+				case *commands.CommandSendReq:
+					cfm = &commands.CommandSendCfm{SessionID: req.SessionID, Success: true}
+				}
+
+				if late {
+					time.AfterFunc(requestTimeout*3/2, func() { gw.answer(cfm) })
+				} else {
+					gw.answer(cfm)
+				}
+			}
+		}
+	}()
+
+	var sessions atomic.Int32
+	var succeeded atomic.Int32
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range 10 {
+				ctx, cancel := context.WithCancel(context.Background())
+				if rand.IntN(5) == 0 {
+					time.AfterFunc(time.Duration(rand.IntN(50))*time.Millisecond, cancel)
+				}
+
+				if rand.IntN(2) == 0 {
+					cfm, err := gw.d.execute(ctx, &commands.GetVersionReq{})
+					if err == nil {
+						succeeded.Add(1)
+
+						if version := cfm.(*commands.GetVersionCfm).HardwareVersion; version != 0 {
+							t.Error("got a late confirm")
+						}
+					}
+				} else {
+					id := int(sessions.Add(1))
+
+					cfm, err := gw.d.execute(ctx, commandSend(id))
+					if err == nil {
+						succeeded.Add(1)
+
+						if got := cfm.(*commands.CommandSendCfm).SessionID; got != id {
+							t.Errorf("session %d got the confirm of session %d", id, got)
+						}
+					}
+				}
+
+				cancel()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if succeeded.Load() == 0 {
+		t.Error("no request succeeded")
+	}
+}
@@ -0,0 +1,42 @@
+package klf200
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+// The client has currently no open connection to the gateway
+var ErrNotConnected = errors.New("not connected")
+
+// The gateway did not answer the request in time
+var ErrTimeout = errors.New("request timeout")
+
+// The connection has been closed while the request was pending
+var ErrConnectionClosed = errors.New("connection closed")
+
+// The connection has been lost while a transaction was waiting for its notifications
+var ErrDisconnected = errors.New("disconnected")
+
+// The gateway refused the password
+var ErrAuthenticationFailed = errors.New("authentication failed")
+
+// The gateway answered a request with GW_ERROR_NTF
+type GatewayError struct {
+	ErrorNumber commands.ErrorNumber
+}
+
+func (err *GatewayError) Error() string {
+	return fmt.Sprintf("gateway error: %s", err.ErrorNumber)
+}
+
+// Indicates if err is a GW_ERROR_NTF answer with the given error number
+func IsGatewayError(err error, errorNumber commands.ErrorNumber) bool {
+	var gwErr *GatewayError
+	if !errors.As(err, &gwErr) {
+		return false
+	}
+
+	return gwErr.ErrorNumber == errorNumber
+}
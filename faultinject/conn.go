@@ -0,0 +1,198 @@
+package faultinject
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+const frameEnd byte = 192
+const frameEsc byte = 219
+
+const readBufferSize = 1024
+
+// Connection wrapped by an Injector
+type Conn struct {
+	net.Conn
+	injector *Injector
+
+	writeLock     sync.Mutex
+	writeSplitter splitter
+
+	readLock     sync.Mutex
+	readSplitter splitter
+	readPending  []byte
+}
+
+var _ net.Conn = (*Conn)(nil)
+
+func (conn *Conn) Close() error {
+	conn.injector.forget(conn)
+	return conn.Conn.Close()
+}
+
+func (conn *Conn) Write(data []byte) (int, error) {
+	conn.writeLock.Lock()
+	defer conn.writeLock.Unlock()
+
+	for _, chunk := range conn.writeSplitter.add(data) {
+		out, err := conn.process(DirectionToGateway, chunk)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := conn.Conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+func (conn *Conn) Read(data []byte) (int, error) {
+	conn.readLock.Lock()
+	defer conn.readLock.Unlock()
+
+	for len(conn.readPending) == 0 {
+		buff := make([]byte, readBufferSize)
+		n, err := conn.Conn.Read(buff)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, chunk := range conn.readSplitter.add(buff[:n]) {
+			out, err := conn.process(DirectionFromGateway, chunk)
+			if err != nil {
+				return 0, err
+			}
+
+			conn.readPending = append(conn.readPending, out...)
+		}
+	}
+
+	n := copy(data, conn.readPending)
+	conn.readPending = conn.readPending[n:]
+	return n, nil
+}
+
+// Apply the matching fault to the chunk, and returns the bytes to transmit instead
+func (conn *Conn) process(direction Direction, chunk []byte) ([]byte, error) {
+	payload, ok := decodeFrame(chunk)
+	if !ok {
+		// Not a complete frame, or already corrupted
+		return chunk, nil
+	}
+
+	var cmd transport.Command
+	hasCmd := len(payload) >= 4
+	if hasCmd {
+		cmd = transport.Command(uint16(payload[2])<<8 | uint16(payload[3]))
+	}
+
+	rule := conn.injector.match(direction, cmd, hasCmd)
+	if rule == nil {
+		return chunk, nil
+	}
+
+	switch rule.Fault {
+	case FaultDrop:
+		return nil, nil
+
+	case FaultDelay:
+		time.Sleep(rule.Delay)
+		return chunk, nil
+
+	case FaultDuplicate:
+		return append(append([]byte(nil), chunk...), chunk...), nil
+
+	case FaultBadChecksum:
+		corrupted := append([]byte(nil), payload...)
+		if len(corrupted) > 0 {
+			corrupted[len(corrupted)-1] ^= 0xFF
+		}
+
+		return encodeFrame(corrupted), nil
+
+	case FaultBadEscape:
+		half := len(payload) / 2
+		out := encodeFrame(payload[:half])
+		out = append(out[:len(out)-1], frameEsc, 0x00)
+		return append(out, encodeFrame(payload[half:])[1:]...), nil
+
+	case FaultTruncate:
+		return encodeFrame(payload[:len(payload)/2]), nil
+
+	case FaultClose:
+		conn.Close()
+		return nil, net.ErrClosed
+
+	default:
+		return chunk, nil
+	}
+}
+
+func decodeFrame(chunk []byte) ([]byte, bool) {
+	if len(chunk) < 2 || chunk[0] != frameEnd || chunk[len(chunk)-1] != frameEnd {
+		return nil, false
+	}
+
+	var decoder transport.SlipDecoder
+	if err := decoder.AddRaw(chunk); err != nil {
+		return nil, false
+	}
+
+	buff := decoder.NextFrame()
+	if buff == nil {
+		return nil, false
+	}
+
+	return buff.Bytes(), true
+}
+
+func encodeFrame(payload []byte) []byte {
+	return transport.SlipEncode(bytes.NewBuffer(append([]byte(nil), payload...))).Bytes()
+}
+
+// Split a SLIP stream into frames (including their END delimiters).
+// Bytes outside of frames are returned as is.
+type splitter struct {
+	current []byte
+}
+
+func (s *splitter) add(data []byte) [][]byte {
+	var chunks [][]byte
+	var stray []byte
+
+	for _, b := range data {
+		if s.current == nil {
+			if b != frameEnd {
+				stray = append(stray, b)
+				continue
+			}
+
+			if len(stray) > 0 {
+				chunks = append(chunks, stray)
+				stray = nil
+			}
+
+			s.current = []byte{b}
+			continue
+		}
+
+		s.current = append(s.current, b)
+
+		if b == frameEnd {
+			chunks = append(chunks, s.current)
+			s.current = nil
+		}
+	}
+
+	if len(stray) > 0 {
+		chunks = append(chunks, stray)
+	}
+
+	return chunks
+}
@@ -0,0 +1,208 @@
+// Fault injection on the SLIP stream between a client and the gateway.
+//
+// An Injector wraps the plaintext side of the connection (inside TLS), splits the stream into
+// frames and applies faults to them: drop, delay, duplicate, corruption, truncation, or abrupt close
+// of the connection. It is meant for chaos tests of the reconnection logic.
+package faultinject
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type Direction int
+
+// Frames sent by the client
+const DirectionToGateway Direction = 1
+
+// Frames received by the client
+const DirectionFromGateway Direction = 2
+
+// Frames in both directions
+const DirectionBoth Direction = DirectionToGateway | DirectionFromGateway
+
+func (dir Direction) String() string {
+	switch dir {
+	case DirectionToGateway:
+		return "ToGateway"
+	case DirectionFromGateway:
+		return "FromGateway"
+	case DirectionBoth:
+		return "Both"
+	default:
+		return fmt.Sprintf("<%d>", dir)
+	}
+}
+
+type Fault int
+
+// The frame is not transmitted
+const FaultDrop Fault = 1
+
+// The frame (and the following ones) is transmitted after Rule.Delay
+const FaultDelay Fault = 2
+
+// The frame is transmitted twice
+const FaultDuplicate Fault = 3
+
+// The checksum of the frame is wrong
+const FaultBadChecksum Fault = 4
+
+// The frame contains an invalid SLIP escape sequence
+const FaultBadEscape Fault = 5
+
+// Only the first half of the frame is transmitted
+const FaultTruncate Fault = 6
+
+// The connection is abruptly closed instead of transmitting the frame
+const FaultClose Fault = 7
+
+func (fault Fault) String() string {
+	switch fault {
+	case FaultDrop:
+		return "Drop"
+	case FaultDelay:
+		return "Delay"
+	case FaultDuplicate:
+		return "Duplicate"
+	case FaultBadChecksum:
+		return "BadChecksum"
+	case FaultBadEscape:
+		return "BadEscape"
+	case FaultTruncate:
+		return "Truncate"
+	case FaultClose:
+		return "Close"
+	default:
+		return fmt.Sprintf("<%d>", fault)
+	}
+}
+
+type Rule struct {
+	Direction Direction
+
+	// Commands the rule applies to. Applies to all frames if empty
+	Commands []transport.Command
+
+	Fault Fault
+
+	// Used by FaultDelay
+	Delay time.Duration
+
+	// Probability to apply the fault to a matching frame, from 0 (never) to Always
+	Probability float64
+
+	// Number of frames the fault is applied to before the rule is removed. 0 means unlimited
+	Count int
+}
+
+// Probability of a rule applied to every matching frame
+const Always = 1.0
+
+// Rule applied once to the next frame in the direction
+func Once(direction Direction, fault Fault) Rule {
+	return Rule{Direction: direction, Fault: fault, Probability: Always, Count: 1}
+}
+
+type Injector struct {
+	lock  sync.Mutex
+	rand  *rand.Rand
+	rules []*Rule
+	conns map[*Conn]struct{}
+}
+
+// Create an injector. The seed makes the probabilistic rules reproducible
+func NewInjector(seed uint64) *Injector {
+	return &Injector{
+		rand:  rand.New(rand.NewPCG(seed, seed)),
+		conns: make(map[*Conn]struct{}),
+	}
+}
+
+// Add a rule. Rules are evaluated in order, the first one matching a frame is applied
+func (inj *Injector) AddRule(rule Rule) {
+	inj.lock.Lock()
+	defer inj.lock.Unlock()
+
+	inj.rules = append(inj.rules, &rule)
+}
+
+// Remove all rules
+func (inj *Injector) ClearRules() {
+	inj.lock.Lock()
+	defer inj.lock.Unlock()
+
+	inj.rules = nil
+}
+
+// Abruptly close all connections wrapped by the injector
+func (inj *Injector) CloseAll() {
+	inj.lock.Lock()
+	conns := make([]*Conn, 0, len(inj.conns))
+	for conn := range inj.conns {
+		conns = append(conns, conn)
+	}
+	inj.lock.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// Wrap the plaintext stream of a connection.
+// Can be given to klf200.WithConnWrapper
+func (inj *Injector) Wrap(conn net.Conn) net.Conn {
+	c := &Conn{Conn: conn, injector: inj}
+
+	inj.lock.Lock()
+	inj.conns[c] = struct{}{}
+	inj.lock.Unlock()
+
+	return c
+}
+
+func (inj *Injector) forget(conn *Conn) {
+	inj.lock.Lock()
+	defer inj.lock.Unlock()
+
+	delete(inj.conns, conn)
+}
+
+// Select the rule to apply to the frame, if any
+func (inj *Injector) match(direction Direction, cmd transport.Command, hasCmd bool) *Rule {
+	inj.lock.Lock()
+	defer inj.lock.Unlock()
+
+	for index, rule := range inj.rules {
+		if rule.Direction&direction == 0 {
+			continue
+		}
+
+		if len(rule.Commands) > 0 && (!hasCmd || !slices.Contains(rule.Commands, cmd)) {
+			continue
+		}
+
+		if rule.Probability < Always && inj.rand.Float64() >= rule.Probability {
+			continue
+		}
+
+		applied := *rule
+
+		if rule.Count > 0 {
+			rule.Count--
+			if rule.Count == 0 {
+				inj.rules = slices.Delete(inj.rules, index, index+1)
+			}
+		}
+
+		return &applied
+	}
+
+	return nil
+}
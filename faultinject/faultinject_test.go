@@ -0,0 +1,307 @@
+package faultinject
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Frame the rules apply to
+const targetCmd = transport.GW_GET_STATE_REQ
+
+// Frame sent after the target one, never faulted, which ends the collection
+const markerCmd = transport.GW_GET_VERSION_REQ
+
+type received struct {
+	frame *transport.Frame
+	err   error
+	at    time.Time
+}
+
+func encode(cmd transport.Command) []byte {
+	frame := &transport.Frame{Cmd: cmd, Data: []byte{1, 2, 3, 4}}
+	return transport.SlipEncode(frame.Write()).Bytes()
+}
+
+func decode(chunk []byte) (*transport.Frame, error) {
+	var decoder transport.SlipDecoder
+	if err := decoder.AddRaw(chunk); err != nil {
+		return nil, err
+	}
+
+	buff := decoder.NextFrame()
+	if buff == nil {
+		return nil, errors.New("incomplete frame")
+	}
+
+	return transport.FrameRead(buff)
+}
+
+// Start the injector on a pipe. Returns the wrapped client end and the gateway end
+func newPipe(t *testing.T, rules ...Rule) (*Injector, net.Conn, net.Conn) {
+	inj := NewInjector(1)
+	for _, rule := range rules {
+		inj.AddRule(rule)
+	}
+
+	client, gateway := net.Pipe()
+	wrapped := inj.Wrap(client)
+
+	t.Cleanup(func() {
+		wrapped.Close()
+		gateway.Close()
+	})
+
+	return inj, wrapped, gateway
+}
+
+// Write the target frame then the marker frame, without blocking the caller (the pipe is synchronous)
+func send(conn net.Conn) <-chan error {
+	result := make(chan error, 1)
+
+	go func() {
+		if _, err := conn.Write(encode(targetCmd)); err != nil {
+			result <- err
+			return
+		}
+
+		_, err := conn.Write(encode(markerCmd))
+		result <- err
+	}()
+
+	return result
+}
+
+// Read the frames until the marker frame
+func collect(t *testing.T, conn net.Conn) []received {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+
+	var split splitter
+	frames := make([]received, 0)
+	buff := make([]byte, 1024)
+
+	for {
+		n, err := conn.Read(buff)
+		if err != nil {
+			t.Fatalf("read error after %d frames: %s", len(frames), err)
+		}
+
+		for _, chunk := range split.add(buff[:n]) {
+			frame, err := decode(chunk)
+			frames = append(frames, received{frame: frame, err: err, at: time.Now()})
+
+			if err == nil && frame.Cmd == markerCmd {
+				return frames
+			}
+		}
+	}
+}
+
+func commands(frames []received) []transport.Command {
+	cmds := make([]transport.Command, 0, len(frames))
+	for _, frame := range frames {
+		if frame.err == nil {
+			cmds = append(cmds, frame.frame.Cmd)
+		}
+	}
+
+	return cmds
+}
+
+func checkCommands(t *testing.T, frames []received, expected ...transport.Command) {
+	t.Helper()
+
+	got := commands(frames)
+	if len(got) != len(expected) {
+		t.Fatalf("got frames %v, expected %v", got, expected)
+	}
+
+	for index := range got {
+		if got[index] != expected[index] {
+			t.Fatalf("got frames %v, expected %v", got, expected)
+		}
+	}
+}
+
+func targetRule(fault Fault) Rule {
+	return Rule{Direction: DirectionToGateway, Commands: []transport.Command{targetCmd}, Fault: fault, Probability: Always}
+}
+
+func TestNoFault(t *testing.T) {
+	_, client, gateway := newPipe(t)
+	send(client)
+
+	checkCommands(t, collect(t, gateway), targetCmd, markerCmd)
+}
+
+func TestDrop(t *testing.T) {
+	_, client, gateway := newPipe(t, targetRule(FaultDrop))
+	send(client)
+
+	frames := collect(t, gateway)
+	checkCommands(t, frames, markerCmd)
+
+	if len(frames) != 1 {
+		t.Errorf("got %d chunks, expected only the marker", len(frames))
+	}
+}
+
+func TestDelay(t *testing.T) {
+	rule := targetRule(FaultDelay)
+	rule.Delay = time.Millisecond * 200
+
+	_, client, gateway := newPipe(t, rule)
+
+	start := time.Now()
+	send(client)
+
+	frames := collect(t, gateway)
+	checkCommands(t, frames, targetCmd, markerCmd)
+
+	if elapsed := frames[0].at.Sub(start); elapsed < rule.Delay {
+		t.Errorf("frame received after %s, expected at least %s", elapsed, rule.Delay)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	_, client, gateway := newPipe(t, targetRule(FaultDuplicate))
+	send(client)
+
+	checkCommands(t, collect(t, gateway), targetCmd, targetCmd, markerCmd)
+}
+
+func TestBadChecksum(t *testing.T) {
+	_, client, gateway := newPipe(t, targetRule(FaultBadChecksum))
+	send(client)
+
+	frames := collect(t, gateway)
+	checkCommands(t, frames, markerCmd)
+
+	if len(frames) != 2 || frames[0].err == nil || frames[0].err.Error() != "wrong checksum" {
+		t.Errorf("expected a wrong checksum, got %+v", frames[0])
+	}
+}
+
+func TestBadEscape(t *testing.T) {
+	_, client, gateway := newPipe(t, targetRule(FaultBadEscape))
+	send(client)
+
+	frames := collect(t, gateway)
+	checkCommands(t, frames, markerCmd)
+
+	if len(frames) != 2 || frames[0].err == nil || frames[0].err.Error() != "bad escape sequence" {
+		t.Errorf("expected a bad escape sequence, got %+v", frames[0])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	_, client, gateway := newPipe(t, targetRule(FaultTruncate))
+	send(client)
+
+	frames := collect(t, gateway)
+	checkCommands(t, frames, markerCmd)
+
+	if len(frames) != 2 || frames[0].err == nil {
+		t.Errorf("expected a truncated frame, got %+v", frames[0])
+	}
+}
+
+func TestClose(t *testing.T) {
+	_, client, gateway := newPipe(t, targetRule(FaultClose))
+	result := send(client)
+
+	gateway.SetReadDeadline(time.Now().Add(time.Second * 2))
+	if _, err := gateway.Read(make([]byte, 1024)); err != io.EOF {
+		t.Errorf("expected EOF on the gateway side, got %v", err)
+	}
+
+	if err := <-result; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the write to fail with ErrClosed, got %v", err)
+	}
+}
+
+func TestFromGateway(t *testing.T) {
+	rule := targetRule(FaultDrop)
+	rule.Direction = DirectionFromGateway
+
+	_, client, gateway := newPipe(t, rule)
+
+	// Not applied in the other direction
+	send(client)
+	checkCommands(t, collect(t, gateway), targetCmd, markerCmd)
+
+	send(gateway)
+	checkCommands(t, collect(t, client), markerCmd)
+}
+
+func TestCount(t *testing.T) {
+	rule := targetRule(FaultDrop)
+	rule.Count = 2
+
+	inj, client, gateway := newPipe(t, rule)
+
+	for range 2 {
+		send(client)
+		checkCommands(t, collect(t, gateway), markerCmd)
+	}
+
+	// The rule is removed
+	send(client)
+	checkCommands(t, collect(t, gateway), targetCmd, markerCmd)
+
+	if len(inj.rules) != 0 {
+		t.Errorf("%d rules left", len(inj.rules))
+	}
+}
+
+func TestProbability(t *testing.T) {
+	never := targetRule(FaultDrop)
+	never.Probability = 0
+
+	_, client, gateway := newPipe(t, never)
+
+	for range 20 {
+		send(client)
+		checkCommands(t, collect(t, gateway), targetCmd, markerCmd)
+	}
+
+	half := targetRule(FaultDrop)
+	half.Probability = 0.5
+
+	_, client, gateway = newPipe(t, half)
+
+	dropped := 0
+	for range 200 {
+		send(client)
+		if frames := collect(t, gateway); len(commands(frames)) == 1 {
+			dropped++
+		}
+	}
+
+	if dropped < 50 || dropped > 150 {
+		t.Errorf("%d frames out of 200 dropped with probability 0.5", dropped)
+	}
+}
+
+func TestSplitter(t *testing.T) {
+	stream := append(encode(targetCmd), encode(markerCmd)...)
+
+	var split splitter
+	chunks := make([][]byte, 0)
+
+	// Fed byte by byte, as reads may cut frames anywhere
+	for _, b := range stream {
+		chunks = append(chunks, split.add([]byte{b})...)
+	}
+
+	if len(chunks) != 2 || !bytes.Equal(chunks[0], encode(targetCmd)) || !bytes.Equal(chunks[1], encode(markerCmd)) {
+		t.Errorf("got chunks %x", chunks)
+	}
+}
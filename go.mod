@@ -1,3 +1,13 @@
 module github.com/mylife-home/klf200-go
 
 go 1.22.3
+
+require (
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	github.com/gorilla/websocket v1.5.0
+)
+
+require (
+	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+)
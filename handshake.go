@@ -50,7 +50,7 @@ func (handshake *handshakeData) handshakeAuthenticate() error {
 
 	tcfm := cfm.(*commands.PasswordEnterCfm)
 	if !tcfm.Success {
-		return errors.New("authentication failed")
+		return ErrAuthenticationFailed
 	}
 
 	return nil
@@ -114,6 +114,10 @@ func (handshake *handshakeData) send(frame *transport.Frame) {
 func (handshake *handshakeData) receive() (*transport.Frame, error) {
 	select {
 	case <-handshake.ctx.Done():
+		if errors.Is(handshake.ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("handshake: %w", ErrTimeout)
+		}
+
 		return nil, errors.New("client closing")
 
 	case frame := <-handshake.conn.Read():
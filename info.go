@@ -4,21 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"reflect"
 
 	"github.com/mylife-home/klf200-go/commands"
 	"github.com/mylife-home/klf200-go/utils"
 )
 
 type Info struct {
-	client          *Client
-	getAllInfoTrans utils.Mutex
+	client            *Client
+	getAllInfoTrans   utils.Mutex
+	getAllGroupsTrans utils.Mutex
 }
 
 func newInfo(client *Client) *Info {
 	return &Info{
-		client:          client,
-		getAllInfoTrans: utils.NewMutex(),
+		client:            client,
+		getAllInfoTrans:   utils.NewMutex(),
+		getAllGroupsTrans: utils.NewMutex(),
 	}
 }
 
@@ -30,14 +31,18 @@ func (info *Info) GetAllNodesInformation(ctx context.Context) ([]*commands.GetAl
 
 	defer info.getAllInfoTrans.Unlock()
 
-	n := info.client.RegisterNotifications([]reflect.Type{
-		reflect.TypeOf(&commands.GetAllNodesInformationNtf{}),
-		reflect.TypeOf(&commands.GetAllNodesInformationFinishedNtf{}),
+	trans := newTransaction(info.client, func(notif commands.Notify) bool {
+		switch notif.(type) {
+		case *commands.GetAllNodesInformationNtf, *commands.GetAllNodesInformationFinishedNtf:
+			return true
+		default:
+			return false
+		}
 	})
 
-	defer n.Close()
+	defer trans.close()
 
-	cfm, err := info.client.execute(&commands.GetAllNodesInformationReq{})
+	cfm, err := trans.execute(ctx, &commands.GetAllNodesInformationReq{})
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +56,7 @@ func (info *Info) GetAllNodesInformation(ctx context.Context) ([]*commands.GetAl
 	nodes := make([]*commands.GetAllNodesInformationNtf, 0, tcfm.TotalNumberOfNodes)
 
 	for {
-		notif, err := info.selectNotif(ctx, n)
+		notif, err := trans.next(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -77,15 +82,75 @@ func (info *Info) GetAllNodesInformation(ctx context.Context) ([]*commands.GetAl
 	return nodes, nil
 }
 
-func (info *Info) selectNotif(ctx context.Context, n Notifier) (commands.Notify, error) {
-	select {
-	// TODO: handle disconnection
-	case <-ctx.Done():
+// List groups defined on the gateway. If groupType is not nil, only groups of this type are returned
+func (info *Info) GetAllGroupsInformation(ctx context.Context, groupType *commands.GroupType) ([]*commands.GetAllGroupsInformationNtf, error) {
+	// Permits only one request at a time to avoid notifications mismatchs
+	if !info.getAllGroupsTrans.TryLockWithContext(ctx) {
 		return nil, ctx.Err()
+	}
+
+	defer info.getAllGroupsTrans.Unlock()
+
+	trans := newTransaction(info.client, func(notif commands.Notify) bool {
+		switch notif.(type) {
+		case *commands.GetAllGroupsInformationNtf, *commands.GetAllGroupsInformationFinishedNtf:
+			return true
+		default:
+			return false
+		}
+	})
+
+	defer trans.close()
+
+	req := &commands.GetAllGroupsInformationReq{}
+	if groupType != nil {
+		req.UseFilter = true
+		req.GroupType = *groupType
+	}
 
-	case notif := <-n.Stream():
-		return notif, nil
+	cfm, err := trans.execute(ctx, req)
+	if err != nil {
+		return nil, err
 	}
+
+	tcfm := cfm.(*commands.GetAllGroupsInformationCfm)
+
+	switch tcfm.Status {
+	case commands.GetAllGroupsInformationStatusSuccess:
+	case commands.GetAllGroupsInformationStatusNoGroups:
+		// No notification follows
+		return []*commands.GetAllGroupsInformationNtf{}, nil
+	default:
+		return nil, fmt.Errorf("error : '%s'", tcfm.Status)
+	}
+
+	groups := make([]*commands.GetAllGroupsInformationNtf, 0, tcfm.TotalNumberOfGroups)
+
+	for {
+		notif, err := trans.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		exit := false
+
+		switch notif := notif.(type) {
+		case *commands.GetAllGroupsInformationNtf:
+			groups = append(groups, notif)
+		case *commands.GetAllGroupsInformationFinishedNtf:
+			exit = true
+		}
+
+		if exit {
+			break
+		}
+	}
+
+	if len(groups) != tcfm.TotalNumberOfGroups {
+		return nil, fmt.Errorf("groups count mismatch (ntf=%d, cfm=%d)", len(groups), tcfm.TotalNumberOfGroups)
+	}
+
+	return groups, nil
 }
 
 // TODO: missing API
@@ -0,0 +1,165 @@
+// Server side of the gateway transport (TLS over TCP, SLIP framing).
+// Used by the simulator and the capture replayer.
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type Listener struct {
+	listener    net.Listener
+	certificate tls.Certificate
+}
+
+// Listen on the given TCP address with a freshly generated self-signed certificate
+func Listen(address string) (*Listener, error) {
+	certificate, err := generateCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+	}
+
+	listener, err := tls.Listen("tcp", address, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Listener{
+		listener:    listener,
+		certificate: certificate,
+	}, nil
+}
+
+func (l *Listener) Addr() string {
+	return l.listener.Addr().String()
+}
+
+// DER encoded certificate presented to clients
+func (l *Listener) Certificate() []byte {
+	return l.certificate.Certificate[0]
+}
+
+func (l *Listener) Accept() (*Conn, error) {
+	conn, err := l.listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{conn: conn}, nil
+}
+
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
+
+// The received data could not be decoded as a frame. The connection can still be used
+var ErrBadFrame = errors.New("bad frame")
+
+// Indicates if the error is due to the listener being closed
+func IsClosed(err error) bool {
+	return errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF)
+}
+
+type Conn struct {
+	conn      net.Conn
+	decoder   transport.SlipDecoder
+	writeLock sync.Mutex
+}
+
+func (c *Conn) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// Block until a full frame is received
+func (c *Conn) ReadFrame() (*transport.Frame, error) {
+	buffer := make([]byte, 1024)
+
+	for {
+		if buff := c.decoder.NextFrame(); buff != nil {
+			frame, err := transport.FrameRead(buff)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrBadFrame, err)
+			}
+
+			return frame, nil
+		}
+
+		n, err := c.conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.decoder.AddRaw(buffer[:n]); err != nil {
+			c.decoder.Reset()
+			return nil, fmt.Errorf("%w: %s", ErrBadFrame, err)
+		}
+	}
+}
+
+// Can be called from any goroutine
+func (c *Conn) WriteFrame(frame *transport.Frame) error {
+	return c.WriteRaw(transport.SlipEncode(frame.Write()).Bytes())
+}
+
+// Write raw bytes on the connection, bypassing SLIP encoding
+func (c *Conn) WriteRaw(data []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	_, err := c.conn.Write(data)
+	return err
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func generateCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "KLF200 simulator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24 * 365),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
@@ -13,3 +13,21 @@ type Logger interface {
 
 	WithError(err error) Logger
 }
+
+type nopLogger struct{}
+
+var _ Logger = nopLogger{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+
+func (nopLogger) Debug(msg string) {}
+func (nopLogger) Info(msg string)  {}
+func (nopLogger) Warn(msg string)  {}
+func (nopLogger) Error(msg string) {}
+
+func (log nopLogger) WithError(err error) Logger {
+	return log
+}
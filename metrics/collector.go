@@ -0,0 +1,230 @@
+// Package metrics collects metrics about a KLF 200 client, and exposes them in the Prometheus text format.
+//
+// The collector observes the connections, the requests (latency, errors, timeouts) and the notifications of the client,
+// and keeps the last known position and state of each node. Serve it on /metrics to let Prometheus scrape it.
+package metrics
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Upper bounds (in seconds) of the request latency histogram buckets
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+const refreshTimeout = time.Second * 30
+
+type requestStats struct {
+	buckets  []uint64
+	count    uint64
+	sum      float64
+	errors   uint64
+	timeouts uint64
+}
+
+type nodeStats struct {
+	name      string
+	nodeType  commands.NodeTypeSubType
+	state     commands.NodeState
+	position  commands.NodePosition
+	target    commands.NodePosition
+	remaining time.Duration
+}
+
+type droppedKey struct {
+	command transport.Command
+	policy  klf200.OverflowPolicy
+}
+
+type Collector struct {
+	client *klf200.Client
+
+	lock              sync.Mutex
+	dials             uint64
+	dialFailures      uint64
+	handshakeFailures uint64
+	requests          map[transport.Command]*requestStats
+	gatewayErrors     map[commands.ErrorNumber]uint64
+	notifications     map[transport.Command]uint64
+	dropped           map[droppedKey]uint64
+	nodes             map[int]*nodeStats
+}
+
+var _ klf200.Observer = (*Collector)(nil)
+var _ http.Handler = (*Collector)(nil)
+
+// Create a collector. Give it to the client with klf200.WithObserver, then attach the client before client.Start:
+//
+//	collector := metrics.New()
+//	client := klf200.NewClient(address, password, klf200.WithObserver(collector))
+//	collector.Attach(client)
+func New() *Collector {
+	return &Collector{
+		requests:      make(map[transport.Command]*requestStats),
+		gatewayErrors: make(map[commands.ErrorNumber]uint64),
+		notifications: make(map[transport.Command]uint64),
+		dropped:       make(map[droppedKey]uint64),
+		nodes:         make(map[int]*nodeStats),
+	}
+}
+
+type attachOptions struct {
+	refreshNodes bool
+}
+
+type AttachOption func(*attachOptions)
+
+// Load the nodes information each time the connection opens, so that the node metrics are available before any change.
+// Not needed when something else loads the nodes on connection (klf200.State, the MQTT bridge, ...):
+// the notifications they receive are observed like the others.
+func WithNodeRefresh() AttachOption {
+	return func(options *attachOptions) {
+		options.refreshNodes = true
+	}
+}
+
+// Attach the observed client, whose connection status and notifier queues are exposed too. Must be called before client.Start.
+// Without WithNodeRefresh, the node metrics are filled by the notifications the client receives.
+func (c *Collector) Attach(client *klf200.Client, options ...AttachOption) {
+	var opts attachOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
+	c.client = client
+
+	if opts.refreshNodes {
+		client.RegisterStatusChange(func(status klf200.ConnectionStatus) {
+			if status == klf200.ConnectionOpen {
+				go c.refresh()
+			}
+		})
+	}
+}
+
+// The notifications sent in response are observed like the others
+func (c *Collector) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	c.client.Info().GetAllNodesInformation(ctx)
+}
+
+func (c *Collector) Dialing() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.dials++
+}
+
+func (c *Collector) DialFailed(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.dialFailures++
+}
+
+func (c *Collector) HandshakeFailed(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.handshakeFailures++
+}
+
+func (c *Collector) RequestCompleted(cmd transport.Command, duration time.Duration, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stats := c.requests[cmd]
+	if stats == nil {
+		stats = &requestStats{buckets: make([]uint64, len(LatencyBuckets))}
+		c.requests[cmd] = stats
+	}
+
+	seconds := duration.Seconds()
+	for index, bound := range LatencyBuckets {
+		if seconds <= bound {
+			stats.buckets[index]++
+		}
+	}
+
+	stats.count++
+	stats.sum += seconds
+
+	if err != nil {
+		stats.errors++
+	}
+
+	if errors.Is(err, klf200.ErrTimeout) {
+		stats.timeouts++
+	}
+}
+
+func (c *Collector) NotificationReceived(notify commands.Notify) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.notifications[notify.Code()]++
+
+	switch notify := notify.(type) {
+	case *commands.ErrorNtf:
+		c.gatewayErrors[notify.ErrorNumber]++
+
+	case *commands.GetAllNodesInformationNtf:
+		node := c.node(notify.NodeID)
+		node.name = notify.Name
+		node.nodeType = notify.NodeTypeSubType
+		node.state = notify.State
+		node.position = notify.CurrentPosition
+		node.target = notify.Target
+		node.remaining = notify.RemainingTime
+
+	case *commands.NodeStatePositionChangedNtf:
+		node := c.node(notify.NodeID)
+		node.state = notify.State
+		node.position = notify.CurrentPosition
+		node.target = notify.Target
+		node.remaining = notify.RemainingTime
+	}
+}
+
+func (c *Collector) NotificationDropped(notify commands.Notify, policy klf200.OverflowPolicy) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.dropped[droppedKey{command: notify.Code(), policy: policy}]++
+}
+
+func (c *Collector) node(id int) *nodeStats {
+	node := c.nodes[id]
+	if node == nil {
+		node = &nodeStats{position: commands.NodePositionUnknown, target: commands.NodePositionUnknown}
+		c.nodes[id] = node
+	}
+
+	return node
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	c.Write(w)
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/simulator"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// Collector of a client which is not started, fed with the given events
+func newTestCollector() *Collector {
+	c := New()
+	c.Attach(klf200.NewClient("127.0.0.1:1", "", klf200.WithObserver(c)))
+
+	return c
+}
+
+func feed(c *Collector) {
+	for range 3 {
+		c.Dialing()
+	}
+
+	c.DialFailed(errors.New("connection refused"))
+	c.HandshakeFailed(errors.New("bad password"))
+
+	c.RequestCompleted(transport.GW_GET_VERSION_REQ, time.Millisecond*3, nil)
+	c.RequestCompleted(transport.GW_GET_VERSION_REQ, time.Millisecond*200, nil)
+	c.RequestCompleted(transport.GW_COMMAND_SEND_REQ, time.Millisecond*40, errors.New("rejected"))
+	c.RequestCompleted(transport.GW_COMMAND_SEND_REQ, time.Second*20, fmt.Errorf("no confirm: %w", klf200.ErrTimeout))
+
+	c.NotificationReceived(&commands.ErrorNtf{ErrorNumber: commands.ErrorBusy})
+	c.NotificationReceived(&commands.GetAllNodesInformationNtf{
+		NodeID:          1,
+		Name:            `Kitchen "left"`,
+		NodeTypeSubType: commands.NodeTypeWindowOpener,
+		State:           commands.NodeStateExecuting,
+		CurrentPosition: commands.NodePositionMax,
+		Target:          commands.NodePositionMax / 2,
+		RemainingTime:   time.Second * 3,
+	})
+
+	c.NotificationReceived(&commands.NodeStatePositionChangedNtf{
+		NodeID:          0,
+		State:           commands.NodeStateDone,
+		CurrentPosition: commands.NodePositionUnknown,
+		Target:          0,
+	})
+
+	c.NotificationDropped(&commands.SessionFinishedNtf{}, klf200.OverflowDropNewest)
+	c.NotificationDropped(&commands.SessionFinishedNtf{}, klf200.OverflowDropNewest)
+	c.NotificationDropped(&commands.ErrorNtf{}, klf200.OverflowDropOldest)
+}
+
+func TestCollectorWrite(t *testing.T) {
+	c := newTestCollector()
+	feed(c)
+
+	buff := &bytes.Buffer{}
+	if err := c.Write(buff); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "collector.golden")
+
+	if *update {
+		if err := os.WriteFile(golden, buff.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buff.Bytes(), expected) {
+		t.Errorf("output differs from %s (run with -update to accept it):\n%s", golden, buff)
+	}
+}
+
+func TestCollectorNotAttached(t *testing.T) {
+	c := New()
+	feed(c)
+
+	buff := &bytes.Buffer{}
+	if err := c.Write(buff); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(buff.Bytes(), []byte("klf200_connection_status")) || !bytes.Contains(buff.Bytes(), []byte("klf200_dials_total 3")) {
+		t.Errorf("got output:\n%s", buff)
+	}
+}
+
+// Start a client of a simulator with two nodes, observed by the collector
+func startObserved(t *testing.T, c *Collector, options ...AttachOption) {
+	t.Helper()
+
+	gw, err := simulator.Start(simulator.Config{
+		Nodes: []simulator.NodeConfig{
+			{Index: 0, Name: "Kitchen", NodeType: commands.NodeTypeWindowOpener},
+			{Index: 1, Name: "Bedroom", NodeType: commands.NodeTypeRollerShutter},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(gw.Close)
+
+	client := klf200.NewClient(gw.Address(), gw.Password(), klf200.WithObserver(c))
+	c.Attach(client, options...)
+
+	opened := make(chan struct{}, 1)
+	client.RegisterStatusChange(func(status klf200.ConnectionStatus) {
+		if status == klf200.ConnectionOpen {
+			select {
+			case opened <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	client.Start()
+	t.Cleanup(client.Close)
+
+	select {
+	case <-opened:
+	case <-time.After(time.Second * 5):
+		t.Fatal("connection not opened")
+	}
+}
+
+func (c *Collector) counts() (int, uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var requests uint64
+	if stats := c.requests[transport.GW_GET_ALL_NODES_INFORMATION_REQ]; stats != nil {
+		requests = stats.count
+	}
+
+	return len(c.nodes), requests
+}
+
+func TestCollectorNoRefreshByDefault(t *testing.T) {
+	c := New()
+	startObserved(t, c)
+
+	time.Sleep(time.Millisecond * 200)
+
+	if nodes, requests := c.counts(); nodes != 0 || requests != 0 {
+		t.Errorf("got %d nodes after %d requests, expected no request", nodes, requests)
+	}
+}
+
+func TestCollectorNodeRefresh(t *testing.T) {
+	c := New()
+	startObserved(t, c, WithNodeRefresh())
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		nodes, requests := c.counts()
+		if nodes == 2 && requests == 1 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d nodes after %d requests", nodes, requests)
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+}
@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+// Content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type exposition struct {
+	w *bufio.Writer
+}
+
+func (e *exposition) header(name string, typ string, help string) {
+	fmt.Fprintf(e.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// labels are name/value pairs
+func (e *exposition) sample(name string, value float64, labels ...string) {
+	e.w.WriteString(name)
+
+	if len(labels) > 0 {
+		e.w.WriteByte('{')
+
+		for index := 0; index+1 < len(labels); index += 2 {
+			if index > 0 {
+				e.w.WriteByte(',')
+			}
+
+			fmt.Fprintf(e.w, `%s="%s"`, labels[index], labelEscaper.Replace(labels[index+1]))
+		}
+
+		e.w.WriteByte('}')
+	}
+
+	e.w.WriteByte(' ')
+	e.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	e.w.WriteByte('\n')
+}
+
+// Write all the metrics in the Prometheus text exposition format
+func (c *Collector) Write(w io.Writer) error {
+	e := &exposition{w: bufio.NewWriter(w)}
+
+	// Not attached yet
+	if c.client != nil {
+		c.writeClient(e)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e.header("klf200_dials_total", "counter", "Connections dialed to the gateway.")
+	e.sample("klf200_dials_total", float64(c.dials))
+	e.header("klf200_reconnects_total", "counter", "Connections dialed to the gateway after the first one.")
+	e.sample("klf200_reconnects_total", float64(max(c.dials, 1)-1))
+	e.header("klf200_dial_failures_total", "counter", "Connections which could not be established.")
+	e.sample("klf200_dial_failures_total", float64(c.dialFailures))
+	e.header("klf200_handshake_failures_total", "counter", "Connections whose handshake failed.")
+	e.sample("klf200_handshake_failures_total", float64(c.handshakeFailures))
+
+	e.header("klf200_request_duration_seconds", "histogram", "Latency of the requests, by command.")
+	for _, cmd := range sortedKeys(c.requests) {
+		stats := c.requests[cmd]
+		name := cmd.String()
+
+		for index, bound := range LatencyBuckets {
+			e.sample("klf200_request_duration_seconds_bucket", float64(stats.buckets[index]), "command", name, "le", strconv.FormatFloat(bound, 'g', -1, 64))
+		}
+
+		e.sample("klf200_request_duration_seconds_bucket", float64(stats.count), "command", name, "le", "+Inf")
+		e.sample("klf200_request_duration_seconds_sum", stats.sum, "command", name)
+		e.sample("klf200_request_duration_seconds_count", float64(stats.count), "command", name)
+	}
+
+	e.header("klf200_request_errors_total", "counter", "Failed requests, by command.")
+	for _, cmd := range sortedKeys(c.requests) {
+		e.sample("klf200_request_errors_total", float64(c.requests[cmd].errors), "command", cmd.String())
+	}
+
+	e.header("klf200_request_timeouts_total", "counter", "Requests not answered in time, by command.")
+	for _, cmd := range sortedKeys(c.requests) {
+		e.sample("klf200_request_timeouts_total", float64(c.requests[cmd].timeouts), "command", cmd.String())
+	}
+
+	e.header("klf200_gateway_errors_total", "counter", "GW_ERROR_NTF received, by error number.")
+	for _, number := range sortedKeys(c.gatewayErrors) {
+		e.sample("klf200_gateway_errors_total", float64(c.gatewayErrors[number]), "error", number.String())
+	}
+
+	e.header("klf200_notifications_total", "counter", "Notifications received, by command.")
+	for _, cmd := range sortedKeys(c.notifications) {
+		e.sample("klf200_notifications_total", float64(c.notifications[cmd]), "command", cmd.String())
+	}
+
+	dropped := make([]droppedKey, 0, len(c.dropped))
+	for key := range c.dropped {
+		dropped = append(dropped, key)
+	}
+
+	slices.SortFunc(dropped, func(a, b droppedKey) int {
+		return cmp.Or(cmp.Compare(a.command, b.command), cmp.Compare(a.policy, b.policy))
+	})
+
+	e.header("klf200_notifications_dropped_total", "counter", "Notifications dropped because a subscriber queue was full, by command and overflow policy.")
+	for _, key := range dropped {
+		e.sample("klf200_notifications_dropped_total", float64(c.dropped[key]), "command", key.command.String(), "policy", key.policy.String())
+	}
+
+	nodes := sortedKeys(c.nodes)
+
+	e.header("klf200_node_info", "gauge", "Information about the node, always 1.")
+	for _, id := range nodes {
+		node := c.nodes[id]
+		e.sample("klf200_node_info", 1, "node", strconv.Itoa(id), "name", node.name, "type", node.nodeType.Description())
+	}
+
+	e.header("klf200_node_position_percent", "gauge", "Current position of the node (0 = open, 100 = closed), absent if unknown.")
+	for _, id := range nodes {
+		if percent, ok := positionPercent(c.nodes[id].position); ok {
+			e.sample("klf200_node_position_percent", percent, "node", strconv.Itoa(id))
+		}
+	}
+
+	e.header("klf200_node_target_percent", "gauge", "Target position of the node (0 = open, 100 = closed), absent if unknown.")
+	for _, id := range nodes {
+		if percent, ok := positionPercent(c.nodes[id].target); ok {
+			e.sample("klf200_node_target_percent", percent, "node", strconv.Itoa(id))
+		}
+	}
+
+	e.header("klf200_node_state", "gauge", "Current state of the node, 1 for the current state label.")
+	for _, id := range nodes {
+		e.sample("klf200_node_state", 1, "node", strconv.Itoa(id), "state", c.nodes[id].state.String())
+	}
+
+	e.header("klf200_node_remaining_seconds", "gauge", "Remaining time of the current movement of the node, as last reported.")
+	for _, id := range nodes {
+		e.sample("klf200_node_remaining_seconds", c.nodes[id].remaining.Seconds(), "node", strconv.Itoa(id))
+	}
+
+	return e.w.Flush()
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+func positionPercent(position commands.NodePosition) (float64, bool) {
+	if position < commands.NodePositionMin || position > commands.NodePositionMax {
+		return 0, false
+	}
+
+	return float64(position) * 100 / float64(commands.NodePositionMax), true
+}
+
+// Gauges of the attached client
+func (c *Collector) writeClient(e *exposition) {
+	status := c.client.Status()
+	e.header("klf200_connection_status", "gauge", "Current status of the connection to the gateway.")
+	for _, value := range []klf200.ConnectionStatus{klf200.ConnectionClosed, klf200.ConnectionHandshaking, klf200.ConnectionOpen} {
+		e.sample("klf200_connection_status", boolValue(status == value), "status", value.String())
+	}
+
+	queues := c.client.NotifierQueueLengths()
+	queued := 0
+	maxQueued := 0
+	for _, length := range queues {
+		queued += length
+		maxQueued = max(maxQueued, length)
+	}
+
+	e.header("klf200_notifiers", "gauge", "Number of registered notifiers.")
+	e.sample("klf200_notifiers", float64(len(queues)))
+	e.header("klf200_notifier_queued_notifications", "gauge", "Notifications waiting in the queues of all the notifiers.")
+	e.sample("klf200_notifier_queued_notifications", float64(queued))
+	e.header("klf200_notifier_max_queue_depth", "gauge", "Notifications waiting in the fullest notifier queue.")
+	e.sample("klf200_notifier_max_queue_depth", float64(maxQueued))
+}
@@ -0,0 +1,474 @@
+// Package mqttbridge publishes the state of the nodes of a KLF 200 gateway on an MQTT broker,
+// and executes the commands received on command topics.
+//
+// Topics, relative to the prefix (see Config):
+//
+//	status               "online" or "offline" (will of the bridge), retained
+//	connection           connection to the gateway: "closed", "handshaking" or "open", retained
+//	node/<id>/name       retained
+//	node/<id>/position   current position in percent (0 = open, 100 = closed), empty if unknown, retained
+//	node/<id>/target     target position in percent, empty if unknown, retained
+//	node/<id>/state      node state, e.g. "Executing" or "Done", retained
+//	node/<id>/remaining  remaining time of the current movement in seconds, retained
+//	node/<id>/set        command: move the node to the position in percent given as payload
+//	node/<id>/stop       command: stop the node
+//	node/<id>/command    command: "open", "close", "stop", "on", "off", "lock" or "unlock"
+//	scene/<id>/name      retained
+//	scene/<id>/activate  command: activate the scene
+//	scene/<id>/stop      command: stop the scene
+//
+// Home Assistant discovery messages can also be published, see Config.HomeAssistant.
+package mqttbridge
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+const DefaultPrefix = "klf200"
+const DefaultClientID = "klf200-bridge"
+
+const qos = 1
+const connectTimeout = time.Second * 10
+const requestTimeout = time.Second * 30
+
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type Config struct {
+	// Broker URL, e.g. "tcp://localhost:1883" or "ssl://broker:8883"
+	Broker string
+
+	// Defaults to DefaultClientID
+	ClientID string
+
+	Username string
+	Password string
+
+	// Optional, for "ssl://" brokers
+	TLSConfig *tls.Config
+
+	// Prefix of all the topics. Defaults to DefaultPrefix
+	Prefix string
+
+	// Publish Home Assistant discovery messages for the nodes
+	HomeAssistant bool
+
+	// Prefix of the Home Assistant discovery topics. Defaults to DefaultDiscoveryPrefix
+	DiscoveryPrefix string
+
+	// Optional
+	Log Logger
+}
+
+type Bridge struct {
+	client *klf200.Client
+	mqtt   mqtt.Client
+	prefix string
+	log    Logger
+
+	homeAssistant   bool
+	discoveryPrefix string
+
+	ctx        context.Context
+	close      context.CancelFunc
+	workerSync sync.WaitGroup
+
+	// Latest connection status not yet published by the worker
+	statuses chan klf200.ConnectionStatus
+
+	// Last value of each retained topic, published again when the connection to the broker is restored
+	lock      sync.Mutex
+	published map[string]string
+}
+
+// Create a bridge between the client and the broker.
+// Must be called before the client is started, so that the first connection to the gateway is seen.
+func New(client *klf200.Client, config Config) *Bridge {
+	ctx, close := context.WithCancel(context.Background())
+
+	b := &Bridge{
+		client:          client,
+		prefix:          config.Prefix,
+		log:             config.Log,
+		homeAssistant:   config.HomeAssistant,
+		discoveryPrefix: config.DiscoveryPrefix,
+		ctx:             ctx,
+		close:           close,
+		statuses:        make(chan klf200.ConnectionStatus, 1),
+		published:       make(map[string]string),
+	}
+
+	if b.prefix == "" {
+		b.prefix = DefaultPrefix
+	}
+
+	if b.homeAssistant && b.discoveryPrefix == "" {
+		b.discoveryPrefix = DefaultDiscoveryPrefix
+	}
+
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = DefaultClientID
+	}
+
+	options := mqtt.NewClientOptions()
+	options.AddBroker(config.Broker)
+	options.SetClientID(clientID)
+	options.SetUsername(config.Username)
+	options.SetPassword(config.Password)
+	options.SetTLSConfig(config.TLSConfig)
+	options.SetConnectTimeout(connectTimeout)
+	options.SetAutoReconnect(true)
+	options.SetConnectRetry(true)
+	options.SetOrderMatters(false)
+	options.SetWill(b.topic("status"), "offline", qos, true)
+	options.SetOnConnectHandler(func(mqtt.Client) { b.brokerConnected() })
+	options.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		b.errorf("Connection to the broker lost: %s", err)
+	})
+
+	b.mqtt = mqtt.NewClient(options)
+
+	client.RegisterStatusChange(b.statusChanged)
+
+	return b
+}
+
+// Called by the client. Must not block: a status not yet published by the worker is replaced by the new one,
+// only the current connection status matters
+func (b *Bridge) statusChanged(status klf200.ConnectionStatus) {
+	for {
+		select {
+		case b.statuses <- status:
+			return
+		default:
+		}
+
+		select {
+		case <-b.statuses:
+		default:
+		}
+	}
+}
+
+// Connect to the broker. The connection is retried in the background if the broker cannot be reached
+func (b *Bridge) Start() {
+	b.mqtt.Connect()
+
+	b.workerSync.Add(1)
+	go b.worker()
+}
+
+func (b *Bridge) Close() {
+	b.close()
+	b.workerSync.Wait()
+
+	if b.mqtt.IsConnected() {
+		b.mqtt.Publish(b.topic("status"), qos, true, "offline").WaitTimeout(connectTimeout)
+	}
+
+	b.mqtt.Disconnect(uint(connectTimeout / time.Millisecond))
+}
+
+func (b *Bridge) topic(name string) string {
+	return b.prefix + "/" + name
+}
+
+func (b *Bridge) worker() {
+	defer b.workerSync.Done()
+
+	positions, cancel := klf200.Subscribe[*commands.NodeStatePositionChangedNtf](b.client, nil)
+	defer func() { cancel() }()
+
+	b.publishConnection(klf200.ConnectionClosed)
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+
+		case status := <-b.statuses:
+			b.publishConnection(status)
+
+			if status == klf200.ConnectionOpen {
+				go b.refresh()
+			}
+
+		case ntf, ok := <-positions:
+			if !ok {
+				// Disconnected on overflow (OverflowDisconnect): subscribe again, and publish the nodes again as positions were lost
+				b.errorf("Position notifications lost, refreshing the nodes")
+				positions, cancel = klf200.Subscribe[*commands.NodeStatePositionChangedNtf](b.client, nil)
+
+				if b.client.Status() == klf200.ConnectionOpen {
+					go b.refresh()
+				}
+
+				continue
+			}
+
+			b.publishNodeState(ntf.NodeID, ntf.State, ntf.CurrentPosition, ntf.Target, ntf.RemainingTime)
+		}
+	}
+}
+
+func (b *Bridge) publishConnection(status klf200.ConnectionStatus) {
+	b.publish("connection", strings.ToLower(status.String()))
+}
+
+// Publish the nodes and scenes of the gateway
+func (b *Bridge) refresh() {
+	ctx, cancel := context.WithTimeout(b.ctx, requestTimeout)
+	defer cancel()
+
+	nodes, err := b.client.Info().GetAllNodesInformation(ctx)
+	if err != nil {
+		b.errorf("Could not get the nodes: %s", err)
+		return
+	}
+
+	for _, node := range nodes {
+		b.publish(fmt.Sprintf("node/%d/name", node.NodeID), node.Name)
+		b.publishNodeState(node.NodeID, node.State, node.CurrentPosition, node.Target, node.RemainingTime)
+
+		if b.homeAssistant {
+			b.publishDiscovery(node)
+		}
+	}
+
+	scenes, err := b.client.Scenes().GetSceneList(ctx)
+	if err != nil {
+		b.errorf("Could not get the scenes: %s", err)
+		return
+	}
+
+	for _, scene := range scenes {
+		b.publish(fmt.Sprintf("scene/%d/name", scene.SceneID), scene.Name)
+	}
+
+	b.infof("Published %d nodes and %d scenes", len(nodes), len(scenes))
+}
+
+func (b *Bridge) publishNodeState(nodeID int, state commands.NodeState, position commands.NodePosition, target commands.NodePosition, remaining time.Duration) {
+	b.publish(fmt.Sprintf("node/%d/state", nodeID), strings.TrimPrefix(state.String(), "NodeState"))
+	b.publish(fmt.Sprintf("node/%d/position", nodeID), formatPosition(position))
+	b.publish(fmt.Sprintf("node/%d/target", nodeID), formatPosition(target))
+	b.publish(fmt.Sprintf("node/%d/remaining", nodeID), strconv.Itoa(int(remaining.Round(time.Second)/time.Second)))
+}
+
+func formatPosition(position commands.NodePosition) string {
+	if position == commands.NodePositionUnknown {
+		return ""
+	}
+
+	ok, percent := commands.MPValue(position).Absolute()
+	if !ok {
+		return ""
+	}
+
+	return strconv.Itoa(percent)
+}
+
+// Publish a retained value under the prefix, if it changed
+func (b *Bridge) publish(name string, value string) {
+	b.publishTopic(b.topic(name), value)
+}
+
+func (b *Bridge) publishTopic(topic string, value string) {
+	b.lock.Lock()
+	last, found := b.published[topic]
+	b.published[topic] = value
+	b.lock.Unlock()
+
+	if found && last == value {
+		return
+	}
+
+	// Not connected: the value is published on connection
+	if !b.mqtt.IsConnectionOpen() {
+		return
+	}
+
+	b.mqtt.Publish(topic, qos, true, value)
+}
+
+// Called each time the connection to the broker is established
+func (b *Bridge) brokerConnected() {
+	b.infof("Connected to the broker")
+
+	filters := map[string]byte{
+		b.topic("node/+/set"):       qos,
+		b.topic("node/+/stop"):      qos,
+		b.topic("node/+/command"):   qos,
+		b.topic("scene/+/activate"): qos,
+		b.topic("scene/+/stop"):     qos,
+	}
+
+	b.mqtt.SubscribeMultiple(filters, func(_ mqtt.Client, msg mqtt.Message) {
+		go b.handleCommand(msg.Topic(), string(msg.Payload()))
+	})
+
+	if b.homeAssistant {
+		// Home Assistant expects the discovery messages to be published again when it restarts
+		b.mqtt.Subscribe(b.discoveryPrefix+"/status", qos, func(_ mqtt.Client, msg mqtt.Message) {
+			if string(msg.Payload()) == "online" {
+				b.republish()
+			}
+		})
+	}
+
+	b.mqtt.Publish(b.topic("status"), qos, true, "online")
+	b.republish()
+}
+
+// Publish again the last value of each retained topic
+func (b *Bridge) republish() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for topic, value := range b.published {
+		b.mqtt.Publish(topic, qos, true, value)
+	}
+}
+
+func (b *Bridge) handleCommand(topic string, payload string) {
+	parts := strings.Split(strings.TrimPrefix(topic, b.prefix+"/"), "/")
+	if len(parts) != 3 {
+		return
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		b.errorf("Bad id in topic '%s'", topic)
+		return
+	}
+
+	b.debugf("Command '%s' on %s %d (payload '%s')", parts[2], parts[0], id, payload)
+
+	switch parts[0] + "/" + parts[2] {
+	case "node/set":
+		position, err := parsePosition(payload)
+		if err != nil {
+			b.errorf("Bad position on topic '%s': %s", topic, err)
+			return
+		}
+
+		err = b.changePosition(id, commands.NewMPValueAbsolute(position))
+
+	case "node/stop":
+		err = b.changePosition(id, commands.NewMPValueCurrent())
+
+	case "node/command":
+		position, ok := commandPositions[strings.ToLower(strings.TrimSpace(payload))]
+		if !ok {
+			b.errorf("Unknown command '%s' on topic '%s'", payload, topic)
+			return
+		}
+
+		err = b.changePosition(id, position)
+
+	case "scene/activate":
+		err = b.activateScene(id)
+
+	case "scene/stop":
+		err = b.client.Scenes().StopContext(b.ctx, id)
+	}
+
+	if err != nil {
+		b.errorf("Command '%s' failed: %s", topic, err)
+	}
+}
+
+// Payloads of the node command topic. The gateway always uses 0% for open, on or unlocked, and 100% for closed, off or locked
+var commandPositions = map[string]commands.MPValue{
+	"open":   commands.NewMPValueAbsolute(0),
+	"close":  commands.NewMPValueAbsolute(100),
+	"stop":   commands.NewMPValueCurrent(),
+	"on":     commands.NewMPValueAbsolute(0),
+	"off":    commands.NewMPValueAbsolute(100),
+	"unlock": commands.NewMPValueAbsolute(0),
+	"lock":   commands.NewMPValueAbsolute(100),
+}
+
+func parsePosition(payload string) (int, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(payload), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if value < 0 || value > 100 {
+		return 0, errors.New("out of range (expected 0 to 100)")
+	}
+
+	return int(math.Round(value)), nil
+}
+
+// The node state is published from the notifications of the gateway
+func (b *Bridge) changePosition(nodeID int, position commands.MPValue) error {
+	session, err := b.client.Commands().ChangePosition(b.ctx, nodeID, position)
+	if err != nil {
+		return err
+	}
+
+	return waitSession(session)
+}
+
+func (b *Bridge) activateScene(sceneID int) error {
+	session, err := b.client.Scenes().Activate(b.ctx, sceneID)
+	if err != nil {
+		return err
+	}
+
+	return waitSession(session)
+}
+
+// Wait for the end of the session, and return its failure if any
+func waitSession(session *klf200.Session) error {
+	var err error
+
+	for event := range session.Events() {
+		switch event := event.(type) {
+		case *klf200.RunError:
+			err = event.Err
+		case *klf200.RunStatus:
+			if event.RunStatus == commands.CommandRunStatusFailed {
+				err = fmt.Errorf("run failed: %s", event.StatusReply)
+			}
+		}
+	}
+
+	return err
+}
+
+func (b *Bridge) debugf(format string, args ...interface{}) {
+	if b.log != nil {
+		b.log.Debugf(format, args...)
+	}
+}
+
+func (b *Bridge) infof(format string, args ...interface{}) {
+	if b.log != nil {
+		b.log.Infof(format, args...)
+	}
+}
+
+func (b *Bridge) errorf(format string, args ...interface{}) {
+	if b.log != nil {
+		b.log.Errorf(format, args...)
+	}
+}
@@ -0,0 +1,288 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/simulator"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+const testTimeout = time.Second * 10
+
+type testSetup struct {
+	gw     *simulator.Gateway
+	broker *testBroker
+	client *klf200.Client
+	bridge *Bridge
+}
+
+// Start a simulator, a broker and a bridge between them, and wait until the gateway is published
+func start(t *testing.T, homeAssistant bool, options ...klf200.ClientOption) *testSetup {
+	t.Helper()
+
+	gw, err := simulator.Start(simulator.Config{
+		Latency:        time.Millisecond * 10,
+		ReportInterval: time.Millisecond * 50,
+		Nodes: []simulator.NodeConfig{
+			{Index: 0, Name: "Kitchen", NodeType: commands.NodeTypeWindowOpener, SerialNumber: 0x1122334455667788, TravelTime: time.Millisecond * 200},
+			{Index: 1, Name: "Bedroom", NodeType: commands.NodeTypeRollerShutter, TravelTime: time.Millisecond * 200, Position: commands.NewMPValueAbsolute(100)},
+			{Index: 2, Name: "Door", NodeType: commands.NodeTypeDoorLock, TravelTime: time.Millisecond * 200},
+		},
+		Scenes: []simulator.SceneConfig{
+			{ID: 0, Name: "Morning", Positions: map[int]commands.MPValue{0: commands.NewMPValueAbsolute(20), 1: commands.NewMPValueAbsolute(0)}},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(gw.Close)
+
+	setup := &testSetup{gw: gw, broker: startBroker(t)}
+
+	setup.client = klf200.NewClient(gw.Address(), gw.Password(), options...)
+	setup.bridge = New(setup.client, Config{Broker: setup.broker.url(), HomeAssistant: homeAssistant})
+
+	setup.bridge.Start()
+	setup.client.Start()
+
+	t.Cleanup(func() {
+		setup.bridge.Close()
+		setup.client.Close()
+	})
+
+	waitRetained(t, setup.broker, "klf200/status", "online")
+	waitRetained(t, setup.broker, "klf200/connection", "open")
+	waitRetained(t, setup.broker, "klf200/scene/0/name", "Morning")
+
+	return setup
+}
+
+// Send a command as another MQTT client would
+func (setup *testSetup) command(t *testing.T, topic string, payload string) {
+	t.Helper()
+
+	waitFor(t, "subscription to "+topic, func() bool { return setup.broker.subscribed(topic) })
+	setup.broker.publish(topic, payload, false)
+}
+
+func (setup *testSetup) waitPosition(t *testing.T, index int, percent int) {
+	t.Helper()
+
+	waitFor(t, "node position", func() bool {
+		position, _ := setup.gw.NodePosition(index)
+		return position == commands.NewMPValueAbsolute(percent)
+	})
+}
+
+func TestBridgePublishesNodes(t *testing.T) {
+	setup := start(t, false)
+
+	waitRetained(t, setup.broker, "klf200/node/0/name", "Kitchen")
+	waitRetained(t, setup.broker, "klf200/node/1/name", "Bedroom")
+	waitRetained(t, setup.broker, "klf200/node/2/name", "Door")
+	waitRetained(t, setup.broker, "klf200/node/0/position", "0")
+	waitRetained(t, setup.broker, "klf200/node/1/position", "100")
+	waitRetained(t, setup.broker, "klf200/node/1/state", "Done")
+
+	if _, ok := setup.broker.get("homeassistant/cover/klf200_1122334455667788/config"); ok {
+		t.Error("discovery message published without Home Assistant")
+	}
+}
+
+func TestBridgeHomeAssistant(t *testing.T) {
+	setup := start(t, true)
+
+	var config map[string]interface{}
+
+	waitFor(t, "cover discovery", func() bool {
+		value, ok := setup.broker.get("homeassistant/cover/klf200_1122334455667788/config")
+		return ok && json.Unmarshal([]byte(value), &config) == nil
+	})
+
+	if config["device_class"] != "window" || config["set_position_topic"] != "klf200/node/0/set" || config["command_topic"] != "klf200/node/0/command" {
+		t.Errorf("got cover config %v", config)
+	}
+
+	waitFor(t, "lock discovery", func() bool {
+		_, ok := setup.broker.get("homeassistant/lock/klf200_node_2/config")
+		return ok
+	})
+
+	// Published again when Home Assistant restarts
+	setup.broker.clearRetained()
+	setup.command(t, "homeassistant/status", "online")
+
+	waitFor(t, "cover discovery after Home Assistant restart", func() bool {
+		_, ok := setup.broker.get("homeassistant/cover/klf200_1122334455667788/config")
+		return ok
+	})
+}
+
+func TestBridgeSetPosition(t *testing.T) {
+	setup := start(t, false)
+
+	setup.command(t, "klf200/node/0/set", "40")
+
+	setup.waitPosition(t, 0, 40)
+	waitRetained(t, setup.broker, "klf200/node/0/position", "40")
+	waitRetained(t, setup.broker, "klf200/node/0/state", "Done")
+
+	// Ignored
+	setup.command(t, "klf200/node/0/set", "120")
+	setup.command(t, "klf200/node/0/set", "abc")
+	setup.command(t, "klf200/node/x/set", "10")
+
+	setup.command(t, "klf200/node/0/set", "60.4")
+	setup.waitPosition(t, 0, 60)
+}
+
+func TestBridgeCommand(t *testing.T) {
+	setup := start(t, false)
+
+	setup.command(t, "klf200/node/1/command", "open")
+	setup.waitPosition(t, 1, 0)
+	waitRetained(t, setup.broker, "klf200/node/1/position", "0")
+
+	setup.command(t, "klf200/node/2/command", "lock")
+	setup.waitPosition(t, 2, 100)
+
+	setup.command(t, "klf200/node/2/command", "UNLOCK")
+	setup.waitPosition(t, 2, 0)
+}
+
+func TestBridgeScene(t *testing.T) {
+	setup := start(t, false)
+
+	setup.command(t, "klf200/scene/0/activate", "")
+
+	setup.waitPosition(t, 0, 20)
+	setup.waitPosition(t, 1, 0)
+	waitRetained(t, setup.broker, "klf200/node/0/position", "20")
+}
+
+func TestBridgeBrokerReconnect(t *testing.T) {
+	setup := start(t, false)
+
+	waitRetained(t, setup.broker, "klf200/node/0/name", "Kitchen")
+
+	setup.broker.clearRetained()
+	setup.broker.disconnectAll()
+
+	// The will is published, then the retained values again on reconnection
+	waitRetained(t, setup.broker, "klf200/status", "online")
+	waitRetained(t, setup.broker, "klf200/node/0/name", "Kitchen")
+	waitRetained(t, setup.broker, "klf200/connection", "open")
+}
+
+func TestBridgeClose(t *testing.T) {
+	setup := start(t, false)
+
+	setup.bridge.Close()
+
+	waitRetained(t, setup.broker, "klf200/status", "offline")
+}
+
+func TestBridgeGatewayConnection(t *testing.T) {
+	setup := start(t, false)
+
+	setup.client.Close()
+	waitRetained(t, setup.broker, "klf200/connection", "closed")
+}
+
+type disconnectObserver struct {
+	disconnected atomic.Bool
+}
+
+func (o *disconnectObserver) Dialing()                                                 {}
+func (o *disconnectObserver) DialFailed(err error)                                     {}
+func (o *disconnectObserver) HandshakeFailed(err error)                                {}
+func (o *disconnectObserver) RequestCompleted(transport.Command, time.Duration, error) {}
+func (o *disconnectObserver) NotificationReceived(commands.Notify)                     {}
+
+func (o *disconnectObserver) NotificationDropped(notify commands.Notify, policy klf200.OverflowPolicy) {
+	if policy == klf200.OverflowDisconnect {
+		o.disconnected.Store(true)
+	}
+}
+
+func TestBridgePositionsOverflow(t *testing.T) {
+	observer := &disconnectObserver{}
+	setup := start(t, false, klf200.WithDefaultOverflowPolicy(klf200.OverflowDisconnect), klf200.WithObserver(observer))
+
+	waitRetained(t, setup.broker, "klf200/node/2/position", "0")
+
+	// The worker is stuck on publish while the gateway floods it
+	setup.bridge.lock.Lock()
+
+	fake := &commands.NodeStatePositionChangedNtf{NodeID: 2, State: commands.NodeStateDone, CurrentPosition: commands.NodePosition(commands.NewMPValueAbsolute(50))}
+	for !observer.disconnected.Load() {
+		setup.gw.Broadcast(fake)
+	}
+
+	setup.bridge.lock.Unlock()
+
+	// The positions are followed with a new subscription
+	setup.command(t, "klf200/node/1/set", "30")
+	setup.waitPosition(t, 1, 30)
+	waitRetained(t, setup.broker, "klf200/node/1/position", "30")
+
+	// The nodes are published again, replacing the flooded position
+	waitRetained(t, setup.broker, "klf200/node/2/position", "0")
+}
+
+func TestStatusChangedDoesNotBlock(t *testing.T) {
+	client := klf200.NewClient("127.0.0.1:1", "")
+	b := New(client, Config{Broker: "tcp://127.0.0.1:1"})
+
+	// The worker is not running: only the latest status is kept
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for range 100 {
+			b.statusChanged(klf200.ConnectionHandshaking)
+			b.statusChanged(klf200.ConnectionClosed)
+		}
+
+		b.statusChanged(klf200.ConnectionOpen)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("status callback blocked")
+	}
+
+	if status := <-b.statuses; status != klf200.ConnectionOpen {
+		t.Errorf("got status %s, expected %s", status, klf200.ConnectionOpen)
+	}
+
+	if len(b.statuses) != 0 {
+		t.Errorf("%d statuses left", len(b.statuses))
+	}
+}
+
+func TestTopicMatch(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		topic    string
+		expected bool
+	}{
+		{"klf200/node/+/set", "klf200/node/1/set", true},
+		{"klf200/node/+/set", "klf200/node/1/stop", false},
+		{"klf200/node/+/set", "klf200/node/1/set/x", false},
+		{"klf200/#", "klf200/node/1/set", true},
+		{"klf200/status", "klf200/status", true},
+	} {
+		if got := topicMatch(test.filter, test.topic); got != test.expected {
+			t.Errorf("%s on %s: got %v", test.filter, test.topic, got)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package mqttbridge
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// Minimal MQTT 3.1.1 broker for the tests: retained messages, wildcards and wills, all delivered at QoS 0
+type testBroker struct {
+	listener net.Listener
+
+	lock     sync.Mutex
+	retained map[string]string
+	conns    map[*brokerConn]struct{}
+
+	workerSync sync.WaitGroup
+}
+
+type brokerConn struct {
+	conn      net.Conn
+	writeLock sync.Mutex
+
+	// Protected by the broker lock
+	filters []string
+	will    *packets.PublishPacket
+}
+
+func startBroker(t *testing.T) *testBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := &testBroker{
+		listener: listener,
+		retained: make(map[string]string),
+		conns:    make(map[*brokerConn]struct{}),
+	}
+
+	broker.workerSync.Add(1)
+	go broker.acceptWorker()
+
+	t.Cleanup(broker.close)
+
+	return broker
+}
+
+func (broker *testBroker) url() string {
+	return "tcp://" + broker.listener.Addr().String()
+}
+
+func (broker *testBroker) close() {
+	broker.listener.Close()
+	broker.disconnectAll()
+	broker.workerSync.Wait()
+}
+
+// Close all connections, as if the broker was restarted
+func (broker *testBroker) disconnectAll() {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	for conn := range broker.conns {
+		conn.conn.Close()
+	}
+}
+
+// Retained value of the topic
+func (broker *testBroker) get(topic string) (string, bool) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	value, ok := broker.retained[topic]
+	return value, ok
+}
+
+func (broker *testBroker) clearRetained() {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	clear(broker.retained)
+}
+
+// True if a connection subscribed to a filter matching the topic
+func (broker *testBroker) subscribed(topic string) bool {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	for conn := range broker.conns {
+		for _, filter := range conn.filters {
+			if topicMatch(filter, topic) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Publish a message to the subscribers, as another client would
+func (broker *testBroker) publish(topic string, payload string, retain bool) {
+	broker.lock.Lock()
+
+	if retain {
+		if payload == "" {
+			delete(broker.retained, topic)
+		} else {
+			broker.retained[topic] = payload
+		}
+	}
+
+	targets := make([]*brokerConn, 0)
+	for conn := range broker.conns {
+		for _, filter := range conn.filters {
+			if topicMatch(filter, topic) {
+				targets = append(targets, conn)
+				break
+			}
+		}
+	}
+
+	broker.lock.Unlock()
+
+	for _, conn := range targets {
+		conn.deliver(topic, payload, false)
+	}
+}
+
+func (broker *testBroker) acceptWorker() {
+	defer broker.workerSync.Done()
+
+	for {
+		conn, err := broker.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &brokerConn{conn: conn}
+
+		broker.lock.Lock()
+		broker.conns[c] = struct{}{}
+		broker.lock.Unlock()
+
+		broker.workerSync.Add(1)
+		go func() {
+			defer broker.workerSync.Done()
+
+			graceful := broker.serve(c)
+			conn.Close()
+
+			broker.lock.Lock()
+			delete(broker.conns, c)
+			will := c.will
+			broker.lock.Unlock()
+
+			if !graceful && will != nil {
+				broker.publish(will.TopicName, string(will.Payload), will.Retain)
+			}
+		}()
+	}
+}
+
+// Serve the connection. Returns true if the client disconnected gracefully
+func (broker *testBroker) serve(c *brokerConn) bool {
+	for {
+		packet, err := packets.ReadPacket(c.conn)
+		if err != nil {
+			return false
+		}
+
+		switch packet := packet.(type) {
+		case *packets.ConnectPacket:
+			if packet.WillFlag {
+				will := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+				will.TopicName = packet.WillTopic
+				will.Payload = packet.WillMessage
+				will.Retain = packet.WillRetain
+
+				broker.lock.Lock()
+				c.will = will
+				broker.lock.Unlock()
+			}
+
+			c.write(packets.NewControlPacket(packets.Connack))
+
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = packet.MessageID
+			suback.ReturnCodes = make([]byte, len(packet.Topics))
+			c.write(suback)
+
+			broker.lock.Lock()
+			c.filters = append(c.filters, packet.Topics...)
+
+			retained := make(map[string]string)
+			for topic, value := range broker.retained {
+				for _, filter := range packet.Topics {
+					if topicMatch(filter, topic) {
+						retained[topic] = value
+					}
+				}
+			}
+
+			broker.lock.Unlock()
+
+			for topic, value := range retained {
+				c.deliver(topic, value, true)
+			}
+
+		case *packets.UnsubscribePacket:
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = packet.MessageID
+			c.write(unsuback)
+
+		case *packets.PublishPacket:
+			if packet.Qos > 0 {
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = packet.MessageID
+				c.write(puback)
+			}
+
+			broker.publish(packet.TopicName, string(packet.Payload), packet.Retain)
+
+		case *packets.PingreqPacket:
+			c.write(packets.NewControlPacket(packets.Pingresp))
+
+		case *packets.DisconnectPacket:
+			return true
+		}
+	}
+}
+
+func (c *brokerConn) deliver(topic string, payload string, retain bool) {
+	publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.TopicName = topic
+	publish.Payload = []byte(payload)
+	publish.Retain = retain
+
+	c.write(publish)
+}
+
+func (c *brokerConn) write(packet packets.ControlPacket) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	packet.Write(c.conn)
+}
+
+func topicMatch(filter string, topic string) bool {
+	filterParts := strings.Split(filter, "/")
+	topicParts := strings.Split(topic, "/")
+
+	for index, part := range filterParts {
+		if part == "#" {
+			return true
+		}
+
+		if index >= len(topicParts) {
+			return false
+		}
+
+		if part != "+" && part != topicParts[index] {
+			return false
+		}
+	}
+
+	return len(filterParts) == len(topicParts)
+}
+
+// Wait until the condition is true
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", description)
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+// Wait until the retained value of the topic is the expected one
+func waitRetained(t *testing.T, broker *testBroker, topic string, expected string) {
+	t.Helper()
+
+	waitFor(t, topic+" = '"+expected+"'", func() bool {
+		value, ok := broker.get(topic)
+		return ok && value == expected
+	})
+}
@@ -0,0 +1,150 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+const DefaultDiscoveryPrefix = "homeassistant"
+
+// Home Assistant cover device class of each actuator type. Other actuator types are not covers.
+var coverDeviceClasses = map[commands.ActuatorType]string{
+	commands.VenetianBlind:         "blind",
+	commands.RollerShutter:         "shutter",
+	commands.Awning:                "awning",
+	commands.WindowOpener:          "window",
+	commands.GarageOpener:          "garage",
+	commands.GateOpener:            "gate",
+	commands.RollingDoorOpener:     "garage",
+	commands.Blind:                 "blind",
+	commands.DualShutter:           "shutter",
+	commands.HorizontalAwning:      "awning",
+	commands.ExternalVenetianBlind: "blind",
+	commands.LouvreBlind:           "blind",
+	commands.CurtainTrack:          "curtain",
+	commands.SwingingShutter:       "shutter",
+}
+
+// Publish the discovery message of the node, if its actuator type has a Home Assistant equivalent
+func (b *Bridge) publishDiscovery(node *commands.GetAllNodesInformationNtf) {
+	component, config := b.discoveryConfig(node)
+	if component == "" {
+		b.debugf("No Home Assistant entity for node %d (%s)", node.NodeID, node.NodeTypeSubType.Description())
+		return
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		b.errorf("Could not encode the discovery message of node %d: %s", node.NodeID, err)
+		return
+	}
+
+	b.publishTopic(fmt.Sprintf("%s/%s/%s/config", b.discoveryPrefix, component, b.uniqueID(node)), string(data))
+}
+
+// Serial numbers are stable across gateways, fallback on the node id if the gateway does not report it
+func (b *Bridge) uniqueID(node *commands.GetAllNodesInformationNtf) string {
+	if node.SerialNumber != 0 {
+		return fmt.Sprintf("klf200_%016x", node.SerialNumber)
+	}
+
+	return fmt.Sprintf("%s_node_%d", b.prefix, node.NodeID)
+}
+
+// Return the Home Assistant component and the discovery payload of the node, or an empty component if not supported
+func (b *Bridge) discoveryConfig(node *commands.GetAllNodesInformationNtf) (string, map[string]interface{}) {
+	nodeTopic := func(name string) string {
+		return b.topic(fmt.Sprintf("node/%d/%s", node.NodeID, name))
+	}
+
+	uniqueID := b.uniqueID(node)
+
+	device := map[string]interface{}{
+		"identifiers":  []string{uniqueID},
+		"name":         node.Name,
+		"manufacturer": "Velux",
+		"model":        node.NodeTypeSubType.Description(),
+	}
+
+	if node.SerialNumber != 0 {
+		device["serial_number"] = fmt.Sprintf("%016x", node.SerialNumber)
+	}
+
+	config := map[string]interface{}{
+		// The entity is named after the device
+		"name":      nil,
+		"unique_id": uniqueID,
+		"device":    device,
+
+		// Available only if the bridge is running and connected to the gateway
+		"availability_mode": "all",
+		"availability": []map[string]string{
+			{"topic": b.topic("status")},
+			{"topic": b.topic("connection"), "payload_available": "open", "payload_not_available": "closed"},
+		},
+
+		"command_topic": nodeTopic("command"),
+	}
+
+	nodeType := node.NodeTypeSubType
+	caps := nodeType.Capabilities()
+
+	if deviceClass, ok := coverDeviceClasses[nodeType.ActuatorType()]; ok {
+		config["device_class"] = deviceClass
+		config["payload_open"] = "open"
+		config["payload_close"] = "close"
+		config["payload_stop"] = "stop"
+		config["position_topic"] = nodeTopic("position")
+		config["set_position_topic"] = nodeTopic("set")
+
+		// The gateway uses 0% for open
+		config["position_open"] = 0
+		config["position_closed"] = 100
+
+		return "cover", config
+	}
+
+	switch nodeType.ActuatorType() {
+	case commands.Light:
+		config["payload_on"] = "on"
+		config["payload_off"] = "off"
+		config["state_topic"] = nodeTopic("position")
+		config["state_value_template"] = "{{ None if value == '' else ('on' if value | int < 100 else 'off') }}"
+
+		if caps.ControlMode != commands.ControlModeOnOff {
+			// The gateway uses 0% for full intensity
+			config["brightness_scale"] = 100
+			config["brightness_state_topic"] = nodeTopic("position")
+			config["brightness_value_template"] = "{{ 100 - (value | int) }}"
+			config["brightness_command_topic"] = nodeTopic("set")
+			config["brightness_command_template"] = "{{ 100 - value }}"
+			config["on_command_type"] = "brightness"
+		}
+
+		return "light", config
+
+	case commands.OnOffSwitch:
+		config["payload_on"] = "on"
+		config["payload_off"] = "off"
+		config["state_on"] = "on"
+		config["state_off"] = "off"
+		config["state_topic"] = nodeTopic("position")
+		config["value_template"] = "{{ 'on' if value == '0' else ('off' if value == '100' else None) }}"
+
+		return "switch", config
+
+	case commands.Lock:
+		config["payload_lock"] = "lock"
+		config["payload_unlock"] = "unlock"
+		config["state_locked"] = "locked"
+		config["state_unlocked"] = "unlocked"
+		config["state_topic"] = nodeTopic("position")
+		config["value_template"] = "{{ 'unlocked' if value == '0' else ('locked' if value == '100' else None) }}"
+
+		return "lock", config
+	}
+
+	return "", nil
+}
@@ -0,0 +1,85 @@
+package klf200
+
+import (
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Observes the activity of the client, eg: to collect metrics.
+// The methods are called from the client goroutines, they must not block.
+type Observer interface {
+	// A connection to the gateway is being dialed (first connection or reconnection)
+	Dialing()
+
+	// The connection could not be established
+	DialFailed(err error)
+
+	// The handshake of a new connection failed
+	HandshakeFailed(err error)
+
+	// A request has been answered, or has failed. Each attempt is reported when the request is retried
+	RequestCompleted(cmd transport.Command, duration time.Duration, err error)
+
+	// A notification has been received from the gateway
+	NotificationReceived(notify commands.Notify)
+
+	// A notification has been dropped because the queue of a subscriber was full
+	NotificationDropped(notify commands.Notify, policy OverflowPolicy)
+}
+
+// Observer forwarding each event to all the observers, in order. Nil observers are skipped
+func MultiObserver(observers ...Observer) Observer {
+	multi := make(multiObserver, 0, len(observers))
+
+	for _, observer := range observers {
+		switch observer := observer.(type) {
+		case nil:
+		case multiObserver:
+			multi = append(multi, observer...)
+		default:
+			multi = append(multi, observer)
+		}
+	}
+
+	return multi
+}
+
+type multiObserver []Observer
+
+func (multi multiObserver) Dialing() {
+	for _, observer := range multi {
+		observer.Dialing()
+	}
+}
+
+func (multi multiObserver) DialFailed(err error) {
+	for _, observer := range multi {
+		observer.DialFailed(err)
+	}
+}
+
+func (multi multiObserver) HandshakeFailed(err error) {
+	for _, observer := range multi {
+		observer.HandshakeFailed(err)
+	}
+}
+
+func (multi multiObserver) RequestCompleted(cmd transport.Command, duration time.Duration, err error) {
+	for _, observer := range multi {
+		observer.RequestCompleted(cmd, duration, err)
+	}
+}
+
+func (multi multiObserver) NotificationReceived(notify commands.Notify) {
+	for _, observer := range multi {
+		observer.NotificationReceived(notify)
+	}
+}
+
+func (multi multiObserver) NotificationDropped(notify commands.Notify, policy OverflowPolicy) {
+	for _, observer := range multi {
+		observer.NotificationDropped(notify, policy)
+	}
+}
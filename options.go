@@ -0,0 +1,158 @@
+package klf200
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+)
+
+const defaultRequestTimeout = time.Second * 5
+const defaultHeartbeatInterval = time.Minute
+
+type clientOptions struct {
+	log               Logger
+	requestTimeout    time.Duration
+	reconnectPolicy   ReconnectPolicy
+	heartbeatInterval time.Duration
+	dialTimeout       time.Duration
+	tlsConfig         *tls.Config
+	verifier          certificateVerifier
+	readChannelSize   int
+	writeChannelSize  int
+	retryPolicy       RetryPolicy
+	connWrapper       ConnWrapper
+	recorder          FrameRecorder
+	observer          Observer
+	overflowPolicy    OverflowPolicy
+}
+
+func defaultClientOptions() clientOptions {
+	return clientOptions{
+		log:               nopLogger{},
+		requestTimeout:    defaultRequestTimeout,
+		reconnectPolicy:   DefaultReconnectPolicy(),
+		heartbeatInterval: defaultHeartbeatInterval,
+		// The gateway uses a self-signed certificate: not verified unless a pinning option is set
+		tlsConfig:        &tls.Config{InsecureSkipVerify: true},
+		readChannelSize:  defaultReadChannelSize,
+		writeChannelSize: defaultWriteChannelSize,
+		retryPolicy:      DefaultRetryPolicy(),
+	}
+}
+
+type ClientOption func(options *clientOptions)
+
+// Context of the establishment of a connection, bounded by the dial timeout
+func (options *clientOptions) establishContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if options.dialTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, options.dialTimeout)
+}
+
+// Set the logger of the client. Defaults to no logging
+func WithLogger(log Logger) ClientOption {
+	return func(options *clientOptions) {
+		if log != nil {
+			options.log = log
+		}
+	}
+}
+
+// Set how long the gateway has to confirm a request. Defaults to 5 seconds
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(options *clientOptions) {
+		options.requestTimeout = timeout
+	}
+}
+
+// Set how the client reconnects after the connection is lost or could not be established. Defaults to DefaultReconnectPolicy()
+func WithReconnectPolicy(policy ReconnectPolicy) ClientOption {
+	return func(options *clientOptions) {
+		options.reconnectPolicy = policy
+	}
+}
+
+// Reconnect after a fixed delay instead of an exponential backoff. Authentication failures still use the policy delay
+func WithReconnectDelay(delay time.Duration) ClientOption {
+	return func(options *clientOptions) {
+		options.reconnectPolicy.InitialDelay = delay
+		options.reconnectPolicy.MaxDelay = delay
+		options.reconnectPolicy.Jitter = 0
+	}
+}
+
+// Set the interval of the GW_GET_STATE_REQ sent to keep the connection alive, 0 disables it. Defaults to 1 minute.
+// Note that the gateway closes connections idle for 15 minutes
+func WithHeartbeatInterval(interval time.Duration) ClientOption {
+	return func(options *clientOptions) {
+		options.heartbeatInterval = interval
+	}
+}
+
+// Set the timeout of the connection establishment: TCP dial, TLS handshake and login. 0 means no timeout. Defaults to no timeout
+func WithDialTimeout(timeout time.Duration) ClientOption {
+	return func(options *clientOptions) {
+		options.dialTimeout = timeout
+	}
+}
+
+// Set the TLS configuration of the connections. Defaults to a configuration which does not verify the certificate of the gateway,
+// see WithPinnedCertificate and WithTrustOnFirstUse to secure it. If the certificate is verified, ServerName must match it
+func WithTLSConfig(conf *tls.Config) ClientOption {
+	return func(options *clientOptions) {
+		options.tlsConfig = conf.Clone()
+		options.verifier = nil
+	}
+}
+
+// Set the number of buffered reads and writes of each connection, both of raw data and of frames. Defaults to 1000 reads and 10 writes
+func WithChannelSizes(read int, write int) ClientOption {
+	return func(options *clientOptions) {
+		options.readChannelSize = read
+		options.writeChannelSize = write
+	}
+}
+
+// Set the policy used to retry requests on transient failures (gateway busy, timeout, ...). Defaults to DefaultRetryPolicy()
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(options *clientOptions) {
+		options.retryPolicy = policy
+	}
+}
+
+// Set a wrapper applied to the stream of each new connection, e.g. to inject faults in tests
+func WithConnWrapper(wrapper ConnWrapper) ClientOption {
+	return func(options *clientOptions) {
+		options.connWrapper = wrapper
+	}
+}
+
+// Set a recorder which observes all frames exchanged with the gateway, including the handshake
+func WithFrameRecorder(recorder FrameRecorder) ClientOption {
+	return func(options *clientOptions) {
+		options.recorder = recorder
+	}
+}
+
+// Add an observer of the client activity (connections, requests, notifications).
+// Can be given several times, the observers are called in order
+func WithObserver(observer Observer) ClientOption {
+	return func(options *clientOptions) {
+		if options.observer == nil {
+			options.observer = observer
+			return
+		}
+
+		options.observer = MultiObserver(options.observer, observer)
+	}
+}
+
+// Set the overflow policy of the subscribers registered with Subscribe. Defaults to OverflowDropOldest.
+// RegisterNotifications does not use it, see WithOverflowPolicy
+func WithDefaultOverflowPolicy(policy OverflowPolicy) ClientOption {
+	return func(options *clientOptions) {
+		options.overflowPolicy = policy
+	}
+}
@@ -0,0 +1,132 @@
+package klf200
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/faultinject"
+	"github.com/mylife-home/klf200-go/simulator"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type dialObserver struct {
+	dials atomic.Int32
+}
+
+func (o *dialObserver) Dialing()                                                 { o.dials.Add(1) }
+func (o *dialObserver) DialFailed(err error)                                     {}
+func (o *dialObserver) HandshakeFailed(err error)                                {}
+func (o *dialObserver) RequestCompleted(transport.Command, time.Duration, error) {}
+func (o *dialObserver) NotificationReceived(commands.Notify)                     {}
+func (o *dialObserver) NotificationDropped(commands.Notify, OverflowPolicy)      {}
+
+// Start the client and return the error of its first failed connection attempt
+func firstFailure(t *testing.T, client *Client) error {
+	t.Helper()
+
+	failures := make(chan error, 10)
+	client.RegisterStatusEvents(func(event StatusEvent) {
+		if event.Err != nil {
+			select {
+			case failures <- event.Err:
+			default:
+			}
+		}
+	})
+
+	client.Start()
+	t.Cleanup(client.Close)
+
+	select {
+	case err := <-failures:
+		return err
+	case <-time.After(testTimeout):
+		t.Fatal("connection did not fail")
+		return nil
+	}
+}
+
+func TestWithObserverChains(t *testing.T) {
+	first := &dialObserver{}
+	second := &dialObserver{}
+
+	client := NewClient("127.0.0.1:1", "", WithObserver(first), WithObserver(nil), WithObserver(second))
+	firstFailure(t, client)
+
+	if first.dials.Load() == 0 || second.dials.Load() == 0 {
+		t.Errorf("got %d dials on the first observer, %d on the second one", first.dials.Load(), second.dials.Load())
+	}
+}
+
+func TestDialTimeoutTLSHandshake(t *testing.T) {
+	// Accepts the connections but never answers the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	start := time.Now()
+	client := NewClient(listener.Addr().String(), "", WithDialTimeout(time.Millisecond*100))
+
+	if err := firstFailure(t, client); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, expected the dial timeout", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > testTimeout/2 {
+		t.Errorf("failed after %s", elapsed)
+	}
+}
+
+func TestDialTimeoutLoginHandshake(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+
+	// The gateway never receives the password
+	inj := faultinject.NewInjector(1)
+	inj.AddRule(faultinject.Rule{Direction: faultinject.DirectionToGateway, Fault: faultinject.FaultDrop, Probability: faultinject.Always})
+
+	client := NewClient(gw.Address(), gw.Password(), WithDialTimeout(time.Millisecond*200), WithConnWrapper(inj.Wrap))
+
+	if err := firstFailure(t, client); !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v, expected the handshake to time out", err)
+	}
+}
+
+func TestWithChannelSizes(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+
+	options := defaultClientOptions()
+	WithChannelSizes(5, 7)(&options)
+
+	conn, err := makeConnection(testContext(t), gw.Address(), nil, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if cap(conn.read) != 5 || cap(conn.sock.read) != 5 {
+		t.Errorf("got read channels of %d frames and %d buffers", cap(conn.read), cap(conn.sock.read))
+	}
+
+	if cap(conn.write) != 7 || cap(conn.sock.write) != 7 {
+		t.Errorf("got write channels of %d frames and %d buffers", cap(conn.write), cap(conn.sock.write))
+	}
+}
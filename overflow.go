@@ -0,0 +1,106 @@
+package klf200
+
+import (
+	"errors"
+	"fmt"
+)
+
+// What to do when the queue of a subscriber is full. Except with OverflowBlock, notifications are never
+// sent in a blocking way, so that a slow subscriber cannot stall the connection.
+type OverflowPolicy int
+
+const (
+	// Drop the oldest queued notification to make room for the new one
+	OverflowDropOldest OverflowPolicy = 0
+
+	// Drop the new notification
+	OverflowDropNewest OverflowPolicy = 1
+
+	// Close the stream of the subscriber
+	OverflowDisconnect OverflowPolicy = 2
+
+	// Wait for the subscriber to make room. Nothing is lost, but a slow subscriber delays all the notifications
+	// and the confirms of the requests. Default of RegisterNotifications, which always behaved this way
+	OverflowBlock OverflowPolicy = 3
+)
+
+func (policy OverflowPolicy) String() string {
+	switch policy {
+	case OverflowDropOldest:
+		return "DropOldest"
+	case OverflowDropNewest:
+		return "DropNewest"
+	case OverflowDisconnect:
+		return "Disconnect"
+	case OverflowBlock:
+		return "Block"
+	default:
+		return fmt.Sprintf("<%d>", policy)
+	}
+}
+
+// The notifications stream has been closed because the subscriber did not keep up (see OverflowDisconnect)
+var ErrOverflow = errors.New("notifications overflow")
+
+const defaultQueueSize = 1000
+
+type subscribeOptions struct {
+	policy    OverflowPolicy
+	queueSize int
+	dropped   func()
+}
+
+type SubscribeOption func(options *subscribeOptions)
+
+// Set the overflow policy of the subscriber. Defaults to the client one (see WithDefaultOverflowPolicy) for Subscribe,
+// and to OverflowBlock for RegisterNotifications
+func WithOverflowPolicy(policy OverflowPolicy) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.policy = policy
+	}
+}
+
+// Set the number of notifications which can be queued for the subscriber. Defaults to 1000
+func WithQueueSize(size int) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.queueSize = size
+	}
+}
+
+// Call the callback each time a notification of the subscriber is dropped, from the dispatch: it must not block.
+// Used by the internal subscribers which resynchronize from the gateway on a loss
+func withDropped(callback func()) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.dropped = callback
+	}
+}
+
+// Queue the item without blocking, applying the policy if the queue is full.
+// Returns false if the subscriber must be disconnected
+func offer[T any](stream chan T, item T, policy OverflowPolicy, dropped func(item T)) bool {
+	for {
+		select {
+		case stream <- item:
+			return true
+		default:
+		}
+
+		switch policy {
+		case OverflowDropNewest:
+			dropped(item)
+			return true
+
+		case OverflowDisconnect:
+			dropped(item)
+			return false
+
+		default:
+			// The subscriber may have consumed an item meanwhile, then there is nothing to drop
+			select {
+			case oldest := <-stream:
+				dropped(oldest)
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package klf200
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type droppedObserver struct {
+	lock    sync.Mutex
+	dropped map[OverflowPolicy]int
+}
+
+func (o *droppedObserver) Dialing()                                                 {}
+func (o *droppedObserver) DialFailed(err error)                                     {}
+func (o *droppedObserver) HandshakeFailed(err error)                                {}
+func (o *droppedObserver) RequestCompleted(transport.Command, time.Duration, error) {}
+func (o *droppedObserver) NotificationReceived(commands.Notify)                     {}
+
+func (o *droppedObserver) NotificationDropped(notify commands.Notify, policy OverflowPolicy) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.dropped[policy]++
+}
+
+func (o *droppedObserver) count(policy OverflowPolicy) int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.dropped[policy]
+}
+
+// Client which is not started, notifications are dispatched by the test
+func newOverflowClient() (*Client, *droppedObserver) {
+	observer := &droppedObserver{dropped: make(map[OverflowPolicy]int)}
+	client := NewClient("127.0.0.1:1", "", WithObserver(observer))
+
+	return client, observer
+}
+
+func dispatchSessions(client *Client, ids ...int) {
+	for _, id := range ids {
+		client.dispatchNotify(&commands.SessionFinishedNtf{SessionID: id})
+	}
+}
+
+func readSessions(t *testing.T, stream <-chan *commands.SessionFinishedNtf, count int) []int {
+	t.Helper()
+
+	ids := make([]int, 0, count)
+
+	for range count {
+		select {
+		case notif := <-stream:
+			ids = append(ids, notif.SessionID)
+		case <-time.After(testTimeout):
+			t.Fatalf("got %v, expected %d notifications", ids, count)
+		}
+	}
+
+	return ids
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	client, observer := newOverflowClient()
+	defer client.Close()
+
+	stream, cancel := Subscribe[*commands.SessionFinishedNtf](client, nil, WithOverflowPolicy(OverflowDropOldest), WithQueueSize(2))
+	defer cancel()
+
+	dispatchSessions(client, 1, 2, 3, 4)
+
+	if ids := readSessions(t, stream, 2); !reflect.DeepEqual(ids, []int{3, 4}) {
+		t.Errorf("got %v, expected the newest notifications", ids)
+	}
+
+	if dropped := observer.count(OverflowDropOldest); dropped != 2 {
+		t.Errorf("got %d notifications dropped", dropped)
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	client, observer := newOverflowClient()
+	defer client.Close()
+
+	stream, cancel := Subscribe[*commands.SessionFinishedNtf](client, nil, WithOverflowPolicy(OverflowDropNewest), WithQueueSize(2))
+	defer cancel()
+
+	dispatchSessions(client, 1, 2, 3, 4)
+
+	if ids := readSessions(t, stream, 2); !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("got %v, expected the oldest notifications", ids)
+	}
+
+	if dropped := observer.count(OverflowDropNewest); dropped != 2 {
+		t.Errorf("got %d notifications dropped", dropped)
+	}
+}
+
+func TestOverflowDisconnect(t *testing.T) {
+	client, _ := newOverflowClient()
+	defer client.Close()
+
+	stream, cancel := Subscribe[*commands.SessionFinishedNtf](client, nil, WithOverflowPolicy(OverflowDisconnect), WithQueueSize(2))
+	defer cancel()
+
+	dispatchSessions(client, 1, 2, 3, 4)
+
+	if ids := readSessions(t, stream, 2); !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("got %v before the disconnection", ids)
+	}
+
+	if _, ok := <-stream; ok {
+		t.Error("stream not closed on overflow")
+	}
+
+	// Cancel after the disconnection is harmless
+	cancel()
+}
+
+func TestOverflowBlock(t *testing.T) {
+	client, observer := newOverflowClient()
+	defer client.Close()
+
+	stream, cancel := Subscribe[*commands.SessionFinishedNtf](client, nil, WithOverflowPolicy(OverflowBlock), WithQueueSize(2))
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatchSessions(client, 1, 2, 3, 4)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("dispatch did not wait for the subscriber")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	if ids := readSessions(t, stream, 4); !reflect.DeepEqual(ids, []int{1, 2, 3, 4}) {
+		t.Errorf("got %v, expected all the notifications", ids)
+	}
+
+	<-done
+
+	if dropped := observer.count(OverflowBlock); dropped != 0 {
+		t.Errorf("got %d notifications dropped", dropped)
+	}
+}
+
+func TestOverflowBlockClose(t *testing.T) {
+	client, _ := newOverflowClient()
+	defer client.Close()
+
+	_, cancel := Subscribe[*commands.SessionFinishedNtf](client, nil, WithOverflowPolicy(OverflowBlock), WithQueueSize(1))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatchSessions(client, 1, 2, 3)
+	}()
+
+	time.Sleep(time.Millisecond * 50)
+
+	// Must not deadlock with the blocked dispatch
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("dispatch still blocked after close")
+	}
+}
+
+func TestOverflowDefaults(t *testing.T) {
+	client, _ := newOverflowClient()
+	defer client.Close()
+
+	// Lossless, as it has always been
+	lossless := client.RegisterNotifications(nil, WithQueueSize(1))
+	defer lossless.Close()
+
+	if policy := lossless.(*notifier[commands.Notify]).policy; policy != OverflowBlock {
+		t.Errorf("RegisterNotifications: got policy %s", policy)
+	}
+
+	// Overridable
+	dropping := client.RegisterNotifications(nil, WithOverflowPolicy(OverflowDropNewest))
+	defer dropping.Close()
+
+	if policy := dropping.(*notifier[commands.Notify]).policy; policy != OverflowDropNewest {
+		t.Errorf("RegisterNotifications with policy: got policy %s", policy)
+	}
+
+	n := subscribe[*commands.SessionFinishedNtf](client, nil, nil)
+	defer n.Close()
+
+	if n.policy != OverflowDropOldest {
+		t.Errorf("Subscribe: got policy %s", n.policy)
+	}
+
+	client = NewClient("127.0.0.1:1", "", WithDefaultOverflowPolicy(OverflowDisconnect))
+	defer client.Close()
+
+	n = subscribe[*commands.SessionFinishedNtf](client, nil, nil)
+	defer n.Close()
+
+	if n.policy != OverflowDisconnect {
+		t.Errorf("Subscribe with WithDefaultOverflowPolicy: got policy %s", n.policy)
+	}
+}
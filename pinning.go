@@ -0,0 +1,236 @@
+package klf200
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Without the options of this file (or WithTLSConfig), the client accepts any certificate: the default TLS configuration
+// uses InsecureSkipVerify, as the certificate of the gateway is self-signed.
+
+// The certificate presented by the gateway does not match the pinned fingerprint
+var ErrCertificateMismatch = errors.New("certificate mismatch")
+
+// Verifies the certificate presented by the gateway at address
+type certificateVerifier func(address string, cert *x509.Certificate) error
+
+// SHA-256 of the DER encoding of the certificate, as colon-separated hex bytes (same as 'openssl x509 -fingerprint -sha256')
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+
+	parts := make([]string, len(sum))
+	for index, value := range sum {
+		parts[index] = fmt.Sprintf("%02X", value)
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// Fingerprints are compared without separators and case
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(fingerprint))
+}
+
+func checkFingerprint(cert *x509.Certificate, expected string) error {
+	fingerprint := CertificateFingerprint(cert)
+	if normalizeFingerprint(fingerprint) != normalizeFingerprint(expected) {
+		return fmt.Errorf("%w: got %s, expected %s", ErrCertificateMismatch, fingerprint, expected)
+	}
+
+	return nil
+}
+
+// Connect to the gateway without verifying its certificate, and return the certificate.
+// Use it to display the fingerprint to the user for enrollment (see WithPinnedCertificate)
+func FetchCertificate(ctx context.Context, address string) (*x509.Certificate, error) {
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate presented")
+	}
+
+	return certs[0], nil
+}
+
+// Only accept the gateway certificate with the given fingerprint (see CertificateFingerprint).
+// Replaces the TLS configuration set by the other options
+func WithPinnedCertificate(fingerprint string) ClientOption {
+	return withVerifier(func(address string, cert *x509.Certificate) error {
+		return checkFingerprint(cert, fingerprint)
+	})
+}
+
+// Trust the certificate presented on the first connection and store its fingerprint, then only accept this certificate.
+// Replaces the TLS configuration set by the other options
+func WithTrustOnFirstUse(store PinStore) ClientOption {
+	return withVerifier(func(address string, cert *x509.Certificate) error {
+		fingerprint, err := store.Load(address)
+		if err != nil {
+			return fmt.Errorf("cannot load pinned certificate: %w", err)
+		}
+
+		if fingerprint == "" {
+			return store.Save(address, CertificateFingerprint(cert))
+		}
+
+		return checkFingerprint(cert, fingerprint)
+	})
+}
+
+// Verify the gateway certificate against the given CA pool. serverName must match the certificate.
+// Replaces the TLS configuration set by the other options
+func WithRootCAs(pool *x509.CertPool, serverName string) ClientOption {
+	return func(options *clientOptions) {
+		options.tlsConfig = &tls.Config{RootCAs: pool, ServerName: serverName}
+		options.verifier = nil
+	}
+}
+
+func withVerifier(verifier certificateVerifier) ClientOption {
+	return func(options *clientOptions) {
+		// The gateway certificate is self-signed, it is checked by the verifier instead of the chain
+		options.tlsConfig = &tls.Config{InsecureSkipVerify: true}
+		options.verifier = verifier
+	}
+}
+
+// TLS configuration used to connect to the gateway at address
+func (options *clientOptions) tlsConfigFor(address string) *tls.Config {
+	conf := options.tlsConfig.Clone()
+
+	if verifier := options.verifier; verifier != nil {
+		conf.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("no certificate presented")
+			}
+
+			return verifier(address, state.PeerCertificates[0])
+		}
+	}
+
+	return conf
+}
+
+// Stores the pinned certificate fingerprints, by gateway address
+type PinStore interface {
+	// Returns an empty fingerprint if none is pinned for the address yet
+	Load(address string) (string, error)
+	Save(address string, fingerprint string) error
+}
+
+// Pin store backed by a file with one "<address> <fingerprint>" line per gateway, like known_hosts.
+// The file is created on first save
+func NewFilePinStore(path string) PinStore {
+	return &filePinStore{path: path}
+}
+
+type filePinStore struct {
+	path string
+	lock sync.Mutex
+}
+
+var _ PinStore = (*filePinStore)(nil)
+
+func (store *filePinStore) Load(address string) (string, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	pins, err := store.read()
+	if err != nil {
+		return "", err
+	}
+
+	return pins[address], nil
+}
+
+func (store *filePinStore) Save(address string, fingerprint string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	pins, err := store.read()
+	if err != nil {
+		return err
+	}
+
+	pins[address] = fingerprint
+
+	addresses := make([]string, 0, len(pins))
+	for address := range pins {
+		addresses = append(addresses, address)
+	}
+
+	slices.Sort(addresses)
+
+	builder := &strings.Builder{}
+	for _, address := range addresses {
+		fmt.Fprintf(builder, "%s %s\n", address, pins[address])
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(builder.String()); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), store.path)
+}
+
+func (store *filePinStore) read() (map[string]string, error) {
+	pins := make(map[string]string)
+
+	file, err := os.Open(store.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return pins, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("bad pin file '%s' (line %d)", store.path, line)
+		}
+
+		pins[fields[0]] = fields[1]
+	}
+
+	return pins, scanner.Err()
+}
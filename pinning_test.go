@@ -0,0 +1,132 @@
+package klf200
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mylife-home/klf200-go/simulator"
+)
+
+// Fingerprint of the self-signed certificate of the simulator
+func simulatorFingerprint(t *testing.T, gw *simulator.Gateway) string {
+	t.Helper()
+
+	cert, err := FetchCertificate(testContext(t), gw.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return CertificateFingerprint(cert)
+}
+
+const wrongFingerprint = "00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF:00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF"
+
+// The connection fails on the certificate, and is reported as an authentication failure
+func checkCertificateMismatch(t *testing.T, err error) {
+	t.Helper()
+
+	if !errors.Is(err, ErrCertificateMismatch) {
+		t.Errorf("got %v, expected a certificate mismatch", err)
+	}
+
+	if !IsAuthFailure(err) {
+		t.Errorf("%v is not an authentication failure", err)
+	}
+}
+
+func TestPinnedCertificate(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+	fingerprint := simulatorFingerprint(t, gw)
+
+	startClient(t, gw, WithPinnedCertificate(fingerprint))
+
+	// Compared without separators and case
+	startClient(t, gw, WithPinnedCertificate(strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))))
+
+	err := firstFailure(t, NewClient(gw.Address(), gw.Password(), WithPinnedCertificate(wrongFingerprint)))
+	checkCertificateMismatch(t, err)
+}
+
+func TestTrustOnFirstUse(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+	fingerprint := simulatorFingerprint(t, gw)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "known_gateways")
+
+	if err := NewFilePinStore(path).Save("other:51200", wrongFingerprint); err != nil {
+		t.Fatal(err)
+	}
+
+	// First use: the certificate is pinned
+	startClient(t, gw, WithTrustOnFirstUse(NewFilePinStore(path)))
+
+	store := NewFilePinStore(path)
+	if pinned, err := store.Load(gw.Address()); err != nil || pinned != fingerprint {
+		t.Fatalf("got pin %q, %v, expected %s", pinned, err, fingerprint)
+	}
+
+	if pinned, _ := store.Load("other:51200"); pinned != wrongFingerprint {
+		t.Errorf("pin of another gateway changed to %q", pinned)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0o600 {
+		t.Errorf("got file permissions %o", perm)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files, expected no temporary file left", len(entries))
+	}
+
+	// Match
+	startClient(t, gw, WithTrustOnFirstUse(NewFilePinStore(path)))
+
+	// Mismatch
+	if err := store.Save(gw.Address(), wrongFingerprint); err != nil {
+		t.Fatal(err)
+	}
+
+	err = firstFailure(t, NewClient(gw.Address(), gw.Password(), WithTrustOnFirstUse(NewFilePinStore(path))))
+	checkCertificateMismatch(t, err)
+
+	// The pin is not replaced by the presented certificate
+	if pinned, _ := store.Load(gw.Address()); pinned != wrongFingerprint {
+		t.Errorf("pin replaced by %q", pinned)
+	}
+}
+
+func TestFilePinStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_gateways")
+	store := NewFilePinStore(path)
+
+	// No file yet
+	if pinned, err := store.Load("gateway:51200"); err != nil || pinned != "" {
+		t.Errorf("got pin %q, %v", pinned, err)
+	}
+
+	content := "# comment\n\ngateway:51200 AB:CD\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if pinned, err := store.Load("gateway:51200"); err != nil || pinned != "AB:CD" {
+		t.Errorf("got pin %q, %v", pinned, err)
+	}
+
+	if err := os.WriteFile(path, []byte("gateway:51200\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Load("gateway:51200"); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("got %v, expected the bad line", err)
+	}
+}
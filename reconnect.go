@@ -0,0 +1,88 @@
+package klf200
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// How the client reconnects after the connection is lost or could not be established
+type ReconnectPolicy struct {
+	// Delay before the first reconnection, doubled after each following failure
+	InitialDelay time.Duration
+
+	// Maximum delay between two attempts
+	MaxDelay time.Duration
+
+	// Random part of each delay, from 0 (fixed delay) to 1 (delay picked in [0, delay])
+	Jitter float64
+
+	// Delay after an authentication failure (wrong password or refused certificate), instead of the backoff.
+	// The gateway may lock out after repeated wrong passwords, so it should be long. 0 uses the default one (10 minutes).
+	// A negative delay stops reconnecting: the last StatusEvent is then marked Stopped
+	AuthFailureDelay time.Duration
+}
+
+const defaultAuthFailureDelay = time.Minute * 10
+
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialDelay:     time.Second,
+		MaxDelay:         time.Minute * 2,
+		Jitter:           0.5,
+		AuthFailureDelay: defaultAuthFailureDelay,
+	}
+}
+
+// Delay before the next connection attempt after the failure err. Returns false if the client must stop reconnecting
+func (policy ReconnectPolicy) nextDelay(delays *backoff, err error) (time.Duration, bool) {
+	if !IsAuthFailure(err) {
+		return delays.Next(), true
+	}
+
+	switch {
+	case policy.AuthFailureDelay < 0:
+		return 0, false
+	case policy.AuthFailureDelay == 0:
+		return defaultAuthFailureDelay, true
+	default:
+		return policy.AuthFailureDelay, true
+	}
+}
+
+// Connection status, with the reconnection diagnostics
+type StatusEvent struct {
+	Status ConnectionStatus
+
+	// Error which ended the last connection or made the last attempt fail. nil when the client is closed
+	Err error
+
+	// Number of consecutive failures, including the loss of an open connection. 0 once the connection is open
+	Attempt int
+
+	// Time of the next connection attempt. Zero if none is planned (not closed, client closed, or reconnection stopped)
+	NextRetry time.Time
+
+	// The client stopped reconnecting after an authentication failure (see ReconnectPolicy.AuthFailureDelay). Last event until it is closed
+	Stopped bool
+}
+
+func (event StatusEvent) String() string {
+	if event.Status != ConnectionClosed || event.Err == nil {
+		return event.Status.String()
+	}
+
+	if event.NextRetry.IsZero() {
+		return fmt.Sprintf("%s (attempt %d: %s, not retrying)", event.Status, event.Attempt, event.Err)
+	}
+
+	return fmt.Sprintf("%s (attempt %d: %s, retry at %s)", event.Status, event.Attempt, event.Err, event.NextRetry.Format(time.TimeOnly))
+}
+
+// Indicates if err is an authentication failure: wrong password, or gateway certificate refused
+func IsAuthFailure(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+
+	return errors.Is(err, ErrAuthenticationFailed) || errors.Is(err, ErrCertificateMismatch) || errors.As(err, &verifyErr)
+}
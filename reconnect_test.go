@@ -0,0 +1,158 @@
+package klf200
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/simulator"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	policy := ReconnectPolicy{InitialDelay: time.Millisecond * 100, MaxDelay: time.Second}
+	delays := newBackoff(policy.InitialDelay, policy.MaxDelay, policy.Jitter)
+	dialErr := errors.New("connection refused")
+
+	expected := []time.Duration{
+		time.Millisecond * 100,
+		time.Millisecond * 200,
+		time.Millisecond * 400,
+		time.Millisecond * 800,
+		time.Second,
+		time.Second,
+	}
+
+	for index, delay := range expected {
+		if got, retry := policy.nextDelay(delays, dialErr); got != delay || !retry {
+			t.Errorf("attempt %d: got %s, %t, expected %s", index+1, got, retry, delay)
+		}
+	}
+}
+
+func TestReconnectJitter(t *testing.T) {
+	policy := DefaultReconnectPolicy()
+	delays := newBackoff(policy.InitialDelay, policy.MaxDelay, policy.Jitter)
+	dialErr := errors.New("connection refused")
+
+	for attempt := range 20 {
+		delay := min(policy.InitialDelay<<attempt, policy.MaxDelay)
+		lowest := delay - time.Duration(float64(delay)*policy.Jitter)
+
+		if got, _ := policy.nextDelay(delays, dialErr); got < lowest || got > delay {
+			t.Fatalf("attempt %d: got %s, expected [%s, %s]", attempt+1, got, lowest, delay)
+		}
+	}
+}
+
+func TestReconnectAuthFailure(t *testing.T) {
+	authErrors := []error{
+		ErrAuthenticationFailed,
+		fmt.Errorf("%w: got 00, expected 01", ErrCertificateMismatch),
+	}
+
+	tests := []struct {
+		authFailureDelay time.Duration
+		delay            time.Duration
+		retry            bool
+	}{
+		{time.Minute, time.Minute, true},
+		{0, defaultAuthFailureDelay, true},
+		{-1, 0, false},
+	}
+
+	for _, err := range authErrors {
+		for _, test := range tests {
+			policy := ReconnectPolicy{InitialDelay: time.Millisecond * 100, MaxDelay: time.Second, AuthFailureDelay: test.authFailureDelay}
+			delays := newBackoff(policy.InitialDelay, policy.MaxDelay, policy.Jitter)
+
+			if delay, retry := policy.nextDelay(delays, err); delay != test.delay || retry != test.retry {
+				t.Errorf("%v with AuthFailureDelay %s: got %s, %t", err, test.authFailureDelay, delay, retry)
+			}
+
+			// The backoff of the other failures is not advanced
+			if delay, _ := policy.nextDelay(delays, errors.New("connection refused")); delay != policy.InitialDelay {
+				t.Errorf("%v with AuthFailureDelay %s: got a backoff of %s", err, test.authFailureDelay, delay)
+			}
+		}
+	}
+}
+
+// Start the client and return its status events with an error
+func failureEvents(t *testing.T, client *Client) <-chan StatusEvent {
+	t.Helper()
+
+	events := make(chan StatusEvent, 100)
+	client.RegisterStatusEvents(func(event StatusEvent) {
+		if event.Err != nil {
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	})
+
+	client.Start()
+	t.Cleanup(client.Close)
+
+	return events
+}
+
+func nextFailure(t *testing.T, events <-chan StatusEvent) StatusEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(testTimeout):
+		t.Fatal("no failure")
+		return StatusEvent{}
+	}
+}
+
+func TestReconnectRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := listener.Addr().String()
+	listener.Close()
+
+	policy := ReconnectPolicy{InitialDelay: time.Millisecond * 20, MaxDelay: time.Millisecond * 80}
+	events := failureEvents(t, NewClient(address, "", WithReconnectPolicy(policy)))
+
+	previous := nextFailure(t, events)
+	for attempt, delay := range []time.Duration{time.Millisecond * 40, time.Millisecond * 80, time.Millisecond * 80} {
+		event := nextFailure(t, events)
+
+		if event.Attempt != attempt+2 || event.Stopped || IsAuthFailure(event.Err) {
+			t.Fatalf("got event %+v", event)
+		}
+
+		// The next attempt is planned after the delay, once the dial failed
+		if elapsed := event.NextRetry.Sub(previous.NextRetry); elapsed < delay || elapsed > delay+testTimeout/2 {
+			t.Errorf("attempt %d: next retry planned %s after the previous one, expected %s", event.Attempt, elapsed, delay)
+		}
+
+		previous = event
+	}
+}
+
+func TestReconnectAuthFailureDefaultDelay(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+
+	// Zero AuthFailureDelay: the default one
+	policy := ReconnectPolicy{InitialDelay: time.Millisecond * 10}
+	events := failureEvents(t, NewClient(gw.Address(), "wrong", WithReconnectPolicy(policy)))
+
+	event := nextFailure(t, events)
+	if !IsAuthFailure(event.Err) || event.Stopped {
+		t.Fatalf("got event %+v", event)
+	}
+
+	if retry := time.Until(event.NextRetry); retry < defaultAuthFailureDelay-time.Minute || retry > defaultAuthFailureDelay {
+		t.Errorf("next retry in %s, expected %s", retry, defaultAuthFailureDelay)
+	}
+}
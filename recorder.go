@@ -0,0 +1,33 @@
+package klf200
+
+import (
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type FrameDirection int
+
+// Frame sent by the client to the gateway
+const FrameSent FrameDirection = 1
+
+// Frame received by the client from the gateway
+const FrameReceived FrameDirection = 2
+
+func (direction FrameDirection) String() string {
+	switch direction {
+	case FrameSent:
+		return "Sent"
+	case FrameReceived:
+		return "Received"
+	default:
+		return fmt.Sprintf("<%d>", direction)
+	}
+}
+
+// Observes the frames exchanged with the gateway.
+// connID identifies the connection (incremented on each reconnection, starting at 1).
+// RecordFrame is called from the connection worker, it must not block.
+type FrameRecorder interface {
+	RecordFrame(connID int, direction FrameDirection, frame *transport.Frame)
+}
@@ -0,0 +1,296 @@
+package restapi
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+const DefaultEventHistory = 1000
+
+// Size of the queue of each subscriber. A subscriber which does not keep up is disconnected,
+// it can then resume from its last event id.
+const subscriberQueue = 100
+
+// Types of the events reported by the runs (position changes, scene activations) started from the API.
+// The other events are notifications from the gateway, with their name as type (eg: GW_NODE_STATE_POSITION_CHANGED_NTF).
+const (
+	EventRunStatus        = "RunStatus"
+	EventRunRemainingTime = "RunRemainingTime"
+	EventRunError         = "RunError"
+	EventRunFinished      = "RunFinished"
+)
+
+// Live event, streamed on GET /events (Server-Sent Events) and GET /events/ws (WebSocket)
+type Event struct {
+	// Increasing id, used to resume the stream
+	ID   uint64 `json:"id"`
+	Type string `json:"type"`
+
+	// Node or scene concerned by the event, if any
+	Node  *int `json:"node,omitempty"`
+	Scene *int `json:"scene,omitempty"`
+
+	// Notification (commands types) or run event (klf200 types)
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Subscriber filter, nil members match everything
+type eventFilter struct {
+	nodes map[int]struct{}
+	types map[string]struct{}
+}
+
+func (filter *eventFilter) match(event *Event) bool {
+	if filter.types != nil {
+		if _, ok := filter.types[strings.ToUpper(event.Type)]; !ok {
+			return false
+		}
+	}
+
+	if filter.nodes != nil {
+		if event.Node == nil {
+			return false
+		}
+
+		if _, ok := filter.nodes[*event.Node]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Parse the filter from the query: 'node' and 'type', each one repeated or comma separated
+func parseEventFilter(r *http.Request) (*eventFilter, error) {
+	filter := &eventFilter{}
+	query := r.URL.Query()
+
+	for _, value := range splitQuery(query["node"]) {
+		node, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, badRequest("bad node '%s'", value)
+		}
+
+		if filter.nodes == nil {
+			filter.nodes = make(map[int]struct{})
+		}
+
+		filter.nodes[node] = struct{}{}
+	}
+
+	for _, value := range splitQuery(query["type"]) {
+		if !isEventType(value) {
+			return nil, badRequest("bad type '%s'", value)
+		}
+
+		if filter.types == nil {
+			filter.types = make(map[string]struct{})
+		}
+
+		filter.types[strings.ToUpper(value)] = struct{}{}
+	}
+
+	return filter, nil
+}
+
+func splitQuery(values []string) []string {
+	list := make([]string, 0)
+
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+
+	return list
+}
+
+func isEventType(name string) bool {
+	for _, typ := range []string{EventRunStatus, EventRunRemainingTime, EventRunError, EventRunFinished} {
+		if strings.EqualFold(typ, name) {
+			return true
+		}
+	}
+
+	for _, code := range commands.NotifyCodes() {
+		if strings.EqualFold(code.String(), name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse the id of the last event received by the client, from the Last-Event-ID header
+// (set by EventSource when it reconnects) or from the 'lastEventId' query parameter
+func parseLastEventID(r *http.Request) (*uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, badRequest("bad last event id '%s'", value)
+	}
+
+	return &id, nil
+}
+
+type subscriber struct {
+	filter *eventFilter
+	events chan *Event
+}
+
+// Keep the last events, and dispatch the new ones to the subscribers
+type eventHub struct {
+	lock        sync.Mutex
+	lastID      uint64
+	history     []*Event
+	maxHistory  int
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+func newEventHub(maxHistory int) *eventHub {
+	return &eventHub{
+		maxHistory:  maxHistory,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (hub *eventHub) publish(event *Event) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	hub.lastID++
+	event.ID = hub.lastID
+
+	hub.history = append(hub.history, event)
+	if len(hub.history) > hub.maxHistory {
+		hub.history = slices.Delete(hub.history, 0, len(hub.history)-hub.maxHistory)
+	}
+
+	for sub := range hub.subscribers {
+		if !sub.filter.match(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			// Too slow, drop it
+			delete(hub.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribe to the new events, and return the ones kept in history after lastID if set
+func (hub *eventHub) subscribe(filter *eventFilter, lastID *uint64) (*subscriber, []*Event) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	backlog := make([]*Event, 0)
+	if lastID != nil {
+		for _, event := range hub.history {
+			if event.ID > *lastID && filter.match(event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	sub := &subscriber{filter: filter, events: make(chan *Event, subscriberQueue)}
+	if hub.closed {
+		close(sub.events)
+	} else {
+		hub.subscribers[sub] = struct{}{}
+	}
+
+	return sub, backlog
+}
+
+func (hub *eventHub) unsubscribe(sub *subscriber) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	if _, ok := hub.subscribers[sub]; ok {
+		delete(hub.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (hub *eventHub) closeAll() {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	hub.closed = true
+	for sub := range hub.subscribers {
+		delete(hub.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (s *Server) worker(notifications <-chan commands.Notify, unsubscribe func()) {
+	defer s.workerSync.Done()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.events.closeAll()
+			return
+
+		case notif := <-notifications:
+			s.events.publish(notificationEvent(notif))
+		}
+	}
+}
+
+func notificationEvent(notif commands.Notify) *Event {
+	event := &Event{Type: notif.Code().String(), Data: notif}
+
+	switch notif := notif.(type) {
+	case *commands.NodeStatePositionChangedNtf:
+		event.Node = &notif.NodeID
+	case *commands.GetAllNodesInformationNtf:
+		event.Node = &notif.NodeID
+	case *commands.CommandRunStatusNtf:
+		event.Node = &notif.NodeIndex
+	case *commands.CommandRemainingTimeNtf:
+		event.Node = &notif.NodeIndex
+	case *commands.StatusRequestNtf:
+		event.Node = &notif.NodeIndex
+	}
+
+	return event
+}
+
+// Publish an event of a run started from the API, or its end if event is nil
+func (s *Server) publishRun(node *int, scene *int, event klf200.Event) {
+	published := &Event{Type: EventRunFinished, Node: node, Scene: scene, Data: event}
+
+	switch event := event.(type) {
+	case *klf200.RunStatus:
+		published.Type = EventRunStatus
+	case *klf200.RunRemainingTime:
+		published.Type = EventRunRemainingTime
+	case *klf200.RunError:
+		published.Type = EventRunError
+		published.Data = &Error{Error: event.Err.Error()}
+	}
+
+	s.events.publish(published)
+}
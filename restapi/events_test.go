@@ -0,0 +1,335 @@
+package restapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Server whose client never connects, so that only the published test events are streamed
+func startEventServer(t *testing.T, config Config) (*Server, *httptest.Server) {
+	t.Helper()
+
+	server := NewServer(klf200.NewClient("127.0.0.1:1", ""), config)
+	httpServer := httptest.NewServer(server)
+
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Close()
+	})
+
+	return server, httpServer
+}
+
+func nodeEvent(typ string, node int) *Event {
+	return &Event{Type: typ, Node: &node}
+}
+
+func sceneEvent(typ string, scene int) *Event {
+	return &Event{Type: typ, Scene: &scene}
+}
+
+// Publish the test events: ids 1 to 5
+func publishTestEvents(server *Server) {
+	server.events.publish(nodeEvent(EventRunStatus, 0))
+	server.events.publish(nodeEvent(EventRunStatus, 1))
+	server.events.publish(nodeEvent(EventRunFinished, 1))
+	server.events.publish(notificationEvent(&commands.NodeStatePositionChangedNtf{NodeID: 1}))
+	server.events.publish(sceneEvent(EventRunStatus, 0))
+}
+
+type sseStream struct {
+	resp   *http.Response
+	reader *bufio.Reader
+}
+
+func openSSE(t *testing.T, httpServer *httptest.Server, query string, lastEventID string) *sseStream {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/events"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got status %d, content type '%s'", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	return &sseStream{resp: resp, reader: bufio.NewReader(resp.Body)}
+}
+
+// Read the next event of the stream
+func (stream *sseStream) next(t *testing.T) *Event {
+	t.Helper()
+
+	result := make(chan *Event, 1)
+
+	go func() {
+		var id uint64
+		var typ string
+		var event *Event
+
+		for {
+			line, err := stream.reader.ReadString('\n')
+			if err != nil {
+				result <- nil
+				return
+			}
+
+			line = strings.TrimSuffix(line, "\n")
+
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id, _ = strconv.ParseUint(strings.TrimPrefix(line, "id: "), 10, 64)
+			case strings.HasPrefix(line, "event: "):
+				typ = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event = &Event{}
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), event)
+			case line == "" && event != nil:
+				if event.ID != id || event.Type != typ {
+					event = nil
+				}
+
+				result <- event
+				return
+			}
+		}
+	}()
+
+	select {
+	case event := <-result:
+		if event == nil {
+			t.Fatal("bad or missing event")
+		}
+
+		return event
+
+	case <-time.After(testTimeout):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func checkIDs(t *testing.T, events []*Event, expected ...uint64) {
+	t.Helper()
+
+	ids := make([]uint64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+
+	if len(ids) != len(expected) {
+		t.Fatalf("got events %v, expected %v", ids, expected)
+	}
+
+	for index := range ids {
+		if ids[index] != expected[index] {
+			t.Fatalf("got events %v, expected %v", ids, expected)
+		}
+	}
+}
+
+func TestSSENodeFilter(t *testing.T) {
+	server, httpServer := startEventServer(t, Config{})
+
+	stream := openSSE(t, httpServer, "?node=1", "")
+	publishTestEvents(server)
+
+	events := []*Event{stream.next(t), stream.next(t), stream.next(t)}
+	checkIDs(t, events, 2, 3, 4)
+
+	if events[2].Type != transport.GW_NODE_STATE_POSITION_CHANGED_NTF.String() {
+		t.Errorf("got type %s", events[2].Type)
+	}
+}
+
+func TestSSETypeFilter(t *testing.T) {
+	server, httpServer := startEventServer(t, Config{})
+
+	// Case insensitive, repeated or comma separated
+	stream := openSSE(t, httpServer, "?type=runstatus&type=RunFinished,"+strings.ToLower(transport.GW_NODE_STATE_POSITION_CHANGED_NTF.String())+"&node=0,1", "")
+	publishTestEvents(server)
+
+	// The scene event has no node
+	checkIDs(t, []*Event{stream.next(t), stream.next(t), stream.next(t), stream.next(t)}, 1, 2, 3, 4)
+
+	stream = openSSE(t, httpServer, "?type=RunFinished", "")
+	publishTestEvents(server)
+
+	checkIDs(t, []*Event{stream.next(t)}, 8)
+}
+
+func TestSSEBadQuery(t *testing.T) {
+	_, httpServer := startEventServer(t, Config{})
+
+	for _, query := range []string{"?node=abc", "?type=NotAnEvent", "?lastEventId=-1"} {
+		resp, err := http.Get(httpServer.URL + "/events" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: got status %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestSSEResume(t *testing.T) {
+	server, httpServer := startEventServer(t, Config{})
+
+	publishTestEvents(server)
+
+	// The history is sent first, then the live events
+	stream := openSSE(t, httpServer, "", "3")
+	server.events.publish(nodeEvent(EventRunStatus, 2))
+
+	checkIDs(t, []*Event{stream.next(t), stream.next(t), stream.next(t)}, 4, 5, 6)
+
+	// Query parameter, with a filter applied to the history
+	stream = openSSE(t, httpServer, "?lastEventId=0&node=1", "")
+	checkIDs(t, []*Event{stream.next(t), stream.next(t), stream.next(t)}, 2, 3, 4)
+
+	// Without last event id, only the live events
+	stream = openSSE(t, httpServer, "", "")
+	server.events.publish(nodeEvent(EventRunStatus, 2))
+	checkIDs(t, []*Event{stream.next(t)}, 7)
+}
+
+func TestHistoryEviction(t *testing.T) {
+	server, httpServer := startEventServer(t, Config{EventHistory: 3})
+
+	publishTestEvents(server)
+
+	if len(server.events.history) != 3 {
+		t.Errorf("got %d events in history", len(server.events.history))
+	}
+
+	// Events 1 and 2 are lost
+	stream := openSSE(t, httpServer, "", "0")
+	checkIDs(t, []*Event{stream.next(t), stream.next(t), stream.next(t)}, 3, 4, 5)
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	hub := newEventHub(DefaultEventHistory)
+	sub, _ := hub.subscribe(&eventFilter{}, nil)
+
+	for range subscriberQueue + 1 {
+		hub.publish(nodeEvent(EventRunStatus, 0))
+	}
+
+	count := 0
+	for range sub.events {
+		count++
+	}
+
+	if count != subscriberQueue {
+		t.Errorf("got %d events before the stream was closed, expected %d", count, subscriberQueue)
+	}
+
+	// Can resume from history
+	_, backlog := hub.subscribe(&eventFilter{}, new(uint64))
+	if len(backlog) != subscriberQueue+1 {
+		t.Errorf("got %d events in backlog", len(backlog))
+	}
+}
+
+func dialWebSocket(t *testing.T, httpServer *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/events/ws" + query
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+
+		t.Fatalf("dial: %s (status %d)", err, status)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func readWebSocket(t *testing.T, conn *websocket.Conn, count int) []*Event {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+
+	events := make([]*Event, 0, count)
+	for range count {
+		event := &Event{}
+		if err := conn.ReadJSON(event); err != nil {
+			t.Fatal(err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestWebSocketFiltersAndResume(t *testing.T) {
+	server, httpServer := startEventServer(t, Config{})
+
+	publishTestEvents(server)
+
+	conn := dialWebSocket(t, httpServer, "?lastEventId=1&node=1&type=RunStatus,RunFinished")
+	server.events.publish(nodeEvent(EventRunStatus, 0))
+	server.events.publish(nodeEvent(EventRunStatus, 1))
+
+	checkIDs(t, readWebSocket(t, conn, 3), 2, 3, 7)
+}
+
+func TestWebSocketClose(t *testing.T) {
+	server, httpServer := startEventServer(t, Config{})
+
+	conn := dialWebSocket(t, httpServer, "")
+	server.events.publish(nodeEvent(EventRunStatus, 0))
+	readWebSocket(t, conn, 1)
+
+	server.Close()
+
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected a going away close, got %v", err)
+	}
+}
+
+func TestWebSocketBadQuery(t *testing.T) {
+	_, httpServer := startEventServer(t, Config{})
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/events/ws?node=abc"
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad request, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package restapi
+
+import (
+	"encoding"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const openAPIVersion = "3.0.3"
+
+var pathParamRegexp = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// Build the OpenAPI document describing the routes served by Server
+func OpenAPI() map[string]interface{} {
+	schemas := newSchemaBuilder()
+	paths := make(map[string]interface{})
+
+	for _, route := range routes {
+		item, _ := paths[route.path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[route.path] = item
+		}
+
+		item[strings.ToLower(route.method)] = operation(route, schemas)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "KLF 200",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.schemas,
+		},
+	}
+}
+
+func operation(route *route, schemas *schemaBuilder) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": route.operationID,
+		"summary":     route.summary,
+	}
+
+	params := make([]interface{}, 0)
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(route.path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":        match[1],
+			"in":          "path",
+			"required":    true,
+			"description": route.params[match[1]],
+			"schema":      map[string]interface{}{"type": "integer"},
+		})
+	}
+
+	queryNames := make([]string, 0, len(route.query))
+	for name := range route.query {
+		queryNames = append(queryNames, name)
+	}
+
+	slices.Sort(queryNames)
+
+	for _, name := range queryNames {
+		params = append(params, map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"description": route.query[name],
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.body != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(schemas.schema(route.body)),
+		}
+	}
+
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content":     jsonContent(schemas.schema(reflect.TypeFor[Error]())),
+		},
+	}
+
+	if route.serve != nil {
+		responses[strconv.Itoa(route.status)] = map[string]interface{}{
+			"description": http.StatusText(route.status) + ", then stream of events",
+			"content": map[string]interface{}{
+				route.contentType: map[string]interface{}{
+					"schema": schemas.schema(route.response),
+				},
+			},
+		}
+	} else if route.response == nil {
+		responses["204"] = map[string]interface{}{
+			"description": http.StatusText(http.StatusNoContent),
+		}
+	} else {
+		responses["200"] = map[string]interface{}{
+			"description": http.StatusText(http.StatusOK),
+			"content":     jsonContent(schemas.schema(route.response)),
+		}
+	}
+
+	op["responses"] = responses
+	return op
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": schema,
+		},
+	}
+}
+
+// Build JSON schemas from Go types, following the encoding/json rules.
+// Named structs are registered as components and referenced.
+type schemaBuilder struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: make(map[string]interface{}),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.TypeFor[time.Duration]():
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Duration in nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+
+		return b.ref(t)
+
+	default:
+		// Interfaces: any value
+		return map[string]interface{}{}
+	}
+}
+
+func (b *schemaBuilder) ref(t reflect.Type) map[string]interface{} {
+	name, ok := b.names[t]
+	if !ok {
+		name = t.Name()
+		if _, used := b.schemas[name]; used {
+			// Same name in another package
+			pkg := path.Base(t.PkgPath())
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+
+		b.names[t] = name
+
+		// Registered before being built, for recursive types
+		b.schemas[name] = nil
+		b.schemas[name] = b.structSchema(t)
+	}
+
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	b.addFields(t, properties)
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]interface{}) {
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(embedded, properties)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schema(field.Type)
+	}
+}
@@ -0,0 +1,299 @@
+package restapi
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+type route struct {
+	method      string
+	path        string
+	operationID string
+	summary     string
+
+	// Description of each path and query parameter
+	params map[string]string
+	query  map[string]string
+
+	// Runs are awaited until they are finished, with a longer timeout
+	run bool
+
+	// Types of the request body and of the response, nil if none
+	body     reflect.Type
+	response reflect.Type
+
+	newBody func() interface{}
+	handle  func(ctx context.Context, s *Server, r *http.Request, body interface{}) (interface{}, error)
+
+	// Streams handle the request themselves. The response type is the one of each event
+	serve       func(s *Server, w http.ResponseWriter, r *http.Request)
+	status      int
+	contentType string
+}
+
+// Marks the absence of request body or of response
+type none struct{}
+
+func typeOf[T any]() reflect.Type {
+	t := reflect.TypeFor[T]()
+	if t == reflect.TypeFor[none]() {
+		return nil
+	}
+
+	return t
+}
+
+func get[Resp any](path string, operationID string, summary string, handle func(ctx context.Context, s *Server, r *http.Request) (Resp, error)) *route {
+	return &route{
+		method:      http.MethodGet,
+		path:        path,
+		operationID: operationID,
+		summary:     summary,
+		response:    typeOf[Resp](),
+		handle: func(ctx context.Context, s *Server, r *http.Request, _ interface{}) (interface{}, error) {
+			return handle(ctx, s, r)
+		},
+	}
+}
+
+func post[Body any, Resp any](path string, operationID string, summary string, handle func(ctx context.Context, s *Server, r *http.Request, body *Body) (Resp, error)) *route {
+	return &route{
+		method:      http.MethodPost,
+		path:        path,
+		operationID: operationID,
+		summary:     summary,
+		body:        typeOf[Body](),
+		response:    typeOf[Resp](),
+		newBody:     func() interface{} { return new(Body) },
+		handle: func(ctx context.Context, s *Server, r *http.Request, body interface{}) (interface{}, error) {
+			typed, _ := body.(*Body)
+			return handle(ctx, s, r, typed)
+		},
+	}
+}
+
+// Route which streams events of type Item
+func stream[Item any](path string, operationID string, summary string, status int, contentType string, serve func(s *Server, w http.ResponseWriter, r *http.Request)) *route {
+	return &route{
+		method:      http.MethodGet,
+		path:        path,
+		operationID: operationID,
+		summary:     summary,
+		response:    typeOf[Item](),
+		serve:       serve,
+		status:      status,
+		contentType: contentType,
+	}
+}
+
+func (r *route) withParam(name string, description string) *route {
+	if r.params == nil {
+		r.params = make(map[string]string)
+	}
+
+	r.params[name] = description
+	return r
+}
+
+func (r *route) withQuery(name string, description string) *route {
+	if r.query == nil {
+		r.query = make(map[string]string)
+	}
+
+	r.query[name] = description
+	return r
+}
+
+// Query parameters of the event streams
+func (r *route) withEventQuery() *route {
+	return r.
+		withQuery("node", "Only events of these nodes (comma separated node indexes)").
+		withQuery("type", "Only events of these types (comma separated notification names or run event types)").
+		withQuery("lastEventId", "Resume after this event id (the Last-Event-ID header is also supported)")
+}
+
+func (r *route) awaitRun() *route {
+	r.run = true
+	return r
+}
+
+// Body of POST /nodes/{id}/position
+type PositionRequest struct {
+	// Absolute position in percent (0 = open, 100 = closed)
+	Position int `json:"position"`
+}
+
+var routes = []*route{
+	get("/nodes", "listNodes", "List the nodes", listNodes),
+	get("/nodes/{id}", "getNode", "Get a node", getNode).withParam("id", "Node index"),
+	get("/nodes/{id}/status", "getNodeStatus", "Request the status of a node", getNodeStatus).withParam("id", "Node index"),
+	post("/nodes/{id}/position", "setNodePosition", "Move a node and wait for the end of the run", setNodePosition).withParam("id", "Node index").awaitRun(),
+	post("/nodes/{id}/stop", "stopNode", "Stop a node and wait for the end of the run", stopNode).withParam("id", "Node index").awaitRun(),
+	get("/groups", "listGroups", "List the groups", listGroups),
+	get("/systemtable", "getSystemTable", "Get the system table (actuators known by the gateway)", getSystemTable),
+	get("/scenes", "listScenes", "List the scenes", listScenes),
+	post("/scenes/{id}/activate", "activateScene", "Activate a scene and wait for the end of the run", activateScene).withParam("id", "Scene id").awaitRun(),
+	post("/scenes/{id}/stop", "stopScene", "Stop a scene", stopScene).withParam("id", "Scene id"),
+	get("/device/version", "getVersion", "Get the firmware and hardware versions of the gateway", getVersion),
+	get("/device/protocol-version", "getProtocolVersion", "Get the protocol version of the gateway", getProtocolVersion),
+	get("/device/state", "getState", "Get the state of the gateway", getState),
+	get("/device/time", "getTime", "Get the time of the gateway", getTime),
+	get("/device/network", "getNetwork", "Get the network setup of the gateway", getNetwork),
+	stream[Event]("/events", "streamEvents", "Stream the live events as Server-Sent Events", http.StatusOK, "text/event-stream", serveEvents).withEventQuery(),
+	stream[Event]("/events/ws", "streamEventsWebSocket", "Stream the live events over a WebSocket, one JSON message per event", http.StatusSwitchingProtocols, "application/json", serveEventsWebSocket).withEventQuery(),
+}
+
+func listNodes(ctx context.Context, s *Server, r *http.Request) ([]*commands.GetAllNodesInformationNtf, error) {
+	return s.client.Info().GetAllNodesInformation(ctx)
+}
+
+func getNode(ctx context.Context, s *Server, r *http.Request) (*commands.GetAllNodesInformationNtf, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := s.client.Info().GetAllNodesInformation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		if node.NodeID == id {
+			return node, nil
+		}
+	}
+
+	return nil, notFound("unknown node %d", id)
+}
+
+func getNodeStatus(ctx context.Context, s *Server, r *http.Request) (*klf200.StatusData, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := s.client.Commands().Status(ctx, []int{id})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		if status.NodeIndex == id {
+			return status, nil
+		}
+	}
+
+	return nil, notFound("no status for node %d", id)
+}
+
+func setNodePosition(ctx context.Context, s *Server, r *http.Request, body *PositionRequest) ([]*klf200.RunStatus, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Position < 0 || body.Position > 100 {
+		return nil, badRequest("bad position %d (expected 0 to 100)", body.Position)
+	}
+
+	session, err := s.client.Commands().ChangePosition(ctx, id, commands.NewMPValueAbsolute(body.Position))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.waitRun(session, &id, nil)
+}
+
+func stopNode(ctx context.Context, s *Server, r *http.Request, _ *none) ([]*klf200.RunStatus, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.client.Commands().ChangePosition(ctx, id, commands.NewMPValueCurrent())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.waitRun(session, &id, nil)
+}
+
+func listGroups(ctx context.Context, s *Server, r *http.Request) ([]*commands.GetAllGroupsInformationNtf, error) {
+	return s.client.Info().GetAllGroupsInformation(ctx, nil)
+}
+
+func getSystemTable(ctx context.Context, s *Server, r *http.Request) ([]commands.SystemtableObject, error) {
+	return s.client.Config().GetSystemTable(ctx)
+}
+
+func listScenes(ctx context.Context, s *Server, r *http.Request) ([]commands.SceneListObject, error) {
+	return s.client.Scenes().GetSceneList(ctx)
+}
+
+func activateScene(ctx context.Context, s *Server, r *http.Request, _ *none) ([]*klf200.RunStatus, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.client.Scenes().Activate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.waitRun(session, nil, &id)
+}
+
+func stopScene(ctx context.Context, s *Server, r *http.Request, _ *none) (none, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return none{}, err
+	}
+
+	return none{}, s.client.Scenes().StopContext(ctx, id)
+}
+
+func getVersion(ctx context.Context, s *Server, r *http.Request) (*commands.GetVersionCfm, error) {
+	return s.client.Device().GetVersionContext(ctx)
+}
+
+func getProtocolVersion(ctx context.Context, s *Server, r *http.Request) (*commands.GetProtocolVersionCfm, error) {
+	return s.client.Device().GetProtocolVersionContext(ctx)
+}
+
+func getState(ctx context.Context, s *Server, r *http.Request) (*commands.GetStateCfm, error) {
+	return s.client.Device().GetStateContext(ctx)
+}
+
+func getTime(ctx context.Context, s *Server, r *http.Request) (*commands.GetLocalTimeCfm, error) {
+	return s.client.Device().GetLocalTimeContext(ctx)
+}
+
+func getNetwork(ctx context.Context, s *Server, r *http.Request) (*commands.GetNetworkSetupCfm, error) {
+	return s.client.Device().GetNetworkSetupContext(ctx)
+}
+
+// Wait for the end of the run, and return the status reports of the nodes.
+// The run events are published to the event streams meanwhile.
+func (s *Server) waitRun(session *klf200.Session, node *int, scene *int) ([]*klf200.RunStatus, error) {
+	statuses := make([]*klf200.RunStatus, 0)
+	defer s.publishRun(node, scene, nil)
+
+	for event := range session.Events() {
+		s.publishRun(node, scene, event)
+
+		switch event := event.(type) {
+		case *klf200.RunStatus:
+			statuses = append(statuses, event)
+		case *klf200.RunError:
+			return nil, event.Err
+		}
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,250 @@
+// Package restapi exposes a KLF 200 gateway as a REST API, described by an OpenAPI document.
+//
+// All the resources are defined in the routes table, from which both the HTTP handlers and
+// the OpenAPI document (served on GET /openapi.json) are built. Resources are returned as JSON,
+// using the types of the commands package.
+//
+// The gateway notifications and the events of the runs started from the API are streamed live
+// on GET /events (Server-Sent Events) and GET /events/ws (WebSocket), so that clients do not need to poll.
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+const DefaultTimeout = time.Second * 30
+const DefaultRunTimeout = time.Minute * 5
+
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type Config struct {
+	// Timeout of the requests to the gateway. Defaults to DefaultTimeout
+	Timeout time.Duration
+
+	// Timeout of the runs (position changes, scene activations), which are awaited until they are finished.
+	// Defaults to DefaultRunTimeout
+	RunTimeout time.Duration
+
+	// Number of events kept to resume the event streams. Defaults to DefaultEventHistory
+	EventHistory int
+
+	// Check the origin of the WebSocket requests. Defaults to same origin only
+	CheckOrigin func(r *http.Request) bool
+
+	// Optional
+	Log Logger
+}
+
+type Server struct {
+	client     *klf200.Client
+	timeout    time.Duration
+	runTimeout time.Duration
+	log        Logger
+	mux        *http.ServeMux
+	openAPI    []byte
+	events     *eventHub
+	upgrader   websocket.Upgrader
+
+	ctx        context.Context
+	close      context.CancelFunc
+	workerSync sync.WaitGroup
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// The server listens to the client notifications until it is closed
+func NewServer(client *klf200.Client, config Config) *Server {
+	ctx, close := context.WithCancel(context.Background())
+
+	s := &Server{
+		client:     client,
+		timeout:    config.Timeout,
+		runTimeout: config.RunTimeout,
+		log:        config.Log,
+		mux:        http.NewServeMux(),
+		upgrader:   websocket.Upgrader{CheckOrigin: config.CheckOrigin},
+		ctx:        ctx,
+		close:      close,
+	}
+
+	if s.timeout == 0 {
+		s.timeout = DefaultTimeout
+	}
+
+	if s.runTimeout == 0 {
+		s.runTimeout = DefaultRunTimeout
+	}
+
+	if config.EventHistory == 0 {
+		config.EventHistory = DefaultEventHistory
+	}
+
+	s.events = newEventHub(config.EventHistory)
+
+	for _, route := range routes {
+		s.mux.HandleFunc(route.method+" "+route.path, s.handler(route))
+	}
+
+	doc, err := json.MarshalIndent(OpenAPI(), "", "  ")
+	if err != nil {
+		panic(fmt.Errorf("could not encode the OpenAPI document: %w", err))
+	}
+
+	s.openAPI = doc
+	s.mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.openAPI)
+	})
+
+	// The event history is best effort, it must not delay the client
+	notifications, unsubscribe := klf200.Subscribe[commands.Notify](client, nil, klf200.WithOverflowPolicy(klf200.OverflowDropOldest))
+
+	s.workerSync.Add(1)
+	go s.worker(notifications, unsubscribe)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Stop listening to the notifications, and end the event streams
+func (s *Server) Close() {
+	s.close()
+	s.workerSync.Wait()
+}
+
+// Error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Error with a specific HTTP status
+type httpError struct {
+	status int
+	err    error
+}
+
+func (err *httpError) Error() string {
+	return err.err.Error()
+}
+
+func (err *httpError) Unwrap() error {
+	return err.err
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{http.StatusBadRequest, fmt.Errorf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &httpError{http.StatusNotFound, fmt.Errorf(format, args...)}
+}
+
+func (s *Server) handler(route *route) http.HandlerFunc {
+	if route.serve != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			route.serve(s, w, r)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout := s.timeout
+		if route.run {
+			timeout = s.runTimeout
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		var body interface{}
+		if route.body != nil {
+			body = route.newBody()
+			if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+				s.writeError(w, r, badRequest("bad request body: %s", err))
+				return
+			}
+		}
+
+		result, err := route.handle(ctx, s, r, body)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+
+		if route.response == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		s.writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		s.errorf("Could not write response: %s", err)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	s.debugf("%s %s: %d %s", r.Method, r.URL.Path, status, err)
+	s.writeJSON(w, status, &Error{Error: err.Error()})
+}
+
+func errorStatus(err error) int {
+	var httpErr *httpError
+
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr.status
+	case errors.Is(err, klf200.ErrNotConnected), errors.Is(err, klf200.ErrConnectionClosed), errors.Is(err, klf200.ErrDisconnected):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, klf200.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		// Including the errors reported by the gateway
+		return http.StatusBadGateway
+	}
+}
+
+// Integer path parameter
+func pathInt(r *http.Request, name string) (int, error) {
+	value, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return 0, badRequest("bad %s '%s'", name, r.PathValue(name))
+	}
+
+	return value, nil
+}
+
+func (s *Server) debugf(format string, args ...interface{}) {
+	if s.log != nil {
+		s.log.Debugf(format, args...)
+	}
+}
+
+func (s *Server) errorf(format string, args ...interface{}) {
+	if s.log != nil {
+		s.log.Errorf(format, args...)
+	}
+}
@@ -0,0 +1,355 @@
+package restapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	klf200 "github.com/mylife-home/klf200-go"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/simulator"
+)
+
+const testTimeout = time.Second * 5
+
+type testSetup struct {
+	gw     *simulator.Gateway
+	client *klf200.Client
+	server *Server
+	http   *httptest.Server
+}
+
+func testConfig() simulator.Config {
+	return simulator.Config{
+		Latency:        time.Millisecond * 10,
+		ReportInterval: time.Millisecond * 50,
+		Nodes: []simulator.NodeConfig{
+			{Index: 0, Name: "Kitchen", NodeType: commands.NodeTypeWindowOpener, TravelTime: time.Millisecond * 200},
+			{Index: 1, Name: "Bedroom", NodeType: commands.NodeTypeRollerShutter, TravelTime: time.Second * 30},
+		},
+		Groups: []simulator.GroupConfig{
+			{ID: 0, Name: "All", Type: commands.GroupTypeRoom, NodeIndexes: []int{0, 1}},
+		},
+		Scenes: []simulator.SceneConfig{
+			{ID: 0, Name: "Morning", Positions: map[int]commands.MPValue{0: commands.NewMPValueAbsolute(10)}},
+		},
+	}
+}
+
+// Start a simulator, a connected client and the API server
+func start(t *testing.T, config Config) *testSetup {
+	t.Helper()
+
+	gw, err := simulator.Start(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(gw.Close)
+
+	setup := &testSetup{gw: gw}
+	setup.client = klf200.NewClient(gw.Address(), gw.Password())
+
+	opened := make(chan struct{}, 1)
+	setup.client.RegisterStatusChange(func(status klf200.ConnectionStatus) {
+		if status == klf200.ConnectionOpen {
+			select {
+			case opened <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	setup.server = NewServer(setup.client, config)
+	setup.http = httptest.NewServer(setup.server)
+	setup.client.Start()
+
+	t.Cleanup(func() {
+		setup.http.Close()
+		setup.server.Close()
+		setup.client.Close()
+	})
+
+	select {
+	case <-opened:
+	case <-time.After(testTimeout):
+		t.Fatal("connection not opened")
+	}
+
+	return setup
+}
+
+// Send a request, check the response status and decode its body into result if not nil
+func (setup *testSetup) request(t *testing.T, method string, path string, body string, status int, result interface{}) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, setup.http.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	buff := &bytes.Buffer{}
+	buff.ReadFrom(resp.Body)
+
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s: got status %d, expected %d (%s)", method, path, resp.StatusCode, status, buff)
+	}
+
+	if status != http.StatusNoContent && resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("%s %s: got content type '%s'", method, path, resp.Header.Get("Content-Type"))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(buff.Bytes(), result); err != nil {
+			t.Fatalf("%s %s: could not decode %s: %s", method, path, buff, err)
+		}
+	}
+}
+
+func TestGetRoutes(t *testing.T) {
+	setup := start(t, Config{})
+
+	var nodes []map[string]interface{}
+	setup.request(t, http.MethodGet, "/nodes", "", http.StatusOK, &nodes)
+	if len(nodes) != 2 {
+		t.Errorf("got %d nodes", len(nodes))
+	}
+
+	var node map[string]interface{}
+	setup.request(t, http.MethodGet, "/nodes/1", "", http.StatusOK, &node)
+	if node["Name"] != "Bedroom" {
+		t.Errorf("got node %v", node)
+	}
+
+	var status map[string]interface{}
+	setup.request(t, http.MethodGet, "/nodes/0/status", "", http.StatusOK, &status)
+
+	var groups []map[string]interface{}
+	setup.request(t, http.MethodGet, "/groups", "", http.StatusOK, &groups)
+	if len(groups) != 1 || groups[0]["Name"] != "All" {
+		t.Errorf("got groups %v", groups)
+	}
+
+	var table []map[string]interface{}
+	setup.request(t, http.MethodGet, "/systemtable", "", http.StatusOK, &table)
+	if len(table) != 2 {
+		t.Errorf("got system table %v", table)
+	}
+
+	var scenes []map[string]interface{}
+	setup.request(t, http.MethodGet, "/scenes", "", http.StatusOK, &scenes)
+	if len(scenes) != 1 || scenes[0]["Name"] != "Morning" {
+		t.Errorf("got scenes %v", scenes)
+	}
+
+	for _, path := range []string{"/device/version", "/device/protocol-version", "/device/state", "/device/time", "/device/network"} {
+		var result map[string]interface{}
+		setup.request(t, http.MethodGet, path, "", http.StatusOK, &result)
+	}
+}
+
+func TestPostRoutes(t *testing.T) {
+	setup := start(t, Config{})
+
+	var statuses []*klf200.RunStatus
+	setup.request(t, http.MethodPost, "/nodes/0/position", `{"position": 40}`, http.StatusOK, &statuses)
+	if len(statuses) == 0 || statuses[len(statuses)-1].RunStatus != commands.CommandRunStatusCompleted {
+		t.Errorf("got run statuses %+v", statuses)
+	}
+
+	if position, _ := setup.gw.NodePosition(0); position != commands.NewMPValueAbsolute(40) {
+		t.Errorf("node at %s after the run", position)
+	}
+
+	setup.request(t, http.MethodPost, "/nodes/0/stop", "", http.StatusOK, &statuses)
+
+	setup.request(t, http.MethodPost, "/scenes/0/activate", "", http.StatusOK, &statuses)
+	if position, _ := setup.gw.NodePosition(0); position != commands.NewMPValueAbsolute(10) {
+		t.Errorf("node at %s after the scene", position)
+	}
+
+	setup.request(t, http.MethodPost, "/scenes/0/stop", "", http.StatusNoContent, nil)
+}
+
+func TestRequestErrors(t *testing.T) {
+	setup := start(t, Config{})
+
+	var apiErr Error
+
+	setup.request(t, http.MethodGet, "/nodes/5", "", http.StatusNotFound, &apiErr)
+	if apiErr.Error != "unknown node 5" {
+		t.Errorf("got error '%s'", apiErr.Error)
+	}
+
+	setup.request(t, http.MethodGet, "/nodes/abc", "", http.StatusBadRequest, &apiErr)
+	setup.request(t, http.MethodPost, "/nodes/0/position", `{"position": `, http.StatusBadRequest, &apiErr)
+	setup.request(t, http.MethodPost, "/nodes/0/position", `{"position": 101}`, http.StatusBadRequest, &apiErr)
+	setup.request(t, http.MethodPost, "/nodes/0/position", `{"position": -1}`, http.StatusBadRequest, &apiErr)
+}
+
+func TestNotConnected(t *testing.T) {
+	client := klf200.NewClient("127.0.0.1:1", "")
+	server := NewServer(client, Config{})
+	defer server.Close()
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/device/version", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d (%s)", recorder.Code, recorder.Body)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	for _, test := range []struct {
+		err    error
+		status int
+	}{
+		{badRequest("bad"), http.StatusBadRequest},
+		{notFound("missing"), http.StatusNotFound},
+		{fmt.Errorf("wrapped: %w", notFound("missing")), http.StatusNotFound},
+		{klf200.ErrNotConnected, http.StatusServiceUnavailable},
+		{klf200.ErrConnectionClosed, http.StatusServiceUnavailable},
+		{klf200.ErrDisconnected, http.StatusServiceUnavailable},
+		{fmt.Errorf("request: %w", klf200.ErrDisconnected), http.StatusServiceUnavailable},
+		{klf200.ErrTimeout, http.StatusGatewayTimeout},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{fmt.Errorf("gateway error"), http.StatusBadGateway},
+	} {
+		if got := errorStatus(test.err); got != test.status {
+			t.Errorf("%s: got %d, expected %d", test.err, got, test.status)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	setup := start(t, Config{})
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+
+	setup.request(t, http.MethodGet, "/openapi.json", "", http.StatusOK, &doc)
+
+	if doc.OpenAPI != openAPIVersion {
+		t.Errorf("got version '%s'", doc.OpenAPI)
+	}
+
+	operations := 0
+	for _, item := range doc.Paths {
+		operations += len(item)
+	}
+
+	if operations != len(routes) {
+		t.Errorf("got %d operations, expected %d", operations, len(routes))
+	}
+
+	for _, route := range routes {
+		op, ok := doc.Paths[route.path][strings.ToLower(route.method)]
+		if !ok {
+			t.Errorf("%s %s is not documented", route.method, route.path)
+			continue
+		}
+
+		if op["operationId"] != route.operationID {
+			t.Errorf("%s %s: got operation id %v", route.method, route.path, op["operationId"])
+		}
+	}
+
+	// The document of the server is the one of OpenAPI()
+	expected, _ := json.MarshalIndent(OpenAPI(), "", "  ")
+	if !bytes.Equal(expected, setup.server.openAPI) {
+		t.Error("served document differs from OpenAPI()")
+	}
+}
+
+// Collect the run events published by the server, until the end of the run
+func collectRun(t *testing.T, sub *subscriber) []*Event {
+	t.Helper()
+
+	events := make([]*Event, 0)
+
+	for {
+		select {
+		case event := <-sub.events:
+			// Run events only, not the gateway notifications
+			if strings.HasPrefix(event.Type, "Run") {
+				events = append(events, event)
+			}
+
+			if event.Type == EventRunFinished {
+				return events
+			}
+
+		case <-time.After(testTimeout):
+			t.Fatal("run not finished")
+		}
+	}
+}
+
+func TestWaitRun(t *testing.T) {
+	setup := start(t, Config{})
+
+	sub, _ := setup.server.events.subscribe(&eventFilter{}, nil)
+	defer setup.server.events.unsubscribe(sub)
+
+	setup.request(t, http.MethodPost, "/nodes/0/position", `{"position": 70}`, http.StatusOK, nil)
+
+	events := collectRun(t, sub)
+
+	types := make(map[string]int)
+	for _, event := range events {
+		types[event.Type]++
+
+		if event.Node == nil || *event.Node != 0 || event.Scene != nil {
+			t.Errorf("%s event on node %v, scene %v", event.Type, event.Node, event.Scene)
+		}
+	}
+
+	if types[EventRunStatus] == 0 || types[EventRunRemainingTime] == 0 || types[EventRunFinished] != 1 || types[EventRunError] != 0 {
+		t.Errorf("got run events %v", types)
+	}
+
+	setup.request(t, http.MethodPost, "/scenes/0/activate", "", http.StatusOK, nil)
+
+	for _, event := range collectRun(t, sub) {
+		if event.Scene == nil || *event.Scene != 0 || event.Node != nil {
+			t.Errorf("%s event on node %v, scene %v", event.Type, event.Node, event.Scene)
+		}
+	}
+}
+
+func TestWaitRunTimeout(t *testing.T) {
+	setup := start(t, Config{RunTimeout: time.Millisecond * 300})
+
+	sub, _ := setup.server.events.subscribe(&eventFilter{}, nil)
+	defer setup.server.events.unsubscribe(sub)
+
+	// The node takes 30s to travel
+	var apiErr Error
+	setup.request(t, http.MethodPost, "/nodes/1/position", `{"position": 100}`, http.StatusGatewayTimeout, &apiErr)
+
+	events := collectRun(t, sub)
+	if len(events) < 2 || events[len(events)-2].Type != EventRunError {
+		t.Fatalf("got events %+v", events)
+	}
+
+	data, _ := json.Marshal(events[len(events)-2].Data)
+	if !strings.Contains(string(data), apiErr.Error) {
+		t.Errorf("got error event %s, expected '%s'", data, apiErr.Error)
+	}
+}
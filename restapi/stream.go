@@ -0,0 +1,165 @@
+package restapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Interval of the keepalives (SSE comments, WebSocket pings), to detect the dead connections and keep the proxies happy
+const keepaliveInterval = time.Second * 30
+
+const writeTimeout = time.Second * 10
+
+// Subscribe according to the request, and return the events to send first
+func (s *Server) subscribe(r *http.Request) (*subscriber, []*Event, error) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lastID, err := parseLastEventID(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sub, backlog := s.events.subscribe(filter, lastID)
+	return sub, backlog, nil
+}
+
+func serveEvents(s *Server, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, r, &httpError{http.StatusInternalServerError, fmt.Errorf("streaming not supported")})
+		return
+	}
+
+	sub, backlog, err := s.subscribe(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	defer s.events.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(event *Event) bool {
+		data, err := json.Marshal(event)
+		if err != nil {
+			s.errorf("Could not encode event %d: %s", event.ID, err)
+			return true
+		}
+
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		return err == nil
+	}
+
+	for _, event := range backlog {
+		if !write(event) {
+			return
+		}
+	}
+
+	flusher.Flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event, ok := <-sub.events:
+			if !ok {
+				s.debugf("Event stream of %s closed", r.RemoteAddr)
+				return
+			}
+
+			if !write(event) {
+				return
+			}
+
+		case <-keepalive.C:
+			if _, err := fmt.Fprintf(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+func serveEventsWebSocket(s *Server, w http.ResponseWriter, r *http.Request) {
+	sub, backlog, err := s.subscribe(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	defer s.events.unsubscribe(sub)
+
+	// The upgrader writes the HTTP error itself
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.debugf("Could not upgrade %s to WebSocket: %s", r.RemoteAddr, err)
+		return
+	}
+
+	defer conn.Close()
+
+	// Incoming messages are ignored, but must be read to process control frames and detect the close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(event *Event) bool {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		return conn.WriteJSON(event) == nil
+	}
+
+	for _, event := range backlog {
+		if !write(event) {
+			return
+		}
+	}
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+
+		case event, ok := <-sub.events:
+			if !ok {
+				s.debugf("Event stream of %s closed", r.RemoteAddr)
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(writeTimeout))
+				return
+			}
+
+			if !write(event) {
+				return
+			}
+
+		case <-keepalive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package klf200
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+type RetryClass int
+
+// The request is never retried (destructive or not idempotent request)
+const RetryNever RetryClass = 0
+
+// The request is retried only if the gateway refused it without processing it (gateway busy)
+const RetryIfNotProcessed RetryClass = 1
+
+// The request can safely be executed several times, it is retried on any transient failure
+const RetryAlways RetryClass = 2
+
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one. 0 or 1 disables retries
+	MaxAttempts int
+
+	// Delay before the first retry, doubled on each following retry
+	InitialBackoff time.Duration
+
+	// Maximum delay between two attempts
+	MaxBackoff time.Duration
+
+	// Random part of each delay, from 0 (fixed delay) to 1 (delay picked in [0, backoff])
+	Jitter float64
+
+	// Returns the retry class of the request. If nil, DefaultRetryClass is used
+	Classify func(req commands.Request) RetryClass
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Millisecond * 200,
+		MaxBackoff:     time.Second * 2,
+		Jitter:         0.5,
+		Classify:       DefaultRetryClass,
+	}
+}
+
+func NoRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 1,
+	}
+}
+
+// Read-only requests and absolute position commands are always retried.
+// Other commands which change the state of actuators or the clock are retried only if the gateway did not process them.
+// Destructive requests (Reboot, SetFactoryDefault, ...) are never retried.
+func DefaultRetryClass(req commands.Request) RetryClass {
+	switch req.Code() {
+	case transport.GW_GET_VERSION_REQ,
+		transport.GW_GET_PROTOCOL_VERSION_REQ,
+		transport.GW_GET_STATE_REQ,
+		transport.GW_GET_NETWORK_SETUP_REQ,
+		transport.GW_GET_LOCAL_TIME_REQ,
+		transport.GW_CS_GET_SYSTEMTABLE_DATA_REQ,
+		transport.GW_GET_ALL_NODES_INFORMATION_REQ,
+		transport.GW_GET_ALL_GROUPS_INFORMATION_REQ,
+		transport.GW_GET_SCENE_LIST_REQ,
+		transport.GW_STATUS_REQUEST_REQ:
+		return RetryAlways
+
+	case transport.GW_COMMAND_SEND_REQ:
+		if send, ok := req.(*commands.CommandSendReq); ok && isAbsoluteCommand(send) {
+			return RetryAlways
+		}
+
+		return RetryIfNotProcessed
+
+	// A timestamp resent after a timeout would be out of date, and the first one may already be applied
+	case transport.GW_MODE_SEND_REQ,
+		transport.GW_SET_UTC_REQ,
+		transport.GW_RTC_SET_TIME_ZONE_REQ:
+		return RetryIfNotProcessed
+
+	default:
+		return RetryNever
+	}
+}
+
+// Relative moves cannot be executed twice safely
+func isAbsoluteCommand(req *commands.CommandSendReq) bool {
+	for _, value := range req.FunctionalParameterValues {
+		if ok, _ := commands.MPValue(value).Relative(); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (policy *RetryPolicy) classify(req commands.Request) RetryClass {
+	if policy.Classify == nil {
+		return DefaultRetryClass(req)
+	}
+
+	return policy.Classify(req)
+}
+
+func (class RetryClass) shouldRetry(err error) bool {
+	switch class {
+	case RetryIfNotProcessed:
+		return isNotProcessedError(err)
+	case RetryAlways:
+		return isNotProcessedError(err) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrConnectionClosed)
+	default:
+		return false
+	}
+}
+
+// The gateway refused the request. ErrNotConnected is not retried: the connection is not back before the next attempt
+func isNotProcessedError(err error) bool {
+	return IsGatewayError(err, commands.ErrorBusy)
+}
+
+func (class RetryClass) String() string {
+	switch class {
+	case RetryNever:
+		return "RetryNever"
+	case RetryIfNotProcessed:
+		return "RetryIfNotProcessed"
+	case RetryAlways:
+		return "RetryAlways"
+	default:
+		return fmt.Sprintf("<%d>", class)
+	}
+}
+
+// Exponential backoff with jitter
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+	jitter  float64
+	attempt int
+}
+
+func newBackoff(initial time.Duration, max time.Duration, jitter float64) *backoff {
+	return &backoff{
+		initial: initial,
+		max:     max,
+		jitter:  jitter,
+	}
+}
+
+func (b *backoff) Next() time.Duration {
+	delay := b.initial
+	for index := 0; index < b.attempt && (b.max <= 0 || delay < b.max); index++ {
+		delay *= 2
+	}
+
+	if b.max > 0 && delay > b.max {
+		delay = b.max
+	}
+
+	b.attempt++
+
+	if b.jitter <= 0 || delay <= 0 {
+		return delay
+	}
+
+	jitter := min(b.jitter, 1)
+	random := time.Duration(rand.Int64N(int64(float64(delay)*jitter) + 1))
+	return delay - random
+}
+
+func (b *backoff) Reset() {
+	b.attempt = 0
+}
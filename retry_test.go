@@ -0,0 +1,231 @@
+package klf200
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/faultinject"
+	"github.com/mylife-home/klf200-go/simulator"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+func TestDefaultRetryClass(t *testing.T) {
+	expected := map[transport.Command]RetryClass{
+		transport.GW_REBOOT_REQ:                     RetryNever,
+		transport.GW_SET_FACTORY_DEFAULT_REQ:        RetryNever,
+		transport.GW_GET_VERSION_REQ:                RetryAlways,
+		transport.GW_GET_PROTOCOL_VERSION_REQ:       RetryAlways,
+		transport.GW_GET_STATE_REQ:                  RetryAlways,
+		transport.GW_LEAVE_LEARN_STATE_REQ:          RetryNever,
+		transport.GW_GET_NETWORK_SETUP_REQ:          RetryAlways,
+		transport.GW_SET_NETWORK_SETUP_REQ:          RetryNever,
+		transport.GW_CS_GET_SYSTEMTABLE_DATA_REQ:    RetryAlways,
+		transport.GW_GET_ALL_NODES_INFORMATION_REQ:  RetryAlways,
+		transport.GW_GET_ALL_GROUPS_INFORMATION_REQ: RetryAlways,
+		transport.GW_COMMAND_SEND_REQ:               RetryAlways,
+		transport.GW_STATUS_REQUEST_REQ:             RetryAlways,
+		transport.GW_MODE_SEND_REQ:                  RetryIfNotProcessed,
+		transport.GW_GET_SCENE_LIST_REQ:             RetryAlways,
+		transport.GW_ACTIVATE_SCENE_REQ:             RetryNever,
+		transport.GW_STOP_SCENE_REQ:                 RetryNever,
+		transport.GW_SET_UTC_REQ:                    RetryIfNotProcessed,
+		transport.GW_RTC_SET_TIME_ZONE_REQ:          RetryIfNotProcessed,
+		transport.GW_GET_LOCAL_TIME_REQ:             RetryAlways,
+		transport.GW_PASSWORD_ENTER_REQ:             RetryNever,
+		transport.GW_PASSWORD_CHANGE_REQ:            RetryNever,
+	}
+
+	for _, code := range commands.RequestCodes() {
+		t.Run(code.String(), func(t *testing.T) {
+			class, ok := expected[code]
+			if !ok {
+				t.Fatal("retry class not specified by the test")
+			}
+
+			// The command send is empty, without relative move
+			if got := DefaultRetryClass(commands.GetRequest(code)); got != class {
+				t.Errorf("got %s, expected %s", got, class)
+			}
+		})
+	}
+}
+
+func TestDefaultRetryClassCommandSend(t *testing.T) {
+	tests := []struct {
+		value commands.MPValue
+		class RetryClass
+	}{
+		{commands.NewMPValueAbsolute(50), RetryAlways},
+		{commands.NewMPValueTarget(), RetryAlways},
+		{commands.NewMPValueRelative(10), RetryIfNotProcessed},
+		{commands.NewMPValueRelative(-10), RetryIfNotProcessed},
+	}
+
+	for _, test := range tests {
+		req := commandSend(1)
+		req.FunctionalParameterValues[commands.FunctionalParameterMP] = int(test.value)
+
+		if class := DefaultRetryClass(req); class != test.class {
+			t.Errorf("MP value 0x%04X: got %s, expected %s", uint16(test.value), class, test.class)
+		}
+	}
+
+	// Unknown request type carrying the command send code
+	if class := DefaultRetryClass(&foreignCommandSend{}); class != RetryIfNotProcessed {
+		t.Errorf("foreign command send: got %s", class)
+	}
+}
+
+type foreignCommandSend struct {
+	commands.CommandSendReq
+}
+
+func TestShouldRetry(t *testing.T) {
+	errs := []error{
+		&GatewayError{ErrorNumber: commands.ErrorBusy},
+		fmt.Errorf("wrapped: %w", &GatewayError{ErrorNumber: commands.ErrorBusy}),
+		ErrTimeout,
+		ErrConnectionClosed,
+		&GatewayError{ErrorNumber: commands.ErrorBadIndex},
+		ErrNotConnected,
+		context.Canceled,
+		errors.New("other"),
+	}
+
+	expected := map[RetryClass][]bool{
+		RetryNever:          {false, false, false, false, false, false, false, false},
+		RetryIfNotProcessed: {true, true, false, false, false, false, false, false},
+		RetryAlways:         {true, true, true, true, false, false, false, false},
+	}
+
+	for class, results := range expected {
+		for index, err := range errs {
+			if got := class.shouldRetry(err); got != results[index] {
+				t.Errorf("%s on %q: got %t", class, err, got)
+			}
+		}
+	}
+}
+
+func TestBackoffGrowth(t *testing.T) {
+	delays := newBackoff(time.Millisecond*100, time.Second, 0)
+
+	expected := []time.Duration{
+		time.Millisecond * 100,
+		time.Millisecond * 200,
+		time.Millisecond * 400,
+		time.Millisecond * 800,
+		time.Second,
+		time.Second,
+	}
+
+	for index, delay := range expected {
+		if got := delays.Next(); got != delay {
+			t.Errorf("attempt %d: got %s, expected %s", index, got, delay)
+		}
+	}
+
+	delays.Reset()
+
+	if got := delays.Next(); got != time.Millisecond*100 {
+		t.Errorf("after reset: got %s", got)
+	}
+}
+
+func TestBackoffUncapped(t *testing.T) {
+	delays := newBackoff(time.Millisecond, 0, 0)
+
+	for range 10 {
+		delays.Next()
+	}
+
+	if got := delays.Next(); got != time.Millisecond*1024 {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	const initial = time.Millisecond * 100
+	const max = time.Millisecond * 400
+
+	for _, jitter := range []float64{0.5, 1, 2} {
+		delays := newBackoff(initial, max, jitter)
+
+		for attempt := range 100 {
+			delay := min(initial<<min(attempt, 10), max)
+			lowest := delay - time.Duration(float64(delay)*min(jitter, 1))
+
+			if got := delays.Next(); got < lowest || got > delay {
+				t.Fatalf("jitter %g, attempt %d: got %s, expected [%s, %s]", jitter, attempt, got, lowest, delay)
+			}
+		}
+	}
+}
+
+type requestObserver struct {
+	dialObserver
+
+	lock     sync.Mutex
+	requests map[transport.Command]int
+}
+
+func (o *requestObserver) RequestCompleted(cmd transport.Command, duration time.Duration, err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.requests[cmd]++
+}
+
+func (o *requestObserver) count(cmd transport.Command) int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.requests[cmd]
+}
+
+func TestRetryNeverDestructive(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+
+	// The gateway never receives the requests, so that it does not reboot
+	inj := faultinject.NewInjector(1)
+	inj.AddRule(faultinject.Rule{
+		Direction:   faultinject.DirectionToGateway,
+		Commands:    []transport.Command{transport.GW_REBOOT_REQ, transport.GW_SET_FACTORY_DEFAULT_REQ, transport.GW_GET_ALL_GROUPS_INFORMATION_REQ},
+		Fault:       faultinject.FaultDrop,
+		Probability: faultinject.Always,
+	})
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	observer := &requestObserver{requests: make(map[transport.Command]int)}
+	client := startClient(t, gw, WithConnWrapper(inj.Wrap), WithRequestTimeout(time.Millisecond*100), WithRetryPolicy(policy), WithObserver(observer))
+	ctx := testContext(t)
+
+	if err := client.Device().RebootContext(ctx); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Reboot: got %v, expected a timeout", err)
+	}
+
+	if err := client.Device().SetFactoryDefaultContext(ctx); !errors.Is(err, ErrTimeout) {
+		t.Errorf("SetFactoryDefault: got %v, expected a timeout", err)
+	}
+
+	if _, err := client.Info().GetAllGroupsInformation(ctx, nil); !errors.Is(err, ErrTimeout) {
+		t.Errorf("GetAllGroupsInformation: got %v, expected a timeout", err)
+	}
+
+	if count := observer.count(transport.GW_REBOOT_REQ); count != 1 {
+		t.Errorf("Reboot sent %d times", count)
+	}
+
+	if count := observer.count(transport.GW_SET_FACTORY_DEFAULT_REQ); count != 1 {
+		t.Errorf("SetFactoryDefault sent %d times", count)
+	}
+
+	if count := observer.count(transport.GW_GET_ALL_GROUPS_INFORMATION_REQ); count != policy.MaxAttempts {
+		t.Errorf("GetAllGroupsInformation sent %d times", count)
+	}
+}
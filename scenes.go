@@ -0,0 +1,121 @@
+package klf200
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/utils"
+)
+
+type Scenes struct {
+	client       *Client
+	getListTrans utils.Mutex
+}
+
+func newScenes(client *Client) *Scenes {
+	return &Scenes{
+		client:       client,
+		getListTrans: utils.NewMutex(),
+	}
+}
+
+func (scenes *Scenes) GetSceneList(ctx context.Context) ([]commands.SceneListObject, error) {
+	// Permits only one request at a time to avoid notifications mismatchs
+	if !scenes.getListTrans.TryLockWithContext(ctx) {
+		return nil, ctx.Err()
+	}
+
+	defer scenes.getListTrans.Unlock()
+
+	trans := newTransaction[*commands.GetSceneListNtf](scenes.client, nil)
+
+	defer trans.close()
+
+	cfm, err := trans.execute(ctx, &commands.GetSceneListReq{})
+	if err != nil {
+		return nil, err
+	}
+
+	tcfm := cfm.(*commands.GetSceneListCfm)
+
+	list := make([]commands.SceneListObject, 0, tcfm.TotalNumberOfObjects)
+
+	for {
+		ntf, err := trans.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, ntf.Objects...)
+
+		if ntf.RemainingNumberOfObject == 0 {
+			break
+		}
+	}
+
+	if len(list) != tcfm.TotalNumberOfObjects {
+		return nil, fmt.Errorf("scenes count mismatch (ntf=%d, cfm=%d)", len(list), tcfm.TotalNumberOfObjects)
+	}
+
+	return list, nil
+}
+
+func (scenes *Scenes) Activate(ctx context.Context, sceneID int) (*Session, error) {
+	// TODO: customize parameters
+	req := &commands.ActivateSceneReq{
+		CommandOriginator: commands.CommandOriginatorUser,
+		PriorityLevel:     commands.PriorityUserLevel2,
+		SceneID:           sceneID,
+		Velocity:          commands.VelocityDefault,
+	}
+
+	sess := newSession(scenes.client, ctx, func(sessionId int) { req.SessionID = sessionId })
+	defer sess.abort()
+
+	cfm, err := sess.trans.execute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	tcfm := cfm.(*commands.ActivateSceneCfm)
+
+	if tcfm.Status != commands.SceneStatusSuccess {
+		return nil, fmt.Errorf("error : '%s'", tcfm.Status)
+	}
+
+	if tcfm.SessionID != sess.trans.sessionId() {
+		return nil, errors.New("session id mismatch")
+	}
+
+	return sess.start(), nil
+}
+
+func (scenes *Scenes) Stop(sceneID int) error {
+	return scenes.StopContext(context.Background(), sceneID)
+}
+
+func (scenes *Scenes) StopContext(ctx context.Context, sceneID int) error {
+	sessionId := scenes.client.commands.newSessionId()
+
+	// TODO: customize parameters
+	req := &commands.StopSceneReq{
+		SessionID:         sessionId,
+		CommandOriginator: commands.CommandOriginatorUser,
+		PriorityLevel:     commands.PriorityUserLevel2,
+		SceneID:           sceneID,
+	}
+
+	cfm, err := scenes.client.executeContext(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	tcfm := cfm.(*commands.StopSceneCfm)
+
+	if tcfm.Status != commands.SceneStatusSuccess {
+		return fmt.Errorf("error : '%s'", tcfm.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package simulator
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/internal/server"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Confirm or notification sent to the client
+type message interface {
+	Code() transport.Command
+	Write() ([]byte, error)
+}
+
+type client struct {
+	gw            *Gateway
+	conn          *server.Conn
+	authenticated atomic.Bool
+}
+
+func newClient(gw *Gateway, conn *server.Conn) *client {
+	return &client{
+		gw:   gw,
+		conn: conn,
+	}
+}
+
+func (c *client) worker() {
+	defer c.conn.Close()
+
+	for {
+		frame, err := c.conn.ReadFrame()
+		if errors.Is(err, server.ErrBadFrame) {
+			c.gw.debugf("Bad frame received: %s", err)
+			c.send(&commands.ErrorNtf{ErrorNumber: commands.ErrorBadFrame})
+			continue
+		}
+
+		if err != nil {
+			if !server.IsClosed(err) {
+				c.gw.debugf("Read error: %s", err)
+			}
+
+			return
+		}
+
+		c.processFrame(frame)
+	}
+}
+
+func (c *client) processFrame(frame *transport.Frame) {
+	req := commands.GetRequest(frame.Cmd)
+	if req == nil {
+		c.gw.debugf("Unsupported command %s", frame.Cmd)
+		c.send(&commands.ErrorNtf{ErrorNumber: commands.ErrorBadCommand})
+		return
+	}
+
+	if err := req.Read(frame.Data); err != nil {
+		c.gw.debugf("Could not decode %s: %s", frame.Cmd, err)
+		c.send(&commands.ErrorNtf{ErrorNumber: commands.ErrorBadFrame})
+		return
+	}
+
+	if _, ok := req.(*commands.PasswordEnterReq); !ok && !c.authenticated.Load() {
+		c.send(&commands.ErrorNtf{ErrorNumber: commands.ErrorNotAuthenticated})
+		return
+	}
+
+	c.gw.debugf("Recv %s", req.Code())
+
+	for _, msg := range c.gw.handle(c, req) {
+		c.send(msg)
+	}
+
+	if isRebootRequest(req) {
+		c.gw.debugf("Rebooting")
+		c.gw.disconnectAll()
+	}
+}
+
+// Send the messages in order after the delay
+func (c *client) sendLater(delay time.Duration, msgs []message) {
+	time.AfterFunc(delay, func() {
+		for _, msg := range msgs {
+			c.send(msg)
+		}
+	})
+}
+
+func (c *client) send(msg message) {
+	data, err := msg.Write()
+	if err != nil {
+		c.gw.errorf("Could not encode %s: %s", msg.Code(), err)
+		return
+	}
+
+	if err := c.conn.WriteFrame(&transport.Frame{Cmd: msg.Code(), Data: data}); err != nil {
+		c.gw.debugf("Write error: %s", err)
+	}
+}
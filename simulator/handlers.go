@@ -0,0 +1,411 @@
+package simulator
+
+import (
+	"slices"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+// Maximum number of objects in one GW_CS_GET_SYSTEMTABLE_DATA_NTF frame
+const systemtableChunkSize = 22
+
+// Process a request and returns the confirm, followed by the notifications to send to the client.
+// Notifications of sessions are sent later, after the io-homecontrol latency.
+func (gw *Gateway) handle(c *client, req commands.Request) []message {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+
+	switch req := req.(type) {
+	case *commands.PasswordEnterReq:
+		success := req.Password == gw.password
+		c.authenticated.Store(success)
+		return []message{&commands.PasswordEnterCfm{Success: success}}
+
+	case *commands.PasswordChangeReq:
+		return gw.handlePasswordChange(c, req)
+
+	case *commands.GetVersionReq:
+		version := gw.version
+		return []message{&version}
+
+	case *commands.GetProtocolVersionReq:
+		return []message{&commands.GetProtocolVersionCfm{MajorVersion: 3, MinorVersion: 14}}
+
+	case *commands.GetStateReq:
+		state := commands.GatewayStateGatewayMode
+		if len(gw.nodes) > 0 {
+			state = commands.GatewayStateGatewayModeWithActuator
+		}
+
+		return []message{&commands.GetStateCfm{GatewayState: state, SubState: commands.GatewaySubStateIdle}}
+
+	case *commands.LeaveLearnStateReq:
+		return []message{&commands.LeaveLearnStateCfm{Success: true}}
+
+	case *commands.RebootReq:
+		return []message{&commands.RebootCfm{}}
+
+	case *commands.SetFactoryDefaultReq:
+		gw.password = DefaultPassword
+		gw.network = defaultNetworkSetup()
+		gw.nodes = make(map[int]*node)
+		gw.groups = nil
+		gw.scenes = nil
+		gw.movements = nil
+		return []message{&commands.SetFactoryDefaultCfm{}}
+
+	case *commands.GetNetworkSetupReq:
+		network := gw.network
+		return []message{&network}
+
+	case *commands.SetNetworkSetupReq:
+		gw.network = commands.GetNetworkSetupCfm{IpAddress: req.IpAddress, Mask: req.Mask, DefGW: req.DefGW, DHCP: req.DHCP}
+		return []message{&commands.SetNetworkSetupCfm{}}
+
+	case *commands.SetUtcReq:
+		gw.utcDelta = time.Until(req.Timestamp)
+		return []message{&commands.SetUtcCfm{}}
+
+	case *commands.RtcSetTimeZoneReq:
+		gw.timeZone = req.TimeZoneString
+		return []message{&commands.RtcSetTimeZoneCfm{Success: true}}
+
+	case *commands.GetLocalTimeReq:
+		return []message{gw.localTime()}
+
+	case *commands.CsGetSystemtableDataReq:
+		return gw.handleGetSystemtable()
+
+	case *commands.GetAllNodesInformationReq:
+		return gw.handleGetAllNodesInformation()
+
+	case *commands.GetAllGroupsInformationReq:
+		return gw.handleGetAllGroupsInformation(req)
+
+	case *commands.GetSceneListReq:
+		return gw.handleGetSceneList()
+
+	case *commands.CommandSendReq:
+		return gw.handleCommandSend(c, req)
+
+	case *commands.ModeSendReq:
+		return gw.handleModeSend(c, req)
+
+	case *commands.StatusRequestReq:
+		return gw.handleStatusRequest(c, req)
+
+	case *commands.ActivateSceneReq:
+		return gw.handleActivateScene(c, req)
+
+	case *commands.StopSceneReq:
+		gw.stopScene(req.SceneID, time.Now())
+		return []message{&commands.StopSceneCfm{Status: commands.SceneStatusSuccess, SessionID: req.SessionID}}
+
+	default:
+		return []message{&commands.ErrorNtf{ErrorNumber: commands.ErrorBadCommand}}
+	}
+}
+
+// The gateway reboots after having confirmed these requests
+func isRebootRequest(req commands.Request) bool {
+	switch req.(type) {
+	case *commands.RebootReq, *commands.SetFactoryDefaultReq, *commands.SetNetworkSetupReq:
+		return true
+	default:
+		return false
+	}
+}
+
+func (gw *Gateway) handlePasswordChange(c *client, req *commands.PasswordChangeReq) []message {
+	if req.CurrentPassword != gw.password || req.NewPassword == "" {
+		return []message{&commands.PasswordChangeCfm{Success: false}}
+	}
+
+	gw.password = req.NewPassword
+
+	// Other clients are informed of the change
+	for other := range gw.clients {
+		if other != c && other.authenticated.Load() {
+			go other.send(&commands.PasswordChangeNtf{NewPassword: req.NewPassword})
+		}
+	}
+
+	return []message{&commands.PasswordChangeCfm{Success: true}}
+}
+
+func (gw *Gateway) localTime() *commands.GetLocalTimeCfm {
+	utc := time.Now().Add(gw.utcDelta).UTC()
+
+	// The time zone string is stored but not interpreted: local time is UTC
+	return &commands.GetLocalTimeCfm{
+		UtcTime: utc,
+		LocalTime: commands.LocalTime{
+			Second:             utc.Second(),
+			Minute:             utc.Minute(),
+			Hour:               utc.Hour(),
+			DayOfMonth:         utc.Day(),
+			Month:              int(utc.Month()) - 1,
+			Year:               utc.Year(),
+			WeekDay:            int(utc.Weekday()),
+			DayOfYear:          utc.YearDay() - 1,
+			DaylightSavingFlag: commands.DaylightSavingNotActive,
+		},
+	}
+}
+
+func (gw *Gateway) sortedNodes() []*node {
+	nodes := make([]*node, 0, len(gw.nodes))
+	for _, n := range gw.nodes {
+		nodes = append(nodes, n)
+	}
+
+	slices.SortFunc(nodes, func(a, b *node) int { return a.config.Index - b.config.Index })
+
+	return nodes
+}
+
+func (gw *Gateway) handleGetSystemtable() []message {
+	nodes := gw.sortedNodes()
+	msgs := []message{&commands.CsGetSystemtableDataCfm{}}
+
+	for len(nodes) > 0 {
+		count := min(len(nodes), systemtableChunkSize)
+		ntf := &commands.CsGetSystemtableDataNtf{
+			NumberOfEntry:          count,
+			Objects:                make([]commands.SystemtableObject, count),
+			RemainingNumberOfEntry: len(nodes) - count,
+		}
+
+		for index, n := range nodes[:count] {
+			ntf.Objects[index] = n.systemtableObject()
+		}
+
+		msgs = append(msgs, ntf)
+		nodes = nodes[count:]
+	}
+
+	if len(msgs) == 1 {
+		msgs = append(msgs, &commands.CsGetSystemtableDataNtf{Objects: []commands.SystemtableObject{}})
+	}
+
+	return msgs
+}
+
+func (gw *Gateway) handleGetAllNodesInformation() []message {
+	nodes := gw.sortedNodes()
+	if len(nodes) == 0 {
+		return []message{&commands.GetAllNodesInformationCfm{Success: false}}
+	}
+
+	msgs := []message{&commands.GetAllNodesInformationCfm{Success: true, TotalNumberOfNodes: len(nodes)}}
+
+	for _, n := range nodes {
+		msgs = append(msgs, n.information())
+	}
+
+	return append(msgs, &commands.GetAllNodesInformationFinishedNtf{})
+}
+
+func (gw *Gateway) handleGetAllGroupsInformation(req *commands.GetAllGroupsInformationReq) []message {
+	groups := make([]GroupConfig, 0, len(gw.groups))
+	for _, group := range gw.groups {
+		if !req.UseFilter || group.Type == req.GroupType {
+			groups = append(groups, group)
+		}
+	}
+
+	if len(groups) == 0 {
+		return []message{&commands.GetAllGroupsInformationCfm{Status: commands.GetAllGroupsInformationStatusNoGroups}}
+	}
+
+	msgs := []message{&commands.GetAllGroupsInformationCfm{Status: commands.GetAllGroupsInformationStatusSuccess, TotalNumberOfGroups: len(groups)}}
+
+	for _, group := range groups {
+		msgs = append(msgs, &commands.GetAllGroupsInformationNtf{
+			GroupID:     group.ID,
+			Name:        group.Name,
+			Velocity:    commands.VelocityDefault,
+			GroupType:   group.Type,
+			NodeIndexes: group.NodeIndexes,
+		})
+	}
+
+	return append(msgs, &commands.GetAllGroupsInformationFinishedNtf{})
+}
+
+func (gw *Gateway) handleGetSceneList() []message {
+	scenes := gw.scenes
+	msgs := []message{&commands.GetSceneListCfm{TotalNumberOfObjects: len(scenes)}}
+
+	for {
+		count := min(len(scenes), commands.SceneListMaxObjects)
+		ntf := &commands.GetSceneListNtf{
+			Objects:                 make([]commands.SceneListObject, count),
+			RemainingNumberOfObject: len(scenes) - count,
+		}
+
+		for index, scene := range scenes[:count] {
+			ntf.Objects[index] = commands.SceneListObject{SceneID: scene.ID, Name: scene.Name}
+		}
+
+		msgs = append(msgs, ntf)
+		scenes = scenes[count:]
+
+		if len(scenes) == 0 {
+			return msgs
+		}
+	}
+}
+
+func (gw *Gateway) handleCommandSend(c *client, req *commands.CommandSendReq) []message {
+	for _, index := range req.NodeIndexes {
+		if _, ok := gw.nodes[index]; !ok {
+			return []message{&commands.ErrorNtf{ErrorNumber: commands.ErrorBadIndex}}
+		}
+	}
+
+	moves := make([]*movement, 0, len(req.NodeIndexes))
+
+	for _, index := range req.NodeIndexes {
+		n := gw.nodes[index]
+
+		if req.PriorityLevelLock == commands.PriorityLevelLockNewLock {
+			n.applyLocks(req.PriorityLevelInfo, req.LockTime, time.Now())
+		}
+
+		for param := commands.FunctionalParameterMP; param <= commands.FunctionalParameterFP16; param++ {
+			value, ok := req.FunctionalParameterValues[param]
+			if !ok {
+				continue
+			}
+
+			if mv := gw.newMovement(n, param, commands.MPValue(value), req.CommandOriginator, req.PriorityLevel, commands.VelocityDefault); mv != nil {
+				moves = append(moves, mv)
+			}
+		}
+	}
+
+	gw.startSession(c, req.SessionID, -1, moves)
+
+	return []message{&commands.CommandSendCfm{SessionID: req.SessionID, Success: true}}
+}
+
+func (gw *Gateway) handleActivateScene(c *client, req *commands.ActivateSceneReq) []message {
+	index := slices.IndexFunc(gw.scenes, func(scene SceneConfig) bool { return scene.ID == req.SceneID })
+	if index < 0 {
+		return []message{&commands.ActivateSceneCfm{Status: commands.SceneStatusInvalidParameter, SessionID: req.SessionID}}
+	}
+
+	scene := gw.scenes[index]
+	moves := make([]*movement, 0, len(scene.Positions))
+
+	for nodeIndex, value := range scene.Positions {
+		n, ok := gw.nodes[nodeIndex]
+		if !ok {
+			continue
+		}
+
+		if mv := gw.newMovement(n, commands.FunctionalParameterMP, value, req.CommandOriginator, req.PriorityLevel, req.Velocity); mv != nil {
+			moves = append(moves, mv)
+		}
+	}
+
+	slices.SortFunc(moves, func(a, b *movement) int { return a.node.config.Index - b.node.config.Index })
+
+	gw.startSession(c, req.SessionID, req.SceneID, moves)
+
+	return []message{&commands.ActivateSceneCfm{Status: commands.SceneStatusSuccess, SessionID: req.SessionID}}
+}
+
+// Build the movement of a node parameter, or nil if the value does not move it
+func (gw *Gateway) newMovement(n *node, param commands.FunctionalParameter, value commands.MPValue, originator commands.CommandOriginator, priority commands.PriorityLevel, velocity commands.Velocity) *movement {
+	target, ok := n.resolveTarget(param, value)
+	if !ok {
+		return nil
+	}
+
+	target, reply := n.limit(param, target)
+	n.originator = originator
+
+	return &movement{
+		node:     n,
+		param:    param,
+		priority: priority,
+		owner:    runOwner(originator),
+		target:   target,
+		reply:    reply,
+		speed:    travelSpeed(n, velocity),
+	}
+}
+
+func (gw *Gateway) handleModeSend(c *client, req *commands.ModeSendReq) []message {
+	for _, index := range req.NodeIndexes {
+		if _, ok := gw.nodes[index]; !ok {
+			return []message{&commands.ErrorNtf{ErrorNumber: commands.ErrorBadIndex}}
+		}
+	}
+
+	ntfs := make([]message, 0, len(req.NodeIndexes)+1)
+
+	for _, index := range req.NodeIndexes {
+		n := gw.nodes[index]
+		ntfs = append(ntfs, &commands.CommandRunStatusNtf{
+			SessionID:      req.SessionID,
+			StatusID:       commands.CommandRunOwnerUser,
+			NodeIndex:      index,
+			NodeParameter:  commands.FunctionalParameterMP,
+			ParameterValue: int(n.current[commands.FunctionalParameterMP]),
+			RunStatus:      commands.CommandRunStatusCompleted,
+			StatusReply:    commands.CommandRunStatusReplyCommandCompletedOk,
+		})
+	}
+
+	c.sendLater(gw.latency, append(ntfs, &commands.SessionFinishedNtf{SessionID: req.SessionID}))
+
+	return []message{&commands.ModeSendCfm{SessionID: req.SessionID, Status: commands.ModeSendStatusSuccess}}
+}
+
+func (gw *Gateway) handleStatusRequest(c *client, req *commands.StatusRequestReq) []message {
+	ntfs := make([]message, 0, len(req.NodeIndexes)+1)
+
+	for _, index := range req.NodeIndexes {
+		ntf := &commands.StatusRequestNtf{
+			SessionID:  req.SessionID,
+			NodeIndex:  index,
+			StatusType: req.StatusType,
+		}
+
+		if n, ok := gw.nodes[index]; ok && n.failure != commands.CommandRunStatusReplyNoContact {
+			ntf.StatusID = n.owner
+			ntf.RunStatus = commands.CommandRunStatusCompleted
+			ntf.StatusReply = commands.CommandRunStatusReplyCommandCompletedOk
+			if len(n.moving) > 0 {
+				ntf.RunStatus = commands.CommandRunStatusActive
+				ntf.StatusReply = commands.CommandRunStatusReplyUnknownStatusReply
+			}
+
+			ntf.StatusData = n.status(req.StatusType, req.FunctionalParameters)
+		} else {
+			ntf.StatusID = commands.CommandRunOwnerUnknown
+			ntf.RunStatus = commands.CommandRunStatusFailed
+			ntf.StatusReply = commands.CommandRunStatusReplyNoContact
+			ntf.StatusData = emptyStatus(req.StatusType)
+		}
+
+		ntfs = append(ntfs, ntf)
+	}
+
+	c.sendLater(gw.latency, append(ntfs, &commands.SessionFinishedNtf{SessionID: req.SessionID}))
+
+	return []message{&commands.StatusRequestCfm{SessionID: req.SessionID, Success: true}}
+}
+
+// Status data sent when the node did not answer (only 0s)
+func emptyStatus(statusType commands.StatusRequestStatusType) commands.StatusData {
+	if statusType == commands.StatusRequestMainInfo {
+		return &commands.StatusDataMainInfo{}
+	}
+
+	return &commands.StatusDataParameters{ParameterValues: make(map[commands.FunctionalParameter]int)}
+}
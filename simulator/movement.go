@@ -0,0 +1,342 @@
+package simulator
+
+import (
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+// Period of the movement worker
+const movementTick = time.Millisecond * 20
+
+// Default interval between two progress reports of a moving actuator
+const DefaultReportInterval = time.Second
+
+// Command session started by a client, finished when all its movements are
+type session struct {
+	id      int
+	client  *client
+	sceneID int // -1 if not a scene activation
+	pending int
+}
+
+// Travel of one node parameter requested by a command
+type movement struct {
+	session  *session
+	node     *node
+	param    commands.FunctionalParameter
+	priority commands.PriorityLevel
+	owner    commands.CommandRunOwner
+
+	target commands.MPValue
+	// Reply sent on completion (CommandCompletedOk, or the limitation that clamped the target)
+	reply commands.CommandRunStatusReply
+
+	start      time.Time
+	speed      float64 // Fraction of the full travel per second
+	begun      bool
+	from       commands.MPValue
+	duration   time.Duration
+	lastReport time.Time
+}
+
+// Message queued for a client, sent by the movement worker outside of the lock
+type outgoing struct {
+	client *client
+	msg    message
+}
+
+// Remaining travel time, rounded up to the second as reported by the gateway
+func (mv *movement) remaining(now time.Time) time.Duration {
+	remaining := mv.duration
+	if mv.begun {
+		remaining = max(mv.start.Add(mv.duration).Sub(now), 0)
+	}
+
+	return (remaining + time.Second - 1).Truncate(time.Second)
+}
+
+// Position reached at the given time
+func (mv *movement) position(now time.Time) commands.MPValue {
+	if mv.duration <= 0 {
+		return mv.target
+	}
+
+	progress := min(float64(now.Sub(mv.start))/float64(mv.duration), 1)
+	return commands.MPValue(float64(mv.from) + progress*(float64(mv.target)-float64(mv.from)))
+}
+
+// Queue the movements of a new session. They begin after the io-homecontrol latency
+func (gw *Gateway) startSession(c *client, sessionID int, sceneID int, moves []*movement) {
+	sess := &session{id: sessionID, client: c, sceneID: sceneID, pending: len(moves)}
+
+	if len(moves) == 0 {
+		c.sendLater(gw.latency, []message{&commands.SessionFinishedNtf{SessionID: sessionID}})
+		return
+	}
+
+	start := time.Now().Add(gw.latency)
+
+	for _, mv := range moves {
+		mv.session = sess
+		mv.start = start
+		gw.movements = append(gw.movements, mv)
+	}
+}
+
+// Travel speed of a node, as a fraction of the full travel per second
+func travelSpeed(n *node, velocity commands.Velocity) float64 {
+	travelTime := n.config.TravelTime
+
+	if velocity == commands.VelocityDefault {
+		velocity = n.config.Velocity
+	}
+
+	switch velocity {
+	case commands.VelocitySilent:
+		travelTime *= 2
+	case commands.VelocityFast:
+		travelTime /= 2
+	}
+
+	return float64(time.Second) / float64(travelTime)
+}
+
+func (gw *Gateway) movementWorker() {
+	defer gw.workerSync.Done()
+
+	ticker := time.NewTicker(movementTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gw.closing:
+			return
+
+		case now := <-ticker.C:
+			gw.lock.Lock()
+			gw.updateMovements(now)
+			outbox := gw.outbox
+			gw.outbox = nil
+			gw.lock.Unlock()
+
+			for _, out := range outbox {
+				out.client.send(out.msg)
+			}
+		}
+	}
+}
+
+// Make the movements progress. Must be called with the lock held
+func (gw *Gateway) updateMovements(now time.Time) {
+	movements := gw.movements
+	gw.movements = make([]*movement, 0, len(movements))
+
+	for _, mv := range movements {
+		if now.Before(mv.start) {
+			gw.movements = append(gw.movements, mv)
+			continue
+		}
+
+		if !mv.begun && !gw.begin(mv, now) {
+			continue
+		}
+
+		if gw.progress(mv, now) {
+			gw.movements = append(gw.movements, mv)
+		}
+	}
+}
+
+// Begin the movement. Returns false if it failed immediately
+func (gw *Gateway) begin(mv *movement, now time.Time) bool {
+	n := mv.node
+
+	switch {
+	case n.failure == commands.CommandRunStatusReplyNoContact:
+		gw.fail(mv, n.failure)
+		return false
+
+	case n.locked(mv.priority, now):
+		gw.fail(mv, commands.CommandRunStatusReplyPriorityLevelLocked)
+		return false
+	}
+
+	if previous, ok := n.moving[mv.param]; ok {
+		gw.overrule(previous)
+	}
+
+	mv.begun = true
+	mv.from = n.current[mv.param]
+	mv.start = now
+	mv.lastReport = now
+
+	distance := float64(mv.target) - float64(mv.from)
+	if distance < 0 {
+		distance = -distance
+	}
+
+	mv.duration = time.Duration(distance / float64(commands.NewMPValueAbsolute(100)) / mv.speed * float64(time.Second))
+
+	n.moving[mv.param] = mv
+	n.target[mv.param] = mv.target
+	n.state = commands.NodeStateExecuting
+	n.owner = mv.owner
+	n.timestamp = now
+
+	gw.report(mv, now)
+	gw.broadcastState(n, now)
+
+	return true
+}
+
+// Update the position of the node. Returns false when the movement is over
+func (gw *Gateway) progress(mv *movement, now time.Time) bool {
+	n := mv.node
+	position := mv.position(now)
+
+	// Other failures happen halfway
+	if n.failure != commands.CommandRunStatusReplyUnknownStatusReply && now.Sub(mv.start)*2 >= mv.duration {
+		n.current[mv.param] = position
+		n.target[mv.param] = position
+		n.state = commands.NodeStateErrorWhileExecution
+		n.timestamp = now
+		delete(n.moving, mv.param)
+
+		gw.fail(mv, n.failure)
+		gw.broadcastState(n, now)
+		return false
+	}
+
+	n.current[mv.param] = position
+
+	if now.Sub(mv.start) >= mv.duration {
+		delete(n.moving, mv.param)
+		if len(n.moving) == 0 {
+			n.state = commands.NodeStateDone
+		}
+
+		n.timestamp = now
+
+		gw.queue(mv.session.client, mv.runStatus(commands.CommandRunStatusCompleted, mv.reply, position))
+		gw.broadcastState(n, now)
+		gw.finish(mv)
+		return false
+	}
+
+	if now.Sub(mv.lastReport) >= gw.reportInterval {
+		mv.lastReport = now
+		gw.report(mv, now)
+		gw.broadcastState(n, now)
+	}
+
+	return true
+}
+
+// Stop a movement which has been replaced by a new command
+func (gw *Gateway) overrule(mv *movement) {
+	gw.movements = deleteMovement(gw.movements, mv)
+	delete(mv.node.moving, mv.param)
+	gw.fail(mv, commands.CommandRunStatusReplyCommandOverruled)
+}
+
+// Stop all movements of the scene
+func (gw *Gateway) stopScene(sceneID int, now time.Time) {
+	for _, mv := range append([]*movement(nil), gw.movements...) {
+		if mv.session.sceneID != sceneID {
+			continue
+		}
+
+		if mv.begun {
+			n := mv.node
+			n.current[mv.param] = mv.position(now)
+			n.target[mv.param] = n.current[mv.param]
+			n.timestamp = now
+			delete(n.moving, mv.param)
+			if len(n.moving) == 0 {
+				n.state = commands.NodeStateDone
+			}
+
+			gw.broadcastState(n, now)
+		}
+
+		gw.movements = deleteMovement(gw.movements, mv)
+		gw.fail(mv, commands.CommandRunStatusReplyCommandOverruled)
+	}
+}
+
+func deleteMovement(movements []*movement, mv *movement) []*movement {
+	for index, item := range movements {
+		if item == mv {
+			return append(movements[:index], movements[index+1:]...)
+		}
+	}
+
+	return movements
+}
+
+func (gw *Gateway) fail(mv *movement, reply commands.CommandRunStatusReply) {
+	gw.queue(mv.session.client, mv.runStatus(commands.CommandRunStatusFailed, reply, mv.node.current[mv.param]))
+	gw.finish(mv)
+}
+
+// Account the end of the movement in its session
+func (gw *Gateway) finish(mv *movement) {
+	mv.session.pending--
+
+	if mv.session.pending == 0 {
+		gw.queue(mv.session.client, &commands.SessionFinishedNtf{SessionID: mv.session.id})
+	}
+}
+
+func (gw *Gateway) report(mv *movement, now time.Time) {
+	gw.queue(mv.session.client, mv.runStatus(commands.CommandRunStatusActive, commands.CommandRunStatusReplyUnknownStatusReply, mv.node.current[mv.param]))
+	gw.queue(mv.session.client, &commands.CommandRemainingTimeNtf{
+		SessionID:     mv.session.id,
+		NodeIndex:     mv.node.config.Index,
+		NodeParameter: mv.param,
+		Duration:      mv.remaining(now),
+	})
+}
+
+func (mv *movement) runStatus(status commands.CommandRunStatus, reply commands.CommandRunStatusReply, position commands.MPValue) *commands.CommandRunStatusNtf {
+	return &commands.CommandRunStatusNtf{
+		SessionID:      mv.session.id,
+		StatusID:       mv.owner,
+		NodeIndex:      mv.node.config.Index,
+		NodeParameter:  mv.param,
+		ParameterValue: int(position),
+		RunStatus:      status,
+		StatusReply:    reply,
+	}
+}
+
+// Send the state of the node to all authenticated clients
+func (gw *Gateway) broadcastState(n *node, now time.Time) {
+	ntf := n.stateChanged(now)
+
+	for c := range gw.clients {
+		if c.authenticated.Load() {
+			gw.queue(c, ntf)
+		}
+	}
+}
+
+func (gw *Gateway) queue(c *client, msg message) {
+	gw.outbox = append(gw.outbox, outgoing{client: c, msg: msg})
+}
+
+// Owner reported in the run status of a command sent by the originator
+func runOwner(originator commands.CommandOriginator) commands.CommandRunOwner {
+	switch originator {
+	case commands.CommandOriginatorSaac:
+		return commands.CommandRunOwnerProgram
+	case commands.CommandOriginatorEmergency:
+		return commands.CommandRunOwnerEmergency
+	case commands.CommandOriginatorUser, commands.CommandOriginatorRain, commands.CommandOriginatorTimer,
+		commands.CommandOriginatorUps, commands.CommandOriginatorWind:
+		return commands.CommandRunOwner(originator)
+	default:
+		return commands.CommandRunOwnerUnknown
+	}
+}
@@ -0,0 +1,348 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+// Gateway without listener nor workers: the tests drive updateMovements with their own clock
+func newTestGateway(configs ...NodeConfig) *Gateway {
+	gw := &Gateway{
+		nodes:          make(map[int]*node),
+		clients:        make(map[*client]struct{}),
+		reportInterval: time.Second,
+	}
+
+	for _, config := range configs {
+		gw.nodes[config.Index] = newNode(config)
+	}
+
+	return gw
+}
+
+// Start a session moving the main parameter of the node at the given time
+func (gw *Gateway) testMove(at time.Time, sessionID int, sceneID int, index int, value commands.MPValue, velocity commands.Velocity) {
+	mv := gw.newMovement(gw.nodes[index], commands.FunctionalParameterMP, value, commands.CommandOriginatorUser, commands.PriorityUserLevel2, velocity)
+	gw.startSession(nil, sessionID, sceneID, []*movement{mv})
+	mv.start = at
+}
+
+// Advance the clock and return the notifications sent meanwhile
+func (gw *Gateway) testUpdate(now time.Time) []message {
+	gw.updateMovements(now)
+
+	msgs := make([]message, 0, len(gw.outbox))
+	for _, out := range gw.outbox {
+		msgs = append(msgs, out.msg)
+	}
+
+	gw.outbox = nil
+	return msgs
+}
+
+func percent(value int) commands.MPValue {
+	return commands.NewMPValueAbsolute(value)
+}
+
+func runStatuses(msgs []message) []*commands.CommandRunStatusNtf {
+	statuses := make([]*commands.CommandRunStatusNtf, 0)
+	for _, msg := range msgs {
+		if ntf, ok := msg.(*commands.CommandRunStatusNtf); ok {
+			statuses = append(statuses, ntf)
+		}
+	}
+
+	return statuses
+}
+
+func remainingTimes(msgs []message) []time.Duration {
+	durations := make([]time.Duration, 0)
+	for _, msg := range msgs {
+		if ntf, ok := msg.(*commands.CommandRemainingTimeNtf); ok {
+			durations = append(durations, ntf.Duration)
+		}
+	}
+
+	return durations
+}
+
+func sessionFinished(msgs []message, sessionID int) bool {
+	for _, msg := range msgs {
+		if ntf, ok := msg.(*commands.SessionFinishedNtf); ok && ntf.SessionID == sessionID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (gw *Gateway) checkPosition(t *testing.T, index int, expected commands.MPValue) {
+	t.Helper()
+
+	position := gw.nodes[index].current[commands.FunctionalParameterMP]
+
+	// The position is computed from floats, allow one unit of rounding
+	if diff := int(position) - int(expected); diff < -1 || diff > 1 {
+		t.Errorf("node %d is at %s, expected %s", index, position, expected)
+	}
+}
+
+func TestMovementPositionOverTime(t *testing.T) {
+	gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 10})
+	start := time.Now()
+	gw.testMove(start, 1, -1, 0, percent(100), commands.VelocityDefault)
+
+	msgs := gw.testUpdate(start)
+	gw.checkPosition(t, 0, percent(0))
+
+	if got := remainingTimes(msgs); len(got) != 1 || got[0] != time.Second*10 {
+		t.Errorf("got remaining times %v at start, expected [10s]", got)
+	}
+
+	if got := runStatuses(msgs); len(got) != 1 || got[0].RunStatus != commands.CommandRunStatusActive {
+		t.Errorf("got run statuses %+v at start, expected one active", got)
+	}
+
+	if state := gw.nodes[0].state; state != commands.NodeStateExecuting {
+		t.Errorf("node state is %s while moving", state)
+	}
+
+	msgs = gw.testUpdate(start.Add(time.Millisecond * 2500))
+	gw.checkPosition(t, 0, percent(25))
+
+	// Reported each second, rounded up to the second
+	if got := remainingTimes(msgs); len(got) != 1 || got[0] != time.Second*8 {
+		t.Errorf("got remaining times %v at 2.5s, expected [8s]", got)
+	}
+
+	// Not reported again before the report interval
+	msgs = gw.testUpdate(start.Add(time.Millisecond * 3000))
+	gw.checkPosition(t, 0, percent(30))
+
+	if got := remainingTimes(msgs); len(got) != 0 {
+		t.Errorf("got remaining times %v at 3s, expected none", got)
+	}
+
+	gw.testUpdate(start.Add(time.Second * 5))
+	gw.checkPosition(t, 0, percent(50))
+
+	msgs = gw.testUpdate(start.Add(time.Second * 10))
+	gw.checkPosition(t, 0, percent(100))
+
+	statuses := runStatuses(msgs)
+	if len(statuses) != 1 || statuses[0].RunStatus != commands.CommandRunStatusCompleted || statuses[0].StatusReply != commands.CommandRunStatusReplyCommandCompletedOk {
+		t.Errorf("got run statuses %+v at the end, expected completed", statuses)
+	}
+
+	if !sessionFinished(msgs, 1) {
+		t.Error("session not finished")
+	}
+
+	if state := gw.nodes[0].state; state != commands.NodeStateDone {
+		t.Errorf("node state is %s after the travel", state)
+	}
+
+	if len(gw.movements) != 0 {
+		t.Errorf("%d movements left", len(gw.movements))
+	}
+}
+
+func TestMovementPartialTravel(t *testing.T) {
+	gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 10, Position: percent(80)})
+	start := time.Now()
+	gw.testMove(start, 1, -1, 0, percent(30), commands.VelocityDefault)
+
+	// 50% of the travel takes 5 seconds
+	gw.testUpdate(start)
+	gw.testUpdate(start.Add(time.Second * 2))
+	gw.checkPosition(t, 0, percent(60))
+
+	msgs := gw.testUpdate(start.Add(time.Second * 5))
+	gw.checkPosition(t, 0, percent(30))
+
+	if !sessionFinished(msgs, 1) {
+		t.Error("session not finished after 5s")
+	}
+}
+
+func TestMovementVelocity(t *testing.T) {
+	for _, test := range []struct {
+		velocity commands.Velocity
+		duration time.Duration
+	}{
+		{commands.VelocityDefault, time.Second * 4},
+		{commands.VelocityFast, time.Second * 2},
+		{commands.VelocitySilent, time.Second * 8},
+	} {
+		gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 4})
+		start := time.Now()
+		gw.testMove(start, 1, -1, 0, percent(100), test.velocity)
+
+		gw.testUpdate(start)
+		gw.testUpdate(start.Add(test.duration / 2))
+		gw.checkPosition(t, 0, percent(50))
+
+		if msgs := gw.testUpdate(start.Add(test.duration)); !sessionFinished(msgs, 1) {
+			t.Errorf("%s: session not finished after %s", test.velocity, test.duration)
+		}
+	}
+}
+
+func TestMovementStopScene(t *testing.T) {
+	gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 10})
+	start := time.Now()
+	gw.testMove(start, 1, 7, 0, percent(100), commands.VelocityDefault)
+
+	gw.testUpdate(start)
+	gw.testUpdate(start.Add(time.Second * 4))
+
+	gw.stopScene(7, start.Add(time.Second*4))
+	msgs := gw.testUpdate(start.Add(time.Second * 4))
+
+	statuses := runStatuses(msgs)
+	if len(statuses) != 1 || statuses[0].RunStatus != commands.CommandRunStatusFailed || statuses[0].StatusReply != commands.CommandRunStatusReplyCommandOverruled {
+		t.Errorf("got run statuses %+v after stop, expected overruled", statuses)
+	}
+
+	if !sessionFinished(msgs, 1) {
+		t.Error("session not finished after stop")
+	}
+
+	// The node stays where it was stopped
+	gw.testUpdate(start.Add(time.Second * 10))
+	gw.checkPosition(t, 0, percent(40))
+
+	if state := gw.nodes[0].state; state != commands.NodeStateDone {
+		t.Errorf("node state is %s after stop", state)
+	}
+}
+
+func TestMovementOverruled(t *testing.T) {
+	gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 10})
+	start := time.Now()
+	gw.testMove(start, 1, -1, 0, percent(100), commands.VelocityDefault)
+
+	gw.testUpdate(start)
+	gw.testUpdate(start.Add(time.Second * 5))
+
+	// New command back to 0 from the current position
+	second := start.Add(time.Second * 5)
+	gw.testMove(second, 2, -1, 0, percent(0), commands.VelocityDefault)
+	msgs := gw.testUpdate(second)
+
+	var first *commands.CommandRunStatusNtf
+	for _, status := range runStatuses(msgs) {
+		if status.SessionID == 1 {
+			first = status
+		}
+	}
+
+	if first == nil || first.StatusReply != commands.CommandRunStatusReplyCommandOverruled {
+		t.Errorf("got %+v for the first session, expected overruled", first)
+	}
+
+	if !sessionFinished(msgs, 1) {
+		t.Error("first session not finished")
+	}
+
+	msgs = gw.testUpdate(second.Add(time.Second * 5))
+	gw.checkPosition(t, 0, percent(0))
+
+	if !sessionFinished(msgs, 2) {
+		t.Error("second session not finished after 5s")
+	}
+}
+
+func TestMovementFailures(t *testing.T) {
+	for _, reply := range []commands.CommandRunStatusReply{
+		commands.CommandRunStatusReplyBlocked,
+		commands.CommandRunStatusReplyThermalProtection,
+	} {
+		gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 10})
+		gw.nodes[0].failure = reply
+		start := time.Now()
+		gw.testMove(start, 1, -1, 0, percent(100), commands.VelocityDefault)
+
+		gw.testUpdate(start)
+		gw.testUpdate(start.Add(time.Second * 2))
+		gw.checkPosition(t, 0, percent(20))
+
+		// Fails halfway
+		msgs := gw.testUpdate(start.Add(time.Second * 5))
+		gw.checkPosition(t, 0, percent(50))
+
+		statuses := runStatuses(msgs)
+		if len(statuses) != 1 || statuses[0].RunStatus != commands.CommandRunStatusFailed || statuses[0].StatusReply != reply {
+			t.Errorf("%s: got run statuses %+v, expected a failure", reply, statuses)
+		}
+
+		if !sessionFinished(msgs, 1) {
+			t.Errorf("%s: session not finished", reply)
+		}
+
+		if state := gw.nodes[0].state; state != commands.NodeStateErrorWhileExecution {
+			t.Errorf("%s: node state is %s", reply, state)
+		}
+	}
+}
+
+func TestMovementNoContact(t *testing.T) {
+	gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 10})
+	gw.nodes[0].failure = commands.CommandRunStatusReplyNoContact
+	start := time.Now()
+	gw.testMove(start, 1, -1, 0, percent(100), commands.VelocityDefault)
+
+	msgs := gw.testUpdate(start)
+	gw.checkPosition(t, 0, percent(0))
+
+	statuses := runStatuses(msgs)
+	if len(statuses) != 1 || statuses[0].StatusReply != commands.CommandRunStatusReplyNoContact {
+		t.Errorf("got run statuses %+v, expected no contact", statuses)
+	}
+
+	if !sessionFinished(msgs, 1) {
+		t.Error("session not finished")
+	}
+}
+
+func TestMovementLocked(t *testing.T) {
+	gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 10})
+	start := time.Now()
+
+	// Locked at a higher priority level than the commands (user level 2), for 10s
+	gw.nodes[0].locks[commands.PriorityUserLevel1] = start.Add(time.Second * 10)
+	gw.testMove(start, 1, -1, 0, percent(100), commands.VelocityDefault)
+
+	msgs := gw.testUpdate(start)
+	statuses := runStatuses(msgs)
+	if len(statuses) != 1 || statuses[0].StatusReply != commands.CommandRunStatusReplyPriorityLevelLocked {
+		t.Errorf("got run statuses %+v, expected priority level locked", statuses)
+	}
+
+	// The lock expires
+	later := start.Add(time.Second * 10)
+	gw.testMove(later, 2, -1, 0, percent(100), commands.VelocityDefault)
+
+	msgs = gw.testUpdate(later)
+	if statuses := runStatuses(msgs); len(statuses) != 1 || statuses[0].RunStatus != commands.CommandRunStatusActive {
+		t.Errorf("got run statuses %+v after the lock expiry, expected active", statuses)
+	}
+}
+
+func TestMovementLimitation(t *testing.T) {
+	gw := newTestGateway(NodeConfig{Index: 0, TravelTime: time.Second * 10})
+	gw.nodes[0].limitations[commands.FunctionalParameterMP] = limitation{min: percent(0), max: percent(60), originator: commands.CommandOriginatorUser}
+	start := time.Now()
+	gw.testMove(start, 1, -1, 0, percent(100), commands.VelocityDefault)
+
+	gw.testUpdate(start)
+	msgs := gw.testUpdate(start.Add(time.Second * 6))
+	gw.checkPosition(t, 0, percent(60))
+
+	statuses := runStatuses(msgs)
+	if len(statuses) != 1 || statuses[0].RunStatus != commands.CommandRunStatusCompleted || statuses[0].StatusReply != commands.CommandRunStatusReplyLimitationByUser {
+		t.Errorf("got run statuses %+v, expected completed with limitation by user", statuses)
+	}
+}
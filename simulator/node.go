@@ -0,0 +1,287 @@
+package simulator
+
+import (
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+type node struct {
+	config     NodeConfig
+	current    map[commands.FunctionalParameter]commands.MPValue
+	target     map[commands.FunctionalParameter]commands.MPValue
+	state      commands.NodeState
+	owner      commands.CommandRunOwner
+	originator commands.CommandOriginator
+	timestamp  time.Time
+
+	// Movements in progress, by parameter
+	moving map[commands.FunctionalParameter]*movement
+
+	// Expiry of the locks set on the node, by priority level. Zero time means no expiry
+	locks map[commands.PriorityLevel]time.Time
+
+	limitations map[commands.FunctionalParameter]limitation
+
+	// Reply of the next commands, if the node is made to fail
+	failure commands.CommandRunStatusReply
+}
+
+// Range of positions a parameter is limited to
+type limitation struct {
+	min        commands.MPValue
+	max        commands.MPValue
+	originator commands.CommandOriginator
+}
+
+// Number of functional parameters reported in GW_GET_ALL_NODES_INFORMATION_NTF
+const reportedFunctionalParameters = 4
+
+func newNode(config NodeConfig) *node {
+	if config.Address == 0 {
+		config.Address = 0x100000 + uint(config.Index)
+	}
+
+	if config.Manufacturer == 0 {
+		config.Manufacturer = commands.Velux
+	}
+
+	if config.TurnaroundTime == 0 {
+		config.TurnaroundTime = time.Millisecond * 5
+	}
+
+	if config.TravelTime == 0 {
+		config.TravelTime = config.TurnaroundTime * 1000
+	}
+
+	n := &node{
+		config:     config,
+		current:    make(map[commands.FunctionalParameter]commands.MPValue),
+		target:     make(map[commands.FunctionalParameter]commands.MPValue),
+		state:      commands.NodeStateDone,
+		owner:      commands.CommandRunOwnerUser,
+		originator: commands.CommandOriginatorUser,
+		timestamp:  time.Now(),
+
+		moving:      make(map[commands.FunctionalParameter]*movement),
+		locks:       make(map[commands.PriorityLevel]time.Time),
+		limitations: make(map[commands.FunctionalParameter]limitation),
+	}
+
+	n.current[commands.FunctionalParameterMP] = config.Position
+	n.target[commands.FunctionalParameterMP] = config.Position
+
+	return n
+}
+
+// Compute the new target of a parameter from the value received in a command.
+// Returns false if the parameter must not be changed
+func (n *node) resolveTarget(param commands.FunctionalParameter, value commands.MPValue) (commands.MPValue, bool) {
+	current := n.current[param]
+
+	if ok, _ := value.Absolute(); ok {
+		return value, true
+	}
+
+	if ok, percent := value.Relative(); ok {
+		position := int(current) + percent*int(commands.NewMPValueAbsolute(100))/100
+		position = max(position, 0)
+		position = min(position, int(commands.NewMPValueAbsolute(100)))
+		return commands.MPValue(position), true
+	}
+
+	switch {
+	case value.Target():
+		return n.target[param], true
+	case value.Current():
+		return current, true
+	default:
+		// Default and Ignore do not move the parameter
+		return 0, false
+	}
+}
+
+// Clamp the target into the limitation of the parameter.
+// Returns the reply to send when the command completes
+func (n *node) limit(param commands.FunctionalParameter, target commands.MPValue) (commands.MPValue, commands.CommandRunStatusReply) {
+	lim, ok := n.limitations[param]
+	if !ok || (target >= lim.min && target <= lim.max) {
+		return target, commands.CommandRunStatusReplyCommandCompletedOk
+	}
+
+	target = max(target, lim.min)
+	target = min(target, lim.max)
+
+	return target, limitationReply(lim.originator)
+}
+
+func limitationReply(originator commands.CommandOriginator) commands.CommandRunStatusReply {
+	switch originator {
+	case commands.CommandOriginatorUser:
+		return commands.CommandRunStatusReplyLimitationByUser
+	case commands.CommandOriginatorRain:
+		return commands.CommandRunStatusReplyLimitationByRain
+	case commands.CommandOriginatorTimer:
+		return commands.CommandRunStatusReplyLimitationByTimer
+	case commands.CommandOriginatorUps:
+		return commands.CommandRunStatusReplyLimitationByUps
+	case commands.CommandOriginatorSaac:
+		return commands.CommandRunStatusReplyLimitationBySaac
+	case commands.CommandOriginatorWind:
+		return commands.CommandRunStatusReplyLimitationByWind
+	case commands.CommandOriginatorEmergency:
+		return commands.CommandRunStatusReplyLimitationByEmergency
+	default:
+		return commands.CommandRunStatusReplyParameterLimited
+	}
+}
+
+// Check if a command of the given priority level is blocked by a lock of a higher priority level
+func (n *node) locked(level commands.PriorityLevel, now time.Time) bool {
+	for lockLevel, expiry := range n.locks {
+		if !expiry.IsZero() && !now.Before(expiry) {
+			delete(n.locks, lockLevel)
+			continue
+		}
+
+		if lockLevel < level {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply the priority level lock information received in a command
+func (n *node) applyLocks(info commands.PriorityLevelInfo, lockTime commands.LockTime, now time.Time) {
+	var expiry time.Time
+	if !lockTime.Unlimited() {
+		expiry = now.Add(lockTime.Duration())
+	}
+
+	for level := commands.PriorityProtectionHuman; level <= commands.PriorityComfortLevel4; level++ {
+		switch info.PLI(level) {
+		case commands.PriorityLevelLevelDisable:
+			delete(n.locks, level)
+		case commands.PriorityLevelLevelEnable, commands.PriorityLevelLevelEnableAll:
+			n.locks[level] = expiry
+		}
+	}
+}
+
+// Remaining travel time of the parameter
+func (n *node) remainingTime(param commands.FunctionalParameter, now time.Time) time.Duration {
+	mv, ok := n.moving[param]
+	if !ok {
+		return 0
+	}
+
+	return mv.remaining(now)
+}
+
+func (n *node) stateChanged(now time.Time) *commands.NodeStatePositionChangedNtf {
+	return &commands.NodeStatePositionChangedNtf{
+		NodeID:             n.config.Index,
+		State:              n.state,
+		CurrentPosition:    n.position(commands.FunctionalParameterMP, n.current),
+		Target:             n.position(commands.FunctionalParameterMP, n.target),
+		FP1CurrentPosition: n.position(commands.FunctionalParameterFP1, n.current),
+		FP2CurrentPosition: n.position(commands.FunctionalParameterFP2, n.current),
+		FP3CurrentPosition: n.position(commands.FunctionalParameterFP3, n.current),
+		FP4CurrentPosition: n.position(commands.FunctionalParameterFP4, n.current),
+		RemainingTime:      n.remainingTime(commands.FunctionalParameterMP, now),
+		TimeStamp:          n.timestamp,
+	}
+}
+
+func (n *node) systemtableObject() commands.SystemtableObject {
+	nodeType := n.config.NodeType
+
+	return commands.SystemtableObject{
+		SystemTableIndex:        n.config.Index,
+		ActuatorAddress:         n.config.Address,
+		ActuatorType:            nodeType.ActuatorType(),
+		ActuatorSubType:         nodeType.SubType(),
+		PowerSaveMode:           n.config.PowerSaveMode,
+		RfSupport:               true,
+		ActuatorTurnaroundTime:  n.config.TurnaroundTime,
+		IoManufacturer:          n.config.Manufacturer,
+		BackboneReferenceNumber: n.config.Address,
+	}
+}
+
+func (n *node) information() *commands.GetAllNodesInformationNtf {
+	powerMode := commands.PowerModeAlwaysAlive
+	if n.config.PowerSaveMode {
+		powerMode = commands.PowerModeLowPowerMode
+	}
+
+	return &commands.GetAllNodesInformationNtf{
+		NodeID:             n.config.Index,
+		Order:              n.config.Order,
+		Placement:          n.config.Placement,
+		Name:               n.config.Name,
+		Velocity:           n.config.Velocity,
+		NodeTypeSubType:    n.config.NodeType,
+		ProductGroup:       0,
+		ProductType:        0,
+		NodeVariation:      n.config.NodeVariation,
+		PowerMode:          powerMode,
+		BuildNumber:        0,
+		SerialNumber:       n.config.SerialNumber,
+		State:              n.state,
+		CurrentPosition:    n.position(commands.FunctionalParameterMP, n.current),
+		Target:             n.position(commands.FunctionalParameterMP, n.target),
+		FP1CurrentPosition: n.position(commands.FunctionalParameterFP1, n.current),
+		FP2CurrentPosition: n.position(commands.FunctionalParameterFP2, n.current),
+		FP3CurrentPosition: n.position(commands.FunctionalParameterFP3, n.current),
+		FP4CurrentPosition: n.position(commands.FunctionalParameterFP4, n.current),
+		RemainingTime:      n.remainingTime(commands.FunctionalParameterMP, time.Now()),
+		TimeStamp:          n.timestamp,
+		Aliases:            make(map[commands.NodeAliasId]int),
+	}
+}
+
+func (n *node) position(param commands.FunctionalParameter, values map[commands.FunctionalParameter]commands.MPValue) commands.NodePosition {
+	value, ok := values[param]
+	if !ok {
+		return commands.NodePositionUnknown
+	}
+
+	return commands.NodePosition(value)
+}
+
+func (n *node) status(statusType commands.StatusRequestStatusType, params map[commands.FunctionalParameter]bool) commands.StatusData {
+	switch statusType {
+	case commands.StatusRequestMainInfo:
+		return &commands.StatusDataMainInfo{
+			TargetPosition:             n.target[commands.FunctionalParameterMP],
+			CurrentPosition:            n.current[commands.FunctionalParameterMP],
+			RemainingTime:              0,
+			LastMasterExecutionAddress: 0,
+			LastCommandOriginator:      commands.CommandOriginatorUser,
+		}
+
+	default:
+		data := &commands.StatusDataParameters{
+			ParameterValues: make(map[commands.FunctionalParameter]int),
+		}
+
+		for param := commands.FunctionalParameterMP; param <= reportedFunctionalParameters; param++ {
+			if param != commands.FunctionalParameterMP && !params[param] {
+				continue
+			}
+
+			switch statusType {
+			case commands.StatusRequestTargetPosition:
+				data.ParameterValues[param] = int(n.position(param, n.target))
+			case commands.StatusRequestCurrentPosition:
+				data.ParameterValues[param] = int(n.position(param, n.current))
+			case commands.StatusRequestRemainingTime:
+				data.ParameterValues[param] = int(n.remainingTime(param, time.Now()) / time.Second)
+			}
+		}
+
+		return data
+	}
+}
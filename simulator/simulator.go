@@ -0,0 +1,394 @@
+// In-process KLF 200 gateway simulator.
+//
+// The simulator speaks the real protocol (TLS, SLIP, frames) so that a Client can be
+// connected to it for tests or demos, without any hardware.
+package simulator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/internal/server"
+)
+
+// Factory password of the gateway
+const DefaultPassword = "velux123"
+
+// Default delay between a confirm and the notifications of the session
+const DefaultLatency = time.Millisecond * 100
+
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type Config struct {
+	// TCP address to listen on. Defaults to "127.0.0.1:0" (random port)
+	Address string
+
+	// Password expected in GW_PASSWORD_ENTER_REQ. Defaults to DefaultPassword
+	Password string
+
+	// Answer to GW_GET_VERSION_REQ. Defaults to a recent firmware if nil
+	Version *commands.GetVersionCfm
+
+	// Delay between a confirm and the notifications of the session, simulating io-homecontrol
+	// round trips. Defaults to DefaultLatency
+	Latency time.Duration
+
+	// Interval between two progress reports of a moving actuator. Defaults to DefaultReportInterval
+	ReportInterval time.Duration
+
+	Nodes  []NodeConfig
+	Groups []GroupConfig
+	Scenes []SceneConfig
+
+	// Optional
+	Log Logger
+}
+
+type NodeConfig struct {
+	// System table index, also used as node ID
+	Index int
+
+	Name     string
+	NodeType commands.NodeTypeSubType
+
+	// io-homecontrol address. Generated from the index if 0
+	Address uint
+
+	// Defaults to Velux
+	Manufacturer commands.IoManufacturer
+
+	SerialNumber  uint64
+	Order         int
+	Placement     int
+	Velocity      commands.Velocity
+	NodeVariation commands.NodeVariation
+	PowerSaveMode bool
+
+	// Defaults to 5ms
+	TurnaroundTime time.Duration
+
+	// Duration of a full travel (0 to 100%) at default velocity. Defaults to 1000 times TurnaroundTime
+	TravelTime time.Duration
+
+	// Initial position of the main parameter
+	Position commands.MPValue
+}
+
+type GroupConfig struct {
+	ID          int
+	Name        string
+	Type        commands.GroupType
+	NodeIndexes []int
+}
+
+type SceneConfig struct {
+	ID   int
+	Name string
+
+	// Main parameter target for each node index of the scene
+	Positions map[int]commands.MPValue
+}
+
+type Gateway struct {
+	listener *server.Listener
+	log      Logger
+	version  commands.GetVersionCfm
+	latency  time.Duration
+
+	reportInterval time.Duration
+
+	lock     sync.Mutex
+	password string
+	utcDelta time.Duration
+	timeZone string
+	network  commands.GetNetworkSetupCfm
+	nodes    map[int]*node
+	groups   []GroupConfig
+	scenes   []SceneConfig
+	clients  map[*client]struct{}
+	closed   bool
+
+	movements []*movement
+	outbox    []outgoing
+
+	closing    chan struct{}
+	closeOnce  sync.Once
+	workerSync sync.WaitGroup
+}
+
+// Start the simulator. It is listening when the function returns
+func Start(config Config) (*Gateway, error) {
+	address := config.Address
+	if address == "" {
+		address = "127.0.0.1:0"
+	}
+
+	password := config.Password
+	if password == "" {
+		password = DefaultPassword
+	}
+
+	gw := &Gateway{
+		log:      config.Log,
+		version:  defaultVersion(),
+		password: password,
+		network:  defaultNetworkSetup(),
+		nodes:    make(map[int]*node),
+		groups:   append([]GroupConfig(nil), config.Groups...),
+		scenes:   append([]SceneConfig(nil), config.Scenes...),
+		clients:  make(map[*client]struct{}),
+		closing:  make(chan struct{}),
+	}
+
+	gw.latency = config.Latency
+	if gw.latency == 0 {
+		gw.latency = DefaultLatency
+	}
+
+	gw.reportInterval = config.ReportInterval
+	if gw.reportInterval == 0 {
+		gw.reportInterval = DefaultReportInterval
+	}
+
+	if config.Version != nil {
+		gw.version = *config.Version
+	}
+
+	for _, nodeConfig := range config.Nodes {
+		if nodeConfig.Index < 0 || nodeConfig.Index >= 200 {
+			return nil, fmt.Errorf("bad node index %d", nodeConfig.Index)
+		}
+
+		if _, exists := gw.nodes[nodeConfig.Index]; exists {
+			return nil, fmt.Errorf("duplicate node index %d", nodeConfig.Index)
+		}
+
+		gw.nodes[nodeConfig.Index] = newNode(nodeConfig)
+	}
+
+	listener, err := server.Listen(address)
+	if err != nil {
+		return nil, err
+	}
+
+	gw.listener = listener
+
+	gw.workerSync.Add(2)
+	go gw.acceptWorker()
+	go gw.movementWorker()
+
+	return gw, nil
+}
+
+// Address the simulator is listening on, to be given to the client
+func (gw *Gateway) Address() string {
+	return gw.listener.Addr()
+}
+
+// Current gateway password
+func (gw *Gateway) Password() string {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+
+	return gw.password
+}
+
+// Stop listening and close all connections. Subsequent calls do nothing
+func (gw *Gateway) Close() {
+	gw.closeOnce.Do(func() {
+		gw.listener.Close()
+
+		// Connections accepted from now on are closed by the accept worker
+		gw.lock.Lock()
+		gw.closed = true
+		gw.lock.Unlock()
+
+		close(gw.closing)
+		gw.disconnectAll()
+		gw.workerSync.Wait()
+	})
+}
+
+// Close all client connections, as if the gateway was rebooted
+func (gw *Gateway) disconnectAll() {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+
+	for c := range gw.clients {
+		c.conn.Close()
+	}
+}
+
+// Current main parameter position of the node
+func (gw *Gateway) NodePosition(index int) (commands.MPValue, bool) {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+
+	n, ok := gw.nodes[index]
+	if !ok {
+		return 0, false
+	}
+
+	return n.current[commands.FunctionalParameterMP], true
+}
+
+// Make the next commands on the node fail with the given reply.
+// NoContact fails at once, other replies fail halfway of the travel
+func (gw *Gateway) SetFailure(index int, reply commands.CommandRunStatusReply) error {
+	return gw.updateNode(index, func(n *node) {
+		n.failure = reply
+	})
+}
+
+// Make the node operate normally again
+func (gw *Gateway) ClearFailure(index int) error {
+	return gw.SetFailure(index, commands.CommandRunStatusReplyUnknownStatusReply)
+}
+
+// Lock the node at the given priority level: commands of lower priority levels fail with PriorityLevelLocked.
+// A zero duration locks without time limit
+func (gw *Gateway) LockNode(index int, level commands.PriorityLevel, duration time.Duration) error {
+	return gw.updateNode(index, func(n *node) {
+		var expiry time.Time
+		if duration > 0 {
+			expiry = time.Now().Add(duration)
+		}
+
+		n.locks[level] = expiry
+	})
+}
+
+// Remove the lock of the node at the given priority level
+func (gw *Gateway) UnlockNode(index int, level commands.PriorityLevel) error {
+	return gw.updateNode(index, func(n *node) {
+		delete(n.locks, level)
+	})
+}
+
+// Limit the parameter of the node into [min, max]. Commands outside the range are clamped, and
+// complete with the limitation reply matching the originator
+func (gw *Gateway) SetLimitation(index int, param commands.FunctionalParameter, min commands.MPValue, max commands.MPValue, originator commands.CommandOriginator) error {
+	return gw.updateNode(index, func(n *node) {
+		n.limitations[param] = limitation{min: min, max: max, originator: originator}
+	})
+}
+
+// Remove the limitation of the parameter of the node
+func (gw *Gateway) ClearLimitation(index int, param commands.FunctionalParameter) error {
+	return gw.updateNode(index, func(n *node) {
+		delete(n.limitations, param)
+	})
+}
+
+func (gw *Gateway) updateNode(index int, update func(n *node)) error {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+
+	n, ok := gw.nodes[index]
+	if !ok {
+		return fmt.Errorf("unknown node %d", index)
+	}
+
+	update(n)
+	return nil
+}
+
+// Send a notification to all authenticated clients
+func (gw *Gateway) Broadcast(ntf commands.Notify) {
+	gw.lock.Lock()
+	clients := make([]*client, 0, len(gw.clients))
+	for c := range gw.clients {
+		clients = append(clients, c)
+	}
+	gw.lock.Unlock()
+
+	for _, c := range clients {
+		if c.authenticated.Load() {
+			c.send(ntf)
+		}
+	}
+}
+
+func (gw *Gateway) acceptWorker() {
+	defer gw.workerSync.Done()
+
+	for {
+		conn, err := gw.listener.Accept()
+		if err != nil {
+			if !server.IsClosed(err) {
+				gw.errorf("Accept error: %s", err)
+			}
+
+			return
+		}
+
+		c := newClient(gw, conn)
+
+		gw.lock.Lock()
+		if gw.closed {
+			gw.lock.Unlock()
+			conn.Close()
+			return
+		}
+
+		gw.clients[c] = struct{}{}
+		gw.lock.Unlock()
+
+		gw.debugf("Client connected from %s", conn.RemoteAddr())
+
+		gw.workerSync.Add(1)
+		go func() {
+			defer gw.workerSync.Done()
+
+			c.worker()
+
+			gw.lock.Lock()
+			delete(gw.clients, c)
+			gw.lock.Unlock()
+
+			gw.debugf("Client %s disconnected", conn.RemoteAddr())
+		}()
+	}
+}
+
+func (gw *Gateway) debugf(format string, args ...interface{}) {
+	if gw.log != nil {
+		gw.log.Debugf(format, args...)
+	}
+}
+
+func (gw *Gateway) errorf(format string, args ...interface{}) {
+	if gw.log != nil {
+		gw.log.Errorf(format, args...)
+	}
+}
+
+func defaultVersion() commands.GetVersionCfm {
+	return commands.GetVersionCfm{
+		SoftwareVersion: commands.SoftWareVersionData{
+			CommandVersionNumber: 0,
+			VersionWholeNumber:   2,
+			VersionSubNumber:     0,
+			BranchID:             0,
+			BuildNumber:          71,
+			MicroBuild:           0,
+		},
+		HardwareVersion: 6,
+		ProductGroup:    int(commands.ProductGroupGateway),
+		ProductType:     int(commands.ProductTypeKlf200),
+	}
+}
+
+func defaultNetworkSetup() commands.GetNetworkSetupCfm {
+	return commands.GetNetworkSetupCfm{
+		IpAddress: []byte{192, 168, 0, 10},
+		Mask:      []byte{255, 255, 255, 0},
+		DefGW:     []byte{192, 168, 0, 1},
+		DHCP:      true,
+	}
+}
@@ -0,0 +1,72 @@
+package simulator
+
+import (
+	"crypto/tls"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloseTwice(t *testing.T) {
+	gw, err := Start(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gw.Close()
+		}()
+	}
+
+	wg.Wait()
+	gw.Close()
+}
+
+func TestCloseDuringAccept(t *testing.T) {
+	gw, err := Start(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Connections racing with Close must be shut down whether they are accepted before or after it
+	conns := make(chan *tls.Conn, 20)
+	var wg sync.WaitGroup
+
+	for range cap(conns) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			conn, err := tls.Dial("tcp", gw.Address(), &tls.Config{InsecureSkipVerify: true})
+			if err == nil {
+				conns <- conn
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 5)
+	gw.Close()
+	wg.Wait()
+	close(conns)
+
+	for conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+		if _, err := conn.Read(make([]byte, 1)); err == nil {
+			t.Error("connection still open after Close")
+		} else if isTimeout(err) {
+			t.Error("connection not closed by Close")
+		}
+
+		conn.Close()
+	}
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(interface{ Timeout() bool })
+	return ok && netErr.Timeout()
+}
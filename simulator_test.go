@@ -0,0 +1,408 @@
+package klf200
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/faultinject"
+	"github.com/mylife-home/klf200-go/simulator"
+)
+
+const testTimeout = time.Second * 5
+
+func testNodes() []simulator.NodeConfig {
+	return []simulator.NodeConfig{
+		{Index: 0, Name: "Kitchen", NodeType: commands.NodeTypeWindowOpener, SerialNumber: 0x1122334455667788, TravelTime: time.Millisecond * 500},
+		{Index: 1, Name: "Bedroom", NodeType: commands.NodeTypeRollerShutter, TravelTime: time.Millisecond * 500, Position: commands.NewMPValueAbsolute(100)},
+		{Index: 2, Name: "Door", NodeType: commands.NodeTypeDoorLock},
+	}
+}
+
+func startSimulator(t *testing.T, config simulator.Config) *simulator.Gateway {
+	t.Helper()
+
+	gw, err := simulator.Start(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(gw.Close)
+
+	return gw
+}
+
+// Start a client of the simulator and wait for its connection
+func startClient(t *testing.T, gw *simulator.Gateway, options ...ClientOption) *Client {
+	t.Helper()
+
+	client := NewClient(gw.Address(), gw.Password(), options...)
+
+	opened := make(chan struct{}, 1)
+	client.RegisterStatusChange(func(status ConnectionStatus) {
+		if status == ConnectionOpen {
+			select {
+			case opened <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	client.Start()
+	t.Cleanup(client.Close)
+
+	select {
+	case <-opened:
+	case <-time.After(testTimeout):
+		t.Fatalf("connection not opened: %s", client.LastStatusEvent())
+	}
+
+	return client
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSimulatorVersion(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+	client := startClient(t, gw)
+	ctx := testContext(t)
+
+	version, err := client.Device().GetVersionContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if commands.ProductGroup(version.ProductGroup) != commands.ProductGroupGateway || commands.ProductType(version.ProductType) != commands.ProductTypeKlf200 {
+		t.Errorf("got product group %d, type %d", version.ProductGroup, version.ProductType)
+	}
+
+	if _, err := client.Device().GetProtocolVersionContext(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := client.Device().GetStateContext(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimulatorWrongPassword(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+
+	client := NewClient(gw.Address(), "wrong", WithReconnectPolicy(ReconnectPolicy{InitialDelay: time.Millisecond * 10, AuthFailureDelay: -1}))
+
+	events := make(chan StatusEvent, 10)
+	client.RegisterStatusEvents(func(event StatusEvent) {
+		events <- event
+	})
+
+	client.Start()
+	t.Cleanup(client.Close)
+
+	for {
+		select {
+		case event := <-events:
+			if event.Status == ConnectionOpen {
+				t.Fatal("connection opened with a wrong password")
+			}
+
+			if event.Err == nil {
+				continue
+			}
+
+			if !IsAuthFailure(event.Err) {
+				t.Fatalf("expected an authentication failure, got %s", event.Err)
+			}
+
+			if !event.NextRetry.IsZero() || !event.Stopped {
+				t.Errorf("expected no retry with a negative AuthFailureDelay, got %+v", event)
+			}
+
+			return
+
+		case <-time.After(testTimeout):
+			t.Fatal("no status event")
+		}
+	}
+}
+
+func TestSimulatorChangePassword(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+	client := startClient(t, gw)
+
+	if err := client.Device().ChangePasswordContext(testContext(t), "new-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := gw.Password(); got != "new-password" {
+		t.Errorf("gateway password is '%s'", got)
+	}
+
+	// A new client must use the new password
+	startClient(t, gw)
+}
+
+func TestSimulatorChangePasswordReconnect(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{})
+	inj := faultinject.NewInjector(1)
+
+	client := NewClient(gw.Address(), gw.Password(), WithReconnectDelay(time.Millisecond*10), WithConnWrapper(inj.Wrap))
+
+	events := make(chan StatusEvent, 10)
+	client.RegisterStatusEvents(func(event StatusEvent) {
+		events <- event
+	})
+
+	client.Start()
+	t.Cleanup(client.Close)
+
+	waitOpen := func() {
+		t.Helper()
+
+		for {
+			select {
+			case event := <-events:
+				if event.Err != nil {
+					t.Fatalf("connection failed: %s", event.Err)
+				}
+
+				if event.Status == ConnectionOpen {
+					return
+				}
+
+			case <-time.After(testTimeout):
+				t.Fatal("connection not opened")
+			}
+		}
+	}
+
+	waitOpen()
+
+	if err := client.Device().ChangePasswordContext(testContext(t), "new-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reconnection uses the new password
+	inj.CloseAll()
+
+	for event := range events {
+		if event.Status == ConnectionClosed {
+			break
+		}
+	}
+
+	waitOpen()
+}
+
+func TestSimulatorNodesInformation(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: testNodes()})
+	client := startClient(t, gw)
+
+	nodes, err := client.Info().GetAllNodesInformation(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != len(testNodes()) {
+		t.Fatalf("got %d nodes, expected %d", len(nodes), len(testNodes()))
+	}
+
+	for _, expected := range testNodes() {
+		var node *commands.GetAllNodesInformationNtf
+		for _, candidate := range nodes {
+			if candidate.NodeID == expected.Index {
+				node = candidate
+			}
+		}
+
+		if node == nil {
+			t.Errorf("node %d is missing", expected.Index)
+			continue
+		}
+
+		if node.Name != expected.Name || node.NodeTypeSubType != expected.NodeType || node.SerialNumber != expected.SerialNumber {
+			t.Errorf("node %d: got name '%s', type %s, serial %x", node.NodeID, node.Name, node.NodeTypeSubType, node.SerialNumber)
+		}
+	}
+}
+
+func TestSimulatorSystemTable(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: testNodes()})
+	client := startClient(t, gw)
+
+	objects, err := client.Config().GetSystemTable(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) != len(testNodes()) {
+		t.Fatalf("got %d objects, expected %d", len(objects), len(testNodes()))
+	}
+
+	for _, obj := range objects {
+		expected := testNodes()[obj.SystemTableIndex]
+
+		if obj.ActuatorType != expected.NodeType.ActuatorType() || obj.ActuatorSubType != expected.NodeType.SubType() {
+			t.Errorf("object %d: got type %s.%s, expected %s", obj.SystemTableIndex, obj.ActuatorType, obj.ActuatorSubType, expected.NodeType)
+		}
+	}
+}
+
+func TestSimulatorGroupsAndScenes(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{
+		Nodes:  testNodes(),
+		Groups: []simulator.GroupConfig{{ID: 0, Name: "Ground floor", Type: commands.GroupTypeRoom, NodeIndexes: []int{0, 1}}},
+		Scenes: []simulator.SceneConfig{{ID: 0, Name: "Night", Positions: map[int]commands.MPValue{1: commands.NewMPValueAbsolute(0)}}},
+	})
+
+	client := startClient(t, gw)
+	ctx := testContext(t)
+
+	groups, err := client.Info().GetAllGroupsInformation(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 1 || groups[0].Name != "Ground floor" || len(groups[0].NodeIndexes) != 2 {
+		t.Errorf("got groups %+v", groups)
+	}
+
+	userType := commands.GroupTypeUser
+	groups, err = client.Info().GetAllGroupsInformation(ctx, &userType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 0 {
+		t.Errorf("expected no user group, got %+v", groups)
+	}
+
+	scenes, err := client.Scenes().GetSceneList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scenes) != 1 || scenes[0].Name != "Night" {
+		t.Fatalf("got scenes %+v", scenes)
+	}
+
+	sess, err := client.Scenes().Activate(ctx, scenes[0].SceneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitSession(t, sess)
+
+	if position, _ := gw.NodePosition(1); position != commands.NewMPValueAbsolute(0) {
+		t.Errorf("node 1 is at %s after the scene", position)
+	}
+}
+
+func TestSimulatorStopScene(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{
+		Nodes:  testNodes(),
+		Scenes: []simulator.SceneConfig{{ID: 0, Name: "Night", Positions: map[int]commands.MPValue{1: commands.NewMPValueAbsolute(0)}}},
+	})
+
+	client := startClient(t, gw)
+	ctx := testContext(t)
+
+	if _, err := client.Scenes().Activate(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Scenes().StopContext(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if err := client.Scenes().StopContext(canceled, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, expected a cancellation", err)
+	}
+}
+
+func TestSimulatorChangePosition(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: testNodes(), Latency: time.Millisecond * 10, ReportInterval: time.Millisecond * 100})
+	client := startClient(t, gw)
+	ctx := testContext(t)
+
+	target := commands.NewMPValueAbsolute(50)
+
+	sess, err := client.Commands().ChangePosition(ctx, 0, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := waitSession(t, sess)
+
+	remaining := 0
+	var last *RunStatus
+	for _, event := range events {
+		switch event := event.(type) {
+		case *RunRemainingTime:
+			remaining++
+		case *RunStatus:
+			last = event
+		}
+	}
+
+	if remaining == 0 {
+		t.Error("no remaining time event")
+	}
+
+	if last == nil || last.RunStatus != commands.CommandRunStatusCompleted || last.ParameterValue != target {
+		t.Errorf("got last run status %+v", last)
+	}
+
+	if position, _ := gw.NodePosition(0); position != target {
+		t.Errorf("node is at %s, expected %s", position, target)
+	}
+
+	status, err := client.Commands().Status(ctx, []int{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) != 2 {
+		t.Fatalf("got %d status, expected 2", len(status))
+	}
+
+	for _, data := range status {
+		expected, _ := gw.NodePosition(data.NodeIndex)
+		if data.CurrentPosition != expected {
+			t.Errorf("node %d: got status position %s, expected %s", data.NodeIndex, data.CurrentPosition, expected)
+		}
+	}
+}
+
+// Collect the events of the session until it is finished
+func waitSession(t *testing.T, sess *Session) []Event {
+	t.Helper()
+
+	events := make([]Event, 0)
+
+	for {
+		select {
+		case event, ok := <-sess.Events():
+			if !ok {
+				return events
+			}
+
+			if runErr, ok := event.(*RunError); ok {
+				t.Fatalf("session error: %s", runErr.Err)
+			}
+
+			events = append(events, event)
+
+		case <-time.After(testTimeout):
+			t.Fatal("session not finished")
+		}
+	}
+}
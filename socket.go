@@ -17,26 +17,39 @@ type socket struct {
 	workersSync sync.WaitGroup
 }
 
-const writeChannelSize = 10
-const readChannelSize = 1000
+const defaultWriteChannelSize = 10
+const defaultReadChannelSize = 1000
 const errorsChannelSize = 10
 const readBufferSize = 1024
 
-func makeSocket(ctx context.Context, address string) (*socket, error) {
+// Wraps the plaintext stream of a connection (inside TLS)
+type ConnWrapper func(conn net.Conn) net.Conn
+
+// ctx bounds the dial and the TLS handshake, see clientOptions.establishContext
+func makeSocket(ctx context.Context, address string, options *clientOptions) (*socket, error) {
 	dialer := net.Dialer{}
 	netConn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
 		return nil, err
 	}
 
-	conf := &tls.Config{}
-	conf.InsecureSkipVerify = true
-	tlsConn := tls.Client(netConn, conf)
+	// Handshake now so that certificate errors are reported as dial errors
+	tlsConn := tls.Client(netConn, options.tlsConfigFor(address))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	var conn net.Conn = tlsConn
+
+	if wrapper := options.connWrapper; wrapper != nil {
+		conn = wrapper(conn)
+	}
 
 	sock := &socket{
-		conn:   tlsConn,
-		write:  make(chan []byte, writeChannelSize),
-		read:   make(chan []byte, readChannelSize),
+		conn:   conn,
+		write:  make(chan []byte, options.writeChannelSize),
+		read:   make(chan []byte, options.readChannelSize),
 		errors: make(chan error, errorsChannelSize),
 	}
 
@@ -0,0 +1,589 @@
+package klf200
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+const stateLoadTimeout = time.Minute
+
+// Last known state of a node
+type Node struct {
+	NodeID          int
+	Name            string
+	Order           int
+	Placement       int
+	NodeTypeSubType commands.NodeTypeSubType
+	NodeVariation   commands.NodeVariation
+	SerialNumber    uint64
+
+	// Entry of the system table, nil if the node is not found in it
+	Actuator *commands.SystemtableObject
+
+	State              commands.NodeState
+	CurrentPosition    commands.NodePosition
+	Target             commands.NodePosition
+	FP1CurrentPosition commands.NodePosition
+	FP2CurrentPosition commands.NodePosition
+	FP3CurrentPosition commands.NodePosition
+	FP4CurrentPosition commands.NodePosition
+	RemainingTime      time.Duration
+
+	// Originator and status of the last run reported on the node
+	LastOriginator commands.CommandRunOwner
+	LastRunStatus  commands.CommandRunStatus
+
+	// Time of the last update reported by the gateway
+	TimeStamp time.Time
+
+	// The node has not been confirmed by the gateway since the connection opened (restored from a snapshot, or disconnected)
+	Stale bool
+}
+
+func (node *Node) clone() *Node {
+	cloned := *node
+
+	if node.Actuator != nil {
+		actuator := *node.Actuator
+		cloned.Actuator = &actuator
+	}
+
+	return &cloned
+}
+
+// Change of a node. Previous is nil if the node is new, Current is nil if the node has been removed
+type NodeChange struct {
+	Previous *Node
+	Current  *Node
+
+	// Names of the fields which changed
+	Fields []string
+}
+
+type StateSubscription interface {
+	Stream() <-chan *NodeChange
+	Close()
+}
+
+// In-memory model of all the nodes, kept current from the notifications.
+// The system table and the nodes information are loaded each time the connection opens.
+type State struct {
+	client   *Client
+	notifier Notifier
+	loads    chan *stateLoad
+
+	// Latest connection status not yet processed by the worker
+	statuses chan ConnectionStatus
+
+	// Notifications have been dropped since the last reload
+	resyncs chan struct{}
+
+	lock          sync.Mutex
+	loaded        bool
+	loadedChanged chan struct{}
+	nodes         map[int]*Node
+	systemTable   []commands.SystemtableObject
+	subscriptions map[*stateSubscription]struct{}
+
+	ctx        context.Context
+	close      context.CancelFunc
+	workerSync sync.WaitGroup
+}
+
+type stateLoad struct {
+	// Canceled when the connection status changes, the load is then ignored
+	ctx context.Context
+
+	systemTable []commands.SystemtableObject
+	nodeIDs     map[int]struct{}
+}
+
+// Create the state of the client. Must be called before client.Start
+func NewState(client *Client) *State {
+	ctx, close := context.WithCancel(context.Background())
+
+	state := &State{
+		client:        client,
+		statuses:      make(chan ConnectionStatus, 1),
+		resyncs:       make(chan struct{}, 1),
+		loads:         make(chan *stateLoad),
+		loadedChanged: make(chan struct{}),
+		nodes:         make(map[int]*Node),
+		subscriptions: make(map[*stateSubscription]struct{}),
+		ctx:           ctx,
+		close:         close,
+	}
+
+	// A slow state must not delay the other subscribers nor the confirms: the lost notifications are recovered by a reload
+	state.notifier = subscribe(client, isStateNotify, []SubscribeOption{WithOverflowPolicy(OverflowDropOldest), withDropped(state.resync)})
+
+	client.RegisterStatusChange(state.statusChanged)
+
+	state.workerSync.Add(1)
+	go state.worker()
+
+	return state
+}
+
+// Called by the client. Must not block: a status not yet processed by the worker is replaced by the new one,
+// which is enough as the worker handles each status from scratch
+func (state *State) statusChanged(status ConnectionStatus) {
+	for {
+		select {
+		case state.statuses <- status:
+			return
+		default:
+		}
+
+		select {
+		case <-state.statuses:
+		default:
+		}
+	}
+}
+
+func isStateNotify(notif commands.Notify) bool {
+	switch notif.(type) {
+	case *commands.GetAllNodesInformationNtf,
+		*commands.NodeStatePositionChangedNtf,
+		*commands.NodeInformationChangedNtf,
+		*commands.CommandRunStatusNtf,
+		*commands.CommandRemainingTimeNtf:
+		return true
+	default:
+		return false
+	}
+}
+
+// Called by the client dispatch when a notification is dropped. Must not block: a pending resync covers the new losses
+func (state *State) resync() {
+	select {
+	case state.resyncs <- struct{}{}:
+	default:
+	}
+}
+
+func (state *State) Close() {
+	state.close()
+	state.workerSync.Wait()
+
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	for sub := range state.subscriptions {
+		delete(state.subscriptions, sub)
+		close(sub.stream)
+	}
+}
+
+// Indicates if the state has been loaded since the connection opened
+func (state *State) Loaded() bool {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	return state.loaded
+}
+
+// Wait until the state is loaded
+func (state *State) WaitLoaded(ctx context.Context) error {
+	for {
+		state.lock.Lock()
+		loaded := state.loaded
+		changed := state.loadedChanged
+		state.lock.Unlock()
+
+		if loaded {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Snapshot of all the nodes, ordered by id
+func (state *State) Nodes() []*Node {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	nodes := make([]*Node, 0, len(state.nodes))
+	for _, node := range state.nodes {
+		nodes = append(nodes, node.clone())
+	}
+
+	slices.SortFunc(nodes, func(a, b *Node) int { return a.NodeID - b.NodeID })
+	return nodes
+}
+
+// Snapshot of a node
+func (state *State) Node(nodeID int) (*Node, bool) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	node, ok := state.nodes[nodeID]
+	if !ok {
+		return nil, false
+	}
+
+	return node.clone(), true
+}
+
+// System table as last loaded
+func (state *State) SystemTable() []commands.SystemtableObject {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	return append([]commands.SystemtableObject(nil), state.systemTable...)
+}
+
+// Subscribe to the changes of the nodes
+func (state *State) Subscribe() StateSubscription {
+	sub := &stateSubscription{state: state, stream: make(chan *NodeChange, 1000)}
+
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	state.subscriptions[sub] = struct{}{}
+
+	return sub
+}
+
+type stateSubscription struct {
+	state  *State
+	stream chan *NodeChange
+}
+
+func (sub *stateSubscription) Stream() <-chan *NodeChange {
+	return sub.stream
+}
+
+func (sub *stateSubscription) Close() {
+	sub.state.lock.Lock()
+	defer sub.state.lock.Unlock()
+
+	if _, ok := sub.state.subscriptions[sub]; ok {
+		delete(sub.state.subscriptions, sub)
+		close(sub.stream)
+	}
+}
+
+func (state *State) worker() {
+	defer state.workerSync.Done()
+	defer state.notifier.Close()
+
+	cancelLoad := func() {}
+	defer func() { cancelLoad() }()
+
+	startLoad := func() {
+		cancelLoad()
+
+		ctx, cancel := context.WithCancel(state.ctx)
+		cancelLoad = cancel
+		go state.load(ctx)
+	}
+
+	for {
+		select {
+		case <-state.ctx.Done():
+			return
+
+		case status := <-state.statuses:
+			// The nodes are confirmed again by the load of the new connection, a load of the previous one must not complete
+			cancelLoad()
+			state.setLoaded(false)
+			state.markStale()
+
+			if status == ConnectionOpen {
+				startLoad()
+			}
+
+		case <-state.resyncs:
+			// The nodes information of the reload supersedes the lost notifications. A load in progress may have lost some of its own
+			if state.client.Status() == ConnectionOpen {
+				state.client.log.Warnf("State notifications dropped, reloading")
+				startLoad()
+			}
+
+		case load := <-state.loads:
+			if load.ctx.Err() == nil {
+				state.applyLoad(load)
+			}
+
+		case notif := <-state.notifier.Stream():
+			state.apply(notif)
+		}
+	}
+}
+
+// The nodes information notifications are applied by the worker like the other ones, in order
+func (state *State) load(ctx context.Context) {
+	requestCtx, cancel := context.WithTimeout(ctx, stateLoadTimeout)
+	defer cancel()
+
+	systemTable, err := state.client.Config().GetSystemTable(requestCtx)
+	if err != nil {
+		if ctx.Err() == nil {
+			state.client.log.WithError(err).Errorf("Could not load the system table")
+		}
+
+		return
+	}
+
+	// Set before loading the nodes, so that they are created with their actuator
+	state.lock.Lock()
+	if ctx.Err() == nil {
+		state.systemTable = systemTable
+	}
+	state.lock.Unlock()
+
+	nodes, err := state.client.Info().GetAllNodesInformation(requestCtx)
+	if err != nil {
+		if ctx.Err() == nil {
+			state.client.log.WithError(err).Errorf("Could not load the nodes information")
+		}
+
+		return
+	}
+
+	load := &stateLoad{
+		ctx:         ctx,
+		systemTable: systemTable,
+		nodeIDs:     make(map[int]struct{}),
+	}
+
+	for _, node := range nodes {
+		load.nodeIDs[node.NodeID] = struct{}{}
+	}
+
+	select {
+	case state.loads <- load:
+	case <-ctx.Done():
+	}
+}
+
+func (state *State) setLoaded(loaded bool) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	if state.loaded == loaded {
+		return
+	}
+
+	state.loaded = loaded
+	close(state.loadedChanged)
+	state.loadedChanged = make(chan struct{})
+}
+
+func (state *State) applyLoad(load *stateLoad) {
+	// The nodes information notifications have all been queued before the load ended
+	for pending := true; pending; {
+		select {
+		case notif := <-state.notifier.Stream():
+			state.apply(notif)
+		default:
+			pending = false
+		}
+	}
+
+	// Nodes removed from the gateway
+	for _, node := range state.Nodes() {
+		if _, ok := load.nodeIDs[node.NodeID]; !ok {
+			state.update(node.NodeID, func(node *Node) bool { return false })
+		}
+	}
+
+	// The system table may have changed since the nodes were created
+	for _, node := range state.Nodes() {
+		state.update(node.NodeID, func(node *Node) bool {
+			node.Actuator = findActuator(load.systemTable, node.NodeID)
+			return true
+		})
+	}
+
+	state.setLoaded(true)
+}
+
+func (state *State) apply(notif commands.Notify) {
+	switch notif := notif.(type) {
+	case *commands.GetAllNodesInformationNtf:
+		state.update(notif.NodeID, func(node *Node) bool {
+			node.Name = notif.Name
+			node.Order = notif.Order
+			node.Placement = notif.Placement
+			node.NodeTypeSubType = notif.NodeTypeSubType
+			node.NodeVariation = notif.NodeVariation
+			node.SerialNumber = notif.SerialNumber
+			node.State = notif.State
+			node.CurrentPosition = notif.CurrentPosition
+			node.Target = notif.Target
+			node.FP1CurrentPosition = notif.FP1CurrentPosition
+			node.FP2CurrentPosition = notif.FP2CurrentPosition
+			node.FP3CurrentPosition = notif.FP3CurrentPosition
+			node.FP4CurrentPosition = notif.FP4CurrentPosition
+			node.RemainingTime = notif.RemainingTime
+			node.TimeStamp = notif.TimeStamp
+			node.Stale = false
+			return true
+		})
+
+	case *commands.NodeStatePositionChangedNtf:
+		state.update(notif.NodeID, func(node *Node) bool {
+			node.State = notif.State
+			node.CurrentPosition = notif.CurrentPosition
+			node.Target = notif.Target
+			node.FP1CurrentPosition = notif.FP1CurrentPosition
+			node.FP2CurrentPosition = notif.FP2CurrentPosition
+			node.FP3CurrentPosition = notif.FP3CurrentPosition
+			node.FP4CurrentPosition = notif.FP4CurrentPosition
+			node.RemainingTime = notif.RemainingTime
+			node.TimeStamp = notif.TimeStamp
+			return true
+		})
+
+	case *commands.NodeInformationChangedNtf:
+		state.update(notif.NodeID, func(node *Node) bool {
+			node.Name = notif.Name
+			node.Order = notif.Order
+			node.Placement = notif.Placement
+			node.NodeVariation = notif.NodeVariation
+			return true
+		})
+
+	case *commands.CommandRunStatusNtf:
+		state.updateExisting(notif.NodeIndex, func(node *Node) {
+			node.LastOriginator = notif.StatusID
+			node.LastRunStatus = notif.RunStatus
+
+			if notif.NodeParameter == commands.FunctionalParameterMP {
+				node.CurrentPosition = commands.NodePosition(notif.ParameterValue)
+			}
+		})
+
+	case *commands.CommandRemainingTimeNtf:
+		state.updateExisting(notif.NodeIndex, func(node *Node) {
+			if notif.NodeParameter == commands.FunctionalParameterMP {
+				node.RemainingTime = notif.Duration
+			}
+		})
+	}
+}
+
+func (state *State) markStale() {
+	for _, node := range state.Nodes() {
+		state.update(node.NodeID, func(node *Node) bool {
+			node.Stale = true
+			return true
+		})
+	}
+}
+
+// Update only a known node, the run notifications do not carry enough information to create one
+func (state *State) updateExisting(nodeID int, change func(node *Node)) {
+	state.lock.Lock()
+	_, ok := state.nodes[nodeID]
+	state.lock.Unlock()
+
+	if ok {
+		state.update(nodeID, func(node *Node) bool {
+			change(node)
+			return true
+		})
+	}
+}
+
+// Apply the change to a copy of the node (created if needed), and publish it if anything changed.
+// The node is removed if change returns false.
+func (state *State) update(nodeID int, change func(node *Node) bool) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	previous := state.nodes[nodeID]
+
+	var current *Node
+	if previous != nil {
+		current = previous.clone()
+	} else {
+		current = &Node{
+			NodeID:             nodeID,
+			CurrentPosition:    commands.NodePositionUnknown,
+			Target:             commands.NodePositionUnknown,
+			FP1CurrentPosition: commands.NodePositionUnknown,
+			FP2CurrentPosition: commands.NodePositionUnknown,
+			FP3CurrentPosition: commands.NodePositionUnknown,
+			FP4CurrentPosition: commands.NodePositionUnknown,
+			Actuator:           findActuator(state.systemTable, nodeID),
+		}
+	}
+
+	if !change(current) {
+		current = nil
+	}
+
+	var fields []string
+
+	switch {
+	case previous == nil && current == nil:
+		return
+	case current == nil:
+		delete(state.nodes, nodeID)
+	default:
+		fields = changedFields(previous, current)
+		if previous != nil && len(fields) == 0 {
+			return
+		}
+
+		state.nodes[nodeID] = current
+	}
+
+	for sub := range state.subscriptions {
+		nodeChange := &NodeChange{Fields: fields}
+		if previous != nil {
+			nodeChange.Previous = previous.clone()
+		}
+		if current != nil {
+			nodeChange.Current = current.clone()
+		}
+
+		// Never block the state on a slow subscriber
+		offer(sub.stream, nodeChange, OverflowDropOldest, func(*NodeChange) {})
+	}
+}
+
+func findActuator(systemTable []commands.SystemtableObject, nodeID int) *commands.SystemtableObject {
+	for index := range systemTable {
+		if systemTable[index].SystemTableIndex == nodeID {
+			actuator := systemTable[index]
+			return &actuator
+		}
+	}
+
+	return nil
+}
+
+// Names of the fields which differ. All of them if previous is nil
+func changedFields(previous *Node, current *Node) []string {
+	fields := make([]string, 0)
+	currentValue := reflect.ValueOf(current).Elem()
+	typ := currentValue.Type()
+
+	for index := 0; index < typ.NumField(); index++ {
+		if previous != nil {
+			previousField := reflect.ValueOf(previous).Elem().Field(index).Interface()
+			if reflect.DeepEqual(previousField, currentValue.Field(index).Interface()) {
+				continue
+			}
+		}
+
+		fields = append(fields, typ.Field(index).Name)
+	}
+
+	return fields
+}
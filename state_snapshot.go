@@ -0,0 +1,260 @@
+package klf200
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mylife-home/klf200-go/binary"
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+// Snapshot format:
+//   - magic "KLF200ST", version (u8), save time (unix seconds, u64)
+//   - system table: number of chunks (u16), then for each chunk: length (u16), encoded as GW_CS_GET_SYSTEMTABLE_DATA_NTF.
+//     Version 1 has a single chunk, without the number of chunks
+//   - number of nodes (u16), then for each node: length (u16), encoded as GW_GET_ALL_NODES_INFORMATION_NTF,
+//     followed by the last originator (u8) and the last run status (u8)
+const snapshotMagic = "KLF200ST"
+const snapshotVersion = 2
+
+// Maximum number of objects of a GW_CS_GET_SYSTEMTABLE_DATA_NTF, as sent by the gateway
+const systemtableChunkSize = 22
+
+// The state already contains nodes, the snapshot would be older
+var ErrStateNotEmpty = errors.New("state not empty")
+
+// Write a snapshot of the state, to restore it on next start
+func (state *State) Save(w io.Writer) error {
+	state.lock.Lock()
+	systemTable := append([]commands.SystemtableObject(nil), state.systemTable...)
+	state.lock.Unlock()
+
+	nodes := state.Nodes()
+
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+
+	writer.Write([]byte(snapshotMagic))
+	writer.WriteU8(snapshotVersion)
+	writer.WriteU64(uint64(time.Now().Unix()))
+
+	chunks := (len(systemTable) + systemtableChunkSize - 1) / systemtableChunkSize
+	writer.WriteU16(uint16(chunks))
+
+	for remaining := systemTable; len(remaining) > 0; {
+		count := min(len(remaining), systemtableChunkSize)
+		table := &commands.CsGetSystemtableDataNtf{
+			NumberOfEntry:          count,
+			Objects:                remaining[:count],
+			RemainingNumberOfEntry: len(remaining) - count,
+		}
+
+		data, err := table.Write()
+		if err != nil {
+			return fmt.Errorf("system table: %w", err)
+		}
+
+		writer.WriteU16(uint16(len(data)))
+		writer.Write(data)
+
+		remaining = remaining[count:]
+	}
+
+	writer.WriteU16(uint16(len(nodes)))
+
+	for _, node := range nodes {
+		info := &commands.GetAllNodesInformationNtf{
+			NodeID:             node.NodeID,
+			Order:              node.Order,
+			Placement:          node.Placement,
+			Name:               node.Name,
+			NodeTypeSubType:    node.NodeTypeSubType,
+			NodeVariation:      node.NodeVariation,
+			SerialNumber:       node.SerialNumber,
+			State:              node.State,
+			CurrentPosition:    node.CurrentPosition,
+			Target:             node.Target,
+			FP1CurrentPosition: node.FP1CurrentPosition,
+			FP2CurrentPosition: node.FP2CurrentPosition,
+			FP3CurrentPosition: node.FP3CurrentPosition,
+			FP4CurrentPosition: node.FP4CurrentPosition,
+			RemainingTime:      node.RemainingTime,
+			TimeStamp:          node.TimeStamp,
+		}
+
+		data, err := info.Write()
+		if err != nil {
+			return fmt.Errorf("node %d: %w", node.NodeID, err)
+		}
+
+		writer.WriteU16(uint16(len(data)))
+		writer.Write(data)
+		writer.WriteU8(uint8(node.LastOriginator))
+		writer.WriteU8(uint8(node.LastRunStatus))
+	}
+
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+// Restore a snapshot written by Save. The restored nodes are marked stale until the state is loaded from the gateway.
+// Must be called before client.Start
+func (state *State) Restore(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	reader := binary.MakeBinaryReader(bytes.NewBuffer(data))
+
+	magic := make([]byte, len(snapshotMagic))
+	if err := reader.Read(magic); err != nil || string(magic) != snapshotMagic {
+		return errors.New("not a state snapshot")
+	}
+
+	version, err := reader.ReadU8()
+	if err != nil {
+		return fmt.Errorf("bad length")
+	}
+
+	if version != 1 && version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	if _, err := reader.ReadU64(); err != nil {
+		return fmt.Errorf("bad length")
+	}
+
+	var chunks uint16 = 1
+	if version > 1 {
+		if chunks, err = reader.ReadU16(); err != nil {
+			return fmt.Errorf("bad length")
+		}
+	}
+
+	var systemTable []commands.SystemtableObject
+
+	for index := 0; index < int(chunks); index++ {
+		table := &commands.CsGetSystemtableDataNtf{}
+		if err := readSnapshotRecord(reader, table); err != nil {
+			return fmt.Errorf("system table: %w", err)
+		}
+
+		systemTable = append(systemTable, table.Objects...)
+	}
+
+	count, err := reader.ReadU16()
+	if err != nil {
+		return fmt.Errorf("bad length")
+	}
+
+	nodes := make([]*Node, 0, count)
+
+	for index := 0; index < int(count); index++ {
+		info := &commands.GetAllNodesInformationNtf{}
+		if err := readSnapshotRecord(reader, info); err != nil {
+			return fmt.Errorf("node #%d: %w", index, err)
+		}
+
+		originator, _ := reader.ReadU8()
+		runStatus, err := reader.ReadU8()
+		if err != nil {
+			return fmt.Errorf("node #%d: bad length", index)
+		}
+
+		nodes = append(nodes, &Node{
+			NodeID:             info.NodeID,
+			Name:               info.Name,
+			Order:              info.Order,
+			Placement:          info.Placement,
+			NodeTypeSubType:    info.NodeTypeSubType,
+			NodeVariation:      info.NodeVariation,
+			SerialNumber:       info.SerialNumber,
+			Actuator:           findActuator(systemTable, info.NodeID),
+			State:              info.State,
+			CurrentPosition:    info.CurrentPosition,
+			Target:             info.Target,
+			FP1CurrentPosition: info.FP1CurrentPosition,
+			FP2CurrentPosition: info.FP2CurrentPosition,
+			FP3CurrentPosition: info.FP3CurrentPosition,
+			FP4CurrentPosition: info.FP4CurrentPosition,
+			RemainingTime:      info.RemainingTime,
+			LastOriginator:     commands.CommandRunOwner(originator),
+			LastRunStatus:      commands.CommandRunStatus(runStatus),
+			TimeStamp:          info.TimeStamp,
+			Stale:              true,
+		})
+	}
+
+	state.lock.Lock()
+	empty := len(state.nodes) == 0
+	if empty {
+		state.systemTable = systemTable
+	}
+	state.lock.Unlock()
+
+	if !empty {
+		return ErrStateNotEmpty
+	}
+
+	for _, restored := range nodes {
+		state.update(restored.NodeID, func(node *Node) bool {
+			*node = *restored
+			return true
+		})
+	}
+
+	return nil
+}
+
+func readSnapshotRecord(reader binary.BinaryReader, record commands.Notify) error {
+	length, err := reader.ReadU16()
+	if err != nil {
+		return fmt.Errorf("bad length")
+	}
+
+	data := make([]byte, length)
+	if err := reader.Read(data); err != nil {
+		return fmt.Errorf("bad length")
+	}
+
+	return record.Read(data)
+}
+
+// Save the snapshot to a file. The file is replaced atomically
+func (state *State) SaveFile(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	if err := state.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// Restore the snapshot from a file. Returns an error matching fs.ErrNotExist if there is no snapshot yet
+func (state *State) RestoreFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return state.Restore(file)
+}
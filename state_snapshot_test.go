@@ -0,0 +1,224 @@
+package klf200
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mylife-home/klf200-go/binary"
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/simulator"
+)
+
+// More nodes than a single GW_CS_GET_SYSTEMTABLE_DATA_NTF can hold
+func manyNodes(count int) []simulator.NodeConfig {
+	nodes := make([]simulator.NodeConfig, 0, count)
+
+	for index := range count {
+		nodes = append(nodes, simulator.NodeConfig{
+			Index:        index,
+			Name:         fmt.Sprintf("Node %d", index),
+			NodeType:     commands.NodeTypeRollerShutter,
+			SerialNumber: uint64(index + 1),
+			Position:     commands.NewMPValueAbsolute(index),
+		})
+	}
+
+	return nodes
+}
+
+// State of a client which is not started, to restore snapshots
+func newRestoreState(t *testing.T) *State {
+	client := NewClient("127.0.0.1:1", "")
+	state := NewState(client)
+
+	t.Cleanup(func() {
+		state.Close()
+		client.Close()
+	})
+
+	return state
+}
+
+// The restored nodes are the saved ones, marked stale
+func checkRestored(t *testing.T, saved *State, restored *State) {
+	t.Helper()
+
+	expected := saved.Nodes()
+	nodes := restored.Nodes()
+
+	if len(nodes) != len(expected) {
+		t.Fatalf("got %d nodes, expected %d", len(nodes), len(expected))
+	}
+
+	for index, node := range nodes {
+		if !node.Stale {
+			t.Errorf("node %d not stale", node.NodeID)
+		}
+
+		node.Stale = false
+
+		if !reflect.DeepEqual(node, expected[index]) {
+			t.Errorf("got node %+v, expected %+v", node, expected[index])
+		}
+	}
+
+	if !reflect.DeepEqual(restored.SystemTable(), saved.SystemTable()) {
+		t.Errorf("got system table %+v, expected %+v", restored.SystemTable(), saved.SystemTable())
+	}
+}
+
+func TestStateSnapshotRoundTrip(t *testing.T) {
+	for _, count := range []int{3, systemtableChunkSize, 30, 50} {
+		t.Run(fmt.Sprintf("%d nodes", count), func(t *testing.T) {
+			gw := startSimulator(t, simulator.Config{Nodes: manyNodes(count)})
+			_, state := startState(t, gw)
+
+			if len(state.SystemTable()) != count {
+				t.Fatalf("got a system table of %d objects", len(state.SystemTable()))
+			}
+
+			buff := &bytes.Buffer{}
+			if err := state.Save(buff); err != nil {
+				t.Fatal(err)
+			}
+
+			restored := newRestoreState(t)
+			if err := restored.Restore(bytes.NewReader(buff.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+
+			checkRestored(t, state, restored)
+
+			// Not over a known state
+			if err := state.Restore(bytes.NewReader(buff.Bytes())); !errors.Is(err, ErrStateNotEmpty) {
+				t.Errorf("restore over a loaded state: got %v", err)
+			}
+		})
+	}
+}
+
+func TestStateSnapshotFile(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: manyNodes(30)})
+	_, state := startState(t, gw)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.bin")
+
+	if err := newRestoreState(t).RestoreFile(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("no snapshot yet: got %v", err)
+	}
+
+	// Saved twice, the file is replaced
+	for range 2 {
+		if err := state.SaveFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("got %d files, expected no temporary file left", len(entries))
+	}
+
+	restored := newRestoreState(t)
+	if err := restored.RestoreFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRestored(t, state, restored)
+}
+
+func TestStateSnapshotCorrupt(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: manyNodes(25)})
+	_, state := startState(t, gw)
+
+	buff := &bytes.Buffer{}
+	if err := state.Save(buff); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buff.Bytes()
+
+	for length := range len(data) {
+		restored := newRestoreState(t)
+		if err := restored.Restore(bytes.NewReader(data[:length])); err == nil {
+			t.Fatalf("snapshot truncated to %d bytes accepted", length)
+		}
+
+		if nodes := restored.Nodes(); len(nodes) != 0 || len(restored.SystemTable()) != 0 {
+			t.Fatalf("snapshot truncated to %d bytes: got %d nodes", length, len(nodes))
+		}
+	}
+
+	corrupt := func(offset int, value byte) []byte {
+		corrupted := bytes.Clone(data)
+		corrupted[offset] = value
+		return corrupted
+	}
+
+	tests := map[string][]byte{
+		"magic":   corrupt(0, 'X'),
+		"version": corrupt(len(snapshotMagic), 99),
+		// Number of objects of the first system table chunk
+		"chunk": corrupt(len(snapshotMagic)+1+8+2+2, 30),
+	}
+
+	for name, corrupted := range tests {
+		if err := newRestoreState(t).Restore(bytes.NewReader(corrupted)); err == nil {
+			t.Errorf("%s: corrupt snapshot accepted", name)
+		}
+	}
+}
+
+func TestStateSnapshotVersion1(t *testing.T) {
+	// Single system table record, as written by the first version
+	table := &commands.CsGetSystemtableDataNtf{
+		NumberOfEntry: 1,
+		Objects:       []commands.SystemtableObject{{SystemTableIndex: 0, ActuatorAddress: 0x123456, ActuatorType: commands.RollerShutter, IoManufacturer: commands.Velux}},
+	}
+
+	info := &commands.GetAllNodesInformationNtf{NodeID: 0, Name: "Kitchen", NodeTypeSubType: commands.NodeTypeRollerShutter, CurrentPosition: commands.NodePositionMax}
+
+	buff := &bytes.Buffer{}
+	writer := binary.MakeBinaryWriter(buff)
+	writer.Write([]byte(snapshotMagic))
+	writer.WriteU8(1)
+	writer.WriteU64(0)
+
+	for _, record := range []commands.Notify{table, info} {
+		data, err := record.Write()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if record == info {
+			writer.WriteU16(1)
+		}
+
+		writer.WriteU16(uint16(len(data)))
+		writer.Write(data)
+	}
+
+	writer.WriteU8(uint8(commands.CommandOriginatorUser))
+	writer.WriteU8(uint8(commands.CommandRunStatusCompleted))
+
+	state := newRestoreState(t)
+	if err := state.Restore(buff); err != nil {
+		t.Fatal(err)
+	}
+
+	node, ok := state.Node(0)
+	if !ok || node.Name != "Kitchen" || node.CurrentPosition != commands.NodePositionMax || node.Actuator == nil || node.Actuator.ActuatorAddress != 0x123456 {
+		t.Errorf("got node %+v", node)
+	}
+}
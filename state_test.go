@@ -0,0 +1,289 @@
+package klf200
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/simulator"
+)
+
+// Start a simulator and a client with its state, and wait for the state to be loaded
+func startState(t *testing.T, gw *simulator.Gateway) (*Client, *State) {
+	t.Helper()
+
+	client := NewClient(gw.Address(), gw.Password())
+	state := NewState(client)
+
+	client.Start()
+
+	t.Cleanup(func() {
+		state.Close()
+		client.Close()
+	})
+
+	if err := state.WaitLoaded(testContext(t)); err != nil {
+		t.Fatalf("state not loaded: %s", err)
+	}
+
+	return client, state
+}
+
+// Wait for a change of the node matching the condition
+func waitChange(t *testing.T, sub StateSubscription, condition func(change *NodeChange) bool) *NodeChange {
+	t.Helper()
+
+	timeout := time.After(testTimeout)
+
+	for {
+		select {
+		case change := <-sub.Stream():
+			if condition(change) {
+				return change
+			}
+
+		case <-timeout:
+			t.Fatal("change not received")
+		}
+	}
+}
+
+func TestStateLoad(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: testNodes()})
+	_, state := startState(t, gw)
+
+	nodes := state.Nodes()
+	if len(nodes) != len(testNodes()) {
+		t.Fatalf("got %d nodes, expected %d", len(nodes), len(testNodes()))
+	}
+
+	for index, expected := range testNodes() {
+		node := nodes[index]
+
+		if node.NodeID != expected.Index || node.Name != expected.Name || node.NodeTypeSubType != expected.NodeType || node.SerialNumber != expected.SerialNumber {
+			t.Errorf("got node %+v, expected %+v", node, expected)
+		}
+
+		if node.Actuator == nil || node.Actuator.SystemTableIndex != expected.Index {
+			t.Errorf("node %d: got actuator %+v", node.NodeID, node.Actuator)
+		}
+
+		if node.Stale {
+			t.Errorf("node %d is stale", node.NodeID)
+		}
+	}
+
+	if len(state.SystemTable()) != len(testNodes()) {
+		t.Errorf("got system table %+v", state.SystemTable())
+	}
+
+	if _, ok := state.Node(42); ok {
+		t.Error("unknown node found")
+	}
+
+	// Snapshots are copies
+	node, _ := state.Node(0)
+	node.Name = "Changed"
+	node.Actuator.SystemTableIndex = 42
+
+	if node, _ := state.Node(0); node.Name != testNodes()[0].Name || node.Actuator.SystemTableIndex != 0 {
+		t.Errorf("snapshot modification changed the state: %+v", node)
+	}
+}
+
+func TestStateFollowsMovements(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: testNodes(), Latency: time.Millisecond * 10, ReportInterval: time.Millisecond * 50})
+	client, state := startState(t, gw)
+
+	sub := state.Subscribe()
+	defer sub.Close()
+
+	target := commands.NewMPValueAbsolute(60)
+
+	sess, err := client.Commands().ChangePosition(testContext(t), 0, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitSession(t, sess)
+
+	change := waitChange(t, sub, func(change *NodeChange) bool {
+		return change.Current.NodeID == 0 && change.Current.CurrentPosition == commands.NodePosition(target)
+	})
+
+	if change.Previous == nil || !slices.Contains(change.Fields, "CurrentPosition") {
+		t.Errorf("got change %+v", change)
+	}
+
+	node, _ := state.Node(0)
+	if node.CurrentPosition != commands.NodePosition(target) || node.LastRunStatus != commands.CommandRunStatusCompleted {
+		t.Errorf("got node %+v", node)
+	}
+}
+
+func TestStateStaleOnDisconnect(t *testing.T) {
+	gw, err := simulator.Start(simulator.Config{Nodes: testNodes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, state := startState(t, gw)
+
+	sub := state.Subscribe()
+	defer sub.Close()
+
+	gw.Close()
+
+	waitChange(t, sub, func(change *NodeChange) bool {
+		return change.Current.NodeID == 0 && change.Current.Stale
+	})
+
+	if state.Loaded() {
+		t.Error("state still loaded after the disconnection")
+	}
+
+	for _, node := range state.Nodes() {
+		if !node.Stale {
+			t.Errorf("node %d is not stale", node.NodeID)
+		}
+	}
+}
+
+func TestStateStalledResync(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: testNodes()})
+
+	observer := &droppedObserver{dropped: make(map[OverflowPolicy]int)}
+	client := NewClient(gw.Address(), gw.Password(), WithObserver(observer))
+	state := NewState(client)
+
+	client.Start()
+
+	t.Cleanup(func() {
+		state.Close()
+		client.Close()
+	})
+
+	if err := state.WaitLoaded(testContext(t)); err != nil {
+		t.Fatalf("state not loaded: %s", err)
+	}
+
+	sub := state.Subscribe()
+	defer sub.Close()
+
+	actual := commands.NodePosition(commands.NewMPValueAbsolute(100))
+	lost := commands.NodePosition(commands.NewMPValueAbsolute(30))
+
+	// Stall the worker until its queue overflows. Released before the state is closed
+	var release sync.Once
+	state.lock.Lock()
+	t.Cleanup(func() { release.Do(state.lock.Unlock) })
+
+	for range defaultQueueSize + 100 {
+		gw.Broadcast(&commands.NodeStatePositionChangedNtf{NodeID: 1, State: commands.NodeStateDone, CurrentPosition: lost, Target: lost})
+	}
+
+	timeout := time.After(testTimeout)
+	for observer.count(OverflowDropOldest) == 0 {
+		select {
+		case <-timeout:
+			t.Fatal("no notification dropped")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	// The stalled state does not delay the confirms
+	if _, err := client.Device().GetStateContext(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	release.Do(state.lock.Unlock)
+
+	// The queued notifications are applied, then the reload restores the gateway position
+	waitChange(t, sub, func(change *NodeChange) bool {
+		return change.Current.NodeID == 1 && change.Current.CurrentPosition == lost
+	})
+
+	waitChange(t, sub, func(change *NodeChange) bool {
+		return change.Current.NodeID == 1 && change.Current.CurrentPosition == actual
+	})
+}
+
+func TestStateStatusChangedDoesNotBlock(t *testing.T) {
+	state := &State{statuses: make(chan ConnectionStatus, 1)}
+
+	// No worker: only the latest status is kept
+	for range 100 {
+		state.statusChanged(ConnectionOpen)
+		state.statusChanged(ConnectionClosed)
+	}
+
+	state.statusChanged(ConnectionHandshaking)
+
+	if status := <-state.statuses; status != ConnectionHandshaking || len(state.statuses) != 0 {
+		t.Errorf("got status %s, %d left", status, len(state.statuses))
+	}
+}
+
+func TestStateIgnoresCanceledLoad(t *testing.T) {
+	state := NewState(NewClient("127.0.0.1:1", ""))
+	defer state.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The worker receives the second load once it is done with the first one
+	state.loads <- &stateLoad{ctx: canceled}
+	state.loads <- &stateLoad{ctx: canceled}
+
+	if state.Loaded() {
+		t.Fatal("state loaded by a canceled load")
+	}
+
+	state.loads <- &stateLoad{ctx: context.Background()}
+
+	if err := state.WaitLoaded(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	previous := &Node{
+		NodeID:          1,
+		Name:            "Kitchen",
+		CurrentPosition: commands.NodePositionUnknown,
+		Actuator:        &commands.SystemtableObject{SystemTableIndex: 1},
+	}
+
+	if fields := changedFields(previous, previous.clone()); len(fields) != 0 {
+		t.Errorf("got %v for an identical node", fields)
+	}
+
+	current := previous.clone()
+	current.CurrentPosition = commands.NodePositionMax
+	current.RemainingTime = time.Second
+
+	if fields := changedFields(previous, current); !slices.Equal(fields, []string{"CurrentPosition", "RemainingTime"}) {
+		t.Errorf("got %v", fields)
+	}
+
+	// Compared by value
+	current = previous.clone()
+	current.Actuator.PowerSaveMode = true
+
+	if fields := changedFields(previous, current); !slices.Equal(fields, []string{"Actuator"}) {
+		t.Errorf("got %v", fields)
+	}
+
+	current.Actuator = nil
+	if fields := changedFields(previous, current); !slices.Equal(fields, []string{"Actuator"}) {
+		t.Errorf("got %v", fields)
+	}
+
+	// All the fields of a new node
+	if fields := changedFields(nil, current); len(fields) != 20 || fields[0] != "NodeID" || fields[len(fields)-1] != "Stale" {
+		t.Errorf("got %v", fields)
+	}
+}
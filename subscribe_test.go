@@ -0,0 +1,97 @@
+package klf200
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+func TestSubscribeTypeFilter(t *testing.T) {
+	client, _ := newOverflowClient()
+	defer client.Close()
+
+	sessions, cancelSessions := Subscribe[*commands.SessionFinishedNtf](client, nil)
+	defer cancelSessions()
+
+	even, cancelEven := Subscribe(client, func(notif *commands.SessionFinishedNtf) bool { return notif.SessionID%2 == 0 })
+	defer cancelEven()
+
+	all, cancelAll := Subscribe[commands.Notify](client, nil)
+	defer cancelAll()
+
+	client.dispatchNotify(&commands.NodeStatePositionChangedNtf{NodeID: 1})
+	dispatchSessions(client, 1, 2)
+	client.dispatchNotify(&commands.CommandRunStatusNtf{SessionID: 3})
+	dispatchSessions(client, 4)
+
+	if ids := readSessions(t, sessions, 3); !reflect.DeepEqual(ids, []int{1, 2, 4}) {
+		t.Errorf("got sessions %v", ids)
+	}
+
+	if ids := readSessions(t, even, 2); !reflect.DeepEqual(ids, []int{2, 4}) {
+		t.Errorf("got filtered sessions %v", ids)
+	}
+
+	var types []reflect.Type
+	for range 5 {
+		select {
+		case notif := <-all:
+			types = append(types, reflect.TypeOf(notif))
+		case <-time.After(testTimeout):
+			t.Fatalf("got %v, expected 5 notifications", types)
+		}
+	}
+
+	expected := []reflect.Type{
+		reflect.TypeOf(&commands.NodeStatePositionChangedNtf{}),
+		reflect.TypeOf(&commands.SessionFinishedNtf{}),
+		reflect.TypeOf(&commands.SessionFinishedNtf{}),
+		reflect.TypeOf(&commands.CommandRunStatusNtf{}),
+		reflect.TypeOf(&commands.SessionFinishedNtf{}),
+	}
+
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("got types %v", types)
+	}
+
+	// Nothing else was queued
+	for name, length := range map[string]int{"sessions": len(sessions), "even": len(even), "all": len(all)} {
+		if length != 0 {
+			t.Errorf("%s: %d notifications left", name, length)
+		}
+	}
+}
+
+func TestSubscribeClose(t *testing.T) {
+	client, _ := newOverflowClient()
+	defer client.Close()
+
+	stream, cancel := Subscribe[*commands.SessionFinishedNtf](client, nil)
+	dispatchSessions(client, 1)
+
+	cancel()
+
+	// Queued notifications are still delivered, then the channel is closed
+	if ids := readSessions(t, stream, 1); !reflect.DeepEqual(ids, []int{1}) {
+		t.Errorf("got %v", ids)
+	}
+
+	select {
+	case notif, ok := <-stream:
+		if ok {
+			t.Errorf("got %+v after close", notif)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("stream not closed")
+	}
+
+	if lengths := client.NotifierQueueLengths(); len(lengths) != 0 {
+		t.Errorf("got %d notifiers registered after close", len(lengths))
+	}
+
+	// Dispatching and closing again are harmless
+	dispatchSessions(client, 2)
+	cancel()
+}
@@ -0,0 +1,134 @@
+package klf200
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/mylife-home/klf200-go/commands"
+)
+
+// A request followed by notifications. Waiting for the notifications fails with ErrDisconnected
+// if the connection on which the request has been confirmed is torn down.
+type transaction[T commands.Notify] struct {
+	notifier *notifier[T]
+	client   *Client
+	done     <-chan struct{}
+
+	// Called before each retry of the request
+	retrying func()
+}
+
+// Subscribe before the request is sent, so that no notification can be missed
+func newTransaction[T commands.Notify](client *Client, filter func(T) bool) *transaction[T] {
+	trans := &transaction[T]{
+		notifier: subscribe(client, filter, []SubscribeOption{WithOverflowPolicy(OverflowDisconnect)}),
+		client:   client,
+	}
+
+	trans.retrying = trans.drain
+
+	return trans
+}
+
+// Discard the notifications of the previous attempts: the gateway may have processed a request which failed (eg: its confirm timed out)
+func (trans *transaction[T]) drain() {
+	for {
+		select {
+		case _, ok := <-trans.notifier.stream:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (trans *transaction[T]) execute(ctx context.Context, req commands.Request) (commands.Confirm, error) {
+	cfm, done, err := trans.client.executeConn(ctx, req, trans.retrying)
+	if err != nil {
+		return nil, err
+	}
+
+	trans.done = done
+
+	return cfm, nil
+}
+
+// Wait for the next notification. The notifications received before the disconnection are still delivered
+func (trans *transaction[T]) next(ctx context.Context) (T, error) {
+	var zero T
+
+	select {
+	case notif, ok := <-trans.notifier.stream:
+		return trans.received(notif, ok)
+	default:
+	}
+
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+
+	case notif, ok := <-trans.notifier.stream:
+		return trans.received(notif, ok)
+
+	case <-trans.done:
+		// The connection loop delivers all its notifications before being torn down
+		select {
+		case notif, ok := <-trans.notifier.stream:
+			return trans.received(notif, ok)
+		default:
+			return zero, ErrDisconnected
+		}
+	}
+}
+
+func (trans *transaction[T]) received(notif T, ok bool) (T, error) {
+	if !ok {
+		var zero T
+		return zero, ErrOverflow
+	}
+
+	return notif, nil
+}
+
+func (trans *transaction[T]) close() {
+	trans.notifier.Close()
+}
+
+// Transaction of a request starting a session. Each attempt of the request has its own session id,
+// so that the notifications of an attempt processed despite its failure are not mixed with the next one
+type sessionTransaction struct {
+	*transaction[commands.Notify]
+	current atomic.Int64
+}
+
+// setSessionId is called with the session id of each attempt, to update the request
+func newSessionTransaction(client *Client, setSessionId func(sessionId int)) *sessionTransaction {
+	trans := &sessionTransaction{}
+	trans.transaction = newTransaction(client, func(notif commands.Notify) bool {
+		session, ok := notif.(commands.SessionCommand)
+		return ok && session.GetSessionID() == trans.sessionId()
+	})
+
+	renew := func() {
+		sessionId := client.commands.newSessionId()
+		trans.current.Store(int64(sessionId))
+		setSessionId(sessionId)
+	}
+
+	renew()
+
+	// The late notifications of the previous attempt are filtered out by the new session id
+	trans.retrying = func() {
+		renew()
+		trans.drain()
+	}
+
+	return trans
+}
+
+// Session id of the current attempt
+func (trans *sessionTransaction) sessionId() int {
+	return int(trans.current.Load())
+}
@@ -0,0 +1,129 @@
+package klf200
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mylife-home/klf200-go/commands"
+	"github.com/mylife-home/klf200-go/faultinject"
+	"github.com/mylife-home/klf200-go/simulator"
+	"github.com/mylife-home/klf200-go/transport"
+)
+
+// Client whose connection is closed by the gateway instead of sending the given notification
+func startDisconnectingClient(t *testing.T, config simulator.Config, notify transport.Command) *Client {
+	t.Helper()
+
+	gw := startSimulator(t, config)
+
+	inj := faultinject.NewInjector(1)
+	inj.AddRule(faultinject.Rule{
+		Direction:   faultinject.DirectionFromGateway,
+		Commands:    []transport.Command{notify},
+		Fault:       faultinject.FaultClose,
+		Probability: faultinject.Always,
+		Count:       1,
+	})
+
+	return startClient(t, gw, WithConnWrapper(inj.Wrap))
+}
+
+func TestTransactionDisconnectedSystemTable(t *testing.T) {
+	client := startDisconnectingClient(t, simulator.Config{Nodes: manyNodes(30)}, transport.GW_CS_GET_SYSTEMTABLE_DATA_NTF)
+
+	if _, err := client.Config().GetSystemTable(testContext(t)); !errors.Is(err, ErrDisconnected) {
+		t.Errorf("got %v, expected ErrDisconnected", err)
+	}
+}
+
+func TestTransactionDisconnectedNodesInformation(t *testing.T) {
+	// The nodes are received, not the end of the list
+	client := startDisconnectingClient(t, simulator.Config{Nodes: testNodes()}, transport.GW_GET_ALL_NODES_INFORMATION_FINISHED_NTF)
+
+	if _, err := client.Info().GetAllNodesInformation(testContext(t)); !errors.Is(err, ErrDisconnected) {
+		t.Errorf("got %v, expected ErrDisconnected", err)
+	}
+}
+
+func TestTransactionDisconnectedStatus(t *testing.T) {
+	client := startDisconnectingClient(t, simulator.Config{Nodes: testNodes()}, transport.GW_SESSION_FINISHED_NTF)
+
+	if _, err := client.Commands().Status(testContext(t), []int{0, 1}); !errors.Is(err, ErrDisconnected) {
+		t.Errorf("got %v, expected ErrDisconnected", err)
+	}
+}
+
+func TestTransactionDisconnectedSession(t *testing.T) {
+	client := startDisconnectingClient(t, simulator.Config{Nodes: testNodes()}, transport.GW_SESSION_FINISHED_NTF)
+
+	sess, err := client.Commands().ChangePosition(testContext(t), 0, commands.NewMPValueAbsolute(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last Event
+	for closed := false; !closed; {
+		select {
+		case event, ok := <-sess.Events():
+			if ok {
+				last = event
+			}
+
+			closed = !ok
+
+		case <-time.After(testTimeout):
+			t.Fatal("session not ended")
+		}
+	}
+
+	if runErr, ok := last.(*RunError); !ok || !errors.Is(runErr.Err, ErrDisconnected) {
+		t.Errorf("got last event %+v, expected ErrDisconnected", last)
+	}
+}
+
+// The confirm of the first attempt is lost, the gateway processes both attempts
+func TestTransactionRetryNewSession(t *testing.T) {
+	gw := startSimulator(t, simulator.Config{Nodes: testNodes()})
+
+	inj := faultinject.NewInjector(1)
+	for _, cfm := range []transport.Command{transport.GW_STATUS_REQUEST_CFM, transport.GW_GET_ALL_NODES_INFORMATION_CFM} {
+		inj.AddRule(faultinject.Rule{
+			Direction:   faultinject.DirectionFromGateway,
+			Commands:    []transport.Command{cfm},
+			Fault:       faultinject.FaultDrop,
+			Probability: faultinject.Always,
+			Count:       1,
+		})
+	}
+
+	policy := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+	client := startClient(t, gw, WithConnWrapper(inj.Wrap), WithRequestTimeout(time.Millisecond*100), WithRetryPolicy(policy))
+	ctx := testContext(t)
+
+	finished, cancel := Subscribe[*commands.SessionFinishedNtf](client, nil)
+	defer cancel()
+
+	data, err := client.Commands().Status(ctx, []int{0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 3 {
+		t.Errorf("got %d status, expected one per node", len(data))
+	}
+
+	if ids := readSessions(t, finished, 2); ids[0] == ids[1] {
+		t.Errorf("both attempts used session %d", ids[0])
+	}
+
+	// The notifications of the first attempt are not counted twice
+	nodes, err := client.Info().GetAllNodesInformation(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != len(testNodes()) {
+		t.Errorf("got %d nodes", len(nodes))
+	}
+}